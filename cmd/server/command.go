@@ -0,0 +1,40 @@
+// Package main 命令行子命令
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// runCommand 执行命令行子命令，返回进程退出码
+// 子命令只初始化所需的依赖（配置、存储等），不启动 HTTP 服务。
+func runCommand(name string, args []string) int {
+	switch name {
+	case "export":
+		return runExportCommand(args)
+	case "rekey":
+		return runRekeyCommand(args)
+	case "reconcile":
+		return runReconcileCommand(args)
+	case "help":
+		printCommandUsage(os.Stdout)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "未知的子命令: %s\n\n", name)
+		printCommandUsage(os.Stderr)
+		return 2
+	}
+}
+
+// printCommandUsage 输出子命令列表
+func printCommandUsage(w io.Writer) {
+	fmt.Fprintln(w, "用法: server [子命令] [参数]")
+	fmt.Fprintln(w, "不带子命令时启动服务。")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "子命令:")
+	fmt.Fprintln(w, "  export   将仓库、标签或 digest 导出为 OCI image layout 归档")
+	fmt.Fprintln(w, "  rekey    将数据库中的凭据重新加密为当前主密钥")
+	fmt.Fprintln(w, "  reconcile 按存储重建仓库与 tag 索引（tags/list、_catalog）")
+	fmt.Fprintln(w, "  help     显示本帮助")
+}
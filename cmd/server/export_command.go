@@ -0,0 +1,100 @@
+// Package main 镜像导出子命令
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/cyp-registry/registry/src/modules/registry"
+	"github.com/cyp-registry/registry/src/modules/storage/factory"
+	"github.com/cyp-registry/registry/src/pkg/config"
+)
+
+// runExportCommand 将所选镜像导出为 OCI image layout 格式的 tar
+// 直接读取配置的存储，不经过 HTTP 服务，也不需要连接数据库。
+//
+//	server export -o images.tar project/nginx:1.25 project/redis project/app@sha256:...
+func runExportCommand(args []string) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	output := fs.String("o", "-", "输出文件，- 表示标准输出")
+	noReferrers := fs.Bool("no-referrers", false, "不导出签名、SBOM 等 referrer")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: server export [-o file] [-no-referrers] <project>/<image>[:tag|@digest] ...")
+		fmt.Fprintln(fs.Output(), "未指定标签或 digest 时导出仓库的全部标签。")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	targets := make([]registry.ExportTarget, 0, fs.NArg())
+	for _, arg := range fs.Args() {
+		target, err := registry.ParseExportTarget(arg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "镜像引用不合法: %s\n", arg)
+			return 2
+		}
+		targets = append(targets, target)
+	}
+
+	cfg, err := config.Load("config.yaml")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "加载配置失败: %v\n", err)
+		return 1
+	}
+	store, err := factory.NewStorage(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "初始化存储失败: %v\n", err)
+		return 1
+	}
+	reg := registry.NewRegistry(store)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	export, err := reg.PrepareExport(ctx, targets, registry.ExportOptions{SkipReferrers: *noReferrers})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "准备导出失败: %v\n", err)
+		return 1
+	}
+
+	if err := writeExport(ctx, export, *output); err != nil {
+		fmt.Fprintf(os.Stderr, "导出失败: %v\n", err)
+		return 1
+	}
+
+	summary := export.Summary()
+	fmt.Fprintf(os.Stderr, "导出完成: %d 个镜像, %d 个 referrer, %d 个 Manifest, %d 个 Blob (%d 字节)\n",
+		summary.Images, summary.Referrers, summary.Manifests, summary.Blobs, summary.Bytes)
+	return 0
+}
+
+// writeExport 写出归档；写入文件时先写临时文件，成功后再重命名，避免留下不完整的归档
+func writeExport(ctx context.Context, export *registry.Export, output string) error {
+	if output == "-" {
+		return export.WriteTo(ctx, os.Stdout)
+	}
+
+	tmp := output + ".partial"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	err = export.WriteTo(ctx, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, output)
+}
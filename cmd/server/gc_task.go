@@ -0,0 +1,48 @@
+// Package main 定时垃圾回收任务
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/cyp-registry/registry/src/modules/registry"
+)
+
+// startGCTask 启动定时垃圾回收任务
+// interval <= 0 时不启用（仍可通过 POST /api/v1/admin/gc 手动触发）
+func startGCTask(reg *registry.Registry, interval time.Duration, opts registry.GCOptions) {
+	if interval <= 0 {
+		return
+	}
+	if opts.GracePeriod <= 0 {
+		opts.GracePeriod = registry.DefaultGCGracePeriod
+	}
+
+	log.Printf("定时垃圾回收任务已启动: 间隔=%v, 宽限期=%v, 删除无tag Manifest=%v", interval, opts.GracePeriod, opts.DeleteUntagged)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		performGC(reg, opts)
+	}
+}
+
+// performGC 执行一次垃圾回收
+func performGC(reg *registry.Registry, opts registry.GCOptions) {
+	ctx, cancel := context.WithTimeout(context.Background(), 6*time.Hour)
+	defer cancel()
+
+	report, err := reg.RunGC(ctx, opts)
+	if err != nil {
+		if err == registry.ErrGCAlreadyRunning {
+			log.Printf("跳过定时垃圾回收: 已有回收任务在执行")
+			return
+		}
+		log.Printf("错误: 垃圾回收失败: %v", err)
+		return
+	}
+	log.Printf("垃圾回收完成: 删除 Manifest %d 个, 链接 %d 个, Blob %d 个, 释放 %d 字节",
+		len(report.DeletedManifests), len(report.DeletedLinks), len(report.DeletedBlobs), report.FreedBytes)
+}
@@ -0,0 +1,41 @@
+// Package main 导入归档清理任务
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	imageimport_service "github.com/cyp-registry/registry/src/modules/imageimport/service"
+)
+
+// importArchiveJanitorInterval 暂存归档清理间隔
+const importArchiveJanitorInterval = time.Hour
+
+// startImportArchiveJanitorTask 启动过期导入归档清理定时任务
+// 清理失败或取消后超过保留时间仍未重试的任务的暂存归档，以及没有任务引用的遗留归档
+func startImportArchiveJanitorTask(svc *imageimport_service.Service) {
+	log.Printf("导入归档清理任务已启动: 归档保留时间=%v, 清理间隔=%v", svc.ArchiveTTL(), importArchiveJanitorInterval)
+
+	ticker := time.NewTicker(importArchiveJanitorInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		performImportArchivePurge(svc)
+	}
+}
+
+// performImportArchivePurge 执行一次导入归档清理
+func performImportArchivePurge(svc *imageimport_service.Service) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	purged, err := svc.PurgeExpiredArchives(ctx)
+	if err != nil {
+		log.Printf("错误: 清理过期导入归档失败: %v", err)
+		return
+	}
+	if purged > 0 {
+		log.Printf("导入归档清理完成: 删除了 %d 个过期归档", purged)
+	}
+}
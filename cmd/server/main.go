@@ -31,6 +31,16 @@ import (
 	"github.com/cyp-registry/registry/src/modules/rbac"
 	"github.com/cyp-registry/registry/src/modules/registry"
 	registry_controller "github.com/cyp-registry/registry/src/modules/registry/controller"
+	registry_index "github.com/cyp-registry/registry/src/modules/registry/index"
+	replication_module "github.com/cyp-registry/registry/src/modules/replication"
+	replication_controller "github.com/cyp-registry/registry/src/modules/replication/controller"
+	replication_service "github.com/cyp-registry/registry/src/modules/replication/service"
+	sbom_controller "github.com/cyp-registry/registry/src/modules/sbom/controller"
+	sbom_service "github.com/cyp-registry/registry/src/modules/sbom/service"
+	"github.com/cyp-registry/registry/src/modules/scanner"
+	scanner_controller "github.com/cyp-registry/registry/src/modules/scanner/controller"
+	scanner_factory "github.com/cyp-registry/registry/src/modules/scanner/factory"
+	scanner_service "github.com/cyp-registry/registry/src/modules/scanner/service"
 	"github.com/cyp-registry/registry/src/modules/storage/factory"
 	"github.com/cyp-registry/registry/src/modules/user/controller"
 	"github.com/cyp-registry/registry/src/modules/user/service"
@@ -40,6 +50,8 @@ import (
 	"github.com/cyp-registry/registry/src/pkg/cache"
 	"github.com/cyp-registry/registry/src/pkg/config"
 	"github.com/cyp-registry/registry/src/pkg/database"
+	"github.com/cyp-registry/registry/src/pkg/imageroute"
+	"github.com/cyp-registry/registry/src/pkg/secrets"
 	appversion "github.com/cyp-registry/registry/src/pkg/version"
 )
 
@@ -56,12 +68,29 @@ func main() {
 	//    这样在本地直接运行二进制/`go run` 时，也能复用全局配置中心 .env。
 	loadDotEnvDefaults(".env")
 
+	// 2.1 子命令（例如 export）：执行完成后直接退出，不启动服务
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		os.Exit(runCommand(os.Args[1], os.Args[2:]))
+	}
+
 	// 3. 加载配置
 	cfg, err := config.Load("config.yaml")
 	if err != nil {
 		log.Fatalf("加载配置失败: %v", err)
 	}
 
+	// 3.1 加载凭据加密主密钥（数据库中的仓库密码、Webhook 密钥等加密保存）
+	keyring, err := secrets.LoadKeyring(cfg.Secrets)
+	if err != nil {
+		log.Fatalf("加载凭据加密主密钥失败: %v", err)
+	}
+	if keyring == nil {
+		log.Printf(`{"timestamp":"%s","level":"warn","module":"secrets","operation":"init","message":"未配置 SECRETS_MASTER_KEY / SECRETS_KEY_FILE，凭据将以明文保存"}`, time.Now().Format(time.RFC3339))
+	} else {
+		secrets.SetKeyring(keyring)
+		log.Printf(`{"timestamp":"%s","level":"info","module":"secrets","operation":"init","primary_key_id":"%s"}`, time.Now().Format(time.RFC3339), keyring.PrimaryKeyID())
+	}
+
 	// 4. 初始化数据库（带重试，避免 DB 启动慢导致服务直接退出）
 	// 默认重试 60 次 * 1s；可通过环境变量覆盖：
 	// - DB_INIT_RETRIES（次数）
@@ -115,6 +144,10 @@ func main() {
 	// 5.2 初始化项目/Registry/Webhook服务
 	projectSvc := project_service.NewService(database.GetDB(), store, cfg)
 	regSvc := registry.NewRegistry(store)
+	regSvc.SetUploadSessionTTL(time.Duration(cfg.Registry.UploadSessionTTL) * time.Second)
+	regSvc.SetBlobRedirectTTL(time.Duration(cfg.Registry.BlobRedirectTTL) * time.Second)
+	regSvc.SetPolicyProvider(projectSvc)
+	regSvc.SetProxyProvider(projectSvc)
 	whSvc := webhook_service.NewWebhookService(&webhook_service.ServiceConfig{
 		WorkerCount: 5,
 		// 发送超时时间适当放宽，避免外部系统轻微抖动导致大量失败
@@ -131,6 +164,33 @@ func main() {
 		log.Printf("警告: 初始化镜像导入数据库表失败: %v", err)
 	}
 
+	// 5.5 补充项目表新增列（tag 规则、代理配置、漏洞拉取策略）
+	if err := project_service.InitDatabase(database.GetDB()); err != nil {
+		log.Printf("警告: 初始化项目数据库表失败: %v", err)
+	}
+
+	// 5.6 初始化数据库表（镜像复制）
+	if err := replication_module.InitDatabase(); err != nil {
+		log.Printf("警告: 初始化镜像复制数据库表失败: %v", err)
+	}
+
+	// 5.7 初始化数据库表（漏洞扫描报告）
+	if err := scanner_service.InitDatabase(database.GetDB()); err != nil {
+		log.Printf("警告: 初始化漏洞扫描数据库表失败: %v", err)
+	}
+
+	// 5.8 初始化仓库、tag 与 Blob 链接索引（tags/list、_catalog、用量与项目配额查询数据库）
+	tagIndex := registry_index.NewStore(database.GetDB())
+	if err := registry_index.InitDatabase(database.GetDB()); err != nil {
+		log.Printf("警告: 初始化仓库索引数据库表失败: %v，tags/list、_catalog 与用量统计将扫描存储，不检查项目配额", err)
+		tagIndex = nil
+	} else {
+		regSvc.SetTagIndex(tagIndex)
+		regSvc.SetLinkIndex(tagIndex)
+	}
+	// 推送与垃圾回收之间的锁放在数据库中，多副本之间互斥
+	regSvc.SetGCLocker(registry_index.NewLocker(database.GetDB()))
+
 	// 6. 初始化RBAC
 	rbacSvc := rbac.NewService()
 	if err := rbacSvc.InitDefaultRoles(context.TODO()); err != nil {
@@ -168,21 +228,57 @@ func main() {
 
 	// 9. 创建控制器
 	userCtrl := controller.NewUserController(userSvc)
-	projectCtrl := project_controller.NewProjectController(projectSvc, userSvc)
+	projectCtrl := project_controller.NewProjectController(projectSvc, userSvc, regSvc)
 	regCtrl := registry_controller.NewRegistryController(regSvc, rbacSvc, authMw, projectSvc, userSvc, whSvc)
+	regCtrl.SetMirrorProject(cfg.Registry.MirrorProject)
 	whCtrl := webhook_controller.NewWebhookController(whSvc, authMw)
 	adminSvc := admin_service.NewService()
 	adminCtrl := admin_controller.NewAdminController(adminSvc)
 
-	// 创建镜像导入服务（使用配置中的Host和Port构建本地仓库地址）
-	localRegistryHost := cfg.App.Host
-	if localRegistryHost == "0.0.0.0" {
-		localRegistryHost = "localhost"
+	// 创建镜像导入服务（直接写入本地存储）
+	imageImportSvc := imageimport_service.NewService(regSvc)
+	imageImportSvc.SetConcurrency(cfg.ImageImport.Workers, cfg.ImageImport.PerProjectLimit)
+	imageImportSvc.SetNotifier(whSvc)
+	imageImportSvc.SetArchiveDir(cfg.ImageImport.ArchiveDir)
+	imageImportSvc.SetArchiveTTL(time.Duration(cfg.ImageImport.ArchiveTTL) * time.Second)
+	if n, err := imageImportSvc.Recover(context.Background()); err != nil {
+		log.Printf("警告: 恢复未完成的镜像导入任务失败: %v", err)
+	} else if n > 0 {
+		log.Printf("已重新排队 %d 个未完成的镜像导入任务", n)
 	}
-	localRegistryHost = fmt.Sprintf("%s:%d", localRegistryHost, cfg.App.Port)
-	imageImportSvc := imageimport_service.NewService(localRegistryHost)
 	imageImportCtrl := imageimport_controller.NewImageImportController(imageImportSvc, projectSvc)
 
+	// 创建镜像复制服务（推送事件与 cron 规则的调度在服务启动后开始）
+	replicationSvc := replication_service.NewService(regSvc, projectSvc)
+	replicationSvc.SetNotifier(whSvc)
+	replicationCtrl := replication_controller.NewReplicationController(replicationSvc, authMw)
+
+	// 创建漏洞扫描服务（扫描器不可用时只提供已有报告的查询）
+	var imageScanner scanner.Scanner
+	if cfg.Scanner.Enabled {
+		sc, err := scanner_factory.NewScanner(&cfg.Scanner)
+		if err != nil {
+			log.Printf("警告: 创建漏洞扫描器失败，已禁用扫描: %v", err)
+		} else {
+			imageScanner = sc
+		}
+	}
+	scanSvc := scanner_service.NewService(regSvc, imageScanner, &cfg.Scanner)
+	scanSvc.SetNotifier(whSvc)
+	scanSvc.SetPolicyProvider(projectSvc)
+	regCtrl.SetPullGuard(scanSvc)
+	replicationSvc.SetPullGuard(scanSvc)
+	scannerCtrl := scanner_controller.NewScannerController(scanSvc, projectSvc, cfg.Scanner.Async)
+
+	// 创建 SBOM 服务（推送后自动生成需要 sbom.enabled，手动生成与上传始终可用）
+	sbomSvc := sbom_service.NewService(regSvc, &cfg.SBOM)
+	sbomCtrl := sbom_controller.NewSBOMController(sbomSvc, projectSvc)
+
+	// 镜像子资源路由：/api/v1/projects/:id/images/<name...>/<action>
+	imageRoutes := imageroute.New()
+	sbomCtrl.RegisterRoutes(imageRoutes)
+	regCtrl.RegisterImageRoutes(imageRoutes)
+
 	// 10. 配置路由
 	// 健康检查 - 必须在最前面
 	healthHandler := func(c *gin.Context) {
@@ -498,8 +594,23 @@ func main() {
 
 			// 镜像导入路由
 			projects.POST("/:id/images/import", imageImportCtrl.ImportImage)
+			projects.POST("/:id/images/import/archive", imageImportCtrl.ImportArchive)
 			projects.GET("/:id/images/import", imageImportCtrl.ListTasks)
 			projects.GET("/:id/images/import/:task_id", imageImportCtrl.GetTask)
+			projects.POST("/:id/images/import/:task_id/cancel", imageImportCtrl.CancelTask)
+			projects.POST("/:id/images/import/:task_id/retry", imageImportCtrl.RetryTask)
+			projects.GET("/:id/images/subscriptions", imageImportCtrl.ListSubscriptions)
+			projects.POST("/:id/images/subscriptions/:sub_id/pause", imageImportCtrl.PauseSubscription)
+			projects.POST("/:id/images/subscriptions/:sub_id/resume", imageImportCtrl.ResumeSubscription)
+			projects.DELETE("/:id/images/subscriptions/:sub_id", imageImportCtrl.DeleteSubscription)
+
+			// 漏洞扫描路由
+			projects.POST("/:id/scans", scannerCtrl.Scan)
+			projects.GET("/:id/scans", scannerCtrl.ListTagReports)
+			projects.GET("/:id/scans/report", scannerCtrl.GetReport)
+
+			// 镜像子资源路由（SBOM、镜像配置等），须在上面的 /:id/images/... 静态路由之后注册
+			imageRoutes.Register(projects, "/:id/images")
 
 			// 团队/成员功能已下线，这些路由保留占位但不再提供实际能力
 			projects.POST("/:id/members", func(c *gin.Context) {
@@ -522,6 +633,13 @@ func main() {
 			})
 		}
 
+		// 镜像导出（OCI image layout 归档，按仓库校验拉取权限）
+		images := v1.Group("/images")
+		images.Use(authMw.Auth())
+		{
+			images.GET("/export", regCtrl.ExportImages)
+		}
+
 		// 管理员路由（需要管理员权限）
 		admin := v1.Group("/admin")
 		admin.Use(authMw.Auth())
@@ -530,12 +648,17 @@ func main() {
 			admin.GET("/logs", adminCtrl.ListAuditLogs)
 			admin.GET("/config", adminCtrl.GetSystemConfig)
 			admin.PUT("/config", adminCtrl.UpdateSystemConfig)
+			admin.POST("/gc", regCtrl.TriggerGC)
+			admin.GET("/gc", regCtrl.GetGCStatus)
 		}
 	}
 
 	// Webhook API（controller 内部已使用 /api/v1/webhooks）
 	whCtrl.RegisterRoutes(r)
 
+	// 镜像复制 API（controller 内部已使用 /api/v1/replication）
+	replicationCtrl.RegisterRoutes(r)
+
 	// Registry V2 API（实现 Docker Registry HTTP API V2）
 	regCtrl.RegisterRoutes(r, userSvc)
 
@@ -589,6 +712,35 @@ func main() {
 	// 启动日志清理定时任务
 	go startAuditLogCleanupTask()
 
+	// 启动过期上传会话清理任务
+	go startUploadJanitorTask(regSvc, time.Duration(cfg.Registry.UploadJanitorInterval)*time.Second)
+
+	// 启动过期导入归档清理任务
+	go startImportArchiveJanitorTask(imageImportSvc)
+
+	// 启动定时垃圾回收任务（未配置间隔时不启用）
+	go startGCTask(regSvc, time.Duration(cfg.Registry.GCInterval)*time.Second, registry.GCOptions{
+		GracePeriod:    time.Duration(cfg.Registry.GCGracePeriod) * time.Second,
+		DeleteUntagged: cfg.Registry.GCDeleteUntagged,
+	})
+
+	// 升级后首次启动时按存储重建仓库索引
+	if tagIndex != nil {
+		go bootstrapTagIndex(regSvc, tagIndex)
+	}
+
+	// 启动镜像复制调度（推送事件触发与 cron 定时触发）
+	go replicationSvc.Start(context.Background())
+
+	// 启动漏洞扫描（推送事件触发与未完成任务的恢复）
+	go scanSvc.Start(context.Background())
+
+	// 启动 SBOM 自动生成（推送事件触发）
+	go sbomSvc.Start(context.Background())
+
+	// 启动镜像同步订阅检查（上游 digest 变化时重新导入）
+	go imageImportSvc.StartSync(context.Background())
+
 	// 等待服务器开始启动
 	<-serverStarted
 	time.Sleep(300 * time.Millisecond) // 给服务器一点时间真正开始监听
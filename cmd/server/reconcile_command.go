@@ -0,0 +1,117 @@
+// Package main 仓库索引重建子命令
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cyp-registry/registry/src/modules/registry"
+	registry_index "github.com/cyp-registry/registry/src/modules/registry/index"
+	"github.com/cyp-registry/registry/src/modules/storage/factory"
+	"github.com/cyp-registry/registry/src/pkg/config"
+	"github.com/cyp-registry/registry/src/pkg/database"
+)
+
+// runReconcileCommand 按存储中的 tag 映射与链接记录重建数据库中的仓库与 tag 索引（tags/list、_catalog 的数据来源）
+// 以及 Blob 链接索引（用量统计与项目配额的数据来源）
+// 升级后首次启用索引、或索引更新失败导致与存储不一致时执行；服务运行期间执行是安全的。
+//
+//	server reconcile [-dry-run]
+func runReconcileCommand(args []string) int {
+	fs := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "只统计与存储不一致的数据，不写入")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: server reconcile [-dry-run]")
+		fmt.Fprintln(fs.Output(), "扫描存储中的全部仓库、tag 与 Blob 链接，重建数据库中的仓库、tag 与链接索引以及项目用量。")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	cfg, err := config.Load("config.yaml")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "加载配置失败: %v\n", err)
+		return 1
+	}
+	if err := database.Init(&cfg.Database); err != nil {
+		fmt.Fprintf(os.Stderr, "连接数据库失败: %v\n", err)
+		return 1
+	}
+	defer database.Close()
+	if err := registry_index.InitDatabase(database.GetDB()); err != nil {
+		fmt.Fprintf(os.Stderr, "初始化数据库表失败: %v\n", err)
+		return 1
+	}
+
+	store, err := factory.NewStorage(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "初始化存储失败: %v\n", err)
+		return 1
+	}
+	reg := registry.NewRegistry(store)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	report, err := registry_index.NewStore(database.GetDB()).Reconcile(ctx, reg, *dryRun)
+	if report != nil {
+		printReconcileReport(report, *dryRun)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "重建索引失败: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// printReconcileReport 输出重建结果
+func printReconcileReport(report *registry_index.ReconcileReport, dryRun bool) {
+	action := "已"
+	if dryRun {
+		action = "需要"
+	}
+	fmt.Fprintf(os.Stderr, "存储中 %d 个仓库, %d 个 tag\n", report.Repositories, report.Tags)
+	fmt.Fprintf(os.Stderr, "%s补充 %d, %s更新 %d, %s删除 %d 个 tag（其中 %d 个仓库已没有 tag）\n",
+		action, report.Added, action, report.Updated, action, report.Removed, report.RemovedRepositories)
+	fmt.Fprintf(os.Stderr, "存储中 %d 个 Blob 链接: %s补充 %d, %s更新 %d, %s删除 %d\n",
+		report.Links, action, report.LinksAdded, action, report.LinksUpdated, action, report.LinksRemoved)
+	if len(report.Skipped) > 0 {
+		fmt.Fprintf(os.Stderr, "所属项目不存在而跳过 %d 个仓库: %s\n", len(report.Skipped), strings.Join(report.Skipped, ", "))
+	}
+}
+
+// bootstrapTagIndex 索引为空时（升级后首次启动）按存储重建索引
+// 重建完成前 tags/list 与 _catalog 只包含启动后推送的 tag，用量只包含启动后写入的链接；多副本同时执行是安全的。
+func bootstrapTagIndex(reg *registry.Registry, index *registry_index.Store) {
+	ctx := context.Background()
+	empty, err := index.Empty(ctx)
+	if err != nil {
+		log.Printf("警告: 检查仓库索引失败: %v", err)
+		return
+	}
+	if !empty {
+		return
+	}
+
+	log.Printf("仓库索引为空，开始按存储重建")
+	start := time.Now()
+	report, err := index.Reconcile(ctx, reg, false)
+	if err != nil {
+		log.Printf("警告: 重建仓库索引失败: %v，可执行 server reconcile 重试", err)
+		return
+	}
+	log.Printf("仓库索引重建完成: 仓库=%d, tag=%d, 补充=%d, 跳过仓库=%d, 链接=%d, 补充链接=%d, 耗时=%v",
+		report.Repositories, report.Tags, report.Added, len(report.Skipped), report.Links, report.LinksAdded, time.Since(start).Round(time.Millisecond))
+}
@@ -0,0 +1,181 @@
+// Package main 凭据重新加密子命令
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"gorm.io/gorm"
+
+	imageimport_module "github.com/cyp-registry/registry/src/modules/imageimport"
+	project_service "github.com/cyp-registry/registry/src/modules/project/service"
+	replication_module "github.com/cyp-registry/registry/src/modules/replication"
+	webhook_module "github.com/cyp-registry/registry/src/modules/webhook"
+	"github.com/cyp-registry/registry/src/pkg/config"
+	"github.com/cyp-registry/registry/src/pkg/database"
+	"github.com/cyp-registry/registry/src/pkg/secrets"
+)
+
+// rekeyBatchSize 每批读取的行数
+const rekeyBatchSize = 500
+
+// secretColumn 加密保存的数据库列（新增加密字段时需要同步登记）
+type secretColumn struct {
+	table  string
+	key    string
+	column string
+	// jsonField 非空时该列为 JSON，只有其中的该字段加密
+	jsonField string
+}
+
+var secretColumns = []secretColumn{
+	{table: "image_import_tasks", key: "id", column: "auth_password"},
+	{table: "image_import_subscriptions", key: "id", column: "auth_password"},
+	{table: "replication_endpoints", key: "id", column: "password"},
+	{table: "webhooks", key: "webhook_id", column: "secret"},
+	{table: "webhooks", key: "webhook_id", column: "headers"},
+	{table: "webhook_deliveries", key: "delivery_id", column: "request_headers"},
+	{table: "registry_projects", key: "id", column: "proxy", jsonField: "password"},
+}
+
+// rekeyStats 单列的处理结果
+type rekeyStats struct {
+	checked, rekeyed, failed int
+}
+
+// runRekeyCommand 将数据库中的凭据重新加密为当前主密钥（主密钥列表中的第一个）
+// 明文保存的旧数据一并加密；已由当前主密钥加密的值保持不变（可重复执行）。
+//
+//	server rekey [-dry-run]
+func runRekeyCommand(args []string) int {
+	fs := flag.NewFlagSet("rekey", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "只统计需要重新加密的数据，不写入")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: server rekey [-dry-run]")
+		fmt.Fprintln(fs.Output(), "将数据库中的凭据重新加密为当前主密钥（SECRETS_MASTER_KEY / SECRETS_KEY_FILE 中的第一个）。")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	cfg, err := config.Load("config.yaml")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "加载配置失败: %v\n", err)
+		return 1
+	}
+	keyring, err := secrets.LoadKeyring(cfg.Secrets)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "加载主密钥失败: %v\n", err)
+		return 1
+	}
+	if keyring == nil {
+		fmt.Fprintln(os.Stderr, "未配置主密钥（SECRETS_MASTER_KEY 或 SECRETS_KEY_FILE）")
+		return 1
+	}
+	secrets.SetKeyring(keyring)
+
+	if err := database.Init(&cfg.Database); err != nil {
+		fmt.Fprintf(os.Stderr, "连接数据库失败: %v\n", err)
+		return 1
+	}
+	defer database.Close()
+
+	// 确保表结构为最新（加密后的值比原列宽更长）
+	for _, migrate := range []func() error{
+		webhook_module.InitWebhookDatabase,
+		imageimport_module.InitDatabase,
+		replication_module.InitDatabase,
+		func() error { return project_service.InitDatabase(database.GetDB()) },
+	} {
+		if err := migrate(); err != nil {
+			fmt.Fprintf(os.Stderr, "初始化数据库表失败: %v\n", err)
+			return 1
+		}
+	}
+
+	failed := false
+	for _, col := range secretColumns {
+		stats, err := rekeyColumn(database.GetDB(), keyring, col, *dryRun)
+		name := col.table + "." + col.column
+		if col.jsonField != "" {
+			name += "." + col.jsonField
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			failed = true
+			continue
+		}
+		action := "已重新加密"
+		if *dryRun {
+			action = "需要重新加密"
+		}
+		fmt.Fprintf(os.Stderr, "%s: 检查 %d, %s %d, 失败 %d\n", name, stats.checked, action, stats.rekeyed, stats.failed)
+		if stats.failed > 0 {
+			failed = true
+		}
+	}
+
+	if failed {
+		fmt.Fprintln(os.Stderr, "部分数据无法解密（缺少对应的主密钥或数据已损坏），请检查后重新执行")
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "完成，当前主密钥: %s\n", keyring.PrimaryKeyID())
+	return 0
+}
+
+// rekeyColumn 按主键顺序分批处理一列
+// 写入时以原值为条件，避免覆盖执行期间被服务修改的数据。
+func rekeyColumn(db *gorm.DB, keyring *secrets.Keyring, col secretColumn, dryRun bool) (rekeyStats, error) {
+	var stats rekeyStats
+
+	value := col.column
+	if col.jsonField != "" {
+		value = fmt.Sprintf("%s ->> '%s'", col.column, col.jsonField)
+	}
+	query := fmt.Sprintf(`SELECT %s AS id, %s AS value FROM %s WHERE %s > ? AND COALESCE(%s, '') <> '' ORDER BY %s LIMIT ?`,
+		col.key, value, col.table, col.key, value, col.key)
+	update := fmt.Sprintf(`UPDATE %s SET %s = ? WHERE %s = ? AND %s = ?`, col.table, col.column, col.key, value)
+	if col.jsonField != "" {
+		update = fmt.Sprintf(`UPDATE %s SET %s = jsonb_set(%s, '{%s}', to_jsonb(?::text)) WHERE %s = ? AND %s = ?`,
+			col.table, col.column, col.column, col.jsonField, col.key, value)
+	}
+
+	last := ""
+	for {
+		var rows []struct {
+			ID    string
+			Value string
+		}
+		if err := db.Raw(query, last, rekeyBatchSize).Scan(&rows).Error; err != nil {
+			return stats, err
+		}
+		for _, row := range rows {
+			stats.checked++
+			rekeyed, changed, err := keyring.Rekey(row.Value)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s %s=%s: %v\n", col.table, col.key, row.ID, err)
+				stats.failed++
+				continue
+			}
+			if !changed {
+				continue
+			}
+			if !dryRun {
+				if err := db.Exec(update, rekeyed, row.ID, row.Value).Error; err != nil {
+					return stats, err
+				}
+			}
+			stats.rekeyed++
+		}
+		if len(rows) < rekeyBatchSize {
+			return stats, nil
+		}
+		last = rows[len(rows)-1].ID
+	}
+}
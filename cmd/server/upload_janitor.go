@@ -0,0 +1,45 @@
+// Package main 上传会话清理任务
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/cyp-registry/registry/src/modules/registry"
+)
+
+// defaultUploadJanitorInterval 默认清理间隔
+const defaultUploadJanitorInterval = time.Hour
+
+// startUploadJanitorTask 启动过期上传会话清理定时任务
+// 清理客户端中断后遗弃的 <project>/uploads/* 数据（多副本同时执行是安全的，删除操作幂等）
+func startUploadJanitorTask(reg *registry.Registry, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultUploadJanitorInterval
+	}
+
+	log.Printf("上传会话清理任务已启动: 会话保留时间=%v, 清理间隔=%v", reg.UploadSessionTTL(), interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		performUploadPurge(reg)
+	}
+}
+
+// performUploadPurge 执行一次上传会话清理
+func performUploadPurge(reg *registry.Registry) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	purged, err := reg.PurgeExpiredUploads(ctx)
+	if err != nil {
+		log.Printf("错误: 清理过期上传会话失败: %v", err)
+		return
+	}
+	if purged > 0 {
+		log.Printf("上传会话清理完成: 删除了 %d 个过期会话", purged)
+	}
+}
@@ -74,6 +74,9 @@ CREATE TABLE IF NOT EXISTS registry_projects (
     storage_used        BIGINT DEFAULT 0,
     storage_quota       BIGINT DEFAULT 10737418240, -- 10GB
     image_count         INTEGER DEFAULT 0,
+    tag_rules           JSONB,                      -- 项目级 tag 规则（不可变/允许/保留模式），NULL 使用默认规则
+    proxy               JSONB,                      -- 代理（pull-through cache）项目的上游配置，NULL 为普通项目
+    vulnerability_policy JSONB,                     -- 项目漏洞拉取策略，NULL 使用全局默认策略
     created_at          TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at          TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     deleted_at          TIMESTAMP
@@ -205,6 +208,19 @@ CREATE TABLE IF NOT EXISTS registry_image_tags (
     UNIQUE(image_id, name)
 );
 
+-- 仓库级 Blob 链接索引表（用量统计与配额）
+CREATE TABLE IF NOT EXISTS registry_blob_links (
+    id                  UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    project             VARCHAR(128) NOT NULL,
+    repository          VARCHAR(512) NOT NULL,
+    digest              VARCHAR(256) NOT NULL,
+    size                BIGINT DEFAULT 0,
+    created_at          TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at          TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at          TIMESTAMP,
+    UNIQUE(repository, digest)
+);
+
 -- 漏洞扫描结果表
 CREATE TABLE IF NOT EXISTS registry_scan_results (
     id                  UUID PRIMARY KEY DEFAULT gen_random_uuid(),
@@ -329,6 +345,7 @@ CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user ON registry_refresh_tokens(us
 CREATE INDEX IF NOT EXISTS idx_images_project ON registry_images(project_id);
 CREATE INDEX IF NOT EXISTS idx_image_tags_image ON registry_image_tags(image_id);
 CREATE INDEX IF NOT EXISTS idx_image_tags_digest ON registry_image_tags(digest);
+CREATE INDEX IF NOT EXISTS idx_blob_links_project_digest ON registry_blob_links(project, digest);
 CREATE INDEX IF NOT EXISTS idx_scan_results_tag ON registry_scan_results(tag_id);
 CREATE INDEX IF NOT EXISTS idx_audit_logs_user ON registry_audit_logs(user_id);
 CREATE INDEX IF NOT EXISTS idx_audit_logs_created ON registry_audit_logs(created_at);
@@ -10,9 +10,11 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 
 	"github.com/cyp-registry/registry/src/modules/auth/jwt"
 	"github.com/cyp-registry/registry/src/modules/user/service"
+	"github.com/cyp-registry/registry/src/pkg/audit"
 	"github.com/cyp-registry/registry/src/pkg/models"
 	"github.com/cyp-registry/registry/src/pkg/response"
 )
@@ -99,6 +101,7 @@ func (m *AuthMiddleware) Auth() gin.HandlerFunc {
 		ctx.Set(ContextKeyUserID, claims.UserID)
 		ctx.Set(ContextKeyUsername, claims.Username)
 		ctx.Set(ContextKeyTokenType, claims.TokenType)
+		setAuditActor(ctx, &claims.UserID)
 
 		// 如果是PAT令牌，解析并存储scopes信息
 		if patModel != nil {
@@ -118,3 +121,13 @@ func (m *AuthMiddleware) Auth() gin.HandlerFunc {
 		ctx.Next()
 	}
 }
+
+// setAuditActor 将操作者（用户、客户端 IP 与 User-Agent）写入请求上下文，
+// 下层模块在请求中产生的审计日志（例如浮动 tag 更新）据此记录操作者
+func setAuditActor(ctx *gin.Context, userID *uuid.UUID) {
+	ctx.Request = ctx.Request.WithContext(audit.WithActor(ctx.Request.Context(), audit.Actor{
+		UserID:    userID,
+		IP:        ctx.ClientIP(),
+		UserAgent: ctx.Request.UserAgent(),
+	}))
+}
@@ -101,6 +101,7 @@ func (m *AuthMiddleware) OptionalAuth() gin.HandlerFunc {
 			ctx.Set(ContextKeyUserID, claims.UserID)
 			ctx.Set(ContextKeyUsername, claims.Username)
 			ctx.Set(ContextKeyTokenType, claims.TokenType)
+			setAuditActor(ctx, &claims.UserID)
 
 			// 如果是PAT令牌，解析并存储scopes信息
 			if patModel != nil {
@@ -5,6 +5,7 @@ package middleware
 import (
 	"fmt"
 	"log"
+	"net/http"
 	"runtime"
 	"strings"
 	"time"
@@ -26,6 +27,11 @@ func NewRecoveryMiddleware() *RecoveryMiddleware {
 // 增强版Recovery中间件，确保错误信息输出到日志
 func (r *RecoveryMiddleware) Recovery() gin.HandlerFunc {
 	return gin.CustomRecovery(func(c *gin.Context, recovered interface{}) {
+		// 处理器主动中断连接（如流式响应中途失败），交给 net/http 关闭连接
+		if recovered == http.ErrAbortHandler {
+			panic(recovered)
+		}
+
 		// 获取TraceID
 		traceID, _ := c.Get(ContextKeyTraceID)
 		traceIDStr := "unknown"
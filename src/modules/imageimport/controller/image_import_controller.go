@@ -2,6 +2,8 @@
 package controller
 
 import (
+	"errors"
+	"io"
 	"strconv"
 	"strings"
 
@@ -22,6 +24,9 @@ type ImageImportController struct {
 	projectSvc projectservice.Service
 }
 
+// maxArchiveFormValue 上传归档时文本字段（target_image、target_tag）的最大长度
+const maxArchiveFormValue = 1024
+
 // NewImageImportController 创建控制器
 func NewImageImportController(
 	svc *imageimportservice.Service,
@@ -109,7 +114,7 @@ func (c *ImageImportController) ImportImage(ctx *gin.Context) {
 		&req,
 	)
 	if err != nil {
-		response.InternalServerError(ctx, err.Error())
+		c.fail(ctx, err)
 		return
 	}
 
@@ -117,6 +122,73 @@ func (c *ImageImportController) ImportImage(ctx *gin.Context) {
 	response.Success(ctx, resp)
 }
 
+// ImportArchive 上传 docker save / OCI layout 归档（tar 或 tar.gz）创建导入任务
+// POST /api/v1/projects/:id/images/import/archive
+// multipart/form-data：file（必填）、target_image、target_tag（可选，需位于 file 之前）
+// 归档可能有数 GB，按 multipart 流式读取直接写入暂存目录，不先缓冲到临时文件。
+func (c *ImageImportController) ImportArchive(ctx *gin.Context) {
+	projectID := ctx.Param("id")
+	if projectID == "" {
+		response.ParamError(ctx, "项目ID不能为空")
+		return
+	}
+
+	var userID *uuid.UUID
+	if v, exists := ctx.Get(middleware.ContextKeyUserID); exists {
+		if id, ok := v.(uuid.UUID); ok && id != uuid.Nil {
+			userID = &id
+		}
+	}
+
+	project, err := c.projectSvc.GetProject(ctx.Request.Context(), projectID)
+	if err != nil {
+		response.NotFound(ctx, "项目不存在")
+		return
+	}
+
+	reader, err := ctx.Request.MultipartReader()
+	if err != nil {
+		response.ParamError(ctx, "请以 multipart/form-data 上传镜像归档文件（file）")
+		return
+	}
+
+	var req imageimportdto.ImportArchiveRequest
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			response.ParamError(ctx, "请上传镜像归档文件（file）")
+			return
+		}
+		if err != nil {
+			response.ParamError(ctx, "请求参数不合法")
+			return
+		}
+
+		switch part.FormName() {
+		case "target_image", "target_tag":
+			value, err := io.ReadAll(io.LimitReader(part, maxArchiveFormValue+1))
+			if err != nil || len(value) > maxArchiveFormValue {
+				response.ParamError(ctx, "请求参数不合法")
+				return
+			}
+			if part.FormName() == "target_image" {
+				req.TargetImage = string(value)
+			} else {
+				req.TargetTag = string(value)
+			}
+		case "file":
+			task, err := c.svc.ImportArchive(ctx.Request.Context(), project.ID, project.Name, userID, part.FileName(), part, &req)
+			if err != nil {
+				c.fail(ctx, err)
+				return
+			}
+			response.Success(ctx, imageimportdto.FromModel(task))
+			return
+		}
+		part.Close()
+	}
+}
+
 // GetTask 获取任务详情
 // GET /api/v1/projects/:id/images/import/:task_id
 func (c *ImageImportController) GetTask(ctx *gin.Context) {
@@ -137,6 +209,66 @@ func (c *ImageImportController) GetTask(ctx *gin.Context) {
 	response.Success(ctx, resp)
 }
 
+// CancelTask 取消等待中或执行中的任务
+// POST /api/v1/projects/:id/images/import/:task_id/cancel
+func (c *ImageImportController) CancelTask(ctx *gin.Context) {
+	projectID := ctx.Param("id")
+	taskID := ctx.Param("task_id")
+	if projectID == "" || taskID == "" {
+		response.ParamError(ctx, "项目ID或任务ID不能为空")
+		return
+	}
+
+	task, err := c.svc.CancelTask(ctx.Request.Context(), projectID, taskID)
+	if err != nil {
+		c.fail(ctx, err)
+		return
+	}
+
+	response.Success(ctx, imageimportdto.FromModel(task))
+}
+
+// RetryTask 重新执行失败或已取消的任务（沿用原有的源地址、凭据与平台选择）
+// POST /api/v1/projects/:id/images/import/:task_id/retry
+func (c *ImageImportController) RetryTask(ctx *gin.Context) {
+	projectID := ctx.Param("id")
+	taskID := ctx.Param("task_id")
+	if projectID == "" || taskID == "" {
+		response.ParamError(ctx, "项目ID或任务ID不能为空")
+		return
+	}
+
+	project, err := c.projectSvc.GetProject(ctx.Request.Context(), projectID)
+	if err != nil {
+		response.NotFound(ctx, "项目不存在")
+		return
+	}
+
+	task, err := c.svc.RetryTask(ctx.Request.Context(), projectID, project.Name, taskID)
+	if err != nil {
+		c.fail(ctx, err)
+		return
+	}
+
+	response.Success(ctx, imageimportdto.FromModel(task))
+}
+
+// fail 将服务层错误映射为响应
+func (c *ImageImportController) fail(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, imageimportservice.ErrInvalidPlatform), errors.Is(err, imageimportservice.ErrInvalidSync),
+		errors.Is(err, imageimportservice.ErrInvalidArchive):
+		response.ParamError(ctx, err.Error())
+	case errors.Is(err, imageimportservice.ErrTaskNotFound), errors.Is(err, imageimportservice.ErrSubscriptionNotFound):
+		response.NotFound(ctx, err.Error())
+	case errors.Is(err, imageimportservice.ErrTaskNotCancellable), errors.Is(err, imageimportservice.ErrTaskNotRetryable),
+		errors.Is(err, imageimportservice.ErrSubscriptionExists), errors.Is(err, imageimportservice.ErrArchiveExpired):
+		response.Conflict(ctx, err.Error())
+	default:
+		response.InternalServerError(ctx, err.Error())
+	}
+}
+
 // ListTasks 列出项目的导入任务
 // GET /api/v1/projects/:id/images/import
 func (c *ImageImportController) ListTasks(ctx *gin.Context) {
@@ -170,3 +302,93 @@ func (c *ImageImportController) ListTasks(ctx *gin.Context) {
 
 	response.Success(ctx, data)
 }
+
+// ListSubscriptions 列出项目的同步订阅
+// GET /api/v1/projects/:id/images/subscriptions
+func (c *ImageImportController) ListSubscriptions(ctx *gin.Context) {
+	projectID := ctx.Param("id")
+	if projectID == "" {
+		response.ParamError(ctx, "项目ID不能为空")
+		return
+	}
+
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("page_size", "20"))
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	subs, total, err := c.svc.ListSubscriptions(ctx.Request.Context(), projectID, page, pageSize)
+	if err != nil {
+		response.InternalServerError(ctx, "获取同步订阅列表失败")
+		return
+	}
+
+	items := make([]imageimportdto.SubscriptionResponse, len(subs))
+	for i := range subs {
+		items[i] = imageimportdto.FromSubscription(&subs[i])
+	}
+	totalPage := int(total) / pageSize
+	if int(total)%pageSize > 0 {
+		totalPage++
+	}
+
+	response.Success(ctx, imageimportdto.SubscriptionListResponse{
+		Subscriptions: items,
+		Total:         total,
+		Page:          page,
+		PageSize:      pageSize,
+		TotalPage:     totalPage,
+	})
+}
+
+// PauseSubscription 暂停同步订阅
+// POST /api/v1/projects/:id/images/subscriptions/:sub_id/pause
+func (c *ImageImportController) PauseSubscription(ctx *gin.Context) {
+	c.setPaused(ctx, true)
+}
+
+// ResumeSubscription 恢复同步订阅（立即进行一次检查）
+// POST /api/v1/projects/:id/images/subscriptions/:sub_id/resume
+func (c *ImageImportController) ResumeSubscription(ctx *gin.Context) {
+	c.setPaused(ctx, false)
+}
+
+// setPaused 暂停或恢复同步订阅
+func (c *ImageImportController) setPaused(ctx *gin.Context, paused bool) {
+	projectID := ctx.Param("id")
+	subID := ctx.Param("sub_id")
+	if projectID == "" || subID == "" {
+		response.ParamError(ctx, "项目ID或订阅ID不能为空")
+		return
+	}
+
+	sub, err := c.svc.SetSubscriptionPaused(ctx.Request.Context(), projectID, subID, paused)
+	if err != nil {
+		c.fail(ctx, err)
+		return
+	}
+
+	response.Success(ctx, imageimportdto.FromSubscription(sub))
+}
+
+// DeleteSubscription 删除同步订阅（已导入的镜像保留）
+// DELETE /api/v1/projects/:id/images/subscriptions/:sub_id
+func (c *ImageImportController) DeleteSubscription(ctx *gin.Context) {
+	projectID := ctx.Param("id")
+	subID := ctx.Param("sub_id")
+	if projectID == "" || subID == "" {
+		response.ParamError(ctx, "项目ID或订阅ID不能为空")
+		return
+	}
+
+	if err := c.svc.DeleteSubscription(ctx.Request.Context(), projectID, subID); err != nil {
+		c.fail(ctx, err)
+		return
+	}
+
+	response.Success(ctx, nil)
+}
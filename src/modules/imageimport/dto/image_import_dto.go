@@ -14,6 +14,22 @@ type ImportImageRequest struct {
 	TargetImage string       `json:"target_image,omitempty"` // 目标镜像名称（可选）
 	TargetTag   string       `json:"target_tag,omitempty"`   // 目标标签（可选）
 	Auth        *AuthRequest `json:"auth,omitempty"`         // 认证信息（私有仓库时可选）
+	// Platforms 只导入指定平台，例如 ["linux/amd64", "linux/arm64"]；为空时导入完整的多架构索引
+	Platforms []string `json:"platforms,omitempty"`
+	// Sync 同时保存为同步订阅：定期检查上游 tag 的 digest，变化时重新导入
+	Sync *SyncRequest `json:"sync,omitempty"`
+}
+
+// SyncRequest 同步订阅设置
+type SyncRequest struct {
+	IntervalSeconds int `json:"interval_seconds,omitempty"` // 检查间隔（秒），默认 3600，最小 300
+}
+
+// ImportArchiveRequest 上传归档导入的表单字段（multipart/form-data，归档文件字段为 file）
+// 未指定时使用归档中记录的镜像名称与标签
+type ImportArchiveRequest struct {
+	TargetImage string `form:"target_image"` // 目标镜像名称（可选，所有镜像写入该名称）
+	TargetTag   string `form:"target_tag"`   // 目标标签（可选）
 }
 
 // AuthRequest 源仓库认证信息
@@ -26,6 +42,7 @@ type AuthRequest struct {
 // 对应 web/src/services/imageImport.ts 中的 ImportTask 接口
 type ImportTaskResponse struct {
 	TaskID      string     `json:"task_id"`
+	SourceType  string     `json:"source_type"`
 	Status      string     `json:"status"`
 	Progress    int        `json:"progress"`
 	Message     string     `json:"message"`
@@ -35,6 +52,12 @@ type ImportTaskResponse struct {
 	Error       string     `json:"error,omitempty"`
 	CreatedAt   time.Time  `json:"created_at"`
 	CompletedAt *time.Time `json:"completed_at,omitempty"`
+
+	Platforms []string                  `json:"platforms,omitempty"` // 请求导入的平台，为空表示全部
+	Manifests []models.ImportedManifest `json:"manifests,omitempty"` // 实际复制的（子）Manifest
+
+	SourceDigest   string `json:"source_digest,omitempty"`   // 源镜像的 digest
+	SubscriptionID string `json:"subscription_id,omitempty"` // 所属同步订阅
 }
 
 // ImportTaskListResponse 任务列表响应结构
@@ -54,6 +77,7 @@ func FromModel(task *models.ImportTask) ImportTaskResponse {
 	}
 	return ImportTaskResponse{
 		TaskID:      task.ID,
+		SourceType:  task.SourceType,
 		Status:      task.Status,
 		Progress:    task.Progress,
 		Message:     task.Message,
@@ -63,6 +87,11 @@ func FromModel(task *models.ImportTask) ImportTaskResponse {
 		Error:       task.Error,
 		CreatedAt:   task.CreatedAt,
 		CompletedAt: task.CompletedAt,
+		Platforms:   task.Platforms,
+		Manifests:   task.Manifests,
+
+		SourceDigest:   task.SourceDigest,
+		SubscriptionID: task.SubscriptionID,
 	}
 }
 
@@ -74,3 +103,53 @@ func FromModelSlice(tasks []models.ImportTask) []ImportTaskResponse {
 	}
 	return result
 }
+
+// SubscriptionResponse 同步订阅响应结构（不返回源仓库密码）
+type SubscriptionResponse struct {
+	ID              string     `json:"id"`
+	SourceURL       string     `json:"source_url"`
+	TargetImage     string     `json:"target_image"`
+	TargetTag       string     `json:"target_tag"`
+	Platforms       []string   `json:"platforms,omitempty"`
+	HasAuth         bool       `json:"has_auth"`
+	IntervalSeconds int        `json:"interval_seconds"`
+	Paused          bool       `json:"paused"`
+	LastDigest      string     `json:"last_digest,omitempty"`
+	LastCheckedAt   *time.Time `json:"last_checked_at,omitempty"`
+	LastSyncedAt    *time.Time `json:"last_synced_at,omitempty"`
+	LastTaskID      string     `json:"last_task_id,omitempty"`
+	LastError       string     `json:"last_error,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// SubscriptionListResponse 同步订阅列表响应结构
+type SubscriptionListResponse struct {
+	Subscriptions []SubscriptionResponse `json:"subscriptions"`
+	Total         int64                  `json:"total"`
+	Page          int                    `json:"page"`
+	PageSize      int                    `json:"page_size"`
+	TotalPage     int                    `json:"total_page"`
+}
+
+// FromSubscription 将同步订阅转换为响应结构
+func FromSubscription(sub *models.SyncSubscription) SubscriptionResponse {
+	if sub == nil {
+		return SubscriptionResponse{}
+	}
+	return SubscriptionResponse{
+		ID:              sub.ID,
+		SourceURL:       sub.SourceURL,
+		TargetImage:     sub.TargetImage,
+		TargetTag:       sub.TargetTag,
+		Platforms:       sub.Platforms,
+		HasAuth:         sub.AuthUsername != "" || sub.AuthPassword != "",
+		IntervalSeconds: sub.IntervalSeconds,
+		Paused:          sub.Paused,
+		LastDigest:      sub.LastDigest,
+		LastCheckedAt:   sub.LastCheckedAt,
+		LastSyncedAt:    sub.LastSyncedAt,
+		LastTaskID:      sub.LastTaskID,
+		LastError:       sub.LastError,
+		CreatedAt:       sub.CreatedAt,
+	}
+}
@@ -18,5 +18,8 @@ func InitDatabase() error {
 	if err := database.DB.AutoMigrate(&models.ImportTask{}); err != nil {
 		return fmt.Errorf("auto migrate image_import_tasks failed: %w", err)
 	}
+	if err := database.DB.AutoMigrate(&models.SyncSubscription{}); err != nil {
+		return fmt.Errorf("auto migrate image_import_subscriptions failed: %w", err)
+	}
 	return nil
 }
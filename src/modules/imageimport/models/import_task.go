@@ -6,6 +6,9 @@ import (
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+
+	// 注册 serializer:secret
+	_ "github.com/cyp-registry/registry/src/pkg/secrets"
 )
 
 // ImportTaskStatus 导入任务状态
@@ -20,6 +23,16 @@ const (
 	TaskStatusSuccess ImportTaskStatus = "success"
 	// TaskStatusFailed 执行失败
 	TaskStatusFailed ImportTaskStatus = "failed"
+	// TaskStatusCancelled 已取消
+	TaskStatusCancelled ImportTaskStatus = "cancelled"
+)
+
+// 导入来源
+const (
+	// SourceTypeRegistry 从镜像仓库拉取
+	SourceTypeRegistry = "registry"
+	// SourceTypeArchive 从上传的 docker save / OCI layout 归档导入
+	SourceTypeArchive = "archive"
 )
 
 // ImportTask 镜像导入任务模型
@@ -27,22 +40,36 @@ const (
 type ImportTask struct {
 	ID string `gorm:"type:varchar(36);primaryKey" json:"id"`
 
-	ProjectID string `gorm:"type:varchar(36);index;not null;comment:项目ID" json:"project_id"`
-	UserID    string `gorm:"type:varchar(36);index;comment:用户ID" json:"user_id"`
+	ProjectID   string `gorm:"type:varchar(36);index;not null;comment:项目ID" json:"project_id"`
+	ProjectName string `gorm:"type:varchar(255);comment:项目名称(重试与重启恢复时用于定位目标仓库)" json:"project_name"`
+	UserID      string `gorm:"type:varchar(36);index;comment:用户ID" json:"user_id"`
 
+	SourceType       string `gorm:"type:varchar(16);not null;default:'registry';comment:导入来源" json:"source_type"`
 	SourceURL        string `gorm:"type:varchar(512);not null;comment:原始镜像URL" json:"source_url"`
 	NormalizedSource string `gorm:"type:varchar(512);not null;comment:规范化后的镜像URL" json:"normalized_source"`
+	// ArchivePath 归档导入时暂存的归档文件（导入成功后删除）
+	ArchivePath string `gorm:"type:varchar(1024);comment:暂存的归档文件" json:"-"`
 
 	TargetImage string `gorm:"type:varchar(256);not null;comment:目标镜像名称" json:"target_image"`
 	TargetTag   string `gorm:"type:varchar(128);not null;comment:目标镜像标签" json:"target_tag"`
 
+	// Platforms 要导入的平台（os/arch[/variant]），为空表示导入完整的多架构索引
+	Platforms []string `gorm:"type:jsonb;serializer:json;comment:要导入的平台" json:"platforms"`
+	// SourceDigest 源镜像（上游 tag 当时指向）的 digest，解析源镜像后写入
+	SourceDigest string `gorm:"type:varchar(128);comment:源镜像digest" json:"source_digest"`
+	// SubscriptionID 由同步订阅创建的任务所属订阅
+	SubscriptionID string `gorm:"type:varchar(36);index;comment:同步订阅ID" json:"subscription_id"`
+	// Manifests 实际复制的（子）Manifest，解析源镜像后写入
+	Manifests []ImportedManifest `gorm:"type:jsonb;serializer:json;comment:已复制的Manifest" json:"manifests"`
+
 	Status   string `gorm:"type:varchar(32);index;not null;comment:任务状态" json:"status"`
 	Progress int    `gorm:"type:int;not null;default:0;comment:进度百分比" json:"progress"`
 	Message  string `gorm:"type:text;comment:状态消息" json:"message"`
 	Error    string `gorm:"type:text;comment:错误信息" json:"error"`
+	Attempts int    `gorm:"type:int;not null;default:0;comment:执行次数" json:"attempts"`
 
 	AuthUsername string `gorm:"type:varchar(128);comment:源仓库用户名(可选)" json:"-"`
-	AuthPassword string `gorm:"type:varchar(256);comment:源仓库密码或Token(可选)" json:"-"`
+	AuthPassword string `gorm:"type:text;serializer:secret;comment:源仓库密码或Token(可选，加密保存)" json:"-"`
 
 	CreatedAt   time.Time      `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt   time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
@@ -50,6 +77,16 @@ type ImportTask struct {
 	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
+// ImportedManifest 导入任务复制的单个 Manifest
+// 多架构镜像为索引中被选中的子 Manifest；单平台镜像为镜像本身。
+type ImportedManifest struct {
+	Digest    string `json:"digest"`
+	MediaType string `json:"media_type"`
+	Size      int64  `json:"size"`
+	Platform  string `json:"platform,omitempty"`  // os/arch[/variant]，无法识别时为空（例如构建证明）
+	Reference string `json:"reference,omitempty"` // 归档导入时写入的目标引用 <image>:<tag>
+}
+
 // TableName 表名
 func (ImportTask) TableName() string {
 	return "image_import_tasks"
@@ -61,6 +98,7 @@ func NewImportTask(projectID, userID, sourceURL, normalizedSource, targetImage,
 		ID:               uuid.New().String(),
 		ProjectID:        projectID,
 		UserID:           userID,
+		SourceType:       SourceTypeRegistry,
 		SourceURL:        sourceURL,
 		NormalizedSource: normalizedSource,
 		TargetImage:      targetImage,
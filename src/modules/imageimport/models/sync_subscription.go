@@ -0,0 +1,83 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SyncSubscription 镜像同步订阅：定期检查上游 tag 的 digest，变化时重新导入
+// 表名: image_import_subscriptions
+type SyncSubscription struct {
+	ID string `gorm:"type:varchar(36);primaryKey" json:"id"`
+
+	ProjectID   string `gorm:"type:varchar(36);index;not null;comment:项目ID" json:"project_id"`
+	ProjectName string `gorm:"type:varchar(255);not null;comment:项目名称" json:"project_name"`
+
+	SourceURL        string   `gorm:"type:varchar(512);not null;comment:原始镜像URL" json:"source_url"`
+	NormalizedSource string   `gorm:"type:varchar(512);not null;comment:规范化后的镜像URL" json:"normalized_source"`
+	TargetImage      string   `gorm:"type:varchar(256);not null;comment:目标镜像名称" json:"target_image"`
+	TargetTag        string   `gorm:"type:varchar(128);not null;comment:目标镜像标签" json:"target_tag"`
+	Platforms        []string `gorm:"type:jsonb;serializer:json;comment:要导入的平台" json:"platforms"`
+
+	AuthUsername string `gorm:"type:varchar(128);comment:源仓库用户名(可选)" json:"-"`
+	AuthPassword string `gorm:"type:text;serializer:secret;comment:源仓库密码或Token(可选，加密保存)" json:"-"`
+
+	IntervalSeconds int  `gorm:"type:int;not null;comment:检查间隔(秒)" json:"interval_seconds"`
+	Paused          bool `gorm:"not null;default:false;comment:是否暂停" json:"paused"`
+
+	LastDigest    string     `gorm:"type:varchar(128);comment:最近一次成功同步的上游digest" json:"last_digest"`
+	LastCheckedAt *time.Time `json:"last_checked_at"`
+	LastSyncedAt  *time.Time `json:"last_synced_at"`
+	LastTaskID    string     `gorm:"type:varchar(36);comment:最近一次导入任务ID" json:"last_task_id"`
+	LastError     string     `gorm:"type:text;comment:最近一次检查或同步的错误" json:"last_error"`
+
+	CreatedBy string         `gorm:"type:varchar(36);index;comment:创建者" json:"created_by"`
+	CreatedAt time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// TableName 表名
+func (SyncSubscription) TableName() string {
+	return "image_import_subscriptions"
+}
+
+// Due 到达下一次检查时间
+func (s *SyncSubscription) Due(now time.Time) bool {
+	if s.LastCheckedAt == nil {
+		return true
+	}
+	return !now.Before(s.LastCheckedAt.Add(time.Duration(s.IntervalSeconds) * time.Second))
+}
+
+// NewTask 按订阅配置创建一次导入任务
+func (s *SyncSubscription) NewTask() *ImportTask {
+	task := NewImportTask(s.ProjectID, s.CreatedBy, s.SourceURL, s.NormalizedSource, s.TargetImage, s.TargetTag)
+	task.ProjectName = s.ProjectName
+	task.Platforms = s.Platforms
+	task.AuthUsername = s.AuthUsername
+	task.AuthPassword = s.AuthPassword
+	task.SubscriptionID = s.ID
+	return task
+}
+
+// NewSyncSubscription 根据导入任务创建同步订阅
+func NewSyncSubscription(task *ImportTask, intervalSeconds int) *SyncSubscription {
+	return &SyncSubscription{
+		ID:               uuid.New().String(),
+		ProjectID:        task.ProjectID,
+		ProjectName:      task.ProjectName,
+		SourceURL:        task.SourceURL,
+		NormalizedSource: task.NormalizedSource,
+		TargetImage:      task.TargetImage,
+		TargetTag:        task.TargetTag,
+		Platforms:        task.Platforms,
+		AuthUsername:     task.AuthUsername,
+		AuthPassword:     task.AuthPassword,
+		IntervalSeconds:  intervalSeconds,
+		LastTaskID:       task.ID,
+		CreatedBy:        task.UserID,
+	}
+}
@@ -0,0 +1,849 @@
+package service
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	imageimportdto "github.com/cyp-registry/registry/src/modules/imageimport/dto"
+	"github.com/cyp-registry/registry/src/modules/imageimport/models"
+	"github.com/cyp-registry/registry/src/modules/registry"
+)
+
+// ErrInvalidArchive 上传的文件不是合法的 docker save / OCI layout 归档
+var ErrInvalidArchive = errors.New("镜像归档格式不合法")
+
+// ErrArchiveExpired 失败或取消的归档任务超过保留时间，暂存的归档已被清理
+var ErrArchiveExpired = errors.New("暂存的归档已过期清理，请重新上传")
+
+// 归档中的元数据文件
+const (
+	ociLayoutFile      = "oci-layout"
+	ociIndexFile       = "index.json"
+	dockerManifestFile = "manifest.json"
+)
+
+// maxArchiveMetadataSize 归档中 JSON 元数据（索引、Manifest、镜像配置）的最大长度
+const maxArchiveMetadataSize = 16 * 1024 * 1024
+
+// defaultArchiveDir 未配置时暂存上传归档的目录
+var defaultArchiveDir = filepath.Join(os.TempDir(), "cyp-registry-import")
+
+// DefaultArchiveTTL 失败或取消的任务保留暂存归档的默认时间（自任务最后一次更新起算）
+const DefaultArchiveTTL = 24 * time.Hour
+
+// SetArchiveDir 设置暂存上传归档的目录（为空表示使用系统临时目录）
+// 导入成功后归档即被删除；失败或取消的任务保留归档以便重试，超过保留时间后由 PurgeExpiredArchives 清理。
+func (s *Service) SetArchiveDir(dir string) {
+	if dir == "" {
+		dir = defaultArchiveDir
+	}
+	s.archiveDir = dir
+}
+
+// SetArchiveTTL 设置失败或取消的任务保留暂存归档的时间（<=0 表示使用默认值）
+func (s *Service) SetArchiveTTL(ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = DefaultArchiveTTL
+	}
+	s.archiveTTL = ttl
+}
+
+// ArchiveTTL 返回暂存归档的保留时间
+func (s *Service) ArchiveTTL() time.Duration {
+	return s.archiveTTL
+}
+
+// PurgeExpiredArchives 清理超过保留时间的暂存归档，返回删除的归档数
+// 包括失败或取消后一直未重试的任务的归档（任务保留，但不能再重试），
+// 以及暂存目录中没有未完成任务引用的文件（如写入任务前进程退出遗留的归档）。
+// 多副本同时执行是安全的：任务记录先以条件更新摘除归档，只有摘除成功的副本删除文件。
+func (s *Service) PurgeExpiredArchives(ctx context.Context) (int, error) {
+	cutoff := time.Now().Add(-s.archiveTTL)
+	finished := []string{string(models.TaskStatusFailed), string(models.TaskStatusCancelled)}
+
+	var tasks []models.ImportTask
+	if err := s.db.WithContext(ctx).Select("id", "archive_path").
+		Where("archive_path <> '' AND status IN ? AND updated_at < ?", finished, cutoff).
+		Find(&tasks).Error; err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, task := range tasks {
+		if ctx.Err() != nil {
+			return purged, ctx.Err()
+		}
+		// 与重试竞争：任务已重新排队时保留归档
+		result := s.db.WithContext(ctx).Model(&models.ImportTask{}).
+			Where("id = ? AND archive_path = ? AND status IN ?", task.ID, task.ArchivePath, finished).
+			Updates(map[string]interface{}{"archive_path": ""})
+		if result.Error != nil {
+			return purged, result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		if err := os.Remove(task.ArchivePath); err == nil {
+			purged++
+		} else if !os.IsNotExist(err) {
+			log.Printf(`{"timestamp":"%s","level":"warn","module":"imageimport","operation":"purge_archive","task_id":"%s","error":"%v"}`, time.Now().Format(time.RFC3339), task.ID, err)
+		}
+	}
+
+	n, err := s.purgeOrphanArchives(ctx, cutoff)
+	return purged + n, err
+}
+
+// purgeOrphanArchives 删除暂存目录中早于 cutoff 且没有任务引用的归档文件
+func (s *Service) purgeOrphanArchives(ctx context.Context, cutoff time.Time) (int, error) {
+	entries, err := os.ReadDir(s.archiveDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	purged := 0
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			return purged, ctx.Err()
+		}
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".tar" {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		archivePath := filepath.Join(s.archiveDir, entry.Name())
+		var count int64
+		if err := s.db.WithContext(ctx).Model(&models.ImportTask{}).
+			Where("archive_path = ? AND status <> ?", archivePath, string(models.TaskStatusSuccess)).
+			Count(&count).Error; err != nil {
+			return purged, err
+		}
+		if count > 0 {
+			continue
+		}
+		if err := os.Remove(archivePath); err == nil {
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// ImportArchive 暂存上传的镜像归档并创建导入任务
+// 归档可以是 docker save 生成的 tar 或 OCI image layout 的 tar（均支持 gzip 压缩），
+// 一个归档对应一个任务；归档中包含多个镜像时全部导入。
+// 上传时只解析元数据以便立即拒绝不合法的归档，Blob 校验与写入在任务执行时进行。
+func (s *Service) ImportArchive(
+	ctx context.Context,
+	projectID string,
+	projectName string,
+	userID *uuid.UUID,
+	filename string,
+	body io.Reader,
+	req *imageimportdto.ImportArchiveRequest,
+) (*models.ImportTask, error) {
+	name := filepath.Base(strings.TrimSpace(filename))
+	if name == "" || name == "." || name == string(filepath.Separator) {
+		name = "archive.tar"
+	}
+
+	uid := ""
+	if userID != nil && *userID != uuid.Nil {
+		uid = userID.String()
+	}
+
+	task := models.NewImportTask(projectID, uid, name, name, "", "")
+	task.SourceType = models.SourceTypeArchive
+	task.ProjectName = projectName
+
+	archivePath, err := s.spoolArchive(task.ID, body)
+	if err != nil {
+		return nil, err
+	}
+	task.ArchivePath = archivePath
+
+	images, err := inspectArchive(archivePath)
+	if err == nil {
+		var targetImage, targetTag string
+		if req != nil {
+			targetImage, targetTag = strings.TrimSpace(req.TargetImage), strings.TrimSpace(req.TargetTag)
+		}
+		err = assignArchiveTargets(images, targetImage, targetTag)
+	}
+	if err != nil {
+		_ = os.Remove(archivePath)
+		return nil, err
+	}
+	// 所有镜像目标一致时记录在任务上，执行时按同样的规则重新分配
+	task.TargetImage, task.TargetTag = commonArchiveTarget(images)
+	task.Message = fmt.Sprintf("归档包含 %d 个镜像，等待执行", len(images))
+
+	if err := s.db.WithContext(ctx).Create(task).Error; err != nil {
+		_ = os.Remove(archivePath)
+		return nil, fmt.Errorf("创建导入任务失败: %w", err)
+	}
+
+	s.enqueue(task)
+	return task, nil
+}
+
+// spoolArchive 将上传的归档写入暂存目录（gzip 压缩的归档解压后保存，便于按偏移随机读取）
+func (s *Service) spoolArchive(taskID string, body io.Reader) (string, error) {
+	dir := s.archiveDir
+	if dir == "" {
+		dir = defaultArchiveDir
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return "", fmt.Errorf("创建归档暂存目录失败: %w", err)
+	}
+
+	archivePath := filepath.Join(dir, taskID+".tar")
+	f, err := os.OpenFile(archivePath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return "", fmt.Errorf("创建归档暂存文件失败: %w", err)
+	}
+
+	br := bufio.NewReader(body)
+	var r io.Reader = br
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			f.Close()
+			_ = os.Remove(archivePath)
+			return "", fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	_, err = io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(archivePath)
+		return "", fmt.Errorf("保存上传的归档失败: %w", err)
+	}
+	return archivePath, nil
+}
+
+// inspectArchive 解析归档中的镜像列表（不读取层数据）
+func inspectArchive(archivePath string) ([]*archiveImage, error) {
+	a, err := openArchive(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer a.Close()
+	return a.images()
+}
+
+// archiveEntry 归档中单个文件的数据位置
+type archiveEntry struct {
+	offset int64
+	size   int64
+}
+
+// imageArchive 已暂存的 tar 归档，按文件名随机读取
+type imageArchive struct {
+	f     *os.File
+	files map[string]archiveEntry
+	// blobFiles docker save 归档中 digest 与文件名的对应关系（OCI layout 按 blobs/<alg>/<hex> 定位）
+	blobFiles map[string]string
+}
+
+// openArchive 打开暂存的归档并建立文件索引
+// tar.Reader 在底层支持 Seek 时跳过文件数据，Next 返回后文件偏移即为该文件数据的起始位置。
+func openArchive(archivePath string) (*imageArchive, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &imageArchive{f: f, files: make(map[string]archiveEntry), blobFiles: make(map[string]string)}
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		offset, err := f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		a.files[path.Clean(strings.TrimPrefix(hdr.Name, "./"))] = archiveEntry{offset: offset, size: hdr.Size}
+	}
+	return a, nil
+}
+
+// Close 关闭归档文件
+func (a *imageArchive) Close() error {
+	return a.f.Close()
+}
+
+// open 返回归档中文件的数据
+func (a *imageArchive) open(name string) (*io.SectionReader, error) {
+	e, ok := a.files[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: 缺少文件 %s", ErrInvalidArchive, name)
+	}
+	return io.NewSectionReader(a.f, e.offset, e.size), nil
+}
+
+// readFile 读取归档中的元数据文件
+func (a *imageArchive) readFile(name string) ([]byte, error) {
+	r, err := a.open(name)
+	if err != nil {
+		return nil, err
+	}
+	if r.Size() > maxArchiveMetadataSize {
+		return nil, fmt.Errorf("%w: %s 过大", ErrInvalidArchive, name)
+	}
+	return io.ReadAll(r)
+}
+
+// blobFile 返回 digest 对应的归档内文件名
+func (a *imageArchive) blobFile(digest string) (string, error) {
+	if name, ok := a.blobFiles[digest]; ok {
+		return name, nil
+	}
+	algorithm, hexDigest, err := registry.ParseDigest(digest)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	return "blobs/" + algorithm + "/" + hexDigest, nil
+}
+
+// readBlob 读取归档中的小型 Blob（Manifest、镜像配置）并校验 digest
+func (a *imageArchive) readBlob(digest string) ([]byte, error) {
+	name, err := a.blobFile(digest)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := a.readFile(name)
+	if err != nil {
+		return nil, err
+	}
+	if actual, _, _ := registry.CalculateDigest(bytes.NewReader(raw)); actual != digest {
+		return nil, fmt.Errorf("%w: %s 的 digest 不匹配（实际为 %s）", ErrInvalidArchive, digest, actual)
+	}
+	return raw, nil
+}
+
+// archiveImage 归档中的单个镜像
+type archiveImage struct {
+	raw       []byte // Manifest 或索引内容；docker save 镜像在执行时生成
+	mediaType string
+	digest    string
+
+	name string   // 目标镜像名称
+	tags []string // 目标标签
+	// untagged 只按 digest 写入（签名、SBOM 等 referrer，或按 digest 导出的镜像）
+	untagged bool
+
+	docker *dockerSaveImage // 非空表示来自 docker save 的 manifest.json
+}
+
+// reference 镜像在归档中的描述（用于错误提示）
+func (img *archiveImage) reference() string {
+	if img.digest != "" {
+		return img.digest
+	}
+	return img.docker.Config
+}
+
+// dockerSaveImage docker save 生成的 manifest.json 中的单个镜像
+type dockerSaveImage struct {
+	Config   string   `json:"Config"`
+	RepoTags []string `json:"RepoTags"`
+	Layers   []string `json:"Layers"`
+}
+
+// images 解析归档中的镜像
+// 同时包含 OCI layout 与 manifest.json 时（Docker 25+ 的 docker save）按 OCI layout 导入，保持 digest 不变。
+func (a *imageArchive) images() ([]*archiveImage, error) {
+	var images []*archiveImage
+	var err error
+	switch {
+	case a.has(ociLayoutFile) && a.has(ociIndexFile):
+		images, err = a.ociImages()
+	case a.has(dockerManifestFile):
+		images, err = a.dockerImages()
+	default:
+		return nil, fmt.Errorf("%w: 未找到 index.json 或 manifest.json", ErrInvalidArchive)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(images) == 0 {
+		return nil, fmt.Errorf("%w: 归档中没有镜像", ErrInvalidArchive)
+	}
+	return images, nil
+}
+
+// has 归档中是否包含文件
+func (a *imageArchive) has(name string) bool {
+	_, ok := a.files[name]
+	return ok
+}
+
+// ociImages 解析 OCI layout 的 index.json
+func (a *imageArchive) ociImages() ([]*archiveImage, error) {
+	raw, err := a.readFile(ociIndexFile)
+	if err != nil {
+		return nil, err
+	}
+	var index struct {
+		Manifests []registry.Descriptor `json:"manifests"`
+	}
+	if err := json.Unmarshal(raw, &index); err != nil {
+		return nil, fmt.Errorf("%w: index.json: %v", ErrInvalidArchive, err)
+	}
+
+	var images []*archiveImage
+	for _, desc := range index.Manifests {
+		manifest, err := a.readBlob(desc.Digest)
+		if err != nil {
+			return nil, err
+		}
+		mediaType := desc.MediaType
+		if mediaType == "" {
+			mediaType = registry.ManifestMediaType(manifest)
+		}
+		img := &archiveImage{raw: manifest, mediaType: mediaType, digest: desc.Digest}
+
+		// containerd / Docker 记录完整镜像名；OCI 规范的 ref.name 可以只是标签
+		full := desc.Annotations[registry.AnnotationContainerdName]
+		if full != "" {
+			img.addReference(full)
+		} else if ref := desc.Annotations[registry.AnnotationRefName]; strings.ContainsAny(ref, "/:") {
+			img.addReference(ref)
+		} else if ref != "" {
+			img.tags = append(img.tags, ref)
+		}
+		if len(img.tags) == 0 && (strings.Contains(full, "@") || hasSubject(manifest)) {
+			img.untagged = true
+		}
+		images = append(images, img)
+	}
+	return images, nil
+}
+
+// hasSubject Manifest 是否声明了 subject（即为其他镜像的 referrer）
+func hasSubject(raw []byte) bool {
+	var doc struct {
+		Subject *registry.Descriptor `json:"subject"`
+	}
+	return json.Unmarshal(raw, &doc) == nil && doc.Subject != nil
+}
+
+// dockerImages 解析 docker save 的 manifest.json
+func (a *imageArchive) dockerImages() ([]*archiveImage, error) {
+	raw, err := a.readFile(dockerManifestFile)
+	if err != nil {
+		return nil, err
+	}
+	var entries []dockerSaveImage
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return nil, fmt.Errorf("%w: manifest.json: %v", ErrInvalidArchive, err)
+	}
+
+	images := make([]*archiveImage, 0, len(entries))
+	for i := range entries {
+		entry := &entries[i]
+		if entry.Config == "" || !a.has(path.Clean(entry.Config)) {
+			return nil, fmt.Errorf("%w: 缺少镜像配置 %s", ErrInvalidArchive, entry.Config)
+		}
+		for _, layer := range entry.Layers {
+			if !a.has(path.Clean(layer)) {
+				return nil, fmt.Errorf("%w: 缺少镜像层 %s", ErrInvalidArchive, layer)
+			}
+		}
+		img := &archiveImage{mediaType: registry.MediaTypeDocker2Manifest, docker: entry}
+		for _, ref := range entry.RepoTags {
+			img.addReference(ref)
+		}
+		images = append(images, img)
+	}
+	return images, nil
+}
+
+// addReference 从完整镜像引用（例如 nginx:1.25、ghcr.io/owner/app:v1）中记录镜像名称与标签
+func (img *archiveImage) addReference(ref string) {
+	normalized := normalizeImageURL(ref)
+	if name := inferTargetImageFromSource(normalized); name != "" && img.name == "" {
+		img.name = name
+	}
+	if tag := inferTagFromSource(normalized); tag != "" {
+		img.tags = append(img.tags, tag)
+	}
+}
+
+// assignArchiveTargets 按请求指定的目标镜像与标签分配每个镜像的写入位置
+// 未指定时使用归档中记录的名称与标签（无标签时为 latest，只按 digest 写入的镜像除外）；
+// 不同镜像不能写入同一标签。
+func assignArchiveTargets(images []*archiveImage, targetImage, targetTag string) error {
+	owners := make(map[string]*archiveImage)
+	for _, img := range images {
+		if targetImage != "" {
+			img.name = targetImage
+		}
+		if img.name == "" {
+			return fmt.Errorf("%w: 镜像 %s 未记录名称，请指定 target_image", ErrInvalidArchive, img.reference())
+		}
+		if img.untagged {
+			continue
+		}
+		if targetTag != "" {
+			img.tags = []string{targetTag}
+		}
+		if len(img.tags) == 0 {
+			img.tags = []string{"latest"}
+		}
+		for _, tag := range img.tags {
+			key := img.name + ":" + tag
+			if owner, ok := owners[key]; ok && owner != img {
+				return fmt.Errorf("%w: 多个镜像将写入同一标签 %s", ErrInvalidArchive, key)
+			}
+			owners[key] = img
+		}
+	}
+	return nil
+}
+
+// commonArchiveTarget 所有镜像共同的目标名称与唯一标签（不一致时对应项为空，只按 digest 写入的镜像不参与标签比较）
+// 以此作为任务的 target_image/target_tag，执行时再次分配得到相同的结果。
+func commonArchiveTarget(images []*archiveImage) (string, string) {
+	name, tag := images[0].name, ""
+	first := true
+	for _, img := range images {
+		if img.name != name {
+			name = ""
+		}
+		if img.untagged {
+			continue
+		}
+		if first {
+			first = false
+			if len(img.tags) == 1 {
+				tag = img.tags[0]
+			}
+		} else if len(img.tags) != 1 || img.tags[0] != tag {
+			tag = ""
+		}
+	}
+	return name, tag
+}
+
+// archiveRepo 写入同一目标仓库的镜像
+type archiveRepo struct {
+	repo   string
+	images []*archiveImage
+	seen   map[string]bool
+	plan   importPlan
+}
+
+// importArchive 执行归档导入：校验并写入 Blob，然后写入 Manifest 与标签
+func (s *Service) importArchive(ctx context.Context, task *models.ImportTask) (string, error) {
+	s.updateProgress(ctx, task.ID, 5, "正在解析镜像归档...")
+	a, err := openArchive(task.ArchivePath)
+	if err != nil {
+		return "", err
+	}
+	defer a.Close()
+
+	images, err := a.images()
+	if err != nil {
+		return "", err
+	}
+	if err := assignArchiveTargets(images, task.TargetImage, task.TargetTag); err != nil {
+		return "", err
+	}
+
+	var repos []*archiveRepo
+	byName := make(map[string]*archiveRepo)
+	var copied []models.ImportedManifest
+	for _, img := range images {
+		if img.docker != nil {
+			if err := a.buildDockerManifest(img); err != nil {
+				return "", err
+			}
+		}
+		r, ok := byName[img.name]
+		if !ok {
+			r = &archiveRepo{repo: task.ProjectName + "/" + img.name, seen: make(map[string]bool)}
+			byName[img.name] = r
+			repos = append(repos, r)
+		}
+		r.images = append(r.images, img)
+		if err := s.resolveArchive(ctx, a, r, img.raw, img.mediaType, img.digest); err != nil {
+			return "", err
+		}
+
+		p := a.configPlatform(img.raw).String()
+		if img.untagged {
+			copied = append(copied, models.ImportedManifest{
+				Digest: img.digest, MediaType: img.mediaType, Size: int64(len(img.raw)),
+				Platform: p, Reference: img.name + "@" + img.digest,
+			})
+		}
+		for _, tag := range img.tags {
+			copied = append(copied, models.ImportedManifest{
+				Digest: img.digest, MediaType: img.mediaType, Size: int64(len(img.raw)),
+				Platform: p, Reference: img.name + ":" + tag,
+			})
+		}
+	}
+	s.recordManifests(ctx, task.ID, "", copied)
+
+	var total int64
+	var blobs, skipped int
+	for _, r := range repos {
+		total += r.plan.total
+		blobs += len(r.plan.blobs)
+		skipped += r.plan.skipped
+	}
+	s.updateProgress(ctx, task.ID, progressResolved, fmt.Sprintf("需要写入 %d 个 Blob（%s），%d 个已存在", blobs, formatBytes(total), skipped))
+
+	tracker := &progressTracker{svc: s, ctx: ctx, taskID: task.ID, total: total}
+	for _, r := range repos {
+		for _, blob := range r.plan.blobs {
+			if err := a.copyBlob(ctx, s.reg, r.repo, blob, tracker); err != nil {
+				return "", fmt.Errorf("写入 Blob %s 失败: %w", blob.Digest, err)
+			}
+		}
+	}
+
+	s.updateProgress(ctx, task.ID, progressBlobsEnd, "正在写入 Manifest...")
+	for _, r := range repos {
+		for _, m := range r.plan.manifests {
+			if _, err := s.reg.PutManifestRaw(ctx, r.repo, m.reference, m.raw, m.mediaType); err != nil {
+				return "", fmt.Errorf("写入 Manifest %s 失败: %w", m.reference, err)
+			}
+		}
+		for _, img := range r.images {
+			for _, tag := range img.tags {
+				digest, err := s.reg.PutManifestRaw(ctx, r.repo, tag, img.raw, img.mediaType)
+				if err != nil {
+					return "", fmt.Errorf("写入标签 %s:%s 失败: %w", img.name, tag, err)
+				}
+				s.notifyPushed(task, r.repo, tag, digest)
+			}
+		}
+	}
+
+	return fmt.Sprintf("归档导入完成：%d 个镜像，写入 %d 个 Blob（%s），跳过 %d 个已存在的 Blob", len(images), blobs, formatBytes(tracker.done), skipped), nil
+}
+
+// resolveArchive 递归解析归档中的 Manifest，生成目标仓库的导入计划
+// 与从仓库导入相同，只有目标仓库已可见的 Blob 才会跳过。
+func (s *Service) resolveArchive(ctx context.Context, a *imageArchive, r *archiveRepo, raw []byte, mediaType, digest string) error {
+	if r.seen[digest] {
+		return nil
+	}
+	r.seen[digest] = true
+
+	blobs, children, err := registry.ManifestReferences(raw, mediaType)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	for _, child := range children {
+		childRaw, err := a.readBlob(child.Digest)
+		if err != nil {
+			return err
+		}
+		childType := child.MediaType
+		if childType == "" {
+			childType = registry.ManifestMediaType(childRaw)
+		}
+		if err := s.resolveArchive(ctx, a, r, childRaw, childType, child.Digest); err != nil {
+			return err
+		}
+	}
+
+	for _, blob := range blobs {
+		if r.seen[blob.Digest] {
+			continue
+		}
+		r.seen[blob.Digest] = true
+
+		exists, err := s.reg.CheckBlob(ctx, r.repo, blob.Digest)
+		if err != nil {
+			return err
+		}
+		if exists {
+			r.plan.skipped++
+			continue
+		}
+		r.plan.blobs = append(r.plan.blobs, blob)
+		r.plan.total += blob.Size
+	}
+
+	r.plan.manifests = append(r.plan.manifests, importManifest{raw: raw, mediaType: mediaType, reference: digest})
+	return nil
+}
+
+// copyBlob 从归档读取 Blob 写入目标仓库（写入时校验 digest 与大小）
+func (a *imageArchive) copyBlob(ctx context.Context, reg *registry.Registry, repo string, blob registry.Descriptor, tracker *progressTracker) error {
+	name, err := a.blobFile(blob.Digest)
+	if err != nil {
+		return err
+	}
+	r, err := a.open(name)
+	if err != nil {
+		return err
+	}
+	if r.Size() != blob.Size {
+		return fmt.Errorf("%w: %s 的大小为 %d，Manifest 中为 %d", ErrInvalidArchive, name, r.Size(), blob.Size)
+	}
+	_, err = reg.PutBlob(ctx, repo, blob.Digest, blob.Size, &progressReader{r: r, tracker: tracker})
+	return err
+}
+
+// buildDockerManifest 为 docker save 镜像生成 Docker v2 schema 2 Manifest
+// 计算镜像配置与每个层的 digest，并以镜像配置中的 rootfs.diff_ids 校验层内容。
+func (a *imageArchive) buildDockerManifest(img *archiveImage) error {
+	configName := path.Clean(img.docker.Config)
+	configRaw, err := a.readFile(configName)
+	if err != nil {
+		return err
+	}
+	configDigest, _, _ := registry.CalculateDigest(bytes.NewReader(configRaw))
+	a.blobFiles[configDigest] = configName
+
+	var config struct {
+		RootFS struct {
+			DiffIDs []string `json:"diff_ids"`
+		} `json:"rootfs"`
+	}
+	if err := json.Unmarshal(configRaw, &config); err != nil {
+		return fmt.Errorf("%w: 镜像配置 %s: %v", ErrInvalidArchive, configName, err)
+	}
+	if len(config.RootFS.DiffIDs) != len(img.docker.Layers) {
+		return fmt.Errorf("%w: 镜像配置 %s 记录了 %d 个层，manifest.json 中为 %d 个", ErrInvalidArchive, configName, len(config.RootFS.DiffIDs), len(img.docker.Layers))
+	}
+
+	type descriptor struct {
+		MediaType string `json:"mediaType"`
+		Size      int64  `json:"size"`
+		Digest    string `json:"digest"`
+	}
+	manifest := struct {
+		SchemaVersion int          `json:"schemaVersion"`
+		MediaType     string       `json:"mediaType"`
+		Config        descriptor   `json:"config"`
+		Layers        []descriptor `json:"layers"`
+	}{
+		SchemaVersion: 2,
+		MediaType:     registry.MediaTypeDocker2Manifest,
+		Config:        descriptor{MediaType: registry.MediaTypeDocker2ImageConfig, Size: int64(len(configRaw)), Digest: configDigest},
+		Layers:        make([]descriptor, 0, len(img.docker.Layers)),
+	}
+
+	for i, layer := range img.docker.Layers {
+		layerName := path.Clean(layer)
+		r, err := a.open(layerName)
+		if err != nil {
+			return err
+		}
+		digest, diffID, compressed, err := hashLayer(r)
+		if err != nil {
+			return fmt.Errorf("%w: 读取镜像层 %s 失败: %v", ErrInvalidArchive, layerName, err)
+		}
+		if diffID != config.RootFS.DiffIDs[i] {
+			return fmt.Errorf("%w: 镜像层 %s 的内容与镜像配置不一致（diff_id 为 %s，应为 %s）", ErrInvalidArchive, layerName, diffID, config.RootFS.DiffIDs[i])
+		}
+		mediaType := registry.MediaTypeDocker2ImageLayerNonDist
+		if compressed {
+			mediaType = registry.MediaTypeDocker2ImageLayer
+		}
+		a.blobFiles[digest] = layerName
+		manifest.Layers = append(manifest.Layers, descriptor{MediaType: mediaType, Size: r.Size(), Digest: digest})
+	}
+
+	raw, err := json.MarshalIndent(manifest, "", "   ")
+	if err != nil {
+		return err
+	}
+	img.raw = raw
+	img.digest, _, _ = registry.CalculateDigest(bytes.NewReader(raw))
+	return nil
+}
+
+// hashLayer 计算层文件的 digest 与 diff_id（未压缩内容的 digest）
+// docker save 的层通常未压缩，此时两者相同。
+func hashLayer(r *io.SectionReader) (digest, diffID string, compressed bool, err error) {
+	var magic [2]byte
+	if n, _ := r.ReadAt(magic[:], 0); n == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		compressed = true
+	}
+
+	raw := sha256.New()
+	if !compressed {
+		if _, err := io.Copy(raw, r); err != nil {
+			return "", "", false, err
+		}
+		digest = "sha256:" + hex.EncodeToString(raw.Sum(nil))
+		return digest, digest, false, nil
+	}
+
+	gz, err := gzip.NewReader(io.TeeReader(r, raw))
+	if err != nil {
+		return "", "", false, err
+	}
+	defer gz.Close()
+	uncompressed := sha256.New()
+	if _, err := io.Copy(uncompressed, gz); err != nil {
+		return "", "", false, err
+	}
+	// gzip 流之后可能还有填充数据，计入压缩文件的 digest
+	if _, err := io.Copy(raw, r); err != nil {
+		return "", "", false, err
+	}
+	return "sha256:" + hex.EncodeToString(raw.Sum(nil)), "sha256:" + hex.EncodeToString(uncompressed.Sum(nil)), true, nil
+}
+
+// configPlatform 读取归档中镜像配置的平台（索引或读取失败时返回空平台）
+func (a *imageArchive) configPlatform(raw []byte) platform {
+	var manifest struct {
+		Config *registry.Descriptor `json:"config"`
+	}
+	if err := json.Unmarshal(raw, &manifest); err != nil || manifest.Config == nil {
+		return platform{}
+	}
+	config, err := a.readBlob(manifest.Config.Digest)
+	if err != nil {
+		return platform{}
+	}
+	var p platform
+	_ = json.Unmarshal(config, &p)
+	return p
+}
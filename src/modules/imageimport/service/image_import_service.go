@@ -4,8 +4,10 @@ package service
 import (
 	"context"
 	"fmt"
-	"os/exec"
+	"log"
+	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,21 +15,41 @@ import (
 
 	imageimportdto "github.com/cyp-registry/registry/src/modules/imageimport/dto"
 	"github.com/cyp-registry/registry/src/modules/imageimport/models"
+	"github.com/cyp-registry/registry/src/modules/registry"
 	"github.com/cyp-registry/registry/src/pkg/database"
 )
 
 // Service 镜像导入服务
+// 任务先进入等待队列，由有界的执行名额（全局与单项目并发上限，见 scheduler.go）调度执行。
 type Service struct {
-	db                *gorm.DB
-	localRegistryHost string
+	db  *gorm.DB
+	reg *registry.Registry
+
+	mu         sync.Mutex
+	workers    int
+	perProject int
+	active     int
+	queue      []queuedTask
+	running    map[string]int      // 项目ID -> 执行中的任务数
+	runs       map[string]*taskRun // 任务ID -> 执行中的任务
+
+	notifier   EventNotifier // 同步更新事件通知，可为空
+	archiveDir string        // 上传归档的暂存目录
+	archiveTTL time.Duration // 失败或取消的任务保留暂存归档的时间
 }
 
 // NewService 创建镜像导入服务
-// localRegistryHost 示例：localhost:8080
-func NewService(localRegistryHost string) *Service {
+// 导入的镜像直接写入 reg 对应的存储。
+func NewService(reg *registry.Registry) *Service {
 	return &Service{
-		db:                database.GetDB(),
-		localRegistryHost: localRegistryHost,
+		db:         database.GetDB(),
+		reg:        reg,
+		workers:    DefaultWorkers,
+		perProject: DefaultPerProjectLimit,
+		running:    make(map[string]int),
+		runs:       make(map[string]*taskRun),
+		archiveDir: defaultArchiveDir,
+		archiveTTL: DefaultArchiveTTL,
 	}
 }
 
@@ -70,18 +92,57 @@ func (s *Service) ImportImageFromURL(
 		uid = userID.String()
 	}
 
+	platforms, err := normalizePlatforms(req.Platforms)
+	if err != nil {
+		return nil, err
+	}
+
 	task := models.NewImportTask(projectID, uid, source, normalized, targetImage, targetTag)
+	task.ProjectName = projectName
+	task.Platforms = platforms
 	if req.Auth != nil {
 		task.AuthUsername = strings.TrimSpace(req.Auth.Username)
 		task.AuthPassword = req.Auth.Password
 	}
 
-	if err := s.db.WithContext(ctx).Create(task).Error; err != nil {
+	var sub *models.SyncSubscription
+	if req.Sync != nil {
+		if strings.Contains(normalized, "@") {
+			return nil, fmt.Errorf("%w: digest 引用不会变化，请使用 tag 引用", ErrInvalidSync)
+		}
+		interval, err := syncInterval(req.Sync.IntervalSeconds)
+		if err != nil {
+			return nil, err
+		}
+		var count int64
+		if err := s.db.WithContext(ctx).Model(&models.SyncSubscription{}).
+			Where("project_id = ? AND target_image = ? AND target_tag = ?", projectID, targetImage, targetTag).
+			Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			return nil, ErrSubscriptionExists
+		}
+		sub = models.NewSyncSubscription(task, interval)
+		now := time.Now()
+		sub.LastCheckedAt = &now
+		task.SubscriptionID = sub.ID
+	}
+
+	if err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(task).Error; err != nil {
+			return err
+		}
+		if sub != nil {
+			return tx.Create(sub).Error
+		}
+		return nil
+	}); err != nil {
 		return nil, fmt.Errorf("创建导入任务失败: %w", err)
 	}
 
-	// 异步执行导入，不阻塞 API 响应
-	go s.executeImport(context.Background(), task.ID, projectName)
+	// 进入等待队列异步执行，不阻塞 API 响应
+	s.enqueue(task)
 
 	return task, nil
 }
@@ -93,7 +154,7 @@ func (s *Service) GetTask(ctx context.Context, projectID, taskID string) (*model
 		Where("id = ? AND project_id = ?", taskID, projectID).
 		First(&task).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("任务不存在")
+			return nil, ErrTaskNotFound
 		}
 		return nil, err
 	}
@@ -131,84 +192,124 @@ func (s *Service) ListTasks(ctx context.Context, projectID string, page, pageSiz
 }
 
 // executeImport 实际执行导入任务
-func (s *Service) executeImport(ctx context.Context, taskID string, projectName string) {
+// 以 Registry API 客户端直接从源仓库拉取 Manifest 与 Blob 写入本地存储，不依赖 Docker 守护进程。
+// ctx 被取消（任务取消）时中断传输；任务状态只在 pending/running 时更新，已取消的任务不会被覆盖。
+func (s *Service) executeImport(ctx context.Context, taskID string) {
+	dbCtx := context.WithoutCancel(ctx)
+
+	// pending -> running：任务可能已在排队期间被取消
+	now := time.Now()
+	result := s.db.WithContext(dbCtx).Model(&models.ImportTask{}).
+		Where("id = ? AND status = ?", taskID, string(models.TaskStatusPending)).
+		Updates(map[string]interface{}{
+			"status":     string(models.TaskStatusRunning),
+			"progress":   0,
+			"message":    "开始导入镜像...",
+			"manifests":  nil,
+			"attempts":   gorm.Expr("attempts + 1"),
+			"updated_at": now,
+		})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return
+	}
+
 	var task models.ImportTask
-	if err := s.db.WithContext(ctx).First(&task, "id = ?", taskID).Error; err != nil {
+	err := s.db.WithContext(dbCtx).First(&task, "id = ?", taskID).Error
+	if err != nil {
 		return
 	}
 
-	updateStatus := func(status models.ImportTaskStatus, progress int, msg string) {
-		now := time.Now()
-		updates := map[string]interface{}{
-			"status":     string(status),
-			"progress":   progress,
-			"message":    msg,
-			"updated_at": now,
-		}
-		if status == models.TaskStatusSuccess || status == models.TaskStatusFailed {
-			updates["completed_at"] = now
+	var summary string
+	if task.SourceType == models.SourceTypeArchive {
+		summary, err = s.importArchive(ctx, &task)
+	} else {
+		summary, err = s.copyImage(ctx, &task, task.ProjectName)
+	}
+	if ctx.Err() != nil {
+		log.Printf(`{"timestamp":"%s","level":"info","module":"imageimport","operation":"import","task_id":"%s","source":"%s","cancelled":true}`, time.Now().Format(time.RFC3339), task.ID, task.NormalizedSource)
+		return
+	}
+	if err != nil {
+		log.Printf(`{"timestamp":"%s","level":"error","module":"imageimport","operation":"import","task_id":"%s","source":"%s","error":"%v"}`, time.Now().Format(time.RFC3339), task.ID, task.NormalizedSource, err)
+		s.fail(dbCtx, task.ID, err, importFailureMessage(err))
+		if task.SubscriptionID != "" {
+			s.completeSync(dbCtx, &task, err)
 		}
-		_ = s.db.WithContext(ctx).Model(&models.ImportTask{}).
-			Where("id = ?", task.ID).
-			Updates(updates).Error
+		return
 	}
 
-	fail := func(err error, msg string) {
-		now := time.Now()
-		_ = s.db.WithContext(ctx).Model(&models.ImportTask{}).
-			Where("id = ?", task.ID).
-			Updates(map[string]interface{}{
-				"status":       string(models.TaskStatusFailed),
-				"progress":     100,
-				"message":      msg,
-				"error":        err.Error(),
-				"completed_at": now,
-				"updated_at":   now,
-			}).Error
-	}
-
-	updateStatus(models.TaskStatusRunning, 10, "开始导入镜像...")
-
-	// 解析 registry host
-	registryHost := extractRegistryHost(task.NormalizedSource)
-
-	// 如提供认证信息，先登录源仓库
-	if task.AuthUsername != "" && task.AuthPassword != "" && registryHost != "" {
-		updateStatus(models.TaskStatusRunning, 20, "正在登录源镜像仓库...")
-		loginCmd := exec.CommandContext(ctx, "docker", "login", registryHost, "-u", task.AuthUsername, "--password-stdin")
-		loginCmd.Stdin = strings.NewReader(task.AuthPassword)
-		if output, err := loginCmd.CombinedOutput(); err != nil {
-			fail(fmt.Errorf("docker login 失败: %v, output=%s", err, strings.TrimSpace(string(output))), "登录源镜像仓库失败")
-			return
-		}
+	log.Printf(`{"timestamp":"%s","level":"info","module":"imageimport","operation":"import","task_id":"%s","source":"%s","target":"%s/%s:%s"}`, time.Now().Format(time.RFC3339), task.ID, task.NormalizedSource, task.ProjectName, task.TargetImage, task.TargetTag)
+	s.updateStatus(dbCtx, task.ID, models.TaskStatusSuccess, 100, summary)
+	if task.ArchivePath != "" {
+		_ = os.Remove(task.ArchivePath)
+	}
+	if task.SubscriptionID != "" {
+		s.completeSync(dbCtx, &task, nil)
 	}
+}
 
-	// 拉取镜像
-	updateStatus(models.TaskStatusRunning, 30, "正在拉取源镜像...")
-	if output, err := exec.CommandContext(ctx, "docker", "pull", task.NormalizedSource).CombinedOutput(); err != nil {
-		fail(fmt.Errorf("docker pull 失败: %v, output=%s", err, strings.TrimSpace(string(output))), "拉取源镜像失败")
+// notifyPushed 导入写入 tag 后发送推送事件（按 digest 写入的子 Manifest 不发送）
+func (s *Service) notifyPushed(task *models.ImportTask, repo, reference, digest string) {
+	if s.notifier == nil {
 		return
 	}
-
-	// 重新标记到本地仓库
-	updateStatus(models.TaskStatusRunning, 60, "正在重新标记镜像...")
-	localRef := fmt.Sprintf("%s/%s/%s:%s", s.localRegistryHost, projectName, task.TargetImage, task.TargetTag)
-	if output, err := exec.CommandContext(ctx, "docker", "tag", task.NormalizedSource, localRef).CombinedOutput(); err != nil {
-		fail(fmt.Errorf("docker tag 失败: %v, output=%s", err, strings.TrimSpace(string(output))), "重新标记镜像失败")
+	if isDigest, _ := registry.ParseReference(reference); isDigest {
 		return
 	}
+	var size int64
+	if td, err := s.reg.GetTag(context.Background(), repo, reference); err == nil {
+		size = td.Size
+	}
+	if err := s.notifier.PushPushEvent(task.ProjectID, repo, reference, digest, size, task.UserID, ""); err != nil {
+		log.Printf(`{"timestamp":"%s","level":"warn","module":"imageimport","operation":"push_event","task_id":"%s","repository":"%s","tag":"%s","error":"%v"}`, time.Now().Format(time.RFC3339), task.ID, repo, reference, err)
+	}
+}
 
-	// 推送到本地仓库
-	updateStatus(models.TaskStatusRunning, 80, "正在推送镜像到本地仓库...")
-	if output, err := exec.CommandContext(ctx, "docker", "push", localRef).CombinedOutput(); err != nil {
-		fail(fmt.Errorf("docker push 失败: %v, output=%s", err, strings.TrimSpace(string(output))), "推送镜像到本地仓库失败")
-		return
+// activeStatuses 未结束的任务状态（只有这些状态的任务会被执行过程更新）
+var activeStatuses = []string{string(models.TaskStatusPending), string(models.TaskStatusRunning)}
+
+// updateStatus 更新任务状态；成功或失败时记录完成时间
+func (s *Service) updateStatus(ctx context.Context, taskID string, status models.ImportTaskStatus, progress int, msg string) {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":     string(status),
+		"progress":   progress,
+		"message":    msg,
+		"updated_at": now,
+	}
+	if status == models.TaskStatusSuccess || status == models.TaskStatusFailed {
+		updates["completed_at"] = now
 	}
+	_ = s.db.WithContext(ctx).Model(&models.ImportTask{}).
+		Where("id = ? AND status IN ?", taskID, activeStatuses).
+		Updates(updates).Error
+}
+
+// updateProgress 更新运行中任务的进度与消息
+func (s *Service) updateProgress(ctx context.Context, taskID string, progress int, msg string) {
+	s.updateStatus(ctx, taskID, models.TaskStatusRunning, progress, msg)
+}
 
-	// 简单清理本地临时镜像（最佳努力）
-	_, _ = exec.CommandContext(ctx, "docker", "rmi", localRef).CombinedOutput()
+// recordManifests 记录本次导入的源 digest 与复制的（子）Manifest
+func (s *Service) recordManifests(ctx context.Context, taskID, sourceDigest string, manifests []models.ImportedManifest) {
+	_ = s.db.WithContext(ctx).Model(&models.ImportTask{ID: taskID}).
+		Select("SourceDigest", "Manifests").
+		Updates(&models.ImportTask{SourceDigest: sourceDigest, Manifests: manifests}).Error
+}
 
-	updateStatus(models.TaskStatusSuccess, 100, "镜像导入完成")
+// fail 标记任务失败
+func (s *Service) fail(ctx context.Context, taskID string, err error, msg string) {
+	now := time.Now()
+	_ = s.db.WithContext(ctx).Model(&models.ImportTask{}).
+		Where("id = ? AND status IN ?", taskID, activeStatuses).
+		Updates(map[string]interface{}{
+			"status":       string(models.TaskStatusFailed),
+			"progress":     100,
+			"message":      msg,
+			"error":        err.Error(),
+			"completed_at": now,
+			"updated_at":   now,
+		}).Error
 }
 
 // normalizeImageURL 根据文档规则规范化镜像URL
@@ -217,12 +318,22 @@ func (s *Service) executeImport(ctx context.Context, taskID string, projectName
 //	nginx:latest           -> docker.io/library/nginx:latest
 //	user/nginx:1.20        -> docker.io/user/nginx:1.20
 //	ghcr.io/owner/repo:tag -> ghcr.io/owner/repo:tag
+//	nginx@sha256:<hex>     -> docker.io/library/nginx@sha256:<hex>
 func normalizeImageURL(source string) string {
 	s := strings.TrimSpace(source)
 	if s == "" {
 		return s
 	}
 
+	// digest 引用：只规范化名称部分
+	if name, digest, ok := strings.Cut(s, "@"); ok {
+		normalized := normalizeImageURL(name)
+		if idx := strings.LastIndex(normalized, ":"); idx > strings.LastIndex(normalized, "/") {
+			normalized = normalized[:idx]
+		}
+		return normalized + "@" + digest
+	}
+
 	// 已包含显式 registry 前缀（包含 . 或 :），直接返回
 	if idx := strings.IndexRune(s, '/'); idx > 0 {
 		hostPart := s[:idx]
@@ -254,7 +365,8 @@ func inferTargetImageFromSource(normalized string) string {
 	if idx := strings.IndexRune(s, '/'); idx > 0 {
 		s = s[idx+1:]
 	}
-	// 去掉 tag
+	// 去掉 digest 与 tag
+	s, _, _ = strings.Cut(s, "@")
 	if idx := strings.LastIndex(s, ":"); idx > -1 && idx > strings.LastIndex(s, "/") {
 		s = s[:idx]
 	}
@@ -265,23 +377,13 @@ func inferTargetImageFromSource(normalized string) string {
 	return parts[len(parts)-1]
 }
 
-// inferTagFromSource 从规范化后的URL推断 tag
+// inferTagFromSource 从规范化后的URL推断 tag（digest 引用返回空）
 func inferTagFromSource(normalized string) string {
+	if strings.Contains(normalized, "@") {
+		return ""
+	}
 	if idx := strings.LastIndex(normalized, ":"); idx > -1 && idx > strings.LastIndex(normalized, "/") {
 		return normalized[idx+1:]
 	}
 	return ""
 }
-
-// extractRegistryHost 从规范化URL中提取 registry host
-// 例如 docker.io/library/nginx:latest -> docker.io
-func extractRegistryHost(normalized string) string {
-	if normalized == "" {
-		return ""
-	}
-	parts := strings.SplitN(normalized, "/", 2)
-	if len(parts) < 2 {
-		return ""
-	}
-	return parts[0]
-}
@@ -0,0 +1,366 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cyp-registry/registry/src/modules/imageimport/models"
+	"github.com/cyp-registry/registry/src/modules/registry"
+	"github.com/cyp-registry/registry/src/pkg/registryclient"
+)
+
+// 进度区间：解析 Manifest 占 0-10%，复制 Blob 占 10-95%，写入 Manifest 占 95-100%
+const (
+	progressResolved = 10
+	progressBlobsEnd = 95
+)
+
+// progressInterval 传输过程中进度写库的最小间隔
+const progressInterval = time.Second
+
+// maxConfigSize 读取镜像配置（用于识别平台）的最大长度
+const maxConfigSize = 4 * 1024 * 1024
+
+// imageReference 解析后的源镜像引用
+type imageReference struct {
+	Host       string // registry 地址，例如 docker.io、ghcr.io、localhost:5000
+	Repository string // 仓库路径，例如 library/nginx
+	Reference  string // tag 或 digest
+}
+
+// parseImageReference 解析规范化后的镜像URL
+// 例如 docker.io/library/nginx:1.25 / ghcr.io/owner/repo@sha256:...
+func parseImageReference(normalized string) (*imageReference, error) {
+	host, rest, ok := strings.Cut(normalized, "/")
+	if !ok || host == "" || rest == "" {
+		return nil, fmt.Errorf("无法解析镜像地址: %s", normalized)
+	}
+
+	ref := &imageReference{Host: host, Repository: rest, Reference: "latest"}
+	if repo, digest, ok := strings.Cut(rest, "@"); ok {
+		ref.Repository, ref.Reference = repo, digest
+	} else if idx := strings.LastIndex(rest, ":"); idx > strings.LastIndex(rest, "/") {
+		ref.Repository, ref.Reference = rest[:idx], rest[idx+1:]
+	}
+	if ref.Repository == "" || ref.Reference == "" {
+		return nil, fmt.Errorf("无法解析镜像地址: %s", normalized)
+	}
+	return ref, nil
+}
+
+// sourceEndpoint 源仓库 API 地址
+// 与 Docker 的默认行为一致：localhost / 127.0.0.1 使用 http，其余使用 https。
+func sourceEndpoint(host string) string {
+	name := host
+	if i := strings.LastIndex(name, ":"); i > 0 {
+		name = name[:i]
+	}
+	if name == "localhost" || name == "127.0.0.1" {
+		return "http://" + host
+	}
+	return "https://" + host
+}
+
+// importManifest 待写入本地的 Manifest
+type importManifest struct {
+	raw       []byte
+	mediaType string
+	reference string
+}
+
+// importPlan 一次导入需要写入的内容
+// manifests 按写入顺序排列（子 Manifest 在前，顶层 Manifest 最后），
+// blobs 为去重后目标仓库尚不可见的 Blob。
+type importPlan struct {
+	manifests []importManifest
+	blobs     []registry.Descriptor
+	skipped   int
+	total     int64
+}
+
+// importer 单个导入任务的执行上下文
+type importer struct {
+	svc    *Service
+	client *registryclient.Client
+	source string // 源仓库（上游实际仓库名）
+	target string // 目标仓库 <project>/<image>
+
+	platforms []platform // 要导入的平台，为空表示全部
+
+	seen map[string]bool
+	plan importPlan
+}
+
+// copyImage 从源仓库复制镜像到本地存储，返回执行摘要
+func (s *Service) copyImage(ctx context.Context, task *models.ImportTask, projectName string) (string, error) {
+	ref, err := parseImageReference(task.NormalizedSource)
+	if err != nil {
+		return "", err
+	}
+	client, err := registryclient.New(sourceEndpoint(ref.Host), registryclient.Options{
+		Username: task.AuthUsername,
+		Password: task.AuthPassword,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	im := &importer{
+		svc:    s,
+		client: client,
+		source: client.RepositoryName(ref.Repository),
+		target: projectName + "/" + task.TargetImage,
+		seen:   make(map[string]bool),
+	}
+
+	for _, p := range task.Platforms {
+		want, err := parsePlatform(p)
+		if err != nil {
+			return "", err
+		}
+		im.platforms = append(im.platforms, want)
+	}
+
+	s.updateProgress(ctx, task.ID, 5, "正在获取源镜像 Manifest...")
+	raw, desc, err := client.GetManifest(ctx, im.source, ref.Reference)
+	if err != nil {
+		return "", fmt.Errorf("获取源镜像 Manifest 失败: %w", err)
+	}
+	mediaType := desc.MediaType
+	if mediaType == "" {
+		mediaType = registry.ManifestMediaType(raw)
+	}
+
+	var copied []models.ImportedManifest
+	if isIndexMediaType(mediaType) {
+		raw, copied, err = selectPlatforms(raw, im.platforms)
+	} else {
+		copied, err = im.singlePlatform(ctx, raw, mediaType, desc.Digest)
+	}
+	if err != nil {
+		return "", err
+	}
+	task.SourceDigest = desc.Digest
+	s.recordManifests(ctx, task.ID, desc.Digest, copied)
+
+	if err := im.resolve(ctx, raw, mediaType, task.TargetTag); err != nil {
+		return "", err
+	}
+
+	s.updateProgress(ctx, task.ID, progressResolved, fmt.Sprintf("需要下载 %d 个 Blob（%s），%d 个已存在", len(im.plan.blobs), formatBytes(im.plan.total), im.plan.skipped))
+	tracker := &progressTracker{svc: s, ctx: ctx, taskID: task.ID, total: im.plan.total}
+	for _, blob := range im.plan.blobs {
+		if err := im.copyBlob(ctx, blob, tracker); err != nil {
+			return "", fmt.Errorf("复制 Blob %s 失败: %w", blob.Digest, err)
+		}
+	}
+
+	s.updateProgress(ctx, task.ID, progressBlobsEnd, "正在写入 Manifest...")
+	for _, m := range im.plan.manifests {
+		digest, err := s.reg.PutManifestRaw(ctx, im.target, m.reference, m.raw, m.mediaType)
+		if err != nil {
+			return "", fmt.Errorf("写入 Manifest %s 失败: %w", m.reference, err)
+		}
+		s.notifyPushed(task, im.target, m.reference, digest)
+	}
+
+	return fmt.Sprintf("镜像导入完成：下载 %d 个 Blob（%s），跳过 %d 个已存在的 Blob", len(im.plan.blobs), formatBytes(tracker.done), im.plan.skipped), nil
+}
+
+// resolve 递归解析 Manifest（索引会展开全部子 Manifest），生成导入计划
+// 只有目标仓库已可见的 Blob 才会跳过；仅存在于其他仓库的 Blob 仍需下载校验，
+// 避免通过伪造的 Manifest 引用其他项目的数据。
+func (im *importer) resolve(ctx context.Context, raw []byte, mediaType, reference string) error {
+	if mediaType == "" {
+		mediaType = registry.ManifestMediaType(raw)
+	}
+	blobs, children, err := registry.ManifestReferences(raw, mediaType)
+	if err != nil {
+		return err
+	}
+
+	for _, child := range children {
+		childRaw, desc, err := im.client.GetManifest(ctx, im.source, child.Digest)
+		if err != nil {
+			return fmt.Errorf("获取子 Manifest %s 失败: %w", child.Digest, err)
+		}
+		if err := im.resolve(ctx, childRaw, desc.MediaType, child.Digest); err != nil {
+			return err
+		}
+	}
+
+	for _, blob := range blobs {
+		if im.seen[blob.Digest] {
+			continue
+		}
+		im.seen[blob.Digest] = true
+
+		exists, err := im.svc.reg.CheckBlob(ctx, im.target, blob.Digest)
+		if err != nil {
+			return err
+		}
+		if exists {
+			im.plan.skipped++
+			continue
+		}
+		im.plan.blobs = append(im.plan.blobs, blob)
+		im.plan.total += blob.Size
+	}
+
+	im.plan.manifests = append(im.plan.manifests, importManifest{raw: raw, mediaType: mediaType, reference: reference})
+	return nil
+}
+
+// singlePlatform 单平台镜像：从镜像配置读取平台，并校验是否满足平台选择
+// 未选择平台时读取失败不影响导入（仅记录中不显示平台）。
+func (im *importer) singlePlatform(ctx context.Context, raw []byte, mediaType, digest string) ([]models.ImportedManifest, error) {
+	copied := []models.ImportedManifest{{Digest: digest, MediaType: mediaType, Size: int64(len(raw))}}
+
+	p, err := im.configPlatform(ctx, raw)
+	if err != nil {
+		if len(im.platforms) > 0 {
+			return nil, fmt.Errorf("读取源镜像平台失败: %w", err)
+		}
+		return copied, nil
+	}
+	copied[0].Platform = p.String()
+
+	if len(im.platforms) == 0 {
+		return copied, nil
+	}
+	for _, want := range im.platforms {
+		if p.matches(want) {
+			return copied, nil
+		}
+	}
+	return nil, fmt.Errorf("%w：源镜像为单平台镜像（%s）", ErrPlatformNotFound, p.String())
+}
+
+// configPlatform 下载镜像配置并解析其中的平台信息
+func (im *importer) configPlatform(ctx context.Context, raw []byte) (platform, error) {
+	var manifest struct {
+		Config *registry.Descriptor `json:"config"`
+	}
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return platform{}, err
+	}
+	if manifest.Config == nil || manifest.Config.Digest == "" {
+		return platform{}, errors.New("manifest has no config")
+	}
+
+	body, _, err := im.client.GetBlob(ctx, im.source, manifest.Config.Digest)
+	if err != nil {
+		return platform{}, err
+	}
+	defer body.Close()
+
+	var p platform
+	if err := json.NewDecoder(io.LimitReader(body, maxConfigSize)).Decode(&p); err != nil {
+		return platform{}, err
+	}
+	return p, nil
+}
+
+// copyBlob 下载单个 Blob 并直接写入本地存储
+func (im *importer) copyBlob(ctx context.Context, blob registry.Descriptor, tracker *progressTracker) error {
+	body, _, err := im.client.GetBlob(ctx, im.source, blob.Digest)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	_, err = im.svc.reg.PutBlob(ctx, im.target, blob.Digest, blob.Size, &progressReader{r: body, tracker: tracker})
+	return err
+}
+
+// progressTracker 按已传输字节数计算任务进度并节流写库
+type progressTracker struct {
+	svc    *Service
+	ctx    context.Context
+	taskID string
+	total  int64
+
+	mu        sync.Mutex
+	done      int64
+	progress  int
+	lastWrite time.Time
+}
+
+// add 累加已传输字节数
+func (t *progressTracker) add(n int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.done += n
+	progress := progressBlobsEnd
+	if t.total > 0 && t.done < t.total {
+		progress = progressResolved + int(int64(progressBlobsEnd-progressResolved)*t.done/t.total)
+	}
+	if progress == t.progress || time.Since(t.lastWrite) < progressInterval {
+		return
+	}
+	t.progress, t.lastWrite = progress, time.Now()
+	t.svc.updateProgress(t.ctx, t.taskID, progress, fmt.Sprintf("正在复制镜像数据：%s / %s", formatBytes(t.done), formatBytes(t.total)))
+}
+
+// progressReader 读取时上报进度的数据流
+type progressReader struct {
+	r       io.Reader
+	tracker *progressTracker
+}
+
+// Read 读取数据并累加进度
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.tracker.add(int64(n))
+	}
+	return n, err
+}
+
+// importFailureMessage 导入失败时展示给用户的简要原因
+func importFailureMessage(err error) string {
+	switch {
+	case errors.Is(err, registryclient.ErrUnauthorized):
+		return "源镜像仓库认证失败，请检查用户名和密码"
+	case errors.Is(err, registryclient.ErrNotFound):
+		return "源镜像不存在"
+	case errors.Is(err, registryclient.ErrUnavailable):
+		return "无法连接源镜像仓库"
+	case errors.Is(err, ErrPlatformNotFound):
+		return "源镜像不包含所选平台"
+	case errors.Is(err, ErrInvalidArchive):
+		return "镜像归档格式不合法或已损坏"
+	case errors.Is(err, os.ErrNotExist):
+		return "归档文件已不存在，请重新上传"
+	case errors.Is(err, registry.ErrProxyReadOnly):
+		return "目标项目为代理项目，不支持导入"
+	default:
+		var policyErr *registry.TagPolicyError
+		if errors.As(err, &policyErr) {
+			return "目标标签不符合项目的标签规则"
+		}
+		return "镜像导入失败"
+	}
+}
+
+// formatBytes 以可读单位格式化字节数
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cyp-registry/registry/src/modules/imageimport/models"
+	"github.com/cyp-registry/registry/src/modules/registry"
+)
+
+// ErrInvalidPlatform 平台格式不合法
+var ErrInvalidPlatform = errors.New("平台格式不合法，应为 os/arch[/variant]，例如 linux/amd64")
+
+// ErrPlatformNotFound 源镜像不包含所选平台
+var ErrPlatformNotFound = errors.New("源镜像不包含所选平台")
+
+// attestation Manifest 的注解（BuildKit 生成的构建证明，平台为 unknown/unknown）
+const (
+	annotationReferenceType   = "vnd.docker.reference.type"
+	annotationReferenceDigest = "vnd.docker.reference.digest"
+)
+
+// platform 镜像平台
+type platform struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// String 返回 os/arch[/variant]
+func (p platform) String() string {
+	if p.OS == "" || p.Architecture == "" || p.OS == "unknown" {
+		return ""
+	}
+	if p.Variant != "" {
+		return p.OS + "/" + p.Architecture + "/" + p.Variant
+	}
+	return p.OS + "/" + p.Architecture
+}
+
+// matches 判断 p 是否满足选择条件 want（want 未指定 variant 时匹配任意 variant）
+func (p platform) matches(want platform) bool {
+	return p.OS == want.OS && p.Architecture == want.Architecture &&
+		(want.Variant == "" || p.Variant == want.Variant)
+}
+
+// parsePlatform 解析 os/arch[/variant]
+func parsePlatform(s string) (platform, error) {
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(s)), "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return platform{}, fmt.Errorf("%w: %q", ErrInvalidPlatform, s)
+	}
+	p := platform{OS: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		if parts[2] == "" {
+			return platform{}, fmt.Errorf("%w: %q", ErrInvalidPlatform, s)
+		}
+		p.Variant = parts[2]
+	}
+	return p, nil
+}
+
+// normalizePlatforms 校验并去重平台列表，返回规范化后的字符串形式
+func normalizePlatforms(list []string) ([]string, error) {
+	var out []string
+	seen := make(map[string]bool)
+	for _, s := range list {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		p, err := parsePlatform(s)
+		if err != nil {
+			return nil, err
+		}
+		if key := p.String(); !seen[key] {
+			seen[key] = true
+			out = append(out, key)
+		}
+	}
+	return out, nil
+}
+
+// indexEntry 索引中的子 Manifest 描述
+type indexEntry struct {
+	registry.Descriptor
+	Platform *platform `json:"platform,omitempty"`
+}
+
+// isIndexMediaType 是否为多架构索引
+func isIndexMediaType(mediaType string) bool {
+	return mediaType == registry.MediaTypeOCIManifestIndex || mediaType == registry.MediaTypeDocker2ManifestList
+}
+
+// selectPlatforms 按平台筛选多架构索引
+// wants 为空时保留全部子 Manifest 并原样返回索引；否则生成只包含所选平台的新索引
+// （引用被选中子 Manifest 的构建证明一并保留），其余字段保持不变。
+// 返回写入本地的索引内容与被复制的子 Manifest 列表。
+func selectPlatforms(raw []byte, wants []platform) ([]byte, []models.ImportedManifest, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, nil, fmt.Errorf("invalid index: %w", err)
+	}
+	var entries []json.RawMessage
+	if err := json.Unmarshal(doc["manifests"], &entries); err != nil {
+		return nil, nil, fmt.Errorf("invalid index: %w", err)
+	}
+
+	parsed := make([]indexEntry, len(entries))
+	for i, entry := range entries {
+		if err := json.Unmarshal(entry, &parsed[i]); err != nil {
+			return nil, nil, fmt.Errorf("invalid index entry: %w", err)
+		}
+	}
+
+	keep := make([]bool, len(entries))
+	selected := make(map[string]bool)
+	for i, e := range parsed {
+		if len(wants) == 0 {
+			keep[i] = true
+			continue
+		}
+		if e.Platform == nil {
+			continue
+		}
+		for _, want := range wants {
+			if e.Platform.matches(want) {
+				keep[i] = true
+				selected[e.Digest] = true
+				break
+			}
+		}
+	}
+	if len(wants) > 0 {
+		// 构建证明随其描述的镜像一起保留
+		for i, e := range parsed {
+			if !keep[i] && e.Annotations[annotationReferenceType] != "" && selected[e.Annotations[annotationReferenceDigest]] {
+				keep[i] = true
+			}
+		}
+		if len(selected) == 0 {
+			return nil, nil, fmt.Errorf("%w，可用平台: %s", ErrPlatformNotFound, strings.Join(indexPlatforms(parsed), ", "))
+		}
+	}
+
+	var kept []json.RawMessage
+	var copied []models.ImportedManifest
+	for i, e := range parsed {
+		if !keep[i] {
+			continue
+		}
+		kept = append(kept, entries[i])
+		m := models.ImportedManifest{Digest: e.Digest, MediaType: e.MediaType, Size: e.Size}
+		if e.Platform != nil {
+			m.Platform = e.Platform.String()
+		}
+		copied = append(copied, m)
+	}
+	if len(kept) == len(entries) {
+		return raw, copied, nil
+	}
+
+	filtered, err := json.Marshal(kept)
+	if err != nil {
+		return nil, nil, err
+	}
+	doc["manifests"] = filtered
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+	return out, copied, nil
+}
+
+// indexPlatforms 列出索引中的全部平台（用于错误提示）
+func indexPlatforms(entries []indexEntry) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, e := range entries {
+		if e.Platform == nil {
+			continue
+		}
+		if s := e.Platform.String(); s != "" && !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
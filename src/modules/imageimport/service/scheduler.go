@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/cyp-registry/registry/src/modules/imageimport/models"
+)
+
+// 导入任务并发控制的默认值
+const (
+	// DefaultWorkers 全局同时执行的导入任务数
+	DefaultWorkers = 4
+	// DefaultPerProjectLimit 单个项目同时执行的导入任务数
+	DefaultPerProjectLimit = 2
+)
+
+// ErrTaskNotFound 任务不存在
+var ErrTaskNotFound = errors.New("任务不存在")
+
+// ErrTaskNotCancellable 任务已结束，无法取消
+var ErrTaskNotCancellable = errors.New("只能取消等待中或执行中的任务")
+
+// ErrTaskNotRetryable 任务未失败，无法重试
+var ErrTaskNotRetryable = errors.New("只能重试失败或已取消的任务")
+
+// queuedTask 排队中的任务
+type queuedTask struct {
+	id        string
+	projectID string
+}
+
+// taskRun 一次执行；同一任务取消后重试时新旧两次执行以不同的 taskRun 区分
+type taskRun struct {
+	cancel context.CancelFunc
+	// requeue 执行尚未退出时任务被重试：退出后重新排队，而不是与旧的执行同时运行
+	requeue bool
+}
+
+// SetConcurrency 设置全局与单项目的并发上限（<=0 表示使用默认值）
+func (s *Service) SetConcurrency(workers, perProject int) {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	if perProject <= 0 {
+		perProject = DefaultPerProjectLimit
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.workers, s.perProject = workers, perProject
+	s.dispatchLocked()
+}
+
+// enqueue 任务进入等待队列，有空闲名额时立即开始执行
+func (s *Service) enqueue(task *models.ImportTask) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queue = append(s.queue, queuedTask{id: task.ID, projectID: task.ProjectID})
+	s.dispatchLocked()
+}
+
+// dispatchLocked 按先进先出顺序启动不超过全局与项目并发上限的任务（调用方持有 s.mu）
+// 所在项目已满的任务保留在队列中，不阻塞其他项目的任务。
+func (s *Service) dispatchLocked() {
+	for i := 0; i < len(s.queue) && s.active < s.workers; {
+		qt := s.queue[i]
+		if s.running[qt.projectID] >= s.perProject {
+			i++
+			continue
+		}
+		s.queue = append(s.queue[:i], s.queue[i+1:]...)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		run := &taskRun{cancel: cancel}
+		s.active++
+		s.running[qt.projectID]++
+		s.runs[qt.id] = run
+		go s.runQueued(ctx, qt, run)
+	}
+}
+
+// runQueued 执行任务并在结束后释放名额
+func (s *Service) runQueued(ctx context.Context, qt queuedTask, run *taskRun) {
+	defer func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		run.cancel()
+		if s.runs[qt.id] == run {
+			delete(s.runs, qt.id)
+		}
+		if run.requeue {
+			s.queue = append(s.queue, qt)
+		}
+		s.active--
+		if s.running[qt.projectID]--; s.running[qt.projectID] <= 0 {
+			delete(s.running, qt.projectID)
+		}
+		s.dispatchLocked()
+	}()
+	s.executeImport(ctx, qt.id)
+}
+
+// requeue 重新执行任务；上一次执行（已取消）尚未退出时等其退出后再排队
+func (s *Service) requeue(task *models.ImportTask) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if run, ok := s.runs[task.ID]; ok {
+		run.requeue = true
+		return
+	}
+	s.queue = append(s.queue, queuedTask{id: task.ID, projectID: task.ProjectID})
+	s.dispatchLocked()
+}
+
+// CancelTask 取消等待中或执行中的任务
+// 执行中的任务会中断正在进行的下载，已写入的 Blob 保留（重试时跳过）。
+func (s *Service) CancelTask(ctx context.Context, projectID, taskID string) (*models.ImportTask, error) {
+	task, err := s.GetTask(ctx, projectID, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	result := s.db.WithContext(ctx).Model(&models.ImportTask{}).
+		Where("id = ? AND status IN ?", task.ID, []string{string(models.TaskStatusPending), string(models.TaskStatusRunning)}).
+		Updates(map[string]interface{}{
+			"status":       string(models.TaskStatusCancelled),
+			"message":      "任务已取消",
+			"completed_at": now,
+			"updated_at":   now,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrTaskNotCancellable
+	}
+
+	s.mu.Lock()
+	if run, ok := s.runs[task.ID]; ok {
+		run.cancel()
+		run.requeue = false
+	}
+	for i, qt := range s.queue {
+		if qt.id == task.ID {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	log.Printf(`{"timestamp":"%s","level":"info","module":"imageimport","operation":"cancel","task_id":"%s"}`, time.Now().Format(time.RFC3339), task.ID)
+	return s.GetTask(ctx, projectID, taskID)
+}
+
+// RetryTask 使用原有的源地址、凭据与平台选择重新执行失败或已取消的任务
+func (s *Service) RetryTask(ctx context.Context, projectID, projectName, taskID string) (*models.ImportTask, error) {
+	task, err := s.GetTask(ctx, projectID, taskID)
+	if err != nil {
+		return nil, err
+	}
+	if task.SourceType == models.SourceTypeArchive && task.ArchivePath == "" {
+		return nil, ErrArchiveExpired
+	}
+
+	result := s.db.WithContext(ctx).Model(&models.ImportTask{}).
+		Where("id = ? AND status IN ? AND archive_path = ?", task.ID, []string{string(models.TaskStatusFailed), string(models.TaskStatusCancelled)}, task.ArchivePath).
+		Updates(map[string]interface{}{
+			"status":       string(models.TaskStatusPending),
+			"progress":     0,
+			"message":      "任务已重新排队",
+			"error":        "",
+			"project_name": projectName,
+			"completed_at": nil,
+			"updated_at":   time.Now(),
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrTaskNotRetryable
+	}
+
+	s.requeue(task)
+	log.Printf(`{"timestamp":"%s","level":"info","module":"imageimport","operation":"retry","task_id":"%s"}`, time.Now().Format(time.RFC3339), task.ID)
+	return s.GetTask(ctx, projectID, taskID)
+}
+
+// Recover 服务启动时恢复未完成的任务，返回重新排队的任务数
+// 上次进程退出时仍为 running 的任务重置为 pending，与 pending 任务一起按创建时间重新排队。
+func (s *Service) Recover(ctx context.Context) (int, error) {
+	if err := s.db.WithContext(ctx).Model(&models.ImportTask{}).
+		Where("status = ?", string(models.TaskStatusRunning)).
+		Updates(map[string]interface{}{
+			"status":     string(models.TaskStatusPending),
+			"message":    "服务重启，任务已重新排队",
+			"updated_at": time.Now(),
+		}).Error; err != nil {
+		return 0, err
+	}
+
+	var tasks []models.ImportTask
+	if err := s.db.WithContext(ctx).
+		Where("status = ?", string(models.TaskStatusPending)).
+		Order("created_at ASC").
+		Find(&tasks).Error; err != nil {
+		return 0, err
+	}
+
+	recovered := 0
+	for i := range tasks {
+		task := &tasks[i]
+		if task.ProjectName == "" {
+			// 早期版本创建的任务未记录项目名称，无法确定目标仓库
+			s.fail(ctx, task.ID, fmt.Errorf("task has no project name"), "服务重启后无法恢复任务，请重新创建")
+			continue
+		}
+		s.enqueue(task)
+		recovered++
+	}
+	return recovered, nil
+}
@@ -0,0 +1,278 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/cyp-registry/registry/src/modules/imageimport/models"
+	"github.com/cyp-registry/registry/src/pkg/audit"
+	"github.com/cyp-registry/registry/src/pkg/registryclient"
+)
+
+// 同步订阅的检查间隔
+const (
+	// DefaultSyncInterval 未指定时的检查间隔
+	DefaultSyncInterval = time.Hour
+	// MinSyncInterval 允许的最小检查间隔（避免触发上游限流）
+	MinSyncInterval = 5 * time.Minute
+)
+
+// syncTickInterval 扫描到期订阅的周期
+const syncTickInterval = time.Minute
+
+// ErrSubscriptionNotFound 订阅不存在
+var ErrSubscriptionNotFound = errors.New("同步订阅不存在")
+
+// ErrSubscriptionExists 目标镜像标签已有同步订阅
+var ErrSubscriptionExists = errors.New("目标镜像标签已存在同步订阅")
+
+// ErrInvalidSync 同步订阅参数不合法
+var ErrInvalidSync = errors.New("同步订阅参数不合法")
+
+// EventNotifier 导入与同步更新事件通知（由 Webhook 服务实现）
+type EventNotifier interface {
+	PushPushEvent(projectID, repository, tag, digest string, imageSize int64, userID, username string) error
+	PushSyncEvent(projectID, repository, tag, digest, previousDigest, source, subscriptionID, userID string) error
+}
+
+// SetNotifier 设置导入与同步更新事件的通知方式
+// 导入写入的每个 tag 都按推送通知，与客户端推送一样触发扫描、SBOM 生成、事件复制与 Webhook。
+func (s *Service) SetNotifier(n EventNotifier) {
+	s.notifier = n
+}
+
+// syncInterval 校验并返回订阅的检查间隔（秒）
+func syncInterval(seconds int) (int, error) {
+	if seconds == 0 {
+		return int(DefaultSyncInterval / time.Second), nil
+	}
+	if time.Duration(seconds)*time.Second < MinSyncInterval {
+		return 0, fmt.Errorf("%w: 检查间隔不能小于 %d 秒", ErrInvalidSync, int(MinSyncInterval/time.Second))
+	}
+	return seconds, nil
+}
+
+// ListSubscriptions 列出项目的同步订阅
+func (s *Service) ListSubscriptions(ctx context.Context, projectID string, page, pageSize int) ([]models.SyncSubscription, int64, error) {
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	var total int64
+	if err := s.db.WithContext(ctx).
+		Model(&models.SyncSubscription{}).
+		Where("project_id = ?", projectID).
+		Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var subs []models.SyncSubscription
+	if err := s.db.WithContext(ctx).
+		Where("project_id = ?", projectID).
+		Order("created_at DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&subs).Error; err != nil {
+		return nil, 0, err
+	}
+	return subs, total, nil
+}
+
+// GetSubscription 获取单个同步订阅
+func (s *Service) GetSubscription(ctx context.Context, projectID, subID string) (*models.SyncSubscription, error) {
+	var sub models.SyncSubscription
+	if err := s.db.WithContext(ctx).
+		Where("id = ? AND project_id = ?", subID, projectID).
+		First(&sub).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSubscriptionNotFound
+		}
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// SetSubscriptionPaused 暂停或恢复同步订阅
+// 恢复时立即进入下一轮检查。
+func (s *Service) SetSubscriptionPaused(ctx context.Context, projectID, subID string, paused bool) (*models.SyncSubscription, error) {
+	sub, err := s.GetSubscription(ctx, projectID, subID)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{"paused": paused}
+	if !paused {
+		updates["last_checked_at"] = nil
+	}
+	if err := s.db.WithContext(ctx).Model(sub).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	return s.GetSubscription(ctx, projectID, subID)
+}
+
+// DeleteSubscription 删除同步订阅（已导入的镜像保留）
+func (s *Service) DeleteSubscription(ctx context.Context, projectID, subID string) error {
+	sub, err := s.GetSubscription(ctx, projectID, subID)
+	if err != nil {
+		return err
+	}
+	return s.db.WithContext(ctx).Delete(sub).Error
+}
+
+// StartSync 定期检查到期的同步订阅（阻塞直到 ctx 结束，通常以 goroutine 方式调用）
+func (s *Service) StartSync(ctx context.Context) {
+	ticker := time.NewTicker(syncTickInterval)
+	defer ticker.Stop()
+
+	for {
+		s.checkSubscriptions(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkSubscriptions 检查全部到期且未暂停的订阅
+func (s *Service) checkSubscriptions(ctx context.Context, now time.Time) {
+	var subs []models.SyncSubscription
+	if err := s.db.WithContext(ctx).Where("paused = ?", false).Find(&subs).Error; err != nil {
+		log.Printf(`{"timestamp":"%s","level":"warn","module":"imageimport","operation":"sync_check","error":"%v"}`, time.Now().Format(time.RFC3339), err)
+		return
+	}
+	for i := range subs {
+		if ctx.Err() != nil {
+			return
+		}
+		if subs[i].Due(now) {
+			s.checkSubscription(ctx, &subs[i])
+		}
+	}
+}
+
+// checkSubscription 解析上游 tag 当前的 digest，与最近一次同步的 digest 不同时创建导入任务
+// 该订阅仍有未结束的导入任务时跳过本轮。
+func (s *Service) checkSubscription(ctx context.Context, sub *models.SyncSubscription) {
+	var active int64
+	if err := s.db.WithContext(ctx).Model(&models.ImportTask{}).
+		Where("subscription_id = ? AND status IN ?", sub.ID, activeStatuses).
+		Count(&active).Error; err != nil || active > 0 {
+		return
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{"last_checked_at": now}
+	defer func() {
+		_ = s.db.WithContext(ctx).Model(sub).Updates(updates).Error
+	}()
+
+	digest, err := s.upstreamDigest(ctx, sub)
+	if err != nil {
+		updates["last_error"] = fmt.Sprintf("%s: %v", importFailureMessage(err), err)
+		log.Printf(`{"timestamp":"%s","level":"warn","module":"imageimport","operation":"sync_check","subscription_id":"%s","source":"%s","error":"%v"}`, time.Now().Format(time.RFC3339), sub.ID, sub.NormalizedSource, err)
+		return
+	}
+	if digest == sub.LastDigest {
+		updates["last_error"] = ""
+		return
+	}
+
+	task := sub.NewTask()
+	task.Message = "上游镜像已更新，等待同步"
+	if err := s.db.WithContext(ctx).Create(task).Error; err != nil {
+		updates["last_error"] = err.Error()
+		return
+	}
+	updates["last_task_id"] = task.ID
+	s.enqueue(task)
+
+	log.Printf(`{"timestamp":"%s","level":"info","module":"imageimport","operation":"sync_check","subscription_id":"%s","source":"%s","previous_digest":"%s","digest":"%s","task_id":"%s"}`, time.Now().Format(time.RFC3339), sub.ID, sub.NormalizedSource, sub.LastDigest, digest, task.ID)
+}
+
+// upstreamDigest 获取上游 tag 当前指向的 digest（HEAD 请求，不计入 Docker Hub 拉取次数）
+func (s *Service) upstreamDigest(ctx context.Context, sub *models.SyncSubscription) (string, error) {
+	ref, err := parseImageReference(sub.NormalizedSource)
+	if err != nil {
+		return "", err
+	}
+	client, err := registryclient.New(sourceEndpoint(ref.Host), registryclient.Options{
+		Username: sub.AuthUsername,
+		Password: sub.AuthPassword,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	repo := client.RepositoryName(ref.Repository)
+	desc, err := client.HeadManifest(ctx, repo, ref.Reference)
+	if err != nil {
+		return "", err
+	}
+	if desc.Digest != "" {
+		return desc.Digest, nil
+	}
+	// 上游未返回 Docker-Content-Digest 时下载 Manifest 计算
+	_, desc, err = client.GetManifest(ctx, repo, ref.Reference)
+	if err != nil {
+		return "", err
+	}
+	return desc.Digest, nil
+}
+
+// completeSync 订阅任务结束后更新订阅状态
+// 上游 digest 相对上一次同步发生变化时发送 Webhook 事件并记录审计日志（首次导入不算更新）。
+func (s *Service) completeSync(ctx context.Context, task *models.ImportTask, importErr error) {
+	var sub models.SyncSubscription
+	if err := s.db.WithContext(ctx).First(&sub, "id = ?", task.SubscriptionID).Error; err != nil {
+		return
+	}
+
+	if importErr != nil {
+		_ = s.db.WithContext(ctx).Model(&sub).
+			Update("last_error", fmt.Sprintf("%s: %v", importFailureMessage(importErr), importErr)).Error
+		return
+	}
+
+	previous := sub.LastDigest
+	if err := s.db.WithContext(ctx).Model(&sub).Updates(map[string]interface{}{
+		"last_digest":    task.SourceDigest,
+		"last_synced_at": time.Now(),
+		"last_error":     "",
+	}).Error; err != nil {
+		return
+	}
+	if previous == "" || previous == task.SourceDigest {
+		return
+	}
+
+	repository := sub.ProjectName + "/" + sub.TargetImage
+	if s.notifier != nil {
+		if err := s.notifier.PushSyncEvent(sub.ProjectID, repository, sub.TargetTag, task.SourceDigest, previous, sub.NormalizedSource, sub.ID, sub.CreatedBy); err != nil {
+			log.Printf(`{"timestamp":"%s","level":"warn","module":"imageimport","operation":"sync_event","subscription_id":"%s","error":"%v"}`, time.Now().Format(time.RFC3339), sub.ID, err)
+		}
+	}
+
+	var userID *uuid.UUID
+	if id, err := uuid.Parse(sub.CreatedBy); err == nil {
+		userID = &id
+	}
+	audit.Record(ctx, "image_sync", "image", nil, userID, "", "", map[string]interface{}{
+		"subscription_id": sub.ID,
+		"task_id":         task.ID,
+		"repository":      repository,
+		"tag":             sub.TargetTag,
+		"source":          sub.NormalizedSource,
+		"previous_digest": previous,
+		"digest":          task.SourceDigest,
+	})
+}
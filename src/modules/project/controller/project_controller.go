@@ -3,9 +3,11 @@
 package project_controller
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -13,6 +15,8 @@ import (
 	"github.com/cyp-registry/registry/src/middleware"
 	"github.com/cyp-registry/registry/src/modules/project/dto"
 	project "github.com/cyp-registry/registry/src/modules/project/service"
+	"github.com/cyp-registry/registry/src/modules/registry"
+	"github.com/cyp-registry/registry/src/modules/scanner"
 	user_service "github.com/cyp-registry/registry/src/modules/user/service"
 	"github.com/cyp-registry/registry/src/pkg/errors"
 	"github.com/cyp-registry/registry/src/pkg/response"
@@ -20,15 +24,17 @@ import (
 
 // ProjectController 项目管理控制器
 type ProjectController struct {
-	svc     project.Service
-	userSvc *user_service.Service
+	svc      project.Service
+	userSvc  *user_service.Service
+	registry *registry.Registry
 }
 
 // NewProjectController 创建项目控制器
-func NewProjectController(svc project.Service, userSvc *user_service.Service) *ProjectController {
+func NewProjectController(svc project.Service, userSvc *user_service.Service, reg *registry.Registry) *ProjectController {
 	return &ProjectController{
-		svc:     svc,
-		userSvc: userSvc,
+		svc:      svc,
+		userSvc:  userSvc,
+		registry: reg,
 	}
 }
 
@@ -55,6 +61,11 @@ func (c *ProjectController) Create(ctx *gin.Context) {
 		return
 	}
 
+	if err := registry.ValidateProxyConfig(req.Proxy); err != nil {
+		response.ParamError(ctx, "invalid proxy: "+err.Error())
+		return
+	}
+
 	// 创建项目
 	p, err := c.svc.CreateProject(ctx.Request.Context(), req.Name, req.Description, userUUID.String(), req.IsPublic, req.StorageQuota)
 	if err != nil {
@@ -74,6 +85,16 @@ func (c *ProjectController) Create(ctx *gin.Context) {
 		return
 	}
 
+	// 代理项目：写入上游配置
+	if req.Proxy != nil {
+		if err := c.svc.UpdateProxy(ctx.Request.Context(), p.ID, req.Proxy); err != nil {
+			log.Printf("[ERROR] 设置代理项目上游失败: %v, 项目名: %s", err, req.Name)
+			response.InternalServerError(ctx, "failed to set project proxy")
+			return
+		}
+		p.Proxy = req.Proxy
+	}
+
 	// 返回结果
 	response.Success(ctx, gin.H{
 		"project": toProjectResponse(p),
@@ -242,17 +263,73 @@ func (c *ProjectController) Update(ctx *gin.Context) {
 		updates["storage_quota"] = *req.StorageQuota
 	}
 
-	if len(updates) == 0 {
+	// tag 规则：null 恢复默认规则
+	var tagRules *registry.TagRules
+	updateTagRules := len(req.TagRules) > 0
+	if updateTagRules && string(req.TagRules) != "null" {
+		tagRules = &registry.TagRules{}
+		if err := json.Unmarshal(req.TagRules, tagRules); err != nil {
+			response.ParamError(ctx, "invalid tag_rules: "+err.Error())
+			return
+		}
+		if err := registry.ValidateTagRules(tagRules); err != nil {
+			response.ParamError(ctx, "invalid tag_rules: "+err.Error())
+			return
+		}
+	}
+
+	// 代理配置：null 恢复为普通项目
+	var proxyCfg *registry.ProxyConfig
+	updateProxy := len(req.Proxy) > 0
+	if updateProxy && string(req.Proxy) != "null" {
+		proxyCfg = &registry.ProxyConfig{}
+		if err := json.Unmarshal(req.Proxy, proxyCfg); err != nil {
+			response.ParamError(ctx, "invalid proxy: "+err.Error())
+			return
+		}
+	}
+
+	// 漏洞拉取策略：null 恢复全局默认策略
+	var vulnPolicy *scanner.PullPolicy
+	updateVulnPolicy := len(req.VulnerabilityPolicy) > 0
+	if updateVulnPolicy && string(req.VulnerabilityPolicy) != "null" {
+		vulnPolicy = &scanner.PullPolicy{}
+		if err := json.Unmarshal(req.VulnerabilityPolicy, vulnPolicy); err != nil {
+			response.ParamError(ctx, "invalid vulnerability_policy: "+err.Error())
+			return
+		}
+		if err := scanner.ValidatePullPolicy(vulnPolicy); err != nil {
+			response.ParamError(ctx, "invalid vulnerability_policy: "+err.Error())
+			return
+		}
+	}
+
+	if len(updates) == 0 && !updateTagRules && !updateProxy && !updateVulnPolicy {
 		response.ParamError(ctx, "no fields to update")
 		return
 	}
 
-	if err := c.svc.UpdateProject(ctx.Request.Context(), projectID, updates); err != nil {
-		if err == project.ErrProjectNotFound {
+	// 所有设置在同一事务中写入，任一部分失败时都不做修改
+	err = c.svc.UpdateProjectSettings(ctx.Request.Context(), projectID, &project.ProjectUpdate{
+		Fields:                 updates,
+		SetTagRules:            updateTagRules,
+		TagRules:               tagRules,
+		SetProxy:               updateProxy,
+		Proxy:                  proxyCfg,
+		SetVulnerabilityPolicy: updateVulnPolicy,
+		VulnerabilityPolicy:    vulnPolicy,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, project.ErrProjectNotFound):
 			response.NotFound(ctx, "project not found")
-			return
+		case errors.Is(err, project.ErrInvalidTagRules),
+			errors.Is(err, project.ErrInvalidProxyConfig),
+			errors.Is(err, project.ErrInvalidVulnerabilityPolicy):
+			response.ParamError(ctx, err.Error())
+		default:
+			response.InternalServerError(ctx, "failed to update project")
 		}
-		response.InternalServerError(ctx, "failed to update project")
 		return
 	}
 
@@ -395,13 +472,28 @@ func (c *ProjectController) GetStorageUsage(ctx *gin.Context) {
 		usagePercent = float64(p.StorageUsed) / float64(p.StorageQuota) * 100
 	}
 
-	response.Success(ctx, gin.H{
+	result := gin.H{
 		"project_id":    p.ID,
 		"project_name":  p.Name,
 		"storage_used":  p.StorageUsed,
 		"storage_quota": p.StorageQuota,
 		"usage_percent": usagePercent,
-	})
+	}
+
+	// 逻辑/物理用量：Blob 全局去重后，多个仓库共享的层只占用一份物理空间；
+	// storage_used 与物理用量口径相同，配额按它检查
+	if c.registry != nil {
+		if usage, uErr := c.registry.GetUsage(ctx.Request.Context(), p.Name); uErr == nil {
+			result["logical_bytes"] = usage.LogicalBytes
+			result["physical_bytes"] = usage.PhysicalBytes
+			result["dedup_saved_bytes"] = usage.LogicalBytes - usage.PhysicalBytes
+			result["blob_count"] = usage.BlobCount
+		} else {
+			log.Printf(`{"timestamp":"%s","level":"warn","module":"project","operation":"get_storage_usage","project_id":"%s","error":"%v"}`, time.Now().Format(time.RFC3339), p.ID, uErr)
+		}
+	}
+
+	response.Success(ctx, result)
 }
 
 // GetStatistics 获取项目统计信息
@@ -437,16 +529,29 @@ func (c *ProjectController) GetStatistics(ctx *gin.Context) {
 
 // toProjectResponse 转换为项目响应
 func toProjectResponse(p *project.Project) dto.ProjectResponse {
+	var proxy *dto.ProxyResponse
+	if p.Proxy != nil {
+		proxy = &dto.ProxyResponse{
+			URL:           p.Proxy.URL,
+			Username:      p.Proxy.Username,
+			HasPassword:   p.Proxy.Password != "",
+			Insecure:      p.Proxy.Insecure,
+			TagTTLSeconds: int(p.Proxy.TagTTL().Seconds()),
+		}
+	}
 	return dto.ProjectResponse{
-		ID:           p.ID,
-		Name:         p.Name,
-		Description:  p.Description,
-		OwnerID:      p.OwnerID,
-		IsPublic:     p.IsPublic,
-		StorageUsed:  p.StorageUsed,
-		StorageQuota: p.StorageQuota,
-		ImageCount:   p.ImageCount,
-		CreatedAt:    p.CreatedAt,
-		UpdatedAt:    p.UpdatedAt,
+		ID:                  p.ID,
+		Name:                p.Name,
+		Description:         p.Description,
+		OwnerID:             p.OwnerID,
+		IsPublic:            p.IsPublic,
+		StorageUsed:         p.StorageUsed,
+		StorageQuota:        p.StorageQuota,
+		ImageCount:          p.ImageCount,
+		TagRules:            p.TagRules,
+		Proxy:               proxy,
+		VulnerabilityPolicy: p.VulnerabilityPolicy,
+		CreatedAt:           p.CreatedAt,
+		UpdatedAt:           p.UpdatedAt,
 	}
 }
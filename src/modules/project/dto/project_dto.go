@@ -2,7 +2,13 @@
 // 定义项目管理的请求和响应结构
 package dto
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/cyp-registry/registry/src/modules/registry"
+	"github.com/cyp-registry/registry/src/modules/scanner"
+)
 
 // CreateProjectRequest 创建项目请求
 type CreateProjectRequest struct {
@@ -10,6 +16,8 @@ type CreateProjectRequest struct {
 	Description  string `json:"description" binding:"omitempty,max=2000"`
 	IsPublic     bool   `json:"is_public"`
 	StorageQuota int64  `json:"storage_quota"` // 单位：字节
+	// Proxy 上游配置：设置后项目为只读的代理（缓存）项目，<project>/<repo> 拉取上游的 <repo>
+	Proxy *registry.ProxyConfig `json:"proxy,omitempty"`
 }
 
 // UpdateProjectRequest 更新项目请求
@@ -18,20 +26,38 @@ type UpdateProjectRequest struct {
 	Description  *string `json:"description,omitempty" binding:"omitempty,max=2000"`
 	IsPublic     *bool   `json:"is_public,omitempty"`
 	StorageQuota *int64  `json:"storage_quota,omitempty"` // 单位：字节
+	// TagRules tag 规则：省略表示不修改，null 表示恢复默认规则
+	TagRules json.RawMessage `json:"tag_rules,omitempty" swaggertype:"object"`
+	// Proxy 上游配置：省略表示不修改，null 表示恢复为普通项目；用户名不变时可省略密码
+	Proxy json.RawMessage `json:"proxy,omitempty" swaggertype:"object"`
+	// VulnerabilityPolicy 漏洞拉取策略：省略表示不修改，null 表示恢复全局默认策略
+	VulnerabilityPolicy json.RawMessage `json:"vulnerability_policy,omitempty" swaggertype:"object"`
 }
 
 // ProjectResponse 项目响应
 type ProjectResponse struct {
-	ID           string    `json:"id"`
-	Name         string    `json:"name"`
-	Description  string    `json:"description"`
-	OwnerID      string    `json:"owner_id"`
-	IsPublic     bool      `json:"is_public"`
-	StorageUsed  int64     `json:"storage_used"`
-	StorageQuota int64     `json:"storage_quota"`
-	ImageCount   int       `json:"image_count"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID                  string              `json:"id"`
+	Name                string              `json:"name"`
+	Description         string              `json:"description"`
+	OwnerID             string              `json:"owner_id"`
+	IsPublic            bool                `json:"is_public"`
+	StorageUsed         int64               `json:"storage_used"`
+	StorageQuota        int64               `json:"storage_quota"`
+	ImageCount          int                 `json:"image_count"`
+	TagRules            *registry.TagRules  `json:"tag_rules"` // 为空表示使用默认规则（语义化版本号标签不可覆盖）
+	Proxy               *ProxyResponse      `json:"proxy,omitempty"`
+	VulnerabilityPolicy *scanner.PullPolicy `json:"vulnerability_policy"` // 为空表示使用全局默认策略（scanner.block_on_critical）
+	CreatedAt           time.Time           `json:"created_at"`
+	UpdatedAt           time.Time           `json:"updated_at"`
+}
+
+// ProxyResponse 代理项目的上游配置（不返回密码）
+type ProxyResponse struct {
+	URL           string `json:"url"`
+	Username      string `json:"username,omitempty"`
+	HasPassword   bool   `json:"has_password"`
+	Insecure      bool   `json:"insecure"`
+	TagTTLSeconds int    `json:"tag_ttl_seconds"`
 }
 
 // ProjectListResponse 项目列表响应
@@ -10,6 +10,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/cyp-registry/registry/src/modules/registry"
+	"github.com/cyp-registry/registry/src/modules/scanner"
 	"github.com/cyp-registry/registry/src/modules/storage"
 	"github.com/cyp-registry/registry/src/pkg/config"
 	"github.com/cyp-registry/registry/src/pkg/database"
@@ -35,17 +37,23 @@ const DefaultQuota int64 = 10 * 1024 * 1024 * 1024
 
 // Project 项目实体（数据库模型）
 type Project struct {
-	ID           string         `gorm:"type:varchar(36);primaryKey" json:"id"`
-	Name         string         `gorm:"type:varchar(255);uniqueIndex;not null" json:"name"`
-	Description  string         `gorm:"type:text" json:"description"`
-	OwnerID      string         `gorm:"type:varchar(36);index;not null" json:"owner_id"`
-	IsPublic     bool           `gorm:"default:false" json:"is_public"`
-	StorageUsed  int64          `gorm:"default:0" json:"storage_used"`
-	StorageQuota int64          `gorm:"default:10737418240" json:"storage_quota"` // 默认10GB
-	ImageCount   int            `gorm:"default:0" json:"image_count"`
-	CreatedAt    time.Time      `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt    time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+	ID           string `gorm:"type:varchar(36);primaryKey" json:"id"`
+	Name         string `gorm:"type:varchar(255);uniqueIndex;not null" json:"name"`
+	Description  string `gorm:"type:text" json:"description"`
+	OwnerID      string `gorm:"type:varchar(36);index;not null" json:"owner_id"`
+	IsPublic     bool   `gorm:"default:false" json:"is_public"`
+	StorageUsed  int64  `gorm:"default:0" json:"storage_used"`            // 去重后的物理用量，由仓库 Blob 链接索引维护
+	StorageQuota int64  `gorm:"default:10737418240" json:"storage_quota"` // 默认10GB
+	ImageCount   int    `gorm:"default:0" json:"image_count"`
+	// TagRules 项目级 tag 规则（JSON），为空时使用默认规则
+	TagRules *registry.TagRules `gorm:"type:jsonb;serializer:json" json:"tag_rules"`
+	// Proxy 代理（pull-through cache）项目的上游配置，为空表示普通项目；含凭据，不直接序列化
+	Proxy *registry.ProxyConfig `gorm:"type:jsonb;serializer:json" json:"-"`
+	// VulnerabilityPolicy 漏洞拉取策略（JSON），为空时使用全局默认策略（scanner.block_on_critical）
+	VulnerabilityPolicy *scanner.PullPolicy `gorm:"type:jsonb;serializer:json" json:"vulnerability_policy"`
+	CreatedAt           time.Time           `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt           time.Time           `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt           gorm.DeletedAt      `gorm:"index" json:"deleted_at"`
 }
 
 // TableName 指定表名
@@ -68,6 +76,21 @@ func (ProjectMember) TableName() string {
 	return "registry_project_members"
 }
 
+// ProjectUpdate 一次更新请求中的项目设置，由 UpdateProjectSettings 在同一事务中写入
+type ProjectUpdate struct {
+	// Fields 基本字段（列名 -> 值）
+	Fields map[string]interface{}
+	// SetTagRules 为 true 时更新 tag 规则，TagRules 为 nil 表示恢复默认规则
+	SetTagRules bool
+	TagRules    *registry.TagRules
+	// SetProxy 为 true 时更新代理配置，Proxy 为 nil 表示恢复为普通项目
+	SetProxy bool
+	Proxy    *registry.ProxyConfig
+	// SetVulnerabilityPolicy 为 true 时更新漏洞拉取策略，VulnerabilityPolicy 为 nil 表示恢复全局默认策略
+	SetVulnerabilityPolicy bool
+	VulnerabilityPolicy    *scanner.PullPolicy
+}
+
 // Service 项目服务接口
 type Service interface {
 	// CRUD操作
@@ -75,6 +98,7 @@ type Service interface {
 	GetProject(ctx context.Context, projectID string) (*Project, error)
 	GetProjectByName(ctx context.Context, name string) (*Project, error)
 	UpdateProject(ctx context.Context, projectID string, updates map[string]interface{}) error
+	UpdateProjectSettings(ctx context.Context, projectID string, update *ProjectUpdate) error
 	DeleteProject(ctx context.Context, projectID string) error
 
 	// 列表查询
@@ -86,6 +110,18 @@ type Service interface {
 	CheckQuota(ctx context.Context, projectID string, additionalSize int64) (bool, error)
 	UpdateStorageUsage(ctx context.Context, projectID string, delta int64) error
 
+	// tag 规则（同时实现 registry.PolicyProvider）
+	UpdateTagRules(ctx context.Context, projectID string, rules *registry.TagRules) error
+	TagRules(ctx context.Context, projectName string) (*registry.TagRules, error)
+
+	// 代理项目配置（同时实现 registry.ProxyProvider）
+	UpdateProxy(ctx context.Context, projectID string, cfg *registry.ProxyConfig) error
+	ProxyConfig(ctx context.Context, projectName string) (*registry.ProxyConfig, error)
+
+	// 漏洞拉取策略（同时实现漏洞扫描模块的 PolicyProvider）
+	UpdateVulnerabilityPolicy(ctx context.Context, projectID string, policy *scanner.PullPolicy) error
+	PullPolicy(ctx context.Context, projectName string) (*scanner.PullPolicy, error)
+
 	// 访问控制（仅基于公开性与项目所有者，无团队/成员角色）
 	CanAccess(ctx context.Context, userID, projectID string, action string) (bool, error)
 	IsOwner(ctx context.Context, userID, projectID string) (bool, error)
@@ -237,6 +273,63 @@ func (s *projectService) UpdateProject(ctx context.Context, projectID string, up
 	return nil
 }
 
+// UpdateProjectSettings 更新项目的基本字段、tag 规则、代理配置与漏洞拉取策略
+// 先校验全部设置，再在同一事务中写入：任一部分不合法或写入失败时都不做修改。
+func (s *projectService) UpdateProjectSettings(ctx context.Context, projectID string, update *ProjectUpdate) error {
+	if update.SetTagRules {
+		if err := registry.ValidateTagRules(update.TagRules); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidTagRules, err)
+		}
+	}
+	if update.SetVulnerabilityPolicy {
+		if err := scanner.ValidatePullPolicy(update.VulnerabilityPolicy); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidVulnerabilityPolicy, err)
+		}
+	}
+	var proxy *registry.ProxyConfig
+	if update.SetProxy {
+		var err error
+		if proxy, err = s.prepareProxy(ctx, projectID, update.Proxy); err != nil {
+			return err
+		}
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if len(update.Fields) > 0 {
+			result := tx.Model(&Project{}).Where("id = ? AND deleted_at IS NULL", projectID).Updates(update.Fields)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrProjectNotFound
+			}
+		}
+		if update.SetTagRules {
+			if err := saveTagRules(tx, projectID, update.TagRules); err != nil {
+				return err
+			}
+		}
+		if update.SetProxy {
+			if err := saveProxy(tx, projectID, proxy); err != nil {
+				return err
+			}
+		}
+		if update.SetVulnerabilityPolicy {
+			if err := saveVulnerabilityPolicy(tx, projectID, update.VulnerabilityPolicy); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf(`{"timestamp":"%s","level":"error","module":"project","operation":"update_settings","project_id":"%s","error":"%v"}`, time.Now().Format(time.RFC3339), projectID, err)
+		return err
+	}
+
+	log.Printf(`{"timestamp":"%s","level":"info","module":"project","operation":"update_settings","project_id":"%s","updates":%v,"tag_rules":%t,"proxy":%t,"vulnerability_policy":%t}`, time.Now().Format(time.RFC3339), projectID, update.Fields, update.SetTagRules, update.SetProxy, update.SetVulnerabilityPolicy)
+	return nil
+}
+
 // DeleteProject 删除项目
 func (s *projectService) DeleteProject(ctx context.Context, projectID string) error {
 	// 获取项目信息
@@ -341,6 +434,7 @@ func (s *projectService) UpdateQuota(ctx context.Context, projectID string, quot
 }
 
 // CheckQuota 检查配额是否充足
+// StorageUsed 为去重后的物理用量：多个仓库共享的 Blob 只计一次（推送时由仓库模块按同一口径检查）。
 func (s *projectService) CheckQuota(ctx context.Context, projectID string, additionalSize int64) (bool, error) {
 	project, err := s.GetProject(ctx, projectID)
 	if err != nil {
@@ -351,6 +445,7 @@ func (s *projectService) CheckQuota(ctx context.Context, projectID string, addit
 }
 
 // UpdateStorageUsage 更新存储使用量
+// 启用仓库 Blob 链接索引时，storage_used 在每次链接变化后按索引重新统计并覆盖此处的增量。
 func (s *projectService) UpdateStorageUsage(ctx context.Context, projectID string, delta int64) error {
 	project, err := s.GetProject(ctx, projectID)
 	if err != nil {
@@ -0,0 +1,112 @@
+// Package project 项目管理模块
+// 提供项目（镜像仓库）的CRUD操作和配额管理
+package project
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/cyp-registry/registry/src/modules/registry"
+	"github.com/cyp-registry/registry/src/pkg/secrets"
+)
+
+// ErrInvalidProxyConfig 代理配置不合法
+var ErrInvalidProxyConfig = errors.New("project: invalid proxy config")
+
+// UpdateProxy 设置项目的上游代理配置，cfg 为 nil 时恢复为普通项目
+// 用户名不变且未提供密码时保留原密码，避免每次修改其他字段都需要重新提交凭据。
+func (s *projectService) UpdateProxy(ctx context.Context, projectID string, cfg *registry.ProxyConfig) error {
+	stored, err := s.prepareProxy(ctx, projectID, cfg)
+	if err != nil {
+		return err
+	}
+	if err := saveProxy(s.db.WithContext(ctx), projectID, stored); err != nil {
+		return err
+	}
+
+	upstream := ""
+	if cfg != nil {
+		upstream = cfg.URL
+	}
+	log.Printf(`{"timestamp":"%s","level":"info","module":"project","operation":"update_proxy","project_id":"%s","upstream":"%s"}`, time.Now().Format(time.RFC3339), projectID, upstream)
+	return nil
+}
+
+// prepareProxy 校验代理配置并返回待保存的配置（密码已加密）
+func (s *projectService) prepareProxy(ctx context.Context, projectID string, cfg *registry.ProxyConfig) (*registry.ProxyConfig, error) {
+	if cfg != nil && cfg.Password == "" && cfg.Username != "" {
+		p, err := s.GetProject(ctx, projectID)
+		if err != nil {
+			return nil, err
+		}
+		if p.Proxy != nil && p.Proxy.Username == cfg.Username {
+			cfg.Password = p.Proxy.Password
+		}
+	}
+	if err := registry.ValidateProxyConfig(cfg); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidProxyConfig, err)
+	}
+	if cfg == nil {
+		return nil, nil
+	}
+
+	// 密码加密后保存（其余字段仍为明文 JSON），读取时由 AfterFind 解密
+	encrypted, err := secrets.Encrypt(cfg.Password)
+	if err != nil {
+		return nil, err
+	}
+	stored := *cfg
+	stored.Password = encrypted
+	return &stored, nil
+}
+
+// saveProxy 写入 prepareProxy 返回的代理配置
+func saveProxy(db *gorm.DB, projectID string, stored *registry.ProxyConfig) error {
+	result := db.Model(&Project{}).
+		Where("id = ? AND deleted_at IS NULL", projectID).
+		Select("proxy").
+		Updates(&Project{Proxy: stored})
+	if result.Error != nil {
+		log.Printf(`{"timestamp":"%s","level":"error","module":"project","operation":"update_proxy","project_id":"%s","error":"%v"}`, time.Now().Format(time.RFC3339), projectID, result.Error)
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrProjectNotFound
+	}
+	return nil
+}
+
+// ProxyConfig 按项目名称获取代理配置（实现 registry.ProxyProvider）
+// 项目不存在或不是代理项目时返回 nil
+func (s *projectService) ProxyConfig(ctx context.Context, projectName string) (*registry.ProxyConfig, error) {
+	p, err := s.GetProjectByName(ctx, projectName)
+	if err != nil {
+		if errors.Is(err, ErrProjectNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return p.Proxy, nil
+}
+
+// AfterFind 解密代理配置中的密码
+// 解密失败（密钥缺失或已轮换、数据损坏）时只记录日志并清空密码，不影响项目的读取；
+// 代理访问上游时以空密码认证失败，由代理模块报告错误，重新设置代理凭据即可恢复。
+func (p *Project) AfterFind(tx *gorm.DB) error {
+	if p.Proxy == nil || p.Proxy.Password == "" {
+		return nil
+	}
+	password, err := secrets.Decrypt(p.Proxy.Password)
+	if err != nil {
+		log.Printf(`{"timestamp":"%s","level":"error","module":"project","operation":"decrypt_proxy_password","project_id":"%s","project_name":"%s","error":"%v"}`, time.Now().Format(time.RFC3339), p.ID, p.Name, err)
+		p.Proxy.Password = ""
+		return nil
+	}
+	p.Proxy.Password = password
+	return nil
+}
@@ -0,0 +1,83 @@
+// Package project 项目管理模块
+// 提供项目（镜像仓库）的CRUD操作和配额管理
+package project
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/cyp-registry/registry/src/modules/registry"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidTagRules tag 规则不合法
+var ErrInvalidTagRules = errors.New("project: invalid tag rules")
+
+// InitDatabase 为已存在的 registry_projects 表补充新增列
+// 表本身由 init-scripts/01-schema.sql 创建；在 cmd/server/main.go 中调用
+func InitDatabase(db *gorm.DB) error {
+	if db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	if !db.Migrator().HasColumn(&Project{}, "TagRules") {
+		if err := db.Migrator().AddColumn(&Project{}, "TagRules"); err != nil {
+			return fmt.Errorf("add column registry_projects.tag_rules failed: %w", err)
+		}
+	}
+	if !db.Migrator().HasColumn(&Project{}, "Proxy") {
+		if err := db.Migrator().AddColumn(&Project{}, "Proxy"); err != nil {
+			return fmt.Errorf("add column registry_projects.proxy failed: %w", err)
+		}
+	}
+	if !db.Migrator().HasColumn(&Project{}, "VulnerabilityPolicy") {
+		if err := db.Migrator().AddColumn(&Project{}, "VulnerabilityPolicy"); err != nil {
+			return fmt.Errorf("add column registry_projects.vulnerability_policy failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// UpdateTagRules 更新项目 tag 规则，rules 为 nil 时恢复默认规则
+func (s *projectService) UpdateTagRules(ctx context.Context, projectID string, rules *registry.TagRules) error {
+	if err := registry.ValidateTagRules(rules); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTagRules, err)
+	}
+	if err := saveTagRules(s.db.WithContext(ctx), projectID, rules); err != nil {
+		return err
+	}
+
+	log.Printf(`{"timestamp":"%s","level":"info","module":"project","operation":"update_tag_rules","project_id":"%s","reset":%t}`, time.Now().Format(time.RFC3339), projectID, rules == nil)
+	return nil
+}
+
+// saveTagRules 写入已校验的 tag 规则
+func saveTagRules(db *gorm.DB, projectID string, rules *registry.TagRules) error {
+	result := db.Model(&Project{}).
+		Where("id = ? AND deleted_at IS NULL", projectID).
+		Select("tag_rules").
+		Updates(&Project{TagRules: rules})
+	if result.Error != nil {
+		log.Printf(`{"timestamp":"%s","level":"error","module":"project","operation":"update_tag_rules","project_id":"%s","error":"%v"}`, time.Now().Format(time.RFC3339), projectID, result.Error)
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrProjectNotFound
+	}
+	return nil
+}
+
+// TagRules 按项目名称获取 tag 规则（实现 registry.PolicyProvider）
+// 项目不存在（例如首次推送尚未自动创建）或未配置时返回 nil，由 Registry 使用默认规则
+func (s *projectService) TagRules(ctx context.Context, projectName string) (*registry.TagRules, error) {
+	p, err := s.GetProjectByName(ctx, projectName)
+	if err != nil {
+		if errors.Is(err, ErrProjectNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return p.TagRules, nil
+}
@@ -0,0 +1,61 @@
+// Package project 项目管理模块
+// 提供项目（镜像仓库）的CRUD操作和配额管理
+package project
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/cyp-registry/registry/src/modules/scanner"
+)
+
+// ErrInvalidVulnerabilityPolicy 漏洞拉取策略不合法
+var ErrInvalidVulnerabilityPolicy = errors.New("project: invalid vulnerability policy")
+
+// UpdateVulnerabilityPolicy 更新项目的漏洞拉取策略，policy 为 nil 时恢复全局默认策略
+func (s *projectService) UpdateVulnerabilityPolicy(ctx context.Context, projectID string, policy *scanner.PullPolicy) error {
+	if err := scanner.ValidatePullPolicy(policy); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidVulnerabilityPolicy, err)
+	}
+	if err := saveVulnerabilityPolicy(s.db.WithContext(ctx), projectID, policy); err != nil {
+		return err
+	}
+
+	enabled := policy != nil && policy.Enabled
+	log.Printf(`{"timestamp":"%s","level":"info","module":"project","operation":"update_vulnerability_policy","project_id":"%s","reset":%t,"enabled":%t}`, time.Now().Format(time.RFC3339), projectID, policy == nil, enabled)
+	return nil
+}
+
+// saveVulnerabilityPolicy 写入已校验的漏洞拉取策略
+func saveVulnerabilityPolicy(db *gorm.DB, projectID string, policy *scanner.PullPolicy) error {
+	result := db.Model(&Project{}).
+		Where("id = ? AND deleted_at IS NULL", projectID).
+		Select("vulnerability_policy").
+		Updates(&Project{VulnerabilityPolicy: policy})
+	if result.Error != nil {
+		log.Printf(`{"timestamp":"%s","level":"error","module":"project","operation":"update_vulnerability_policy","project_id":"%s","error":"%v"}`, time.Now().Format(time.RFC3339), projectID, result.Error)
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrProjectNotFound
+	}
+	return nil
+}
+
+// PullPolicy 按项目名称获取漏洞拉取策略（实现漏洞扫描模块的 PolicyProvider）
+// 项目不存在或未配置时返回 nil，由扫描服务使用全局默认策略
+func (s *projectService) PullPolicy(ctx context.Context, projectName string) (*scanner.PullPolicy, error) {
+	p, err := s.GetProjectByName(ctx, projectName)
+	if err != nil {
+		if errors.Is(err, ErrProjectNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return p.VulnerabilityPolicy, nil
+}
@@ -3,18 +3,24 @@
 package registry
 
 import (
-	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"log"
 	"time"
 
+	"github.com/cyp-registry/registry/src/modules/storage"
 	"github.com/cyp-registry/registry/src/pkg/response"
 	"github.com/google/uuid"
 )
 
+// DefaultBlobRedirectTTL Blob 下载预签名地址默认有效期
+const DefaultBlobRedirectTTL = 5 * time.Minute
+
 // APIError API错误响应
 type APIError struct {
 	Errors []ErrorDetail `json:"errors"`
@@ -22,77 +28,172 @@ type APIError struct {
 
 // ErrorDetail 错误详情
 type ErrorDetail struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Detail  interface{} `json:"detail,omitempty"`
 }
 
 // CheckBlob 检查Blob是否存在
 // GET /v2/<name>/blobs/<digest>
 func (r *Registry) CheckBlob(ctx context.Context, project, digest string) (bool, error) {
 	// 验证摘要格式
-	algorithm, hexDigest, err := ParseDigest(digest)
+	fullDigest, err := normalizeDigest(digest)
 	if err != nil {
 		return false, err
 	}
 
-	// 存储路径使用完整摘要（包含算法前缀），与 CompleteBlobUpload/MountBlob/DeleteBlob 保持一致
-	fullDigest := fmt.Sprintf("%s:%s", algorithm, hexDigest)
-	path := BuildBlobPath(project, fullDigest)
-	return r.storage.Exists(ctx, path)
+	// 仓库可见性以链接记录为准（兼容旧版项目内数据）
+	if _, _, err := r.resolveBlobPath(ctx, project, fullDigest); err != nil {
+		if !errors.Is(err, ErrBlobNotFound) {
+			return false, err
+		}
+		// 代理项目：本地未缓存时查询上游
+		if target := r.lookupProxy(ctx, project); target != nil {
+			if _, err := r.proxyBlobSize(ctx, target, fullDigest); err == nil {
+				return true, nil
+			} else if !errors.Is(err, ErrBlobNotFound) {
+				return false, err
+			}
+		}
+		return false, nil
+	}
+	return true, nil
 }
 
 // GetBlob 获取Blob
 // GET /v2/<name>/blobs/<digest>
 func (r *Registry) GetBlob(ctx context.Context, project, digest string) (io.Reader, int64, error) {
 	// 验证摘要格式
-	algorithm, hexDigest, err := ParseDigest(digest)
+	fullDigest, err := normalizeDigest(digest)
 	if err != nil {
 		return nil, 0, err
 	}
 
-	// 存储路径使用完整摘要（包含算法前缀）
-	fullDigest := fmt.Sprintf("%s:%s", algorithm, hexDigest)
-	path := BuildBlobPath(project, fullDigest)
+	path, _, err := r.resolveBlobPath(ctx, project, fullDigest)
+	if err != nil {
+		// 代理项目：从上游下载并同时写入缓存
+		if target := r.lookupProxy(ctx, project); target != nil {
+			return r.fetchProxiedBlob(ctx, target, project, fullDigest)
+		}
+		// 统一向上游暴露为 ErrBlobNotFound，方便控制器返回 404
+		return nil, 0, ErrBlobNotFound
+	}
 
 	reader, size, err := r.storage.Get(ctx, path)
 	if err != nil {
-		// 统一向上游暴露为 ErrBlobNotFound，方便控制器返回 404
 		return nil, 0, ErrBlobNotFound
 	}
 	return reader, size, nil
 }
 
+// GetBlobRange 读取Blob的一部分，用于断点续传与分段下载
+// length 为 -1 表示读到末尾；offset 超出 Blob 大小时返回 ErrBlobRangeInvalid。
+// 返回数据流和实际可读取的长度。
+func (r *Registry) GetBlobRange(ctx context.Context, project, digest string, offset, length int64) (io.Reader, int64, error) {
+	fullDigest, err := normalizeDigest(digest)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	path, _, err := r.resolveBlobPath(ctx, project, fullDigest)
+	if err != nil {
+		// 代理项目：先完整缓存上游 Blob，再按范围读取
+		target := r.lookupProxy(ctx, project)
+		if target == nil {
+			return nil, 0, ErrBlobNotFound
+		}
+		if err := r.cacheProxiedBlob(ctx, target, project, fullDigest); err != nil {
+			return nil, 0, err
+		}
+		if path, _, err = r.resolveBlobPath(ctx, project, fullDigest); err != nil {
+			return nil, 0, ErrBlobNotFound
+		}
+	}
+
+	reader, n, err := r.storage.GetRange(ctx, path, offset, length)
+	if err != nil {
+		if errors.Is(err, storage.ErrInvalidRange) {
+			return nil, 0, ErrBlobRangeInvalid
+		}
+		return nil, 0, ErrBlobNotFound
+	}
+	return reader, n, nil
+}
+
+// SetBlobRedirectTTL 设置 Blob 下载预签名地址的有效期，d <= 0 时使用默认值
+func (r *Registry) SetBlobRedirectTTL(d time.Duration) {
+	if d <= 0 {
+		d = DefaultBlobRedirectTTL
+	}
+	r.redirectTTL = d
+}
+
+// BlobRedirectURL 返回 Blob 的临时直链下载地址
+// 存储驱动不支持或未启用重定向（见 storage.Redirector）时返回空字符串。
+func (r *Registry) BlobRedirectURL(ctx context.Context, project, digest string) (string, error) {
+	redirector, ok := r.storage.(storage.Redirector)
+	if !ok {
+		return "", nil
+	}
+
+	fullDigest, err := normalizeDigest(digest)
+	if err != nil {
+		return "", err
+	}
+	path, _, err := r.resolveBlobPath(ctx, project, fullDigest)
+	if err != nil {
+		if errors.Is(err, ErrBlobNotFound) {
+			// 本地没有数据（例如代理项目尚未缓存）：不重定向，由 GetBlob 处理
+			return "", nil
+		}
+		return "", err
+	}
+
+	ttl := r.redirectTTL
+	if ttl <= 0 {
+		ttl = DefaultBlobRedirectTTL
+	}
+	return redirector.RedirectURL(ctx, path, ttl)
+}
+
 // GetBlobSize 获取Blob大小
 func (r *Registry) GetBlobSize(ctx context.Context, project, digest string) (int64, error) {
-	algorithm, hexDigest, err := ParseDigest(digest)
+	fullDigest, err := normalizeDigest(digest)
 	if err != nil {
 		return 0, err
 	}
 
-	// 存储路径使用完整摘要（包含算法前缀）
-	fullDigest := fmt.Sprintf("%s:%s", algorithm, hexDigest)
-	path := BuildBlobPath(project, fullDigest)
-	size, _, err := r.storage.Stat(ctx, path)
+	_, size, err := r.resolveBlobPath(ctx, project, fullDigest)
 	if err != nil {
-		if errors.Is(err, response.ErrNotFound) {
-			return 0, ErrBlobNotFound
+		if errors.Is(err, ErrBlobNotFound) {
+			if target := r.lookupProxy(ctx, project); target != nil {
+				return r.proxyBlobSize(ctx, target, fullDigest)
+			}
 		}
 		return 0, err
 	}
-
 	return size, nil
 }
 
 // InitiateBlobUpload 初始化Blob上传
 // POST /v2/<name>/blobs/uploads/
-func (r *Registry) InitiateBlobUpload(ctx context.Context, project string) (*UploadInfo, error) {
-	uploadID := uuid.New().String()
+// ownerID 为发起上传的用户（匿名上传为空），记录在会话中便于审计与清理。
+func (r *Registry) InitiateBlobUpload(ctx context.Context, project, ownerID string) (*UploadInfo, error) {
+	if err := r.checkProxyWrite(ctx, project); err != nil {
+		return nil, err
+	}
 
+	now := time.Now()
 	info := &UploadInfo{
-		UUID:        uploadID,
-		Project:     project,
-		StartedAt:   time.Now(),
-		LastUpdated: time.Now(),
+		UUID:      uuid.New().String(),
+		Project:   project,
+		OwnerID:   ownerID,
+		StartedAt: now,
+	}
+
+	// 持久化会话记录（含初始摘要状态）
+	if err := r.saveUploadSession(ctx, info, sha256.New()); err != nil {
+		return nil, err
 	}
 
 	return info, nil
@@ -100,100 +201,98 @@ func (r *Registry) InitiateBlobUpload(ctx context.Context, project string) (*Upl
 
 // UploadBlobChunk 上传Blob分片
 // PATCH /v2/<name>/blobs/uploads/<uuid>
+// 分片以流式追加到上传会话，同时增量更新摘要状态；offset 为 UploadAppend 时追加到当前末尾，
+// 否则必须等于已接收的数据长度（重试已被接收的分片会返回 ErrUploadOffsetMismatch，而不是重复追加）。
+// size >= 0 时为分片声明的长度：只接收该长度的数据，请求体更短或更长时返回 ErrUploadRangeMismatch，
+// 已接收的部分保留在会话中，客户端可按上传状态续传。
 func (r *Registry) UploadBlobChunk(ctx context.Context, project, uploadID string, offset int64, body io.Reader, size int64) (int64, error) {
-	// 验证uploadID
-	if uploadID == "" {
-		return 0, ErrUploadNotFound
+	info, h, err := r.loadUploadSession(ctx, project, uploadID)
+	if err != nil {
+		return 0, err
 	}
 
-	// 构建上传路径
-	path := fmt.Sprintf("%s/uploads/%s", project, uploadID)
-
-	// 检查是否已存在
-	exists, err := r.storage.Exists(ctx, path)
+	w, err := r.storage.Writer(ctx, buildUploadPath(project, uploadID, uploadDataFile), true)
 	if err != nil {
 		return 0, err
 	}
 
-	// 如果文件存在，获取当前大小作为offset
-	if exists {
-		currentSize, _, err := r.storage.Stat(ctx, path)
-		if err != nil {
-			return 0, err
-		}
-		// 追加模式：检查offset是否匹配
-		if offset != 0 && offset != currentSize {
-			return currentSize, fmt.Errorf("offset mismatch: expected %d, got %d", offset, currentSize)
-		}
+	currentSize := w.Size()
+	if currentSize != info.Size {
+		// 数据与摘要状态不一致（例如上次写入中途失败），会话无法继续，需客户端重新上传
+		w.Close()
+		return 0, fmt.Errorf("%w: upload state inconsistent (data %d, hashed %d)", ErrUploadNotFound, currentSize, info.Size)
 	}
 
-	// 如果是追加，更新offset
-	if exists {
-		// 获取现有文件
-		existing, _, err := r.storage.Get(ctx, path)
-		if err != nil {
-			return 0, err
-		}
-
-		// 将新数据追加到现有数据
-		var buf bytes.Buffer
-		if _, err := buf.ReadFrom(existing); err != nil {
-			return 0, err
-		}
-
-		// 读取新数据
-		newData, err := io.ReadAll(body)
-		if err != nil {
-			return 0, err
-		}
-
-		// 合并数据
-		buf.Write(newData)
+	// 指定了起始位置时必须与已接收的数据衔接
+	if offset != UploadAppend && offset != currentSize {
+		w.Close()
+		return currentSize, fmt.Errorf("%w: expected %d, got %d", ErrUploadOffsetMismatch, currentSize, offset)
+	}
 
-		// 重新上传（删除旧数据失败时仅记录错误，由后续覆盖操作保证一致性）
-		if err := r.storage.Delete(ctx, path); err != nil {
-			log.Printf(`{"timestamp":"%s","level":"warn","module":"registry","operation":"append_blob","path":"%s","error":"failed to delete old blob: %v"}`, time.Now().Format(time.RFC3339), path, err)
+	src := body
+	if size >= 0 {
+		src = io.LimitReader(body, size)
+	}
+	n, err := io.Copy(&hashingWriter{w: w, h: h}, src)
+	if err == nil && size >= 0 {
+		if n < size {
+			err = fmt.Errorf("%w: declared %d bytes, received %d", ErrUploadRangeMismatch, size, n)
+		} else if extra, _ := io.ReadFull(body, make([]byte, 1)); extra > 0 {
+			err = fmt.Errorf("%w: received more than the declared %d bytes", ErrUploadRangeMismatch, size)
 		}
-		if err := r.storage.Put(ctx, path, &buf, int64(buf.Len())); err != nil {
-			return 0, err
+	}
+	if err != nil {
+		// 写入中途失败（例如客户端断开）或长度与声明不符：保留已写入的数据并同步保存摘要状态，
+		// 客户端查询上传状态后可从已接收的位置续传
+		if closeErr := w.Close(); closeErr == nil {
+			info.Size = w.Size()
+			if saveErr := r.saveUploadSession(ctx, info, h); saveErr != nil {
+				log.Printf(`{"timestamp":"%s","level":"warn","module":"registry","operation":"upload_blob_chunk","upload_id":"%s","error":"failed to save upload session: %v"}`, time.Now().Format(time.RFC3339), uploadID, saveErr)
+			}
 		}
-
-		return int64(buf.Len()), nil
+		return info.Size, err
+	}
+	if err := w.Close(); err != nil {
+		return 0, err
 	}
 
-	// 首次上传
-	if err := r.storage.Put(ctx, path, body, size); err != nil {
+	info.Size = w.Size()
+	if err := r.saveUploadSession(ctx, info, h); err != nil {
 		return 0, err
 	}
 
-	return size, nil
+	return info.Size, nil
+}
+
+// hashingWriter 写入数据的同时更新摘要，只对实际写入的部分计算摘要，使数据与摘要状态始终一致
+type hashingWriter struct {
+	w io.Writer
+	h hash.Hash
+}
+
+func (hw *hashingWriter) Write(p []byte) (int, error) {
+	n, err := hw.w.Write(p)
+	hw.h.Write(p[:n])
+	return n, err
 }
 
 // CompleteBlobUpload 完成Blob上传
 // PUT /v2/<name>/blobs/uploads/<uuid>?digest=<digest>
+// 摘要由分片上传时的增量状态得出，完成时不再重新读取数据；
+// 数据通过存储内部 Move 进入全局存储，若相同内容已存在则只写链接。
 func (r *Registry) CompleteBlobUpload(ctx context.Context, project, uploadID, digest string, size int64) error {
-	if uploadID == "" {
-		return ErrUploadNotFound
-	}
-
 	// 验证摘要格式
-	algorithm, hexDigest, err := ParseDigest(digest)
-	if err != nil {
+	if _, _, err := ParseDigest(digest); err != nil {
 		return err
 	}
 
-	// 获取上传的数据
-	uploadPath := fmt.Sprintf("%s/uploads/%s", project, uploadID)
-	reader, _, err := r.storage.Get(ctx, uploadPath)
+	info, h, err := r.loadUploadSession(ctx, project, uploadID)
 	if err != nil {
 		return err
 	}
 
-	// 计算实际摘要
-	actualDigest, actualSize, err := CalculateDigest(reader)
-	if err != nil {
-		return err
-	}
+	actualSize := info.Size
+	actualDigest := "sha256:" + hex.EncodeToString(h.Sum(nil))
 
 	// 验证摘要匹配
 	if digest != actualDigest {
@@ -205,109 +304,196 @@ func (r *Registry) CompleteBlobUpload(ctx context.Context, project, uploadID, di
 		return fmt.Errorf("size mismatch: expected %d, got %d", size, actualSize)
 	}
 
-	// 移动到最终位置
-	finalPath := BuildBlobPath(project, algorithm+":"+hexDigest)
+	// 与垃圾回收互斥：数据进入全局存储与写链接之间不能被清除
+	release, err := r.beginPush(ctx, project, actualDigest)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	dataPath := buildUploadPath(project, uploadID, uploadDataFile)
 
-	// 重新读取数据
-	reader2, _, err := r.storage.Get(ctx, uploadPath)
+	// 全局存储中已有相同内容：只写链接，丢弃本次上传的数据
+	if _, err := r.globalBlobSize(ctx, actualDigest); err == nil {
+		if err := r.deleteUpload(ctx, project, uploadID); err != nil {
+			log.Printf(`{"timestamp":"%s","level":"warn","module":"registry","operation":"complete_blob_upload","upload_id":"%s","error":"failed to delete upload data: %v"}`, time.Now().Format(time.RFC3339), uploadID, err)
+		}
+		log.Printf(`{"timestamp":"%s","level":"info","module":"registry","operation":"complete_blob_upload","repository":"%s","digest":"%s","deduplicated":true}`, time.Now().Format(time.RFC3339), project, actualDigest)
+		return r.linkBlob(ctx, project, actualDigest, actualSize)
+	} else if !errors.Is(err, ErrBlobNotFound) {
+		return err
+	}
+
+	// 固化上传数据（对象存储在此完成 multipart 合并）
+	w, err := r.storage.Writer(ctx, dataPath, true)
 	if err != nil {
 		return err
 	}
+	if w.Size() != actualSize {
+		w.Close()
+		return fmt.Errorf("%w: upload state inconsistent (data %d, hashed %d)", ErrUploadNotFound, w.Size(), actualSize)
+	}
+	if err := w.Commit(ctx); err != nil {
+		return err
+	}
 
-	// 删除上传的临时文件（删除失败不应影响后续读操作）
-	if err := r.storage.Delete(ctx, uploadPath); err != nil {
-		log.Printf(`{"timestamp":"%s","level":"warn","module":"registry","operation":"complete_blob_upload","upload_path":"%s","error":"failed to delete upload temp blob: %v"}`, time.Now().Format(time.RFC3339), uploadPath, err)
+	// 移动到全局存储
+	if err := r.storage.Move(ctx, dataPath, BuildGlobalBlobPath(actualDigest)); err != nil {
+		return err
+	}
+
+	// 删除上传会话的剩余数据（删除失败不应影响后续读操作）
+	if err := r.deleteUpload(ctx, project, uploadID); err != nil {
+		log.Printf(`{"timestamp":"%s","level":"warn","module":"registry","operation":"complete_blob_upload","upload_id":"%s","error":"failed to delete upload data: %v"}`, time.Now().Format(time.RFC3339), uploadID, err)
 	}
 
-	// 保存到最终位置
-	return r.storage.Put(ctx, finalPath, reader2, actualSize)
+	return r.linkBlob(ctx, project, actualDigest, actualSize)
 }
 
 // CancelBlobUpload 取消Blob上传
 // DELETE /v2/<name>/blobs/uploads/<uuid>
 func (r *Registry) CancelBlobUpload(ctx context.Context, project, uploadID string) error {
-	if uploadID == "" {
-		return ErrUploadNotFound
+	if _, _, err := r.loadUploadSession(ctx, project, uploadID); err != nil {
+		return err
 	}
+	return r.deleteUpload(ctx, project, uploadID)
+}
 
-	path := fmt.Sprintf("%s/uploads/%s", project, uploadID)
-	return r.storage.Delete(ctx, path)
+// PutBlob 直接写入已知摘要的Blob（服务端导入、复制等场景，不经过上传会话）
+// 数据流式写入临时文件并同时计算摘要，校验通过后进入全局存储并为仓库写入链接；
+// 全局存储已有相同内容时只写链接。size 为 -1 表示大小未知（不校验）。返回实际写入的字节数。
+func (r *Registry) PutBlob(ctx context.Context, project, digest string, size int64, body io.Reader) (int64, error) {
+	if _, _, err := ParseDigest(digest); err != nil {
+		return 0, err
+	}
+	if err := r.checkProxyWrite(ctx, project); err != nil {
+		return 0, err
+	}
+
+	// 复用上传会话目录：进程异常退出时遗留的数据由上传清理任务回收
+	uploadID := uuid.New().String()
+	dataPath := buildUploadPath(project, uploadID, uploadDataFile)
+	w, err := r.storage.Writer(ctx, dataPath, false)
+	if err != nil {
+		return 0, err
+	}
+	cleanup := func() {
+		cctx := context.WithoutCancel(ctx)
+		_ = w.Cancel(cctx)
+		_ = r.deleteUpload(cctx, project, uploadID)
+	}
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(w, h), body)
+	if err != nil {
+		cleanup()
+		return n, err
+	}
+	actualDigest := "sha256:" + hex.EncodeToString(h.Sum(nil))
+	if actualDigest != digest {
+		cleanup()
+		return n, fmt.Errorf("digest mismatch: expected %s, got %s", digest, actualDigest)
+	}
+	if size >= 0 && n != size {
+		cleanup()
+		return n, fmt.Errorf("size mismatch: expected %d, got %d", size, n)
+	}
+
+	// 与垃圾回收互斥：数据进入全局存储与写链接之间不能被清除
+	release, err := r.beginPush(ctx, project, actualDigest)
+	if err != nil {
+		cleanup()
+		return n, err
+	}
+	defer release()
+
+	if _, err := r.globalBlobSize(ctx, actualDigest); err == nil {
+		cleanup()
+	} else if !errors.Is(err, ErrBlobNotFound) {
+		cleanup()
+		return n, err
+	} else {
+		if err := w.Commit(ctx); err != nil {
+			cleanup()
+			return n, err
+		}
+		if err := r.storage.Move(ctx, dataPath, BuildGlobalBlobPath(actualDigest)); err != nil {
+			_ = r.deleteUpload(context.WithoutCancel(ctx), project, uploadID)
+			return n, err
+		}
+		_ = r.deleteUpload(ctx, project, uploadID)
+	}
+
+	return n, r.linkBlob(ctx, project, actualDigest, n)
 }
 
 // MountBlob 跨仓库挂载Blob
 // POST /v2/<name>/blobs/uploads/?mount=<digest>&from=<source-project>
+// 数据只在全局存储中保留一份，挂载只为目标仓库写入链接记录。
 func (r *Registry) MountBlob(ctx context.Context, project, sourceProject, digest string) error {
 	// 验证摘要格式
-	algorithm, hexDigest, err := ParseDigest(digest)
+	fullDigest, err := normalizeDigest(digest)
 	if err != nil {
 		return err
 	}
 
-	// 源路径
-	sourcePath := BuildBlobPath(sourceProject, algorithm+":"+hexDigest)
-
-	// 检查源Blob是否存在
-	exists, err := r.storage.Exists(ctx, sourcePath)
-	if err != nil {
+	if err := r.checkProxyWrite(ctx, project); err != nil {
 		return err
 	}
 
-	if !exists {
-		return ErrBlobNotFound
+	release, err := r.beginPush(ctx, project, fullDigest)
+	if err != nil {
+		return err
 	}
+	defer release()
 
-	// 目标路径
-	destPath := BuildBlobPath(project, algorithm+":"+hexDigest)
-
-	// 获取源Blob
-	reader, size, err := r.storage.Get(ctx, sourcePath)
+	// 源仓库必须可见该Blob（防止通过 mount 探测其他项目的数据）
+	path, size, err := r.resolveBlobPath(ctx, sourceProject, fullDigest)
 	if err != nil {
 		return err
 	}
 
-	// 复制到目标位置
-	return r.storage.Put(ctx, destPath, reader, size)
+	// 源仓库仍是旧版项目内数据：先迁移到全局存储
+	if path != BuildGlobalBlobPath(fullDigest) {
+		if size, err = r.promoteLegacyBlob(ctx, sourceProject, fullDigest); err != nil {
+			return err
+		}
+	}
+
+	return r.linkBlob(ctx, project, fullDigest, size)
 }
 
 // DeleteBlob 删除Blob
 // DELETE /v2/<name>/blobs/<digest>
+// 只删除该仓库的链接记录（以及旧版项目内数据），全局数据由垃圾回收统一清理。
 func (r *Registry) DeleteBlob(ctx context.Context, project, digest string) error {
 	// 验证摘要格式
-	algorithm, hexDigest, err := ParseDigest(digest)
+	fullDigest, err := normalizeDigest(digest)
 	if err != nil {
 		return err
 	}
 
-	path := BuildBlobPath(project, algorithm+":"+hexDigest)
-	return r.storage.Delete(ctx, path)
-}
-
-// GetBlobUploadStatus 获取上传状态
-func (r *Registry) GetBlobUploadStatus(ctx context.Context, project, uploadID string) (*UploadInfo, error) {
-	if uploadID == "" {
-		return nil, ErrUploadNotFound
+	linkErr := r.storage.Delete(ctx, BuildBlobLinkPath(project, fullDigest))
+	legacyErr := r.storage.Delete(ctx, BuildBlobPath(project, fullDigest))
+	if linkErr != nil && !errors.Is(linkErr, response.ErrNotFound) {
+		return linkErr
 	}
-
-	path := fmt.Sprintf("%s/uploads/%s", project, uploadID)
-
-	exists, err := r.storage.Exists(ctx, path)
-	if err != nil {
-		return nil, err
+	if legacyErr != nil && !errors.Is(legacyErr, response.ErrNotFound) {
+		return legacyErr
 	}
-
-	if !exists {
-		return nil, ErrUploadNotFound
+	if linkErr != nil && legacyErr != nil {
+		return ErrBlobNotFound
 	}
+	return r.unindexLinks(ctx, project, fullDigest)
+}
 
-	size, _, err := r.storage.Stat(ctx, path)
+// GetBlobUploadStatus 获取上传状态
+// 返回持久化的会话记录，Size 即客户端续传时应使用的偏移
+func (r *Registry) GetBlobUploadStatus(ctx context.Context, project, uploadID string) (*UploadInfo, error) {
+	info, _, err := r.loadUploadSession(ctx, project, uploadID)
 	if err != nil {
 		return nil, err
 	}
-
-	return &UploadInfo{
-		UUID:        uploadID,
-		Project:     project,
-		Size:        size,
-		LastUpdated: time.Now(),
-	}, nil
+	info.HashState = nil
+	return info, nil
 }
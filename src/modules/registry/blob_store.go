@@ -0,0 +1,240 @@
+// Package registry Docker Registry API模块
+// 实现Docker Registry HTTP API V2规范
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/cyp-registry/registry/src/pkg/response"
+)
+
+// 全局内容寻址存储布局：
+//
+//	_blobs/<algorithm>/<hex>               Blob 数据，全局只存一份
+//	<repository>/_links/<algorithm>/<hex>  仓库级链接记录（JSON），表示该仓库可见此 Blob
+//
+// 同一层被推送到多个项目、或通过 mount 跨仓库挂载时，只新增链接记录，不再复制数据。
+// 旧版按项目存放的 <repository>/blobs/sha256/<digest> 数据仍可读取（兼容历史数据）。
+const (
+	globalBlobRoot = "_blobs"
+	blobLinkDir    = "_links"
+)
+
+// BlobLink 仓库级 Blob 链接记录
+type BlobLink struct {
+	Digest    string    `json:"digest"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// StorageUsage 存储用量统计
+// LogicalBytes 按仓库链接累加（同一 Blob 被多个仓库引用时重复计算），
+// PhysicalBytes 为去重后实际占用的字节数。
+type StorageUsage struct {
+	LogicalBytes  int64 `json:"logical_bytes"`
+	PhysicalBytes int64 `json:"physical_bytes"`
+	LinkCount     int64 `json:"link_count"`
+	BlobCount     int64 `json:"blob_count"`
+}
+
+// BuildGlobalBlobPath 构建全局Blob数据路径
+// 路径格式: _blobs/<algorithm>/<hex>
+func BuildGlobalBlobPath(digest string) string {
+	algorithm, hexDigest, err := ParseDigest(digest)
+	if err != nil {
+		return fmt.Sprintf("%s/invalid/%s", globalBlobRoot, digest)
+	}
+	return fmt.Sprintf("%s/%s/%s", globalBlobRoot, algorithm, hexDigest)
+}
+
+// BuildBlobLinkPath 构建仓库级Blob链接路径
+// 路径格式: <project>/_links/<algorithm>/<hex>
+func BuildBlobLinkPath(project, digest string) string {
+	algorithm, hexDigest, err := ParseDigest(digest)
+	if err != nil {
+		return fmt.Sprintf("%s/%s/invalid/%s", project, blobLinkDir, digest)
+	}
+	return fmt.Sprintf("%s/%s/%s/%s", project, blobLinkDir, algorithm, hexDigest)
+}
+
+// normalizeDigest 校验并返回规范化的 algorithm:hex 摘要
+func normalizeDigest(digest string) (string, error) {
+	algorithm, hexDigest, err := ParseDigest(digest)
+	if err != nil {
+		return "", err
+	}
+	return algorithm + ":" + hexDigest, nil
+}
+
+// readBlobLink 读取仓库级链接记录，不存在时返回 ErrBlobNotFound
+func (r *Registry) readBlobLink(ctx context.Context, project, digest string) (*BlobLink, error) {
+	reader, _, err := r.storage.Get(ctx, BuildBlobLinkPath(project, digest))
+	if err != nil {
+		if errors.Is(err, response.ErrNotFound) {
+			return nil, ErrBlobNotFound
+		}
+		return nil, err
+	}
+	if closer, ok := reader.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	var link BlobLink
+	if err := json.NewDecoder(reader).Decode(&link); err != nil {
+		return nil, fmt.Errorf("invalid blob link: %w", err)
+	}
+	return &link, nil
+}
+
+// writeBlobLink 写入仓库级链接记录（幂等）并更新链接索引
+func (r *Registry) writeBlobLink(ctx context.Context, project, digest string, size int64) error {
+	data, err := json.Marshal(BlobLink{
+		Digest:    digest,
+		Size:      size,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	if err := r.storage.Put(ctx, BuildBlobLinkPath(project, digest), bytes.NewReader(data), int64(len(data))); err != nil {
+		return err
+	}
+	return r.indexLink(ctx, project, digest, size)
+}
+
+// globalBlobSize 返回全局Blob大小，不存在时返回 ErrBlobNotFound
+func (r *Registry) globalBlobSize(ctx context.Context, digest string) (int64, error) {
+	size, _, err := r.storage.Stat(ctx, BuildGlobalBlobPath(digest))
+	if err != nil {
+		if errors.Is(err, response.ErrNotFound) {
+			return 0, ErrBlobNotFound
+		}
+		return 0, err
+	}
+	return size, nil
+}
+
+// resolveBlobPath 将仓库内的 Blob 解析为实际数据路径
+// 优先使用链接记录指向的全局数据，其次回退到旧版项目内数据。
+func (r *Registry) resolveBlobPath(ctx context.Context, project, digest string) (string, int64, error) {
+	if _, err := r.readBlobLink(ctx, project, digest); err == nil {
+		size, err := r.globalBlobSize(ctx, digest)
+		if err != nil {
+			return "", 0, err
+		}
+		return BuildGlobalBlobPath(digest), size, nil
+	} else if !errors.Is(err, ErrBlobNotFound) {
+		return "", 0, err
+	}
+
+	legacyPath := BuildBlobPath(project, digest)
+	size, _, err := r.storage.Stat(ctx, legacyPath)
+	if err != nil {
+		if errors.Is(err, response.ErrNotFound) {
+			return "", 0, ErrBlobNotFound
+		}
+		return "", 0, err
+	}
+	return legacyPath, size, nil
+}
+
+// storeGlobalBlob 将已校验摘要的数据写入全局存储（已存在则跳过写入）
+func (r *Registry) storeGlobalBlob(ctx context.Context, digest string, reader io.Reader, size int64) error {
+	if _, err := r.globalBlobSize(ctx, digest); err == nil {
+		return nil
+	} else if !errors.Is(err, ErrBlobNotFound) {
+		return err
+	}
+	return r.storage.Put(ctx, BuildGlobalBlobPath(digest), reader, size)
+}
+
+// promoteLegacyBlob 将旧版项目内 Blob 迁移到全局存储，并为该仓库写入链接
+func (r *Registry) promoteLegacyBlob(ctx context.Context, project, digest string) (int64, error) {
+	legacyPath := BuildBlobPath(project, digest)
+	reader, size, err := r.storage.Get(ctx, legacyPath)
+	if err != nil {
+		if errors.Is(err, response.ErrNotFound) {
+			return 0, ErrBlobNotFound
+		}
+		return 0, err
+	}
+	if closer, ok := reader.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	if err := r.storeGlobalBlob(ctx, digest, reader, size); err != nil {
+		return 0, err
+	}
+	if err := r.writeBlobLink(ctx, project, digest, size); err != nil {
+		return 0, err
+	}
+
+	log.Printf(`{"timestamp":"%s","level":"info","module":"registry","operation":"promote_legacy_blob","repository":"%s","digest":"%s","size":%d}`, time.Now().Format(time.RFC3339), project, digest, size)
+	return size, nil
+}
+
+// GetUsage 统计 prefix（项目名或仓库名，空字符串表示全部）下的存储用量
+// 逻辑大小按仓库链接累加；物理大小对同一摘要只计一次。
+// 设置了链接索引时直接查询索引（旧版项目内 Blob 在重建索引时按链接计入）；
+// 否则扫描存储并逐个读取链接，旧版项目内 Blob 按实际副本计算。
+func (r *Registry) GetUsage(ctx context.Context, prefix string) (*StorageUsage, error) {
+	if r.linkIndex != nil {
+		return r.linkIndex.Usage(ctx, prefix)
+	}
+
+	usage := &StorageUsage{}
+	distinct := make(map[string]struct{})
+
+	err := r.walkStorage(ctx, prefix, func(path string, isDir bool) error {
+		if isDir {
+			if path == globalBlobRoot || path == layerFilesDir {
+				return errSkipDir
+			}
+			return nil
+		}
+
+		switch {
+		case strings.Contains(path, "/"+blobLinkDir+"/"):
+			parts := strings.Split(path, "/")
+			if len(parts) < 3 {
+				return nil
+			}
+			digest := parts[len(parts)-2] + ":" + parts[len(parts)-1]
+
+			var size int64
+			if link, err := r.readBlobLink(ctx, path[:strings.Index(path, "/"+blobLinkDir+"/")], digest); err == nil {
+				size = link.Size
+			}
+			usage.LogicalBytes += size
+			usage.LinkCount++
+			if _, ok := distinct[digest]; !ok {
+				distinct[digest] = struct{}{}
+				usage.PhysicalBytes += size
+				usage.BlobCount++
+			}
+		case strings.Contains(path, "/blobs/sha256/"):
+			size, _, err := r.storage.Stat(ctx, path)
+			if err != nil {
+				return nil
+			}
+			usage.LogicalBytes += size
+			usage.PhysicalBytes += size
+			usage.LinkCount++
+			usage.BlobCount++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return usage, nil
+}
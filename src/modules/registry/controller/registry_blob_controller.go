@@ -2,7 +2,7 @@
 package registry_controller
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -111,52 +111,158 @@ func (c *RegistryController) GetBlob(ctx *gin.Context) {
 		return
 	}
 
-	// 获取Blob
-	reader, size, err := c.registry.GetBlob(ctx.Request.Context(), project, digest)
-	if err != nil {
-		// 记录失败日志
-		var userID *uuid.UUID
-		if userIDVal, exists := ctx.Get(middleware.ContextKeyUserID); exists {
-			if userUUID, ok := userIDVal.(uuid.UUID); ok {
-				userID = &userUUID
-			}
+	var userID *uuid.UUID
+	if userIDVal, exists := ctx.Get(middleware.ContextKeyUserID); exists {
+		if userUUID, ok := userIDVal.(uuid.UUID); ok {
+			userID = &userUUID
 		}
-		audit.RecordError(ctx.Request.Context(), "get_blob", "image", nil, userID, ctx.ClientIP(), ctx.Request.UserAgent(), err, map[string]interface{}{
+	}
+
+	// 获取Blob大小（同时确认 Blob 在该仓库中可见）
+	size, err := c.registry.GetBlobSize(ctx.Request.Context(), project, digest)
+	if err != nil {
+		c.abortGetBlob(ctx, project, digest, userID, err)
+		return
+	}
+
+	// 存储驱动启用了重定向时，返回预签名地址，由客户端直接从对象存储下载（Range 请求同样由对象存储处理）
+	if location, rErr := c.registry.BlobRedirectURL(ctx.Request.Context(), project, digest); rErr != nil {
+		log.Printf(`{"timestamp":"%s","level":"warn","module":"registry","operation":"get_blob","repository":"%s","digest":"%s","error":"presign failed: %v"}`, time.Now().Format(time.RFC3339), project, digest, rErr)
+	} else if location != "" {
+		audit.Record(ctx.Request.Context(), "get_blob", "image", nil, userID, ctx.ClientIP(), ctx.Request.UserAgent(), map[string]interface{}{
 			"repository": project,
 			"digest":     digest,
-			"not_found":  err == registry.ErrBlobNotFound,
+			"size":       size,
+			"redirect":   true,
 		})
-		// Blob 不存在：返回 404；其他错误：500
+		ctx.Header("Docker-Content-Digest", digest)
 		ctx.Header("Docker-Distribution-Api-Version", "registry/2.0")
-		if err == registry.ErrBlobNotFound {
-			ctx.AbortWithStatus(http.StatusNotFound)
-		} else {
-			ctx.AbortWithStatus(http.StatusInternalServerError)
-		}
+		ctx.Redirect(http.StatusTemporaryRedirect, location)
 		return
 	}
 
-	// 设置响应头
-	ctx.Header("Content-Length", strconv.FormatInt(size, 10))
+	etag := `"` + digest + `"`
 	ctx.Header("Docker-Content-Digest", digest)
 	ctx.Header("Accept-Ranges", "bytes")
+	ctx.Header("Etag", etag)
 	ctx.Header("Docker-Distribution-Api-Version", "registry/2.0")
 
-	// 记录成功日志
-	var userID *uuid.UUID
-	if userIDVal, exists := ctx.Get(middleware.ContextKeyUserID); exists {
-		if userUUID, ok := userIDVal.(uuid.UUID); ok {
-			userID = &userUUID
+	// 解析 Range 请求头（If-Range 与当前 Blob 不一致时忽略 Range，返回完整内容）
+	start, length, ranged, ok := parseByteRange(ctx.GetHeader("Range"), size)
+	if ifRange := ctx.GetHeader("If-Range"); ifRange != "" && ifRange != etag {
+		ranged, ok = false, true
+	}
+	if !ok {
+		ctx.Header("Content-Range", fmt.Sprintf("bytes */%d", size))
+		ctx.AbortWithStatus(http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+
+	var reader io.Reader
+	status := http.StatusOK
+	if ranged {
+		var n int64
+		reader, n, err = c.registry.GetBlobRange(ctx.Request.Context(), project, digest, start, length)
+		if err == nil {
+			status = http.StatusPartialContent
+			ctx.Header("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, start+n-1, size))
+			length = n
+		}
+	} else {
+		reader, length, err = c.registry.GetBlob(ctx.Request.Context(), project, digest)
+	}
+	if err != nil {
+		if errors.Is(err, registry.ErrBlobRangeInvalid) {
+			ctx.Header("Content-Range", fmt.Sprintf("bytes */%d", size))
+			ctx.AbortWithStatus(http.StatusRequestedRangeNotSatisfiable)
+			return
 		}
+		c.abortGetBlob(ctx, project, digest, userID, err)
+		return
 	}
-	audit.Record(ctx.Request.Context(), "get_blob", "image", nil, userID, ctx.ClientIP(), ctx.Request.UserAgent(), map[string]interface{}{
+	if closer, ok := reader.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	// 记录成功日志
+	details := map[string]interface{}{
 		"repository": project,
 		"digest":     digest,
 		"size":       size,
-	})
+	}
+	if ranged {
+		details["range"] = fmt.Sprintf("%d-%d", start, start+length-1)
+	}
+	audit.Record(ctx.Request.Context(), "get_blob", "image", nil, userID, ctx.ClientIP(), ctx.Request.UserAgent(), details)
 
 	// 返回数据
-	ctx.DataFromReader(http.StatusOK, size, "", reader, nil)
+	ctx.DataFromReader(status, length, "", reader, nil)
+}
+
+// abortGetBlob 记录 Blob 下载失败并返回错误（Blob 不存在：404；其他错误：500）
+func (c *RegistryController) abortGetBlob(ctx *gin.Context, project, digest string, userID *uuid.UUID, err error) {
+	notFound := errors.Is(err, registry.ErrBlobNotFound)
+	audit.RecordError(ctx.Request.Context(), "get_blob", "image", nil, userID, ctx.ClientIP(), ctx.Request.UserAgent(), err, map[string]interface{}{
+		"repository": project,
+		"digest":     digest,
+		"not_found":  notFound,
+	})
+	ctx.Header("Docker-Distribution-Api-Version", "registry/2.0")
+	if notFound {
+		ctx.AbortWithStatus(http.StatusNotFound)
+	} else if registry.IsUpstreamError(err) {
+		ctx.AbortWithStatus(http.StatusBadGateway)
+	} else {
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+	}
+}
+
+// parseByteRange 解析单个字节范围（RFC 7233），返回起始偏移与长度
+// ranged=false 表示没有（或忽略）Range 头，应返回完整内容；ok=false 表示范围无法满足（416）。
+// 多段范围与无法识别的格式按规范忽略，返回完整内容。
+func parseByteRange(header string, size int64) (start, length int64, ranged, ok bool) {
+	spec, found := strings.CutPrefix(strings.TrimSpace(header), "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, size, false, true
+	}
+	first, last, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return 0, size, false, true
+	}
+
+	if first == "" {
+		// 后缀范围：bytes=-N 表示最后 N 个字节
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < 0 {
+			return 0, size, false, true
+		}
+		if n == 0 || size == 0 {
+			return 0, 0, true, false
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, n, true, true
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return 0, size, false, true
+	}
+	if start >= size {
+		return 0, 0, true, false
+	}
+	end := size - 1
+	if last != "" {
+		e, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || e < start {
+			return 0, size, false, true
+		}
+		if e < end {
+			end = e
+		}
+	}
+	return start, end - start + 1, true, true
 }
 
 // InitiateBlobUpload 初始化Blob上传
@@ -207,6 +313,18 @@ func (c *RegistryController) InitiateBlobUpload(ctx *gin.Context) {
 			// 兼容 Docker 客户端：当 mount 失败（源 blob 不存在）时，必须回退到“普通上传初始化”，
 			// 而不是返回自定义 JSON（会导致客户端拿不到 upload Location，进而出现 `https:?digest=...` 这类无 Host URL）。
 			// 参考：OCI Distribution / Docker Registry 挂载失败应返回 202 并提供上传地址（或直接走普通上传流程）。
+			if errors.Is(err, registry.ErrGCInProgress) {
+				abortGCInProgress(ctx)
+				return
+			}
+			if errors.Is(err, registry.ErrQuotaExceeded) {
+				abortQuotaExceeded(ctx, err)
+				return
+			}
+			if errors.Is(err, registry.ErrProxyReadOnly) {
+				abortProxyReadOnly(ctx)
+				return
+			}
 			if err != registry.ErrBlobNotFound {
 				ctx.Header("Docker-Distribution-Api-Version", "registry/2.0")
 				ctx.AbortWithStatus(http.StatusInternalServerError)
@@ -234,15 +352,10 @@ func (c *RegistryController) InitiateBlobUpload(ctx *gin.Context) {
 	// Docker 客户端可能在 POST 请求中直接包含 digest 参数和请求体
 	digest := ctx.Query("digest")
 	if digest != "" && ctx.Request.ContentLength > 0 {
-		// Monolithic upload：直接完成上传
-		body, err := io.ReadAll(ctx.Request.Body)
-		if err != nil {
-			response.Fail(ctx, 10001, "failed to read request body")
-			return
-		}
-
+		// Monolithic upload：直接完成上传（请求体流式写入上传会话）
 		// 初始化上传
-		info, err := c.registry.InitiateBlobUpload(ctx.Request.Context(), project)
+		uploaderID, _ := c.getOwnerIDFromContext(ctx)
+		info, err := c.registry.InitiateBlobUpload(ctx.Request.Context(), project, uploaderID)
 		if err != nil {
 			// 记录失败日志
 			var userID *uuid.UUID
@@ -256,12 +369,16 @@ func (c *RegistryController) InitiateBlobUpload(ctx *gin.Context) {
 				"digest":     digest,
 				"mode":       "monolithic",
 			})
+			if errors.Is(err, registry.ErrProxyReadOnly) {
+				abortProxyReadOnly(ctx)
+				return
+			}
 			response.Fail(ctx, 50001, "failed to initiate upload")
 			return
 		}
 
 		// 上传数据
-		_, err = c.registry.UploadBlobChunk(ctx.Request.Context(), project, info.UUID, 0, bytes.NewReader(body), int64(len(body)))
+		uploaded, err := c.registry.UploadBlobChunk(ctx.Request.Context(), project, info.UUID, registry.UploadAppend, ctx.Request.Body, ctx.Request.ContentLength)
 		if err != nil {
 			// 记录失败日志
 			var userID *uuid.UUID
@@ -280,7 +397,7 @@ func (c *RegistryController) InitiateBlobUpload(ctx *gin.Context) {
 		}
 
 		// 完成上传
-		err = c.registry.CompleteBlobUpload(ctx.Request.Context(), project, info.UUID, digest, int64(len(body)))
+		err = c.registry.CompleteBlobUpload(ctx.Request.Context(), project, info.UUID, digest, uploaded)
 		if err != nil {
 			// 记录失败日志
 			var userID *uuid.UUID
@@ -295,6 +412,14 @@ func (c *RegistryController) InitiateBlobUpload(ctx *gin.Context) {
 				"digest":     digest,
 				"mode":       "monolithic",
 			})
+			if errors.Is(err, registry.ErrGCInProgress) {
+				abortGCInProgress(ctx)
+				return
+			}
+			if errors.Is(err, registry.ErrQuotaExceeded) {
+				abortQuotaExceeded(ctx, err)
+				return
+			}
 			response.Fail(ctx, 50001, "failed to complete upload: "+err.Error())
 			return
 		}
@@ -310,7 +435,7 @@ func (c *RegistryController) InitiateBlobUpload(ctx *gin.Context) {
 			"repository": project,
 			"upload_id":  info.UUID,
 			"digest":     digest,
-			"size":       len(body),
+			"size":       uploaded,
 			"mode":       "monolithic",
 		})
 
@@ -323,7 +448,8 @@ func (c *RegistryController) InitiateBlobUpload(ctx *gin.Context) {
 	}
 
 	// 初始化新上传（分片上传模式）
-	info, err := c.registry.InitiateBlobUpload(ctx.Request.Context(), project)
+	uploaderID, _ := c.getOwnerIDFromContext(ctx)
+	info, err := c.registry.InitiateBlobUpload(ctx.Request.Context(), project, uploaderID)
 	if err != nil {
 		// 记录失败日志
 		var userID *uuid.UUID
@@ -337,6 +463,10 @@ func (c *RegistryController) InitiateBlobUpload(ctx *gin.Context) {
 			"mount":      mount,
 			"from":       from,
 		})
+		if errors.Is(err, registry.ErrProxyReadOnly) {
+			abortProxyReadOnly(ctx)
+			return
+		}
 		response.Fail(ctx, 50001, "failed to initiate upload")
 		return
 	}
@@ -363,6 +493,14 @@ func (c *RegistryController) InitiateBlobUpload(ctx *gin.Context) {
 	ctx.Status(http.StatusAccepted)
 }
 
+// uploadRange 上传会话已接收数据的 Range 响应头（尚未接收数据时与 distribution 一致返回 0-0）
+func uploadRange(size int64) string {
+	if size <= 0 {
+		return "0-0"
+	}
+	return fmt.Sprintf("0-%d", size-1)
+}
+
 // UploadBlobChunk 上传Blob分片
 // PATCH /v2/<name>/blobs/uploads/<uuid>
 func (c *RegistryController) UploadBlobChunk(ctx *gin.Context) {
@@ -383,28 +521,23 @@ func (c *RegistryController) UploadBlobChunk(ctx *gin.Context) {
 		return
 	}
 
-	// 解析请求头
-	contentRange := ctx.GetHeader("Content-Range")
-	var offset int64 = 0
-	if contentRange != "" {
-		start, _, total, err := registry.ParseContentRange(contentRange)
-		if err == nil {
-			offset = start
-			ctx.Request.ContentLength = total - start
+	// 解析请求头：未带 Content-Range 时追加到会话末尾，带了则校验起始位置与分片长度
+	size := ctx.Request.ContentLength
+	offset := registry.UploadAppend
+	if contentRange := ctx.GetHeader("Content-Range"); contentRange != "" {
+		start, end, err := registry.ParseUploadRange(contentRange)
+		if err != nil || end < start || (size >= 0 && end-start+1 != size) {
+			ctx.Header("Docker-Distribution-Api-Version", "registry/2.0")
+			ctx.AbortWithStatus(http.StatusRequestedRangeNotSatisfiable)
+			return
 		}
+		// 未带 Content-Length（分块传输）时按声明的范围接收，实际长度不符同样返回 416
+		offset = start
+		size = end - start + 1
 	}
 
-	// 读取请求体
-	body, err := io.ReadAll(ctx.Request.Body)
-	if err != nil {
-		response.Fail(ctx, 10001, "failed to read request body")
-		return
-	}
-
-	size := int64(len(body))
-
-	// 上传分片
-	newOffset, err := c.registry.UploadBlobChunk(ctx.Request.Context(), project, uploadID, offset, bytes.NewReader(body), size)
+	// 上传分片：请求体直接流式追加到上传会话，不在内存中缓存
+	newOffset, err := c.registry.UploadBlobChunk(ctx.Request.Context(), project, uploadID, offset, ctx.Request.Body, size)
 	if err != nil {
 		// 记录失败日志
 		var userID *uuid.UUID
@@ -419,6 +552,20 @@ func (c *RegistryController) UploadBlobChunk(ctx *gin.Context) {
 			"offset":     offset,
 			"size":       size,
 		})
+		if errors.Is(err, registry.ErrUploadOffsetMismatch) || errors.Is(err, registry.ErrUploadRangeMismatch) {
+			// OCI Distribution 规范：分片范围与已接收的数据不衔接或与实际长度不符时返回 416，并通过 Range 告知已接收的范围
+			ctx.Header("Docker-Distribution-Api-Version", "registry/2.0")
+			ctx.Header("Location", fmt.Sprintf("/v2/%s/blobs/uploads/%s", project, uploadID))
+			ctx.Header("Range", uploadRange(newOffset))
+			ctx.Header("Docker-Upload-UUID", uploadID)
+			ctx.AbortWithStatus(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		if errors.Is(err, registry.ErrUploadNotFound) {
+			ctx.Header("Docker-Distribution-Api-Version", "registry/2.0")
+			ctx.AbortWithStatus(http.StatusNotFound)
+			return
+		}
 		response.Fail(ctx, 50001, "failed to upload chunk")
 		return
 	}
@@ -442,7 +589,7 @@ func (c *RegistryController) UploadBlobChunk(ctx *gin.Context) {
 	locationPath := fmt.Sprintf("/v2/%s/blobs/uploads/%s", project, uploadID)
 	ctx.Header("Location", locationPath)
 	ctx.Header("Docker-Upload-UUID", uploadID)
-	ctx.Header("Range", fmt.Sprintf("0-%d", newOffset-1))
+	ctx.Header("Range", uploadRange(newOffset))
 	ctx.Status(http.StatusAccepted)
 }
 
@@ -473,33 +620,26 @@ func (c *RegistryController) CompleteBlobUpload(ctx *gin.Context) {
 		return
 	}
 
-	// 读取请求体（如果有）
-	body, err := io.ReadAll(ctx.Request.Body)
-	if err != nil {
-		ctx.Header("Docker-Distribution-Api-Version", "registry/2.0")
-		ctx.AbortWithStatus(http.StatusBadRequest)
-		return
-	}
-
 	// Docker/BuildKit 可能使用“单次 PUT 完成上传”的模式：
 	// POST /blobs/uploads/ -> PUT /blobs/uploads/<uuid>?digest=... (带请求体)
-	// 此时必须先把本次 PUT 的 body 追加到 upload 临时对象中，否则 CompleteBlobUpload 会对空文件计算 digest 导致失败，
+	// 此时必须先把本次 PUT 的 body 追加到上传会话中，否则 CompleteBlobUpload 会对不完整的数据计算 digest 导致失败，
 	// 且如果错误被包装成 200，会造成客户端误判“已推送”但实际 blob 缺失（进而 Trivy 拉取 404）。
-	if len(body) > 0 {
-		// 尽量从当前上传状态获取 offset，实现幂等追加
-		var offset int64 = 0
-		if info, stErr := c.registry.GetBlobUploadStatus(ctx.Request.Context(), project, uploadID); stErr == nil && info != nil {
-			offset = info.Size
-		}
+	// ContentLength 为 -1 表示分块传输编码，长度未知，同样需要追加。
+	if ctx.Request.ContentLength != 0 {
+		// 追加到会话当前末尾
 		if _, upErr := c.registry.UploadBlobChunk(
 			ctx.Request.Context(),
 			project,
 			uploadID,
-			offset,
-			bytes.NewReader(body),
-			int64(len(body)),
+			registry.UploadAppend,
+			ctx.Request.Body,
+			ctx.Request.ContentLength,
 		); upErr != nil {
 			ctx.Header("Docker-Distribution-Api-Version", "registry/2.0")
+			if errors.Is(upErr, registry.ErrUploadNotFound) {
+				ctx.AbortWithStatus(http.StatusNotFound)
+				return
+			}
 			ctx.AbortWithStatus(http.StatusInternalServerError)
 			return
 		}
@@ -507,7 +647,7 @@ func (c *RegistryController) CompleteBlobUpload(ctx *gin.Context) {
 
 	// 完成上传
 	// size 传 0：由存储层按实际内容校验 digest，并避免客户端在不同上传模式下导致 size mismatch
-	err = c.registry.CompleteBlobUpload(ctx.Request.Context(), project, uploadID, digest, 0)
+	err := c.registry.CompleteBlobUpload(ctx.Request.Context(), project, uploadID, digest, 0)
 	if err != nil {
 		// 记录失败日志
 		var userID *uuid.UUID
@@ -521,7 +661,19 @@ func (c *RegistryController) CompleteBlobUpload(ctx *gin.Context) {
 			"upload_id":  uploadID,
 			"digest":     digest,
 		})
+		if errors.Is(err, registry.ErrGCInProgress) {
+			abortGCInProgress(ctx)
+			return
+		}
+		if errors.Is(err, registry.ErrQuotaExceeded) {
+			abortQuotaExceeded(ctx, err)
+			return
+		}
 		ctx.Header("Docker-Distribution-Api-Version", "registry/2.0")
+		if errors.Is(err, registry.ErrUploadNotFound) {
+			ctx.AbortWithStatus(http.StatusNotFound)
+			return
+		}
 		ctx.AbortWithStatus(http.StatusInternalServerError)
 		return
 	}
@@ -545,3 +697,12 @@ func (c *RegistryController) CompleteBlobUpload(ctx *gin.Context) {
 	ctx.Header("Docker-Content-Digest", digest)
 	ctx.Status(http.StatusCreated)
 }
+
+// abortQuotaExceeded 项目去重后的存储用量将超出配额：拒绝推送，返回 403 DENIED
+func abortQuotaExceeded(ctx *gin.Context, err error) {
+	ctx.Header("Docker-Distribution-Api-Version", "registry/2.0")
+	ctx.AbortWithStatusJSON(http.StatusForbidden, registry.APIError{Errors: []registry.ErrorDetail{{
+		Code:    "DENIED",
+		Message: err.Error(),
+	}}})
+}
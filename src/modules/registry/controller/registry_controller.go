@@ -5,6 +5,8 @@ package registry_controller
 
 import (
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -33,6 +35,8 @@ type RegistryController struct {
 	projectSvc     project.Service
 	userSvc        *user_service.Service
 	whSvc          *webhook_service.WebhookService
+	mirrorProject  string
+	pullGuard      PullGuard
 }
 
 // TokenEndpoint Docker Registry Bearer Token 端点（最小可用实现）
@@ -134,6 +138,10 @@ func parseRepoPath(path string) (project, subPath string, ok bool) {
 	if len(parts) == 2 {
 		return parts[0], "tags/" + parts[1], true
 	}
+	parts = strings.SplitN(path, "/referrers/", 2)
+	if len(parts) == 2 {
+		return parts[0], "referrers/" + parts[1], true
+	}
 	return "", "", false
 }
 
@@ -167,10 +175,11 @@ func (c *RegistryController) dispatchRepoRequest(ctx *gin.Context) {
 		response.Fail(ctx, 10001, "invalid repository path")
 		return
 	}
+	project = c.mirrorProjectPath(ctx, project)
 	ctx.Set("_project", project)
 
 	// 根据 subPath 和 method 分发
-	// subPath 格式: manifests/<ref>, blobs/<digest>, tags/list, blobs/uploads/, blobs/uploads/<uuid>
+	// subPath 格式: manifests/<ref>, blobs/<digest>, tags/list, blobs/uploads/, blobs/uploads/<uuid>, referrers/<digest>
 	switch {
 	case strings.HasPrefix(subPath, "manifests/"):
 		ref := strings.TrimPrefix(subPath, "manifests/")
@@ -225,6 +234,15 @@ func (c *RegistryController) dispatchRepoRequest(ctx *gin.Context) {
 				ctx.Status(http.StatusMethodNotAllowed)
 			}
 		}
+	case strings.HasPrefix(subPath, "referrers/"):
+		// OCI Distribution 1.1: GET /v2/<name>/referrers/<digest>
+		ctx.Set("_reference", strings.TrimPrefix(subPath, "referrers/"))
+		switch ctx.Request.Method {
+		case http.MethodGet, http.MethodHead:
+			c.GetReferrers(ctx)
+		default:
+			ctx.Status(http.StatusMethodNotAllowed)
+		}
 	case strings.HasPrefix(subPath, "tags/"):
 		if strings.TrimPrefix(subPath, "tags/") == "list" {
 			c.ListTags(ctx)
@@ -572,7 +590,13 @@ func (c *RegistryController) GetBlobUploadStatus(ctx *gin.Context) {
 			"repository": project,
 			"upload_id":  uploadID,
 		})
-		response.Fail(ctx, 20001, "upload not found")
+		// 会话不存在或已过期：返回 404，客户端据此重新发起上传
+		ctx.Header("Docker-Distribution-Api-Version", "registry/2.0")
+		if errors.Is(err, registry.ErrUploadNotFound) {
+			ctx.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		ctx.AbortWithStatus(http.StatusInternalServerError)
 		return
 	}
 
@@ -589,17 +613,22 @@ func (c *RegistryController) GetBlobUploadStatus(ctx *gin.Context) {
 		"size":       info.Size,
 	})
 
+	// 客户端断线重连后据 Range 从正确偏移续传（会话记录在共享存储中，任一副本均可应答）
+	ctx.Header("Docker-Distribution-Api-Version", "registry/2.0")
+	ctx.Header("Location", fmt.Sprintf("/v2/%s/blobs/uploads/%s", project, uploadID))
 	ctx.Header("Docker-Upload-UUID", uploadID)
-	ctx.Header("Range", fmt.Sprintf("0-%d", info.Size-1))
+	ctx.Header("Range", uploadRange(info.Size))
 	ctx.Status(http.StatusNoContent)
 }
 
-// getReferrers 获取引用列表
-// GET /v2/<name>/manifests/<reference>/referrers
+// GetReferrers 获取引用指定 Manifest 的 referrers（签名、SBOM、attestation 等）
+// GET /v2/<name>/referrers/<digest>[?artifactType=<type>]
+// 兼容旧路径 GET /v2/<name>/manifests/<digest>/referrers
+// 返回 OCI 镜像索引；按 artifactType 过滤时设置 OCI-Filters-Applied 响应头
 func (c *RegistryController) GetReferrers(ctx *gin.Context) {
 	project := getProjectParam(ctx)
-	// 这里的 reference 实际上应该是 manifest 的 digest（符合 ORAS Referrers API 设计）
 	digest := getRepoParam(ctx, "reference")
+	artifactType := ctx.Query("artifactType")
 
 	// 检查读取权限
 	hasPermission, errorCode, errorMessage := c.checkProjectPermission(ctx, project, "pull")
@@ -619,8 +648,18 @@ func (c *RegistryController) GetReferrers(ctx *gin.Context) {
 		return
 	}
 
-	referrers, err := c.registry.ManifestReferrers(ctx.Request.Context(), project, digest)
+	index, filtered, err := c.registry.GetReferrers(ctx.Request.Context(), project, digest, artifactType)
 	if err != nil {
+		ctx.Header("Docker-Distribution-Api-Version", "registry/2.0")
+		if errors.Is(err, registry.ErrInvalidDigest) {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, registry.APIError{Errors: []registry.ErrorDetail{{
+				Code:    "DIGEST_INVALID",
+				Message: "invalid digest",
+				Detail:  map[string]string{"digest": digest},
+			}}})
+			return
+		}
+
 		// 记录失败日志
 		var userID *uuid.UUID
 		if userIDVal, exists := ctx.Get(middleware.ContextKeyUserID); exists {
@@ -632,29 +671,28 @@ func (c *RegistryController) GetReferrers(ctx *gin.Context) {
 			"repository": project,
 			"digest":     digest,
 		})
-		response.Fail(ctx, 50001, "failed to get referrers")
+		ctx.AbortWithStatus(http.StatusInternalServerError)
 		return
 	}
 
-	// 记录成功日志
-	var userID *uuid.UUID
-	if userIDVal, exists := ctx.Get(middleware.ContextKeyUserID); exists {
-		if userUUID, ok := userIDVal.(uuid.UUID); ok {
-			userID = &userUUID
-		}
+	data, err := json.Marshal(index)
+	if err != nil {
+		ctx.Header("Docker-Distribution-Api-Version", "registry/2.0")
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
 	}
-	audit.Record(ctx.Request.Context(), "get_referrers", "image", nil, userID, ctx.ClientIP(), ctx.Request.UserAgent(), map[string]interface{}{
-		"repository": project,
-		"digest":     digest,
-		"count":      len(referrers),
-	})
 
-	// 返回referrers
-	result := gin.H{
-		"referrers": referrers,
+	ctx.Header("Docker-Distribution-Api-Version", "registry/2.0")
+	if filtered {
+		ctx.Header("OCI-Filters-Applied", "artifactType")
 	}
-
-	response.Success(ctx, result)
+	ctx.Header("Content-Length", strconv.Itoa(len(data)))
+	if ctx.Request.Method == http.MethodHead {
+		ctx.Header("Content-Type", registry.MediaTypeOCIManifestIndex)
+		ctx.Status(http.StatusOK)
+		return
+	}
+	ctx.Data(http.StatusOK, registry.MediaTypeOCIManifestIndex, data)
 }
 
 // getOwnerIDFromContext 从上下文中获取ownerID（用于自动创建项目）
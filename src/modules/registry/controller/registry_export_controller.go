@@ -0,0 +1,101 @@
+// Package registry_controller 提供镜像导出（OCI image layout 归档）的 HTTP 处理
+package registry_controller
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/cyp-registry/registry/src/middleware"
+	"github.com/cyp-registry/registry/src/modules/registry"
+	"github.com/cyp-registry/registry/src/pkg/audit"
+	"github.com/cyp-registry/registry/src/pkg/response"
+)
+
+// ExportImages 将所选仓库、标签或 digest 导出为 OCI image layout 格式的 tar 流
+// GET /api/v1/images/export?image=<project>/<image>[:tag|@digest]&image=...&referrers=false
+// 未指定标签或 digest 时导出仓库的全部标签；默认包含签名、SBOM 等 referrer。
+func (c *RegistryController) ExportImages(ctx *gin.Context) {
+	images := ctx.QueryArray("image")
+	if len(images) == 0 {
+		response.ParamError(ctx, "请至少指定一个 image 参数")
+		return
+	}
+
+	targets := make([]registry.ExportTarget, 0, len(images))
+	for _, image := range images {
+		target, err := registry.ParseExportTarget(image)
+		if err != nil {
+			response.ParamError(ctx, "镜像引用不合法: "+image)
+			return
+		}
+		hasPermission, errorCode, errorMessage := c.checkProjectPermission(ctx, target.Repository, "pull")
+		if !hasPermission {
+			if errorCode != 0 {
+				response.Fail(ctx, errorCode, errorMessage)
+			} else {
+				response.Forbidden(ctx, "权限不足")
+			}
+			return
+		}
+		targets = append(targets, target)
+	}
+
+	opts := registry.ExportOptions{}
+	if v := ctx.Query("referrers"); v != "" {
+		include, err := strconv.ParseBool(v)
+		if err != nil {
+			response.ParamError(ctx, "referrers 参数不合法")
+			return
+		}
+		opts.SkipReferrers = !include
+	}
+
+	// 先解析全部 Manifest：目标不存在等错误在开始传输前以 JSON 返回
+	export, err := c.registry.PrepareExport(ctx.Request.Context(), targets, opts)
+	if err != nil {
+		if errors.Is(err, registry.ErrManifestNotFound) {
+			response.NotFound(ctx, err.Error())
+			return
+		}
+		response.InternalServerError(ctx, "准备导出失败: "+err.Error())
+		return
+	}
+	// 导出等同于拉取：按名称导出的每个镜像都需满足漏洞拉取策略
+	if !c.checkImagesPullPolicy(ctx, export.Images()) {
+		return
+	}
+	summary := export.Summary()
+
+	var userID *uuid.UUID
+	if userIDVal, exists := ctx.Get(middleware.ContextKeyUserID); exists {
+		if userUUID, ok := userIDVal.(uuid.UUID); ok {
+			userID = &userUUID
+		}
+	}
+	audit.Record(ctx.Request.Context(), "export_images", "registry", nil, userID, ctx.ClientIP(), ctx.Request.UserAgent(), map[string]interface{}{
+		"images":    images,
+		"referrers": !opts.SkipReferrers,
+		"manifests": summary.Manifests,
+		"blobs":     summary.Blobs,
+		"bytes":     summary.Bytes,
+	})
+
+	filename := fmt.Sprintf("images-%s.tar", time.Now().Format("20060102-150405"))
+	ctx.Header("Content-Type", "application/x-tar")
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	ctx.Status(200)
+
+	// 传输开始后无法再返回错误响应，只能中断连接：分块响应不会正常结束，客户端得到传输失败而不是被截断的 tar
+	if err := export.WriteTo(ctx.Request.Context(), ctx.Writer); err != nil {
+		log.Printf(`{"timestamp":"%s","level":"error","module":"registry","operation":"export","images":%d,"error":"%v"}`, time.Now().Format(time.RFC3339), len(images), err)
+		panic(http.ErrAbortHandler)
+	}
+	log.Printf(`{"timestamp":"%s","level":"info","module":"registry","operation":"export","images":%d,"manifests":%d,"blobs":%d,"bytes":%d}`, time.Now().Format(time.RFC3339), summary.Images, summary.Manifests, summary.Blobs, summary.Bytes)
+}
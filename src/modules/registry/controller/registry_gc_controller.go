@@ -0,0 +1,109 @@
+// Package registry_controller Registry API控制器
+// 实现Docker Registry HTTP API V2的RESTful接口
+package registry_controller
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/cyp-registry/registry/src/middleware"
+	"github.com/cyp-registry/registry/src/modules/registry"
+	"github.com/cyp-registry/registry/src/pkg/audit"
+	"github.com/cyp-registry/registry/src/pkg/response"
+)
+
+// gcRetryAfterSeconds GC 进行中拒绝推送时建议客户端的重试间隔
+const gcRetryAfterSeconds = "30"
+
+// TriggerGCRequest 触发垃圾回收请求
+type TriggerGCRequest struct {
+	DryRun           bool `json:"dry_run"`
+	DeleteUntagged   bool `json:"delete_untagged"`
+	GracePeriodHours int  `json:"grace_period_hours"` // 0 表示使用默认宽限期
+}
+
+// TriggerGC 手动触发垃圾回收
+// @Summary 触发垃圾回收
+// @Description 在后台执行一次标记-清除垃圾回收（需要管理员权限），dry_run=true 时仅生成报告
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param body body TriggerGCRequest false "回收选项"
+// @Success 20000 {object} response.Response
+// @Failure 30003 {object} response.Response
+// @Security Bearer
+// @Router /api/v1/admin/gc [post]
+func (c *RegistryController) TriggerGC(ctx *gin.Context) {
+	var req TriggerGCRequest
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			response.ParamError(ctx, "参数错误: "+err.Error())
+			return
+		}
+	}
+	if req.GracePeriodHours < 0 {
+		response.ParamError(ctx, "grace_period_hours 不能为负数")
+		return
+	}
+	if c.registry.GCRunning() {
+		response.Conflict(ctx, "垃圾回收正在执行中")
+		return
+	}
+
+	opts := registry.GCOptions{
+		DryRun:         req.DryRun,
+		DeleteUntagged: req.DeleteUntagged,
+		GracePeriod:    time.Duration(req.GracePeriodHours) * time.Hour,
+	}
+
+	var userID *uuid.UUID
+	if userIDVal, exists := ctx.Get(middleware.ContextKeyUserID); exists {
+		if userUUID, ok := userIDVal.(uuid.UUID); ok {
+			userID = &userUUID
+		}
+	}
+	audit.Record(ctx.Request.Context(), "trigger_gc", "registry", nil, userID, ctx.ClientIP(), ctx.Request.UserAgent(), map[string]interface{}{
+		"dry_run":            req.DryRun,
+		"delete_untagged":    req.DeleteUntagged,
+		"grace_period_hours": req.GracePeriodHours,
+	})
+
+	// 回收可能耗时较长，在后台执行；结果通过 GET /api/v1/admin/gc 查询
+	go func() {
+		gcCtx, cancel := context.WithTimeout(context.Background(), 6*time.Hour)
+		defer cancel()
+		_, _ = c.registry.RunGC(gcCtx, opts)
+	}()
+
+	response.SuccessWithMessage(ctx, "垃圾回收已开始", gin.H{
+		"dry_run":         opts.DryRun,
+		"delete_untagged": opts.DeleteUntagged,
+	})
+}
+
+// GetGCStatus 获取垃圾回收状态与最近一次报告
+// @Summary 获取垃圾回收状态
+// @Description 返回是否正在执行垃圾回收以及最近一次回收（或 dry-run）报告（需要管理员权限）
+// @Tags admin
+// @Produce json
+// @Success 20000 {object} response.Response
+// @Failure 30003 {object} response.Response
+// @Security Bearer
+// @Router /api/v1/admin/gc [get]
+func (c *RegistryController) GetGCStatus(ctx *gin.Context) {
+	response.Success(ctx, gin.H{
+		"running":     c.registry.GCRunning(),
+		"last_report": c.registry.LastGCReport(),
+	})
+}
+
+// abortGCInProgress 垃圾回收清理期间拒绝推送：返回 503 并提示客户端稍后重试
+func abortGCInProgress(ctx *gin.Context) {
+	ctx.Header("Docker-Distribution-Api-Version", "registry/2.0")
+	ctx.Header("Retry-After", gcRetryAfterSeconds)
+	ctx.AbortWithStatus(http.StatusServiceUnavailable)
+}
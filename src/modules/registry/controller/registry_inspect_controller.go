@@ -0,0 +1,132 @@
+// Package registry_controller Registry API控制器
+// 实现Docker Registry HTTP API V2的RESTful接口
+package registry_controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	project "github.com/cyp-registry/registry/src/modules/project/service"
+	"github.com/cyp-registry/registry/src/modules/registry"
+	"github.com/cyp-registry/registry/src/pkg/imageroute"
+	"github.com/cyp-registry/registry/src/pkg/response"
+)
+
+// RegisterImageRoutes 注册镜像内容查看接口（/api/v1/projects/:id/images/<name>/<action>）
+func (c *RegistryController) RegisterImageRoutes(r *imageroute.Router) {
+	r.Handle(http.MethodGet, "inspect", c.InspectImage)
+	r.Handle(http.MethodGet, "diff", c.DiffImages)
+	r.Handle(http.MethodGet, "files", c.ListLayerFiles)
+	r.Handle(http.MethodGet, "file", c.DownloadLayerFile)
+}
+
+// InspectImage 查看镜像配置与构建历史
+// GET /api/v1/projects/:id/images/<name>/inspect?reference=<tag|digest>
+// 多架构镜像返回各平台的配置（platforms），单平台镜像返回 image。
+func (c *RegistryController) InspectImage(ctx *gin.Context) {
+	reference := strings.TrimSpace(ctx.Query("reference"))
+	if reference == "" {
+		response.ParamError(ctx, "reference 不能为空")
+		return
+	}
+	repo, ok := c.resolveImageRepo(ctx, "pull")
+	if !ok {
+		return
+	}
+
+	result, err := c.registry.InspectImage(ctx.Request.Context(), repo, reference)
+	if err != nil {
+		c.failImage(ctx, err)
+		return
+	}
+	response.Success(ctx, result)
+}
+
+// DiffImages 对比两个 tag 或 digest 指向的镜像
+// GET /api/v1/projects/:id/images/<name>/diff?from=<tag|digest>&to=<tag|digest>&platform=&files=true
+// 返回镜像层、配置差异；files=true 时根据层文件列表计算文件级差异。
+func (c *RegistryController) DiffImages(ctx *gin.Context) {
+	from := strings.TrimSpace(ctx.Query("from"))
+	to := strings.TrimSpace(ctx.Query("to"))
+	if from == "" || to == "" {
+		response.ParamError(ctx, "from 和 to 不能为空")
+		return
+	}
+	files, err := strconv.ParseBool(ctx.DefaultQuery("files", "false"))
+	if err != nil {
+		response.ParamError(ctx, "files 参数错误")
+		return
+	}
+	repo, ok := c.resolveImageRepo(ctx, "pull")
+	if !ok {
+		return
+	}
+
+	result, err := c.registry.DiffImages(ctx.Request.Context(), repo, from, to, registry.DiffOptions{
+		Platform: strings.TrimSpace(ctx.Query("platform")),
+		Files:    files,
+	})
+	if err != nil {
+		c.failImage(ctx, err)
+		return
+	}
+	response.Success(ctx, result)
+}
+
+// resolveImageRepo 按项目 ID 与镜像名得到完整仓库名（项目名/镜像名）并校验权限，失败时已写入响应
+func (c *RegistryController) resolveImageRepo(ctx *gin.Context, permission string) (string, bool) {
+	image := imageroute.Image(ctx)
+	if c.projectSvc == nil {
+		response.InternalServerError(ctx, "项目服务不可用")
+		return "", false
+	}
+	p, err := c.projectSvc.GetProject(ctx.Request.Context(), ctx.Param("id"))
+	if err != nil {
+		if errors.Is(err, project.ErrProjectNotFound) {
+			response.NotFound(ctx, "项目不存在")
+			return "", false
+		}
+		response.InternalServerError(ctx, "获取项目失败")
+		return "", false
+	}
+
+	repo := p.Name + "/" + image
+	hasPermission, errorCode, errorMessage := c.checkProjectPermission(ctx, repo, permission)
+	if !hasPermission {
+		if errorCode != 0 {
+			response.Fail(ctx, errorCode, errorMessage)
+		} else {
+			response.Forbidden(ctx, "权限不足")
+		}
+		return "", false
+	}
+	return repo, true
+}
+
+// failImage 将镜像内容查看的错误转换为HTTP响应
+func (c *RegistryController) failImage(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, registry.ErrManifestNotFound):
+		response.NotFound(ctx, "镜像不存在")
+	case errors.Is(err, registry.ErrBlobNotFound):
+		response.NotFound(ctx, "镜像数据不完整: "+err.Error())
+	case errors.Is(err, registry.ErrNotImage):
+		response.ParamError(ctx, "不是容器镜像（签名、SBOM 等 artifact 没有镜像配置）")
+	case errors.Is(err, registry.ErrPlatformRequired):
+		response.ParamError(ctx, "多架构镜像需要指定 platform，例如 linux/amd64")
+	case errors.Is(err, registry.ErrInvalidDigest):
+		response.ParamError(ctx, "digest 格式错误")
+	case errors.Is(err, registry.ErrInvalidLayer):
+		response.ParamError(ctx, "不是镜像层（无法按 tar 解析）")
+	case errors.Is(err, registry.ErrLayerFileNotFound):
+		response.NotFound(ctx, "层中不存在该文件")
+	case errors.Is(err, registry.ErrNotRegularFile):
+		response.ParamError(ctx, "只能下载普通文件: "+err.Error())
+	default:
+		response.InternalServerError(ctx, err.Error())
+	}
+}
@@ -0,0 +1,103 @@
+// Package registry_controller Registry API控制器
+// 实现Docker Registry HTTP API V2的RESTful接口
+package registry_controller
+
+import (
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/cyp-registry/registry/src/middleware"
+	"github.com/cyp-registry/registry/src/modules/registry"
+	"github.com/cyp-registry/registry/src/pkg/audit"
+	"github.com/cyp-registry/registry/src/pkg/response"
+)
+
+// ListLayerFiles 列出镜像层中的文件
+// GET /api/v1/projects/:id/images/<name>/files?digest=<layer digest>&prefix=<dir>
+// 支持 gzip、zstd 与未压缩的层；whiteout 条目的 path 为被删除的路径。prefix 只返回该路径及其下的条目。
+func (c *RegistryController) ListLayerFiles(ctx *gin.Context) {
+	digest := strings.TrimSpace(ctx.Query("digest"))
+	if digest == "" {
+		response.ParamError(ctx, "digest 不能为空")
+		return
+	}
+	repo, ok := c.resolveImageRepo(ctx, "pull")
+	if !ok {
+		return
+	}
+
+	listing, err := c.registry.ListLayerFiles(ctx.Request.Context(), repo, digest)
+	if err != nil {
+		c.failImage(ctx, err)
+		return
+	}
+
+	prefix := strings.Trim(path.Clean("/"+ctx.Query("prefix")), "/")
+	if prefix == "" {
+		response.Success(ctx, listing)
+		return
+	}
+	filtered := *listing
+	filtered.Entries = make([]registry.LayerEntry, 0)
+	for _, entry := range listing.Entries {
+		if entry.Path == prefix || strings.HasPrefix(entry.Path, prefix+"/") {
+			filtered.Entries = append(filtered.Entries, entry)
+		}
+	}
+	response.Success(ctx, &filtered)
+}
+
+// DownloadLayerFile 下载镜像层中的单个文件
+// GET /api/v1/projects/:id/images/<name>/file?digest=<layer digest>&path=<file path>
+// 只能下载普通文件（硬链接解析为目标文件）；需要项目拉取权限，并与拉取 Manifest 一样受漏洞拉取策略限制。
+func (c *RegistryController) DownloadLayerFile(ctx *gin.Context) {
+	digest := strings.TrimSpace(ctx.Query("digest"))
+	filePath := strings.TrimSpace(ctx.Query("path"))
+	if digest == "" || filePath == "" {
+		response.ParamError(ctx, "digest 和 path 不能为空")
+		return
+	}
+	repo, ok := c.resolveImageRepo(ctx, "pull")
+	if !ok {
+		return
+	}
+	if !c.checkLayerPullPolicy(ctx, repo, digest) {
+		return
+	}
+
+	file, err := c.registry.OpenLayerFile(ctx.Request.Context(), repo, digest, filePath)
+	if err != nil {
+		c.failImage(ctx, err)
+		return
+	}
+	defer file.Close()
+
+	var userID *uuid.UUID
+	if v, exists := ctx.Get(middleware.ContextKeyUserID); exists {
+		if id, ok := v.(uuid.UUID); ok && id != uuid.Nil {
+			userID = &id
+		}
+	}
+	audit.Record(ctx.Request.Context(), "download_layer_file", "image", nil, userID, ctx.ClientIP(), ctx.Request.UserAgent(), map[string]interface{}{
+		"project_id": ctx.Param("id"),
+		"repository": repo,
+		"digest":     digest,
+		"path":       file.Path,
+		"size":       file.Size,
+	})
+
+	headers := map[string]string{
+		"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": path.Base(file.Path)}),
+		"X-File-Mode":         strconv.FormatInt(file.Mode&07777, 8),
+	}
+	if !file.ModTime.IsZero() {
+		headers["Last-Modified"] = file.ModTime.UTC().Format(http.TimeFormat)
+	}
+	ctx.DataFromReader(http.StatusOK, file.Size, "application/octet-stream", file, headers)
+}
@@ -3,6 +3,7 @@ package registry_controller
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -16,6 +17,7 @@ import (
 	"github.com/cyp-registry/registry/src/middleware"
 	project "github.com/cyp-registry/registry/src/modules/project/service"
 	"github.com/cyp-registry/registry/src/modules/registry"
+	"github.com/cyp-registry/registry/src/modules/webhook"
 	"github.com/cyp-registry/registry/src/pkg/audit"
 	"github.com/cyp-registry/registry/src/pkg/response"
 )
@@ -61,10 +63,20 @@ func (c *RegistryController) GetManifest(ctx *gin.Context) {
 			return
 		}
 		ctx.Header("Docker-Distribution-Api-Version", "registry/2.0")
+		if registry.IsUpstreamError(err) {
+			// 代理项目：上游返回错误或认证失败
+			ctx.AbortWithStatus(http.StatusBadGateway)
+			return
+		}
 		ctx.AbortWithStatus(http.StatusInternalServerError)
 		return
 	}
 
+	// 项目漏洞拉取策略（仅 GET）
+	if !c.checkPullPolicy(ctx, project, reference, digest, manifestData) {
+		return
+	}
+
 	// 解析 manifest 获取 MediaType
 	var manifest registry.Manifest
 	if err := json.Unmarshal(manifestData, &manifest); err != nil {
@@ -134,20 +146,36 @@ func (c *RegistryController) PutManifest(ctx *gin.Context) {
 		contentType = registry.MediaTypeDocker2Manifest
 	}
 
-	// 验证 Manifest 格式（但不使用解析后的数据）
-	var manifest registry.Manifest
-	if err := json.Unmarshal(body, &manifest); err != nil {
-		response.Fail(ctx, 10001, "invalid manifest format")
-		return
-	}
+	// Manifest 格式与引用对象的校验在 PutManifestRaw 中完成（MANIFEST_INVALID / MANIFEST_BLOB_UNKNOWN）
 
 	// 上传Manifest（使用原始字节，避免重新序列化导致的 digest 不匹配）
 	digest, err := c.registry.PutManifestRaw(ctx.Request.Context(), repoName, reference, body, contentType)
 	if err != nil {
-		// 不可覆盖的历史版本标签：返回明确的业务错误码
-		if err == registry.ErrImmutableTag {
-			log.Printf(`{"timestamp":"%s","level":"warn","module":"registry","operation":"push_manifest","repository":"%s","reference":"%s","error":"immutable tag"}`, time.Now().Format(time.RFC3339), repoName, reference)
-			response.Fail(ctx, 20002, "immutable tag cannot be overwritten; please use a new version tag")
+		// 项目 tag 规则拒绝（不可变标签、保留名称、命名规则）：返回 403 DENIED
+		var perr *registry.TagPolicyError
+		if errors.As(err, &perr) {
+			log.Printf(`{"timestamp":"%s","level":"warn","module":"registry","operation":"push_manifest","repository":"%s","reference":"%s","error":%q}`, time.Now().Format(time.RFC3339), repoName, reference, perr.Error())
+			ctx.Header("Docker-Distribution-Api-Version", "registry/2.0")
+			ctx.AbortWithStatusJSON(http.StatusForbidden, registry.APIError{Errors: []registry.ErrorDetail{{
+				Code:    "DENIED",
+				Message: perr.Error(),
+				Detail:  map[string]string{"tag": perr.Tag, "pattern": perr.Pattern},
+			}}})
+			return
+		}
+		if errors.Is(err, registry.ErrGCInProgress) {
+			abortGCInProgress(ctx)
+			return
+		}
+		if errors.Is(err, registry.ErrProxyReadOnly) {
+			abortProxyReadOnly(ctx)
+			return
+		}
+		var verr *registry.ManifestValidationError
+		if errors.As(err, &verr) {
+			log.Printf(`{"timestamp":"%s","level":"warn","module":"registry","operation":"push_manifest","repository":"%s","reference":"%s","code":"%s","error":%q}`, time.Now().Format(time.RFC3339), repoName, reference, verr.Code, verr.Error())
+			ctx.Header("Docker-Distribution-Api-Version", "registry/2.0")
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, verr.APIError())
 			return
 		}
 		log.Printf(`{"timestamp":"%s","level":"error","module":"registry","operation":"push_manifest","repository":"%s","reference":"%s","error":"%v"}`, time.Now().Format(time.RFC3339), repoName, reference, err)
@@ -160,6 +188,10 @@ func (c *RegistryController) PutManifest(ctx *gin.Context) {
 
 	// 设置响应头
 	ctx.Header("Docker-Content-Digest", digest)
+	// OCI Distribution 1.1：带 subject 的 Manifest 已登记到 referrers 索引
+	if subject := registry.ParseManifestSubject(body); subject != "" {
+		ctx.Header("OCI-Subject", subject)
+	}
 	ctx.Status(http.StatusCreated)
 }
 
@@ -320,6 +352,16 @@ func (c *RegistryController) afterManifestPushed(ctx *gin.Context, repoName, ref
 						imageSize = tagData.Size
 					}
 					log.Printf(`{"timestamp":"%s","level":"info","module":"registry","operation":"push_manifest","repository":"%s","reference":"%s","digest":"%s","size":%d,"ip":"%s","project_id":"%s","user_id":"","username":""}`, time.Now().Format(time.RFC3339), repoName, reference, digest, imageSize, ctx.ClientIP(), p.ID)
+
+					// 匿名推送不触发外部 Webhook，但仍广播本地事件（前端刷新、事件触发的镜像复制）
+					webhook.PublishRegistryEvent(webhook.RegistryEvent{
+						Type:       webhook.RegistryEventPush,
+						Repository: repoName,
+						Tag:        reference,
+						Digest:     digest,
+						ProjectID:  p.ID,
+						Timestamp:  time.Now(),
+					})
 				}
 			} else {
 				// 项目不存在且无法识别用户，仍然记录推送成功日志（基本信息）
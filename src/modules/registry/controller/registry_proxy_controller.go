@@ -0,0 +1,48 @@
+// Package registry_controller Registry API控制器
+// 实现Docker Registry HTTP API V2的RESTful接口
+package registry_controller
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/cyp-registry/registry/src/modules/registry"
+)
+
+// SetMirrorProject 设置 Docker 守护进程 registry-mirrors 使用的代理项目
+// 设置后，拉取请求中首段不是已存在项目的仓库（例如 library/nginx）会改写到该项目下。
+func (c *RegistryController) SetMirrorProject(name string) {
+	c.mirrorProject = name
+}
+
+// mirrorProjectPath 将 registry-mirrors 的拉取请求改写到镜像代理项目
+// 仅对 GET/HEAD 生效；首段已是本地项目时保持不变。
+func (c *RegistryController) mirrorProjectPath(ctx *gin.Context, repo string) string {
+	if c.mirrorProject == "" {
+		return repo
+	}
+	if ctx.Request.Method != http.MethodGet && ctx.Request.Method != http.MethodHead {
+		return repo
+	}
+	slug, _, _ := strings.Cut(repo, "/")
+	if slug == c.mirrorProject {
+		return repo
+	}
+	if c.projectSvc != nil {
+		if _, err := c.projectSvc.GetProjectByName(ctx.Request.Context(), slug); err == nil {
+			return repo
+		}
+	}
+	return c.mirrorProject + "/" + repo
+}
+
+// abortProxyReadOnly 代理项目只读：拒绝推送，返回 405 UNSUPPORTED
+func abortProxyReadOnly(ctx *gin.Context) {
+	ctx.Header("Docker-Distribution-Api-Version", "registry/2.0")
+	ctx.AbortWithStatusJSON(http.StatusMethodNotAllowed, registry.APIError{Errors: []registry.ErrorDetail{{
+		Code:    "UNSUPPORTED",
+		Message: registry.ErrProxyReadOnly.Error(),
+	}}})
+}
@@ -0,0 +1,197 @@
+// Package registry_controller Registry API控制器
+// 实现Docker Registry HTTP API V2的RESTful接口
+package registry_controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/cyp-registry/registry/src/middleware"
+	"github.com/cyp-registry/registry/src/modules/registry"
+	"github.com/cyp-registry/registry/src/modules/scanner"
+	"github.com/cyp-registry/registry/src/pkg/audit"
+	"github.com/cyp-registry/registry/src/pkg/response"
+)
+
+// PolicyBypassHeader 管理员紧急拉取时跳过漏洞拉取策略的请求头（值为 true 或 1）
+// 可通过 Docker 客户端 config.json 的 HttpHeaders 设置；非管理员设置无效。
+const PolicyBypassHeader = "X-Registry-Policy-Bypass"
+
+// PullGuard 拉取 Manifest 前的漏洞策略检查（由漏洞扫描模块实现）
+// 不满足策略时返回 *scanner.PullDeniedError，其余错误表示无法完成检查。
+type PullGuard interface {
+	CheckPull(ctx context.Context, repository, digest string, manifest []byte) error
+}
+
+// SetPullGuard 设置拉取检查；未设置时不限制拉取
+func (c *RegistryController) SetPullGuard(g PullGuard) {
+	c.pullGuard = g
+}
+
+// checkPullPolicy 按项目漏洞策略检查 GET Manifest，拒绝时已写入响应并返回 false
+// HEAD 请求只用于查询 digest，不做检查。
+func (c *RegistryController) checkPullPolicy(ctx *gin.Context, repo, reference, digest string, manifest []byte) bool {
+	if c.pullGuard == nil || ctx.Request.Method != http.MethodGet {
+		return true
+	}
+
+	err := c.pullGuard.CheckPull(ctx.Request.Context(), repo, digest, manifest)
+	if err == nil {
+		return true
+	}
+	denied, err := c.evaluatePullDenial(ctx, repo, reference, digest, err)
+	if err != nil {
+		ctx.Header("Docker-Distribution-Api-Version", "registry/2.0")
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return false
+	}
+	if denied == nil {
+		return true
+	}
+
+	ctx.Header("Docker-Distribution-Api-Version", "registry/2.0")
+	ctx.AbortWithStatusJSON(http.StatusForbidden, registry.APIError{Errors: []registry.ErrorDetail{{
+		Code:    "DENIED",
+		Message: denied.Error(),
+		Detail: map[string]interface{}{
+			"digest":          digest,
+			"severity":        denied.Severity,
+			"scan_status":     denied.Status,
+			"vulnerabilities": denied.Count,
+		},
+	}}})
+	return false
+}
+
+// checkImagesPullPolicy 按项目漏洞策略检查通过 REST API 读取镜像内容（导出、下载层中的文件），
+// 全部镜像都满足策略时返回 true，否则已写入响应并返回 false
+func (c *RegistryController) checkImagesPullPolicy(ctx *gin.Context, images []registry.ImageRef) bool {
+	if c.pullGuard == nil {
+		return true
+	}
+	for _, image := range images {
+		err := c.pullGuard.CheckPull(ctx.Request.Context(), image.Repository, image.Digest, image.Manifest)
+		if err == nil {
+			continue
+		}
+		if !c.denyImagePull(ctx, image, err) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkLayerPullPolicy 下载镜像层中的文件前按漏洞策略检查，拒绝时已写入响应并返回 false
+// 包含该层的任一标签满足策略即放行；该层不属于任何标签时无法确认，不放行。
+func (c *RegistryController) checkLayerPullPolicy(ctx *gin.Context, repo, digest string) bool {
+	if c.pullGuard == nil {
+		return true
+	}
+	images, err := c.registry.LayerImages(ctx.Request.Context(), repo, digest)
+	if err != nil {
+		c.failImage(ctx, err)
+		return false
+	}
+	if len(images) == 0 {
+		response.Forbidden(ctx, "镜像层不属于任何标签，无法确认是否满足漏洞拉取策略")
+		return false
+	}
+
+	var firstErr error
+	for _, image := range images {
+		err := c.pullGuard.CheckPull(ctx.Request.Context(), image.Repository, image.Digest, image.Manifest)
+		if err == nil {
+			return true
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return c.denyImagePull(ctx, images[0], firstErr)
+}
+
+// denyImagePull 处理 REST API 拉取检查的失败结果，管理员绕过时返回 true，否则已写入响应并返回 false
+func (c *RegistryController) denyImagePull(ctx *gin.Context, image registry.ImageRef, checkErr error) bool {
+	denied, err := c.evaluatePullDenial(ctx, image.Repository, image.Reference, image.Digest, checkErr)
+	if err != nil {
+		response.InternalServerError(ctx, "检查漏洞拉取策略失败")
+		return false
+	}
+	if denied == nil {
+		return true
+	}
+	response.Forbidden(ctx, fmt.Sprintf("%s/%s: %s", image.Repository, image.Reference, denied.Error()))
+	return false
+}
+
+// evaluatePullDenial 处理拉取检查的失败结果：记录日志与审计，管理员可通过 PolicyBypassHeader 绕过
+// 返回 (nil, nil) 表示放行；无法确认镜像是否满足策略时返回错误（不放行）。
+func (c *RegistryController) evaluatePullDenial(ctx *gin.Context, repo, reference, digest string, checkErr error) (*scanner.PullDeniedError, error) {
+	var userID *uuid.UUID
+	if v, exists := ctx.Get(middleware.ContextKeyUserID); exists {
+		if id, ok := v.(uuid.UUID); ok && id != uuid.Nil {
+			userID = &id
+		}
+	}
+
+	var denied *scanner.PullDeniedError
+	if !errors.As(checkErr, &denied) {
+		log.Printf(`{"timestamp":"%s","level":"error","module":"registry","operation":"pull_policy","repository":"%s","digest":"%s","error":%q}`, time.Now().Format(time.RFC3339), repo, digest, checkErr.Error())
+		return nil, checkErr
+	}
+
+	details := map[string]interface{}{
+		"repository":      repo,
+		"reference":       reference,
+		"digest":          digest,
+		"severity":        denied.Severity,
+		"scan_status":     denied.Status,
+		"vulnerabilities": denied.Count,
+		"cves":            denied.IDs,
+	}
+
+	if bypass := ctx.GetHeader(PolicyBypassHeader); bypass == "true" || bypass == "1" {
+		if c.isAdminRequest(ctx, userID) {
+			log.Printf(`{"timestamp":"%s","level":"warn","module":"registry","operation":"pull_policy_bypass","repository":"%s","digest":"%s","user_id":"%s"}`, time.Now().Format(time.RFC3339), repo, digest, userID.String())
+			audit.Record(ctx.Request.Context(), "pull_policy_bypass", "image", nil, userID, ctx.ClientIP(), ctx.Request.UserAgent(), details)
+			return nil, nil
+		}
+		details["bypass_rejected"] = true
+	}
+
+	log.Printf(`{"timestamp":"%s","level":"warn","module":"registry","operation":"pull_denied","repository":"%s","digest":"%s","error":%q}`, time.Now().Format(time.RFC3339), repo, digest, denied.Error())
+	audit.Record(ctx.Request.Context(), "pull_denied", "image", nil, userID, ctx.ClientIP(), ctx.Request.UserAgent(), details)
+	return denied, nil
+}
+
+// isAdminRequest 当前请求是否来自管理员（PAT 令牌还需要 admin scope）
+func (c *RegistryController) isAdminRequest(ctx *gin.Context, userID *uuid.UUID) bool {
+	if userID == nil || c.userSvc == nil {
+		return false
+	}
+	user, err := c.userSvc.GetUserByID(ctx.Request.Context(), *userID)
+	if err != nil || !user.IsActive || !user.IsAdmin {
+		return false
+	}
+
+	if tokenType, _ := ctx.Get(middleware.ContextKeyTokenType); tokenType == "pat" {
+		scopes, _ := ctx.Get(middleware.ContextKeyPATScopes)
+		list, _ := scopes.([]string)
+		for _, scope := range list {
+			scope = strings.TrimSpace(scope)
+			if scope == "admin" || scope == "admin:*" || scope == "*" {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,423 @@
+// Package registry Docker Registry API模块
+// 实现Docker Registry HTTP API V2规范
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// maxDiffFileChanges 文件差异每一类（新增、删除、修改）返回的条目上限
+const maxDiffFileChanges = 10000
+
+// DiffOptions 镜像对比选项
+type DiffOptions struct {
+	Platform string // 多架构镜像的平台，例如 linux/amd64；只有一个平台时可为空
+	Files    bool   // 是否根据层内容计算文件级差异
+}
+
+// ImageDiff 两个镜像的差异（From 为基准，To 为对比目标）
+type ImageDiff struct {
+	From   DiffImage  `json:"from"`
+	To     DiffImage  `json:"to"`
+	Layers LayerDiff  `json:"layers"`
+	Config ConfigDiff `json:"config"`
+	Files  *FileDiff  `json:"files,omitempty"`
+}
+
+// DiffImage 参与对比的镜像
+type DiffImage struct {
+	Reference    string `json:"reference"`
+	Digest       string `json:"digest"`       // 单平台镜像 Manifest 的 digest
+	IndexDigest  string `json:"index_digest"` // reference 指向多架构索引时为索引的 digest
+	Platform     string `json:"platform"`
+	ConfigDigest string `json:"config_digest"`
+	Size         int64  `json:"size"` // 配置与各层（压缩后）大小之和
+	LayerCount   int    `json:"layer_count"`
+}
+
+// LayerDiff 镜像层差异
+type LayerDiff struct {
+	CommonPrefix int          `json:"common_prefix"` // 从底层开始连续相同的层数
+	Shared       []ImageLayer `json:"shared"`
+	Added        []ImageLayer `json:"added"`   // 仅在 To 中
+	Removed      []ImageLayer `json:"removed"` // 仅在 From 中
+	SharedSize   int64        `json:"shared_size"`
+	AddedSize    int64        `json:"added_size"`
+	RemovedSize  int64        `json:"removed_size"`
+}
+
+// ConfigDiff 镜像配置差异，未变化的字段为空
+type ConfigDiff struct {
+	Platform     *ValueChange `json:"platform,omitempty"`
+	User         *ValueChange `json:"user,omitempty"`
+	WorkingDir   *ValueChange `json:"working_dir,omitempty"`
+	Entrypoint   *ValueChange `json:"entrypoint,omitempty"`
+	Cmd          *ValueChange `json:"cmd,omitempty"`
+	StopSignal   *ValueChange `json:"stop_signal,omitempty"`
+	Env          MapDiff      `json:"env"` // 按变量名对比
+	Labels       MapDiff      `json:"labels"`
+	ExposedPorts SetDiff      `json:"exposed_ports"`
+	Volumes      SetDiff      `json:"volumes"`
+}
+
+// ValueChange 字段的旧值与新值
+type ValueChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// MapDiff 键值对差异
+type MapDiff struct {
+	Added   map[string]string      `json:"added"`
+	Removed map[string]string      `json:"removed"`
+	Changed map[string]ValueChange `json:"changed"`
+}
+
+// SetDiff 集合差异
+type SetDiff struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+}
+
+// FileDiff 合并各层（应用 whiteout）后的文件系统差异
+// 层内容不做哈希：类型、权限、属主、大小、链接目标或修改时间不同视为修改，目录只比较权限与属主。
+type FileDiff struct {
+	Added     []FileChange `json:"added"`
+	Removed   []FileChange `json:"removed"`
+	Modified  []FileChange `json:"modified"`
+	Truncated bool         `json:"truncated"` // 差异条目或层文件列表超过上限
+}
+
+// FileChange 单个路径的变化
+type FileChange struct {
+	Path string     `json:"path"`
+	From *FileState `json:"from,omitempty"`
+	To   *FileState `json:"to,omitempty"`
+}
+
+// FileState 文件在镜像中的状态
+type FileState struct {
+	LayerEntry
+	Layer string `json:"layer"` // 提供该文件的层 digest
+}
+
+// diffTarget 解析后的单平台镜像
+type diffTarget struct {
+	info     DiffImage
+	manifest Manifest
+	config   *ImageConfig
+}
+
+// DiffImages 对比仓库中两个 tag 或 digest 指向的镜像
+func (r *Registry) DiffImages(ctx context.Context, repo, from, to string, opts DiffOptions) (*ImageDiff, error) {
+	a, err := r.resolveDiffTarget(ctx, repo, from, opts.Platform)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", from, err)
+	}
+	b, err := r.resolveDiffTarget(ctx, repo, to, opts.Platform)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", to, err)
+	}
+
+	diff := &ImageDiff{
+		From:   a.info,
+		To:     b.info,
+		Layers: diffLayers(a.manifest.Layers, b.manifest.Layers),
+		Config: diffConfig(a, b),
+	}
+	if opts.Files {
+		files, err := r.diffFiles(ctx, repo, a.manifest.Layers, b.manifest.Layers)
+		if err != nil {
+			return nil, err
+		}
+		diff.Files = files
+	}
+	return diff, nil
+}
+
+// resolveDiffTarget 解析 reference；多架构索引按平台选择子 Manifest
+func (r *Registry) resolveDiffTarget(ctx context.Context, repo, reference, platform string) (*diffTarget, error) {
+	raw, digest, err := r.GetManifestRaw(ctx, repo, reference)
+	if err != nil {
+		return nil, err
+	}
+	target := &diffTarget{info: DiffImage{Reference: reference, Digest: digest}}
+
+	if IsIndexManifest(raw) {
+		child, err := SelectIndexPlatform(raw, platform)
+		if err != nil {
+			return nil, err
+		}
+		target.info.IndexDigest = digest
+		if raw, digest, err = r.GetManifestRaw(ctx, repo, child); err != nil {
+			return nil, err
+		}
+		target.info.Digest = digest
+	}
+	if !IsImageManifest(raw) {
+		return nil, ErrNotImage
+	}
+
+	if err := json.Unmarshal(raw, &target.manifest); err != nil {
+		return nil, fmt.Errorf("parse manifest: %w", err)
+	}
+	if target.config, err = r.ReadImageConfig(ctx, repo, &target.manifest); err != nil {
+		return nil, err
+	}
+	target.info.Platform = platformString(target.config.OS, target.config.Architecture, target.config.Variant)
+	target.info.ConfigDigest = target.manifest.Config.Digest
+	target.info.LayerCount = len(target.manifest.Layers)
+	target.info.Size = target.manifest.Config.Size
+	for _, layer := range target.manifest.Layers {
+		target.info.Size += layer.Size
+	}
+	return target, nil
+}
+
+// diffLayers 按 digest 对比两组镜像层
+func diffLayers(from, to []LayerInfo) LayerDiff {
+	diff := LayerDiff{Shared: []ImageLayer{}, Added: []ImageLayer{}, Removed: []ImageLayer{}}
+	for diff.CommonPrefix < len(from) && diff.CommonPrefix < len(to) &&
+		from[diff.CommonPrefix].Digest == to[diff.CommonPrefix].Digest {
+		diff.CommonPrefix++
+	}
+
+	inFrom := make(map[string]bool, len(from))
+	for _, l := range from {
+		inFrom[l.Digest] = true
+	}
+	inTo := make(map[string]bool, len(to))
+	for _, l := range to {
+		inTo[l.Digest] = true
+	}
+
+	seen := make(map[string]bool)
+	for _, l := range to {
+		layer := ImageLayer{Digest: l.Digest, MediaType: l.MediaType, Size: l.Size}
+		switch {
+		case seen[l.Digest]:
+		case inFrom[l.Digest]:
+			diff.Shared = append(diff.Shared, layer)
+			diff.SharedSize += l.Size
+		default:
+			diff.Added = append(diff.Added, layer)
+			diff.AddedSize += l.Size
+		}
+		seen[l.Digest] = true
+	}
+	for _, l := range from {
+		if inTo[l.Digest] || seen[l.Digest] {
+			continue
+		}
+		seen[l.Digest] = true
+		diff.Removed = append(diff.Removed, ImageLayer{Digest: l.Digest, MediaType: l.MediaType, Size: l.Size})
+		diff.RemovedSize += l.Size
+	}
+	return diff
+}
+
+// diffConfig 对比镜像配置
+func diffConfig(a, b *diffTarget) ConfigDiff {
+	ca, cb := a.config.Config, b.config.Config
+	return ConfigDiff{
+		Platform:     changed(a.info.Platform, b.info.Platform),
+		User:         changed(ca.User, cb.User),
+		WorkingDir:   changed(ca.WorkingDir, cb.WorkingDir),
+		Entrypoint:   changed(nonNil(ca.Entrypoint), nonNil(cb.Entrypoint)),
+		Cmd:          changed(nonNil(ca.Cmd), nonNil(cb.Cmd)),
+		StopSignal:   changed(ca.StopSignal, cb.StopSignal),
+		Env:          diffMap(envMap(ca.Env), envMap(cb.Env)),
+		Labels:       diffMap(ca.Labels, cb.Labels),
+		ExposedPorts: diffSet(ca.ExposedPorts, cb.ExposedPorts),
+		Volumes:      diffSet(ca.Volumes, cb.Volumes),
+	}
+}
+
+// changed 值不同时返回 ValueChange
+func changed(from, to interface{}) *ValueChange {
+	if reflect.DeepEqual(from, to) {
+		return nil
+	}
+	return &ValueChange{From: from, To: to}
+}
+
+// envMap 将 KEY=VALUE 形式的环境变量转换为 map（同名变量以最后一个为准）
+func envMap(env []string) map[string]string {
+	m := make(map[string]string, len(env))
+	for _, kv := range env {
+		key, value, _ := strings.Cut(kv, "=")
+		m[key] = value
+	}
+	return m
+}
+
+// diffMap 对比两个键值对集合
+func diffMap(from, to map[string]string) MapDiff {
+	diff := MapDiff{Added: map[string]string{}, Removed: map[string]string{}, Changed: map[string]ValueChange{}}
+	for k, v := range to {
+		old, ok := from[k]
+		switch {
+		case !ok:
+			diff.Added[k] = v
+		case old != v:
+			diff.Changed[k] = ValueChange{From: old, To: v}
+		}
+	}
+	for k, v := range from {
+		if _, ok := to[k]; !ok {
+			diff.Removed[k] = v
+		}
+	}
+	return diff
+}
+
+// diffSet 对比两个集合，结果有序
+func diffSet(from, to map[string]struct{}) SetDiff {
+	diff := SetDiff{Added: []string{}, Removed: []string{}}
+	for _, k := range sortedSet(to) {
+		if _, ok := from[k]; !ok {
+			diff.Added = append(diff.Added, k)
+		}
+	}
+	for _, k := range sortedSet(from) {
+		if _, ok := to[k]; !ok {
+			diff.Removed = append(diff.Removed, k)
+		}
+	}
+	return diff
+}
+
+// diffFiles 合并两组镜像层的文件列表并对比
+func (r *Registry) diffFiles(ctx context.Context, repo string, from, to []LayerInfo) (*FileDiff, error) {
+	diff := &FileDiff{Added: []FileChange{}, Removed: []FileChange{}, Modified: []FileChange{}}
+	a, truncatedA, err := r.mergeLayerFiles(ctx, repo, from)
+	if err != nil {
+		return nil, err
+	}
+	b, truncatedB, err := r.mergeLayerFiles(ctx, repo, to)
+	if err != nil {
+		return nil, err
+	}
+	diff.Truncated = truncatedA || truncatedB
+
+	add := func(list *[]FileChange, change FileChange) {
+		if len(*list) >= maxDiffFileChanges {
+			diff.Truncated = true
+			return
+		}
+		*list = append(*list, change)
+	}
+	for _, p := range sortedKeys(b) {
+		old, ok := a[p]
+		switch {
+		case !ok:
+			add(&diff.Added, FileChange{Path: p, To: b[p]})
+		case fileModified(old, b[p]):
+			add(&diff.Modified, FileChange{Path: p, From: old, To: b[p]})
+		}
+	}
+	for _, p := range sortedKeys(a) {
+		if _, ok := b[p]; !ok {
+			add(&diff.Removed, FileChange{Path: p, From: a[p]})
+		}
+	}
+	return diff, nil
+}
+
+// mergeLayerFiles 按顺序应用各层（含 whiteout），返回最终文件系统中的路径
+func (r *Registry) mergeLayerFiles(ctx context.Context, repo string, layers []LayerInfo) (map[string]*FileState, bool, error) {
+	files := make(map[string]*FileState)
+	truncated := false
+	for _, layer := range layers {
+		listing, err := r.ListLayerFiles(ctx, repo, layer.Digest)
+		if err != nil {
+			return nil, false, fmt.Errorf("layer %s: %w", layer.Digest, err)
+		}
+		truncated = truncated || listing.Truncated
+
+		// whiteout 只影响下层，先删除再加入本层的条目
+		removed := make(map[string]bool) // 删除路径本身及其下的全部路径
+		opaque := make(map[string]bool)  // 只删除目录下的路径
+		for _, entry := range listing.Entries {
+			switch entry.Type {
+			case LayerEntryWhiteout:
+				removed[entry.Path] = true
+			case LayerEntryOpaqueWhiteout:
+				opaque[entry.Path] = true
+			}
+		}
+		if len(removed) > 0 || len(opaque) > 0 {
+			for p := range files {
+				if whitedOut(p, removed, opaque) {
+					delete(files, p)
+				}
+			}
+		}
+		for _, entry := range listing.Entries {
+			if entry.Type == LayerEntryWhiteout || entry.Type == LayerEntryOpaqueWhiteout {
+				continue
+			}
+			files[entry.Path] = &FileState{LayerEntry: entry, Layer: layer.Digest}
+		}
+	}
+	return files, truncated, nil
+}
+
+// whitedOut 判断路径是否被本层的 whiteout 删除
+func whitedOut(p string, removed, opaque map[string]bool) bool {
+	if removed[p] {
+		return true
+	}
+	for dir := path.Dir(p); ; dir = path.Dir(dir) {
+		if dir == "." {
+			dir = ""
+		}
+		if removed[dir] || opaque[dir] {
+			return true
+		}
+		if dir == "" {
+			return false
+		}
+	}
+}
+
+// fileModified 判断同一路径在两个镜像中是否不同
+func fileModified(a, b *FileState) bool {
+	if a.Type != b.Type || a.Mode != b.Mode || a.UID != b.UID || a.GID != b.GID {
+		return true
+	}
+	if a.Type == LayerEntryDir {
+		return false
+	}
+	if a.Layer == b.Layer {
+		return false
+	}
+	if a.Size != b.Size || a.LinkTarget != b.LinkTarget {
+		return true
+	}
+	return !sameTime(a, b)
+}
+
+// sameTime 判断修改时间是否一致（均缺失视为一致）
+func sameTime(a, b *FileState) bool {
+	if a.ModTime == nil || b.ModTime == nil {
+		return a.ModTime == nil && b.ModTime == nil
+	}
+	return a.ModTime.Equal(*b.ModTime)
+}
+
+// sortedKeys 返回 map 键的有序列表
+func sortedKeys(m map[string]*FileState) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,334 @@
+package registry
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// OCI image layout 中标识镜像名称与标签的注解
+const (
+	AnnotationRefName        = "org.opencontainers.image.ref.name"
+	AnnotationContainerdName = "io.containerd.image.name"
+)
+
+// ociLayoutVersion oci-layout 文件内容
+const ociLayoutVersion = `{"imageLayoutVersion":"1.0.0"}`
+
+// ErrInvalidExportTarget 导出目标格式不合法
+var ErrInvalidExportTarget = errors.New("registry: invalid export target")
+
+// ExportTarget 导出的镜像
+// Reference 为 tag 或 digest；为空表示导出仓库的全部标签。
+type ExportTarget struct {
+	Repository string
+	Reference  string
+}
+
+// String 返回 <repository>[:tag|@digest]
+func (t ExportTarget) String() string {
+	switch {
+	case t.Reference == "":
+		return t.Repository
+	case strings.Contains(t.Reference, ":"):
+		return t.Repository + "@" + t.Reference
+	default:
+		return t.Repository + ":" + t.Reference
+	}
+}
+
+// ParseExportTarget 解析 <repository>[:tag|@digest]，例如 project/nginx:1.25、project/nginx@sha256:...、project/nginx
+func ParseExportTarget(s string) (ExportTarget, error) {
+	s = strings.TrimSpace(s)
+	t := ExportTarget{Repository: s}
+	if repo, digest, ok := strings.Cut(s, "@"); ok {
+		if _, _, err := ParseDigest(digest); err != nil {
+			return ExportTarget{}, fmt.Errorf("%w: %s", ErrInvalidExportTarget, s)
+		}
+		t.Repository, t.Reference = repo, digest
+	} else if idx := strings.LastIndex(s, ":"); idx > strings.LastIndex(s, "/") {
+		t.Repository, t.Reference = s[:idx], s[idx+1:]
+	}
+	if t.Repository == "" || strings.HasPrefix(t.Repository, "/") || strings.HasSuffix(t.Repository, "/") ||
+		(t.Reference == "" && strings.ContainsAny(s, ":@")) {
+		return ExportTarget{}, fmt.Errorf("%w: %s", ErrInvalidExportTarget, s)
+	}
+	return t, nil
+}
+
+// ImageRef 按标签或 digest 解析出的镜像 Manifest
+type ImageRef struct {
+	Repository string
+	Reference  string // 标签或 digest
+	Digest     string
+	Manifest   []byte
+}
+
+// ExportOptions 导出选项
+type ExportOptions struct {
+	// SkipReferrers 不导出签名、SBOM 等引用镜像的 referrer
+	SkipReferrers bool
+}
+
+// ExportSummary 导出内容统计
+type ExportSummary struct {
+	Images    int   `json:"images"`    // 按名称导出的镜像（标签或 digest）
+	Referrers int   `json:"referrers"` // 随镜像导出的 referrer
+	Manifests int   `json:"manifests"` // Manifest 与索引（含子 Manifest）
+	Blobs     int   `json:"blobs"`
+	Bytes     int64 `json:"bytes"` // Blob 总字节数
+}
+
+// exportBlob 待导出的 Blob 及其所在仓库
+type exportBlob struct {
+	repo string
+	desc Descriptor
+}
+
+// Export 一次 OCI image layout 导出
+// PrepareExport 解析全部 Manifest（只读取 Manifest，不读取 Blob 数据），
+// WriteTo 以 tar 流的形式写出，Blob 直接从存储流式读取，不在本地暂存。
+type Export struct {
+	r *Registry
+
+	entries   []Descriptor // index.json 中的条目
+	images    []ImageRef   // 按名称导出的镜像
+	manifests []exportManifest
+	blobs     []exportBlob
+	seen      map[string]bool
+	opts      ExportOptions
+	summary   ExportSummary
+}
+
+// exportManifest 待导出的 Manifest
+type exportManifest struct {
+	digest string
+	raw    []byte
+}
+
+// PrepareExport 解析导出目标，生成导出计划
+// 多架构索引连同全部子 Manifest 导出；未设置 SkipReferrers 时递归导出每个 Manifest 的 referrer。
+func (r *Registry) PrepareExport(ctx context.Context, targets []ExportTarget, opts ExportOptions) (*Export, error) {
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("%w: no target", ErrInvalidExportTarget)
+	}
+
+	e := &Export{r: r, seen: make(map[string]bool), opts: opts}
+	named := make(map[string]bool)
+	for _, t := range targets {
+		refs := []string{t.Reference}
+		if t.Reference == "" {
+			tags, err := r.ListTags(ctx, t.Repository)
+			if err != nil {
+				return nil, fmt.Errorf("list tags of %s: %w", t.Repository, err)
+			}
+			if len(tags) == 0 {
+				return nil, fmt.Errorf("%w: %s has no tags", ErrManifestNotFound, t.Repository)
+			}
+			refs = tags
+		}
+
+		for _, ref := range refs {
+			target := ExportTarget{Repository: t.Repository, Reference: ref}
+			if named[target.String()] {
+				continue
+			}
+			named[target.String()] = true
+
+			raw, digest, err := r.GetManifestRaw(ctx, t.Repository, ref)
+			if err != nil {
+				return nil, fmt.Errorf("read manifest %s: %w", target, err)
+			}
+			annotations := map[string]string{AnnotationContainerdName: target.String()}
+			if isDigest, _ := ParseReference(ref); !isDigest {
+				annotations[AnnotationRefName] = ref
+			}
+			e.entries = append(e.entries, Descriptor{
+				MediaType:   ManifestMediaType(raw),
+				Digest:      digest,
+				Size:        int64(len(raw)),
+				Annotations: annotations,
+			})
+			e.images = append(e.images, ImageRef{Repository: t.Repository, Reference: ref, Digest: digest, Manifest: raw})
+			e.summary.Images++
+
+			if err := e.addManifest(ctx, t.Repository, digest, raw); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return e, nil
+}
+
+// addManifest 记录 Manifest 及其引用的子 Manifest、Blob 与 referrer（按 digest 去重）
+func (e *Export) addManifest(ctx context.Context, repo, digest string, raw []byte) error {
+	if e.seen[digest] {
+		return nil
+	}
+	e.seen[digest] = true
+
+	blobs, children, err := ManifestReferences(raw, ManifestMediaType(raw))
+	if err != nil {
+		return fmt.Errorf("parse manifest %s: %w", digest, err)
+	}
+	for _, child := range children {
+		childRaw, _, err := e.r.GetManifestRaw(ctx, repo, child.Digest)
+		if err != nil {
+			return fmt.Errorf("read child manifest %s: %w", child.Digest, err)
+		}
+		if err := e.addManifest(ctx, repo, child.Digest, childRaw); err != nil {
+			return err
+		}
+	}
+	for _, blob := range blobs {
+		if e.seen[blob.Digest] {
+			continue
+		}
+		e.seen[blob.Digest] = true
+		e.blobs = append(e.blobs, exportBlob{repo: repo, desc: blob})
+		e.summary.Blobs++
+		e.summary.Bytes += blob.Size
+	}
+	e.manifests = append(e.manifests, exportManifest{digest: digest, raw: raw})
+	e.summary.Manifests++
+
+	if e.opts.SkipReferrers {
+		return nil
+	}
+	referrers, err := e.r.ManifestReferrers(ctx, repo, digest)
+	if err != nil {
+		return fmt.Errorf("read referrers of %s: %w", digest, err)
+	}
+	for _, ref := range referrers {
+		if e.seen[ref.Digest] {
+			continue
+		}
+		refRaw, _, err := e.r.GetManifestRaw(ctx, repo, ref.Digest)
+		if errors.Is(err, ErrManifestNotFound) {
+			// referrers 索引可能残留已删除的条目
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("read referrer %s: %w", ref.Digest, err)
+		}
+		// referrer 不带标签登记在 index.json 中，导入方可据此发现签名、SBOM 等
+		annotations := map[string]string{AnnotationContainerdName: repo + "@" + ref.Digest}
+		for k, v := range ref.Annotations {
+			annotations[k] = v
+		}
+		e.entries = append(e.entries, Descriptor{
+			MediaType:    ref.MediaType,
+			Digest:       ref.Digest,
+			Size:         int64(len(refRaw)),
+			ArtifactType: ref.ArtifactType,
+			Annotations:  annotations,
+		})
+		e.summary.Referrers++
+		if err := e.addManifest(ctx, repo, ref.Digest, refRaw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Images 返回按名称导出的镜像（不含随镜像导出的子 Manifest 与 referrer）
+func (e *Export) Images() []ImageRef {
+	return e.images
+}
+
+// Summary 返回导出内容统计
+func (e *Export) Summary() ExportSummary {
+	return e.summary
+}
+
+// WriteTo 以 OCI image layout 的 tar 流写出：oci-layout、index.json、blobs/<alg>/<hex>
+func (e *Export) WriteTo(ctx context.Context, w io.Writer) error {
+	index, err := json.MarshalIndent(ManifestIndex{
+		SchemaVersion: 2,
+		MediaType:     MediaTypeOCIManifestIndex,
+		Manifests:     e.entries,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(w)
+	modTime := time.Now()
+	for _, dir := range []string{"blobs/", "blobs/sha256/"} {
+		if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: dir, Mode: 0o755, ModTime: modTime}); err != nil {
+			return err
+		}
+	}
+	writeFile := func(name string, size int64, body io.Reader) error {
+		if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0o644, Size: size, ModTime: modTime}); err != nil {
+			return err
+		}
+		n, err := io.Copy(tw, body)
+		if err == nil && n != size {
+			err = fmt.Errorf("size mismatch: expected %d, got %d", size, n)
+		}
+		if err != nil {
+			return fmt.Errorf("write %s: %w", name, err)
+		}
+		return nil
+	}
+
+	if err := writeFile("oci-layout", int64(len(ociLayoutVersion)), strings.NewReader(ociLayoutVersion)); err != nil {
+		return err
+	}
+	if err := writeFile("index.json", int64(len(index)), bytes.NewReader(index)); err != nil {
+		return err
+	}
+	for _, m := range e.manifests {
+		name, err := exportBlobPath(m.digest)
+		if err != nil {
+			return err
+		}
+		if err := writeFile(name, int64(len(m.raw)), bytes.NewReader(m.raw)); err != nil {
+			return err
+		}
+	}
+	for _, b := range e.blobs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := e.writeBlob(ctx, b, writeFile); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// writeBlob 从存储流式读取 Blob 写入 tar
+func (e *Export) writeBlob(ctx context.Context, b exportBlob, writeFile func(string, int64, io.Reader) error) error {
+	name, err := exportBlobPath(b.desc.Digest)
+	if err != nil {
+		return err
+	}
+	reader, size, err := e.r.GetBlob(ctx, b.repo, b.desc.Digest)
+	if err != nil {
+		return fmt.Errorf("read blob %s: %w", b.desc.Digest, err)
+	}
+	if closer, ok := reader.(io.Closer); ok {
+		defer closer.Close()
+	}
+	if size >= 0 && size != b.desc.Size {
+		return fmt.Errorf("blob %s: size %d does not match manifest (%d)", b.desc.Digest, size, b.desc.Size)
+	}
+	return writeFile(name, b.desc.Size, reader)
+}
+
+// exportBlobPath 返回 digest 在 OCI image layout 中的路径
+func exportBlobPath(digest string) (string, error) {
+	algorithm, hexDigest, err := ParseDigest(digest)
+	if err != nil {
+		return "", err
+	}
+	return "blobs/" + algorithm + "/" + hexDigest, nil
+}
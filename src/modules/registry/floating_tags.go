@@ -0,0 +1,309 @@
+// Package registry Docker Registry API模块
+// 实现Docker Registry HTTP API V2规范
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cyp-registry/registry/src/pkg/audit"
+	"github.com/cyp-registry/registry/src/pkg/response"
+)
+
+// ErrFloatingTag tag 由浮动 tag 规则自动维护，不允许直接推送
+var ErrFloatingTag = errors.New("registry: tag is managed by a floating tag rule")
+
+// 浮动 tag 的选择策略
+const (
+	FloatingStrategyHighestSemver = "highest_semver" // 跟随语义化版本号最高的候选 tag（默认）
+	FloatingStrategyLatestPushed  = "latest_pushed"  // 跟随最近一次推送的候选 tag
+)
+
+// FloatingTagRule 浮动 tag 规则，例如：
+//
+//	{"tag": "latest", "strategy": "highest_semver"}
+//	{"tag": "stable", "pattern": "^v\\d+\\.\\d+\\.\\d+$"}
+//
+// Pattern 为空时所有非浮动 tag 都是候选；语法与 TagRules 中的其他模式相同。
+// highest_semver 策略默认忽略预发布版本（如 v2.0.0-beta），避免 latest 被推到测试镜像。
+type FloatingTagRule struct {
+	Tag               string `json:"tag"`
+	Pattern           string `json:"pattern,omitempty"`
+	Strategy          string `json:"strategy,omitempty"`
+	IncludePrerelease bool   `json:"include_prerelease,omitempty"`
+}
+
+// FloatingTagChange 一次浮动 tag 变化
+// NewDigest 为空表示没有候选 tag，浮动 tag 已被移除。
+type FloatingTagChange struct {
+	Tag       string `json:"tag"`
+	Follows   string `json:"follows,omitempty"`
+	OldDigest string `json:"old_digest,omitempty"`
+	NewDigest string `json:"new_digest,omitempty"`
+}
+
+// tagNameRegexp OCI 规范的 tag 名称格式
+var tagNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9._-]{0,127}$`)
+
+// validateFloatingTagRules 校验浮动 tag 规则
+func validateFloatingTagRules(rules []FloatingTagRule) error {
+	seen := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		if !tagNameRegexp.MatchString(rule.Tag) {
+			return fmt.Errorf("floating_tags: invalid tag name %q", rule.Tag)
+		}
+		if seen[rule.Tag] {
+			return fmt.Errorf("floating_tags: duplicate tag %q", rule.Tag)
+		}
+		seen[rule.Tag] = true
+
+		switch rule.Strategy {
+		case "", FloatingStrategyHighestSemver, FloatingStrategyLatestPushed:
+		default:
+			return fmt.Errorf("floating_tags: unknown strategy %q", rule.Strategy)
+		}
+		if rule.Pattern != "" {
+			if err := ValidateTagRules(&TagRules{AllowedPatterns: []string{rule.Pattern}}); err != nil {
+				return fmt.Errorf("floating_tags: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// floatingRule 返回 tag 对应的浮动规则（不是浮动 tag 时返回 nil）
+func (t *TagRules) floatingRule(tag string) *FloatingTagRule {
+	if t == nil {
+		return nil
+	}
+	for i := range t.FloatingTags {
+		if t.FloatingTags[i].Tag == tag {
+			return &t.FloatingTags[i]
+		}
+	}
+	return nil
+}
+
+// ReevaluateFloatingTags 按项目的浮动 tag 规则重新计算仓库中各浮动 tag 的指向
+// Manifest 写入与删除后自动调用（见 refreshFloatingTags）；返回发生变化的浮动 tag。
+func (r *Registry) ReevaluateFloatingTags(ctx context.Context, repo string) ([]FloatingTagChange, error) {
+	rules, err := r.projectTagRules(ctx, repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tag rules: %w", err)
+	}
+	if rules == nil || len(rules.FloatingTags) == 0 {
+		return nil, nil
+	}
+
+	tags, err := r.ListTags(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	// 读取所有非浮动 tag 作为候选
+	candidates := make(map[string]*TagData, len(tags))
+	for _, tag := range tags {
+		if rules.floatingRule(tag) != nil {
+			continue
+		}
+		if td, err := r.GetTag(ctx, repo, tag); err == nil {
+			candidates[tag] = td
+		}
+	}
+
+	var changes []FloatingTagChange
+	for _, rule := range rules.FloatingTags {
+		source := selectFloatingSource(rule, candidates)
+
+		current, err := r.GetTag(ctx, repo, rule.Tag)
+		if err != nil && !errors.Is(err, response.ErrNotFound) {
+			return changes, err
+		}
+		var oldDigest string
+		if current != nil {
+			oldDigest = current.Digest
+		}
+
+		if source == "" {
+			// 没有候选：移除浮动 tag
+			if current == nil {
+				continue
+			}
+			if err := r.storage.Delete(ctx, BuildManifestPath(repo, "tags/"+rule.Tag)); err != nil && !errors.Is(err, response.ErrNotFound) {
+				return changes, err
+			}
+			if err := r.unindexTags(ctx, repo, rule.Tag); err != nil {
+				return changes, err
+			}
+			changes = append(changes, FloatingTagChange{Tag: rule.Tag, OldDigest: oldDigest})
+			continue
+		}
+
+		target := candidates[source]
+		if current != nil && current.Digest == target.Digest && current.Follows == source {
+			continue
+		}
+
+		td := TagData{
+			Digest:    target.Digest,
+			MediaType: target.MediaType,
+			Size:      target.Size,
+			PushedAt:  time.Now().UTC(),
+			Follows:   source,
+		}
+		data, _ := json.Marshal(td)
+		if err := r.storage.Put(ctx, BuildManifestPath(repo, "tags/"+rule.Tag), bytes.NewReader(data), int64(len(data))); err != nil {
+			return changes, err
+		}
+		if err := r.indexTag(ctx, repo, rule.Tag, &td); err != nil {
+			return changes, err
+		}
+		changes = append(changes, FloatingTagChange{Tag: rule.Tag, Follows: source, OldDigest: oldDigest, NewDigest: target.Digest})
+	}
+
+	return changes, nil
+}
+
+// refreshFloatingTags tag 推送或删除后重新计算仓库的浮动 tag，并将每次变化记录到审计日志
+// 由 Registry 的 Manifest 写入与删除统一调用，导入、复制等服务写入的 tag 同样生效；最佳努力，不影响本次写入。
+// 审计日志的操作者取自上下文（见 audit.WithActor），后台任务触发时为空。
+func (r *Registry) refreshFloatingTags(ctx context.Context, repo string) {
+	changes, err := r.ReevaluateFloatingTags(ctx, repo)
+	if err != nil {
+		log.Printf(`{"timestamp":"%s","level":"error","module":"registry","operation":"floating_tag_update","repository":"%s","error":"%v"}`, time.Now().Format(time.RFC3339), repo, err)
+	}
+	actor := audit.ActorFrom(ctx)
+	for _, change := range changes {
+		log.Printf(`{"timestamp":"%s","level":"info","module":"registry","operation":"floating_tag_update","repository":"%s","tag":"%s","follows":"%s","old_digest":"%s","new_digest":"%s"}`, time.Now().Format(time.RFC3339), repo, change.Tag, change.Follows, change.OldDigest, change.NewDigest)
+		audit.Record(ctx, "floating_tag_update", "registry", nil, actor.UserID, actor.IP, actor.UserAgent, map[string]interface{}{
+			"repository": repo,
+			"tag":        change.Tag,
+			"follows":    change.Follows,
+			"old_digest": change.OldDigest,
+			"new_digest": change.NewDigest,
+		})
+	}
+}
+
+// selectFloatingSource 按规则从候选 tag 中选出浮动 tag 应跟随的源 tag
+func selectFloatingSource(rule FloatingTagRule, candidates map[string]*TagData) string {
+	var best string
+	var bestVer *semver
+	for tag, td := range candidates {
+		if rule.Pattern != "" {
+			if _, ok := matchTagPattern([]string{rule.Pattern}, tag); !ok {
+				continue
+			}
+		}
+
+		switch rule.Strategy {
+		case FloatingStrategyLatestPushed:
+			if best == "" || td.PushedAt.After(candidates[best].PushedAt) ||
+				(td.PushedAt.Equal(candidates[best].PushedAt) && tag > best) {
+				best = tag
+			}
+		default:
+			v, ok := parseSemver(tag)
+			if !ok || (len(v.prerelease) > 0 && !rule.IncludePrerelease) {
+				continue
+			}
+			if bestVer == nil || v.compare(bestVer) > 0 || (v.compare(bestVer) == 0 && tag > best) {
+				best, bestVer = tag, v
+			}
+		}
+	}
+	return best
+}
+
+// semver 语义化版本号（允许 v 前缀，忽略构建元数据）
+type semver struct {
+	core       [3]uint64
+	prerelease []string
+}
+
+// parseSemver 解析 tag 中的语义化版本号
+func parseSemver(tag string) (*semver, bool) {
+	s := strings.TrimPrefix(tag, "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+	var pre string
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		s, pre = s[:i], s[i+1:]
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return nil, false
+	}
+	v := &semver{}
+	for i, p := range parts {
+		n, err := strconv.ParseUint(p, 10, 64)
+		if err != nil {
+			return nil, false
+		}
+		v.core[i] = n
+	}
+	if pre != "" {
+		v.prerelease = strings.Split(pre, ".")
+	}
+	return v, true
+}
+
+// compare 按 SemVer 2.0 优先级比较：正式版本高于同号的预发布版本
+func (v *semver) compare(o *semver) int {
+	for i := 0; i < 3; i++ {
+		if v.core[i] != o.core[i] {
+			if v.core[i] > o.core[i] {
+				return 1
+			}
+			return -1
+		}
+	}
+	switch {
+	case len(v.prerelease) == 0 && len(o.prerelease) == 0:
+		return 0
+	case len(v.prerelease) == 0:
+		return 1
+	case len(o.prerelease) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.prerelease) && i < len(o.prerelease); i++ {
+		a, b := v.prerelease[i], o.prerelease[i]
+		if a == b {
+			continue
+		}
+		an, aErr := strconv.ParseUint(a, 10, 64)
+		bn, bErr := strconv.ParseUint(b, 10, 64)
+		switch {
+		case aErr == nil && bErr == nil:
+			if an > bn {
+				return 1
+			}
+			return -1
+		case aErr == nil:
+			return -1 // 数字标识符优先级低于字母标识符
+		case bErr == nil:
+			return 1
+		case a > b:
+			return 1
+		default:
+			return -1
+		}
+	}
+	switch {
+	case len(v.prerelease) > len(o.prerelease):
+		return 1
+	case len(v.prerelease) < len(o.prerelease):
+		return -1
+	}
+	return 0
+}
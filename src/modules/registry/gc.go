@@ -0,0 +1,559 @@
+// Package registry Docker Registry API模块
+// 实现Docker Registry HTTP API V2规范
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cyp-registry/registry/src/pkg/response"
+)
+
+// ErrGCInProgress 垃圾回收清理阶段正在进行，推送被拒绝（客户端应稍后重试）
+var ErrGCInProgress = errors.New("registry: garbage collection in progress")
+
+// ErrGCAlreadyRunning 已有垃圾回收任务在执行
+var ErrGCAlreadyRunning = errors.New("registry: garbage collection already running")
+
+// DefaultGCGracePeriod 默认宽限期：新近写入的 Blob/链接即使未被引用也不会被回收，
+// 避免删除正在推送（Blob 已上传、Manifest 尚未提交）的镜像数据
+const DefaultGCGracePeriod = 24 * time.Hour
+
+// gcPushWait 推送请求在 GC 清理阶段的最长等待时间，超时后返回 ErrGCInProgress
+const gcPushWait = 10 * time.Second
+
+// GCOptions 垃圾回收选项
+type GCOptions struct {
+	DryRun         bool          `json:"dry_run"`         // 仅生成报告，不删除任何数据
+	GracePeriod    time.Duration `json:"-"`               // 宽限期，<= 0 时使用默认值
+	DeleteUntagged bool          `json:"delete_untagged"` // 同时删除没有任何 tag（直接或经由索引/referrers）可达的 Manifest
+}
+
+// GCReport 垃圾回收报告
+type GCReport struct {
+	DryRun          bool      `json:"dry_run"`
+	DeleteUntagged  bool      `json:"delete_untagged"`
+	GracePeriod     string    `json:"grace_period"`
+	StartedAt       time.Time `json:"started_at"`
+	FinishedAt      time.Time `json:"finished_at"`
+	Repositories    int       `json:"repositories"`
+	MarkedManifests int       `json:"marked_manifests"`
+	MarkedBlobs     int       `json:"marked_blobs"`
+
+	// 以下列表在 DryRun 模式下表示“将被删除”的对象
+	DeletedManifests []string `json:"deleted_manifests"` // <repo>@<digest>
+	DeletedLinks     []string `json:"deleted_links"`     // <repo>@<digest>
+	DeletedBlobs     []string `json:"deleted_blobs"`     // 全局 Blob 或旧版仓库内 Blob
+	FreedBytes       int64    `json:"freed_bytes"`
+	Errors           []string `json:"errors,omitempty"`
+}
+
+// gcState 垃圾回收运行状态
+type gcState struct {
+	// locker 推送与清理之间的锁，见 SetGCLocker；为空时使用 local
+	locker    GCLocker
+	localOnce sync.Once
+	local     *localGCLocker
+
+	mu      sync.Mutex
+	running bool
+	last    *GCReport
+	linked  map[string]bool // 回收期间本进程推送链接的 Blob，见 noteLinked
+}
+
+// GCRunning 返回是否有垃圾回收任务正在执行
+func (r *Registry) GCRunning() bool {
+	r.gc.mu.Lock()
+	defer r.gc.mu.Unlock()
+	return r.gc.running
+}
+
+// LastGCReport 返回最近一次垃圾回收报告（尚未执行过时返回 nil）
+func (r *Registry) LastGCReport() *GCReport {
+	r.gc.mu.Lock()
+	defer r.gc.mu.Unlock()
+	return r.gc.last
+}
+
+// gcManifestRefs Manifest 中引用的对象
+type gcManifestRefs struct {
+	children []string // 索引中的子 Manifest
+	blobs    []string // config 与 layers
+	subject  string   // OCI referrers 的 subject
+}
+
+// parseManifestRefs 解析 Manifest 引用的子 Manifest、Blob 与 subject
+func parseManifestRefs(raw []byte) (*gcManifestRefs, error) {
+	var doc manifestDoc
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+
+	refs := &gcManifestRefs{}
+	for _, m := range doc.Manifests {
+		if m.Digest != "" {
+			refs.children = append(refs.children, m.Digest)
+		}
+	}
+	if doc.Config != nil && doc.Config.Digest != "" {
+		refs.blobs = append(refs.blobs, doc.Config.Digest)
+	}
+	for _, l := range doc.Layers {
+		if l.Digest != "" {
+			refs.blobs = append(refs.blobs, l.Digest)
+		}
+	}
+	for _, b := range doc.Blobs {
+		if b.Digest != "" {
+			refs.blobs = append(refs.blobs, b.Digest)
+		}
+	}
+	if doc.Subject != nil {
+		refs.subject = doc.Subject.Digest
+	}
+	return refs, nil
+}
+
+// gcRepo 单个仓库的标记结果
+type gcRepo struct {
+	name      string
+	manifests map[string]*gcManifestRefs // 仓库内全部 Manifest（digest -> 引用）
+	marked    map[string]bool            // 可达的 Manifest
+	blobs     map[string]bool            // 可达的 Blob
+}
+
+// RunGC 执行一次标记-清除垃圾回收
+//
+// 标记阶段：遍历所有仓库，以 tag（DeleteUntagged=false 时还包括全部 Manifest）为根，
+// 沿镜像索引子 Manifest、config/layers 以及指向已标记 Manifest 的 referrers 标记可达对象。
+// 清除阶段：删除未被标记的仓库链接、旧版仓库内 Blob、未被标记的 Manifest（仅 DeleteUntagged），
+// 以及不再被任何链接引用的全局 Blob。早于宽限期写入的对象才会被删除。
+// 非 DryRun 模式下逐个仓库标记并清除，期间只有该仓库的推送等待（超时返回 ErrGCInProgress）；
+// 删除全局 Blob 前持有该 Blob 的锁并重新确认没有新的链接。
+func (r *Registry) RunGC(ctx context.Context, opts GCOptions) (*GCReport, error) {
+	r.gc.mu.Lock()
+	if r.gc.running {
+		r.gc.mu.Unlock()
+		return nil, ErrGCAlreadyRunning
+	}
+	r.gc.running = true
+	r.gc.mu.Unlock()
+
+	if opts.GracePeriod <= 0 {
+		opts.GracePeriod = DefaultGCGracePeriod
+	}
+
+	report := &GCReport{
+		DryRun:           opts.DryRun,
+		DeleteUntagged:   opts.DeleteUntagged,
+		GracePeriod:      opts.GracePeriod.String(),
+		StartedAt:        time.Now(),
+		DeletedManifests: []string{},
+		DeletedLinks:     []string{},
+		DeletedBlobs:     []string{},
+	}
+
+	err := r.runGC(ctx, opts, report)
+	report.FinishedAt = time.Now()
+
+	r.gc.mu.Lock()
+	r.gc.running = false
+	if err == nil {
+		r.gc.last = report
+	}
+	r.gc.mu.Unlock()
+
+	if err != nil {
+		log.Printf(`{"timestamp":"%s","level":"error","module":"registry","operation":"gc","dry_run":%t,"error":"%v"}`, time.Now().Format(time.RFC3339), opts.DryRun, err)
+		return nil, err
+	}
+
+	log.Printf(`{"timestamp":"%s","level":"info","module":"registry","operation":"gc","dry_run":%t,"repositories":%d,"deleted_manifests":%d,"deleted_links":%d,"deleted_blobs":%d,"freed_bytes":%d,"errors":%d,"duration":"%s"}`,
+		time.Now().Format(time.RFC3339), opts.DryRun, report.Repositories, len(report.DeletedManifests), len(report.DeletedLinks),
+		len(report.DeletedBlobs), report.FreedBytes, len(report.Errors), report.FinishedAt.Sub(report.StartedAt))
+	return report, nil
+}
+
+// runGC 执行标记与清除
+func (r *Registry) runGC(ctx context.Context, opts GCOptions, report *GCReport) error {
+	if !opts.DryRun {
+		release, err := r.gcLocker().TryLockGC(ctx)
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		r.gc.mu.Lock()
+		r.gc.linked = make(map[string]bool)
+		r.gc.mu.Unlock()
+		defer func() {
+			r.gc.mu.Lock()
+			r.gc.linked = nil
+			r.gc.mu.Unlock()
+		}()
+	}
+
+	repoNames, err := r.ListRepositories(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list repositories: %w", err)
+	}
+	report.Repositories = len(repoNames)
+
+	cutoff := time.Now().Add(-opts.GracePeriod)
+	globalMarked := make(map[string]bool)
+	liveLinks := make(map[string]bool)
+	for _, name := range repoNames {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := r.gcRepository(ctx, name, opts, cutoff, globalMarked, liveLinks, report); err != nil {
+			return err
+		}
+	}
+
+	if err := r.gcSweepGlobalBlobs(ctx, opts, cutoff, globalMarked, liveLinks, report); err != nil {
+		return err
+	}
+	return r.gcSweepLayerFiles(ctx, opts, cutoff, report)
+}
+
+// gcRepository 标记并清除单个仓库，可达的 Manifest 与 Blob 记入 globalMarked
+// 非 DryRun 时持有该仓库的排他锁：标记之后新打的 tag 不会指向被清除的 Manifest，
+// 新的 Manifest 也不会引用被清除的链接。
+func (r *Registry) gcRepository(ctx context.Context, name string, opts GCOptions, cutoff time.Time, globalMarked, liveLinks map[string]bool, report *GCReport) error {
+	if !opts.DryRun {
+		release, err := r.gcLocker().Lock(ctx, true, gcRepoKey(name))
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+
+	repo, err := r.gcMarkRepository(ctx, name, opts)
+	if err != nil {
+		return fmt.Errorf("failed to mark repository %s: %w", name, err)
+	}
+	report.MarkedManifests += len(repo.marked)
+	report.MarkedBlobs += len(repo.blobs)
+	for d := range repo.blobs {
+		globalMarked[d] = true
+	}
+	for d := range repo.marked {
+		globalMarked[d] = true
+	}
+
+	r.gcSweepRepository(ctx, repo, opts, cutoff, liveLinks, report)
+	return nil
+}
+
+// gcMarkRepository 标记单个仓库中可达的 Manifest 与 Blob
+func (r *Registry) gcMarkRepository(ctx context.Context, name string, opts GCOptions) (*gcRepo, error) {
+	repo := &gcRepo{
+		name:      name,
+		manifests: make(map[string]*gcManifestRefs),
+		marked:    make(map[string]bool),
+		blobs:     make(map[string]bool),
+	}
+
+	// 读取仓库内全部 Manifest 及其引用关系
+	manifestDir := name + "/manifests"
+	entries, err := r.storage.List(ctx, manifestDir)
+	if err != nil && !errors.Is(err, response.ErrNotFound) {
+		return nil, err
+	}
+	referrers := make(map[string][]string) // subject -> referrer digests
+	for _, entry := range entries {
+		if strings.HasSuffix(entry, "/") {
+			continue // tags/、referrers/ 等子目录
+		}
+		hexDigest := path.Base(entry)
+		digest := "sha256:" + hexDigest
+		raw, err := r.readStorageFile(ctx, BuildManifestPath(name, hexDigest))
+		if err != nil {
+			continue
+		}
+		refs, err := parseManifestRefs(raw)
+		if err != nil {
+			// 无法解析的 Manifest 仍视为存在，但不引用任何对象
+			refs = &gcManifestRefs{}
+		}
+		repo.manifests[digest] = refs
+		if refs.subject != "" {
+			referrers[refs.subject] = append(referrers[refs.subject], digest)
+		}
+	}
+
+	// referrers 索引中登记的 referrer 也纳入（索引可能先于 Manifest 遍历写入）
+	for subject := range repo.manifests {
+		for _, d := range r.gcReadReferrersIndex(ctx, name, subject) {
+			referrers[subject] = append(referrers[subject], d)
+		}
+	}
+
+	// 根集合：tag 指向的 Manifest；不删除无 tag Manifest 时全部 Manifest 都是根
+	var queue []string
+	tags, err := r.storage.List(ctx, BuildManifestPath(name, "tags"))
+	if err != nil && !errors.Is(err, response.ErrNotFound) {
+		return nil, err
+	}
+	for _, entry := range tags {
+		tag := path.Base(strings.TrimSuffix(entry, "/"))
+		if tag == "" || tag == "." {
+			continue
+		}
+		td, err := r.getTagData(ctx, BuildManifestPath(name, "tags/"+tag))
+		if err != nil || td.Digest == "" {
+			continue
+		}
+		queue = append(queue, gcDigest(td.Digest))
+	}
+	if !opts.DeleteUntagged {
+		for d := range repo.manifests {
+			queue = append(queue, d)
+		}
+	}
+
+	for len(queue) > 0 {
+		d := queue[0]
+		queue = queue[1:]
+		if repo.marked[d] {
+			continue
+		}
+		repo.marked[d] = true
+
+		if refs, ok := repo.manifests[d]; ok {
+			queue = append(queue, refs.children...)
+			for _, b := range refs.blobs {
+				repo.blobs[gcDigest(b)] = true
+			}
+		}
+		// 指向可达 Manifest 的 referrers（签名、SBOM 等）同样可达
+		queue = append(queue, referrers[d]...)
+	}
+
+	return repo, nil
+}
+
+// gcReadReferrersIndex 读取 referrers 索引中登记的 referrer digest 列表
+func (r *Registry) gcReadReferrersIndex(ctx context.Context, repo, subject string) []string {
+	_, hexDigest, err := ParseDigest(subject)
+	if err != nil {
+		return nil
+	}
+	raw, err := r.readStorageFile(ctx, BuildManifestPath(repo, "referrers/"+hexDigest))
+	if err != nil {
+		return nil
+	}
+	var descs []Descriptor
+	if err := json.Unmarshal(raw, &descs); err != nil {
+		return nil
+	}
+	digests := make([]string, 0, len(descs))
+	for _, desc := range descs {
+		if desc.Digest != "" {
+			digests = append(digests, desc.Digest)
+		}
+	}
+	return digests
+}
+
+// gcSweepRepository 清除单个仓库中不可达的 Manifest、链接与旧版 Blob
+// 保留下来的链接记入 liveLinks，用于判断全局 Blob 是否仍被引用。
+func (r *Registry) gcSweepRepository(ctx context.Context, repo *gcRepo, opts GCOptions, cutoff time.Time, liveLinks map[string]bool, report *GCReport) {
+	// 无 tag 可达的 Manifest
+	if opts.DeleteUntagged {
+		for d := range repo.manifests {
+			if repo.marked[d] {
+				continue
+			}
+			_, hexDigest, _ := ParseDigest(d)
+			manifestPath := BuildManifestPath(repo.name, hexDigest)
+			if !r.gcOlderThan(ctx, manifestPath, cutoff) {
+				continue
+			}
+			report.DeletedManifests = append(report.DeletedManifests, repo.name+"@"+d)
+			if opts.DryRun {
+				continue
+			}
+			if subject := repo.manifests[d].subject; subject != "" {
+				_ = r.removeReferrer(ctx, repo.name, subject, d)
+			}
+			r.gcDelete(ctx, manifestPath, report)
+			_ = r.storage.Delete(ctx, BuildManifestPath(repo.name, "referrers/"+hexDigest))
+		}
+	}
+
+	// 仓库链接
+	var unlinked []string
+	_ = r.walkStorage(ctx, repo.name+"/"+blobLinkDir, func(p string, isDir bool) error {
+		if isDir {
+			return nil
+		}
+		alg := path.Base(path.Dir(p))
+		digest := alg + ":" + path.Base(p)
+		if repo.blobs[digest] || repo.marked[digest] {
+			liveLinks[digest] = true
+			return nil
+		}
+
+		link, err := r.readBlobLink(ctx, repo.name, digest)
+		if err != nil || link.CreatedAt.After(cutoff) {
+			// 读取失败或仍在宽限期内：保留
+			liveLinks[digest] = true
+			return nil
+		}
+		report.DeletedLinks = append(report.DeletedLinks, repo.name+"@"+digest)
+		if !opts.DryRun && r.gcDelete(ctx, p, report) {
+			unlinked = append(unlinked, digest)
+		}
+		return nil
+	})
+
+	// 旧版仓库内 Blob：<repo>/blobs/sha256/<sha256:hex>
+	// 只遍历 blobs/sha256：blobs 下的其他目录可能是嵌套的仓库（例如 proj/blobs/app）
+	_ = r.walkStorage(ctx, repo.name+"/blobs/sha256", func(p string, isDir bool) error {
+		if isDir {
+			return errSkipDir
+		}
+		digest := gcDigest(path.Base(p))
+		if repo.blobs[digest] || repo.marked[digest] {
+			return nil
+		}
+		size, modTime, err := r.storage.Stat(ctx, p)
+		if err != nil || !gcBefore(modTime, cutoff) {
+			return nil
+		}
+		report.DeletedBlobs = append(report.DeletedBlobs, repo.name+"@"+digest)
+		report.FreedBytes += size
+		if !opts.DryRun && r.gcDelete(ctx, p, report) {
+			unlinked = append(unlinked, digest)
+		}
+		return nil
+	})
+
+	// 已删除的链接与旧版 Blob 从链接索引中移除（同时更新项目用量）
+	if err := r.unindexLinks(ctx, repo.name, unlinked...); err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", repo.name, err))
+	}
+}
+
+// gcSweepGlobalBlobs 清除既未被标记、也不再被任何仓库链接引用的全局 Blob
+// liveLinks 只包含清除各仓库时保留的链接；非 DryRun 时删除前持有该 Blob 的排他锁，
+// 并确认清除仓库之后没有推送新链接到它（见 gcBlobLinked）。
+func (r *Registry) gcSweepGlobalBlobs(ctx context.Context, opts GCOptions, cutoff time.Time, marked, liveLinks map[string]bool, report *GCReport) error {
+	return r.walkStorage(ctx, globalBlobRoot, func(p string, isDir bool) error {
+		if isDir {
+			return nil
+		}
+		alg := path.Base(path.Dir(p))
+		digest := alg + ":" + path.Base(p)
+		if marked[digest] || liveLinks[digest] {
+			return nil
+		}
+		size, modTime, err := r.storage.Stat(ctx, p)
+		if err != nil || !gcBefore(modTime, cutoff) {
+			return nil
+		}
+		if opts.DryRun {
+			report.DeletedBlobs = append(report.DeletedBlobs, digest)
+			report.FreedBytes += size
+			return nil
+		}
+
+		release, err := r.gcLocker().Lock(ctx, true, gcBlobKey(digest))
+		if err != nil {
+			return err
+		}
+		defer release()
+		if linked, err := r.gcBlobLinked(ctx, digest); err != nil || linked {
+			if err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", p, err))
+			}
+			return nil
+		}
+		report.DeletedBlobs = append(report.DeletedBlobs, digest)
+		report.FreedBytes += size
+		// 先删除该层的文件列表缓存，再删除 Blob：中途失败时留下的 Blob 会在下次回收时连同缓存一起清除
+		if cachePath, err := buildLayerFilesPath(digest); err == nil {
+			r.gcDelete(ctx, cachePath, report)
+		}
+		r.gcDelete(ctx, p, report)
+		return nil
+	})
+}
+
+// gcSweepLayerFiles 清除对应 Blob 已不存在的层文件列表缓存
+// 正常情况下缓存与 Blob 在同一步删除；这里清理回收期间由并发的文件列表请求重新写入、或 Blob 以其他方式丢失后遗留的缓存。
+func (r *Registry) gcSweepLayerFiles(ctx context.Context, opts GCOptions, cutoff time.Time, report *GCReport) error {
+	return r.walkStorage(ctx, layerFilesRoot, func(p string, isDir bool) error {
+		if isDir || !strings.HasSuffix(p, ".json.gz") {
+			return nil
+		}
+		digest := path.Base(path.Dir(p)) + ":" + strings.TrimSuffix(path.Base(p), ".json.gz")
+		if exists, err := r.storage.Exists(ctx, BuildGlobalBlobPath(digest)); err != nil || exists {
+			return nil
+		}
+		if !r.gcOlderThan(ctx, p, cutoff) {
+			return nil
+		}
+		if !opts.DryRun {
+			r.gcDelete(ctx, p, report)
+		}
+		return nil
+	})
+}
+
+// gcDelete 删除对象，失败时记入报告（不存在视为已删除）
+func (r *Registry) gcDelete(ctx context.Context, p string, report *GCReport) bool {
+	if err := r.storage.Delete(ctx, p); err != nil && !errors.Is(err, response.ErrNotFound) {
+		report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", p, err))
+		return false
+	}
+	return true
+}
+
+// gcOlderThan 判断对象的修改时间是否早于 cutoff
+func (r *Registry) gcOlderThan(ctx context.Context, p string, cutoff time.Time) bool {
+	_, modTime, err := r.storage.Stat(ctx, p)
+	if err != nil {
+		return false
+	}
+	return gcBefore(modTime, cutoff)
+}
+
+// gcBefore 解析存储返回的 RFC3339 修改时间并与 cutoff 比较，无法解析时视为较新（不删除）
+func gcBefore(modTime string, cutoff time.Time) bool {
+	t, err := time.Parse(time.RFC3339, modTime)
+	if err != nil {
+		return false
+	}
+	return t.Before(cutoff)
+}
+
+// readStorageFile 读取存储中的小文件（Manifest、索引等）
+func (r *Registry) readStorageFile(ctx context.Context, p string) ([]byte, error) {
+	reader, _, err := r.storage.Get(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	if closer, ok := reader.(io.Closer); ok {
+		defer closer.Close()
+	}
+	return io.ReadAll(reader)
+}
+
+// gcDigest 规范化 digest（兼容仅含 hex 的写法），无法解析时原样返回
+func gcDigest(digest string) string {
+	if d, err := normalizeDigest(digest); err == nil {
+		return d
+	}
+	return digest
+}
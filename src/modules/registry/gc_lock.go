@@ -0,0 +1,196 @@
+// Package registry Docker Registry API模块
+// 实现Docker Registry HTTP API V2规范
+package registry
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// 推送与垃圾回收互斥
+//
+// 垃圾回收只在标记并清除单个仓库期间排斥该仓库的推送，删除单个全局 Blob 前排斥正在链接该 Blob 的推送，
+// 其余时间推送照常进行。推送持有仓库（以及要链接的 Blob）的共享锁，清理持有排他锁。
+// 锁由 GCLocker 提供：默认为进程内实现，只适用于单副本；多副本部署时必须通过 SetGCLocker 设置
+// 跨副本的实现（数据库 advisory lock），它同时保证同一时间只有一个副本执行垃圾回收。
+
+// GCLocker 推送与垃圾回收之间的锁
+type GCLocker interface {
+	// Lock 取得 keys 上的共享（推送）或排他（清理）锁，直到调用返回的释放函数；ctx 结束时放弃等待
+	Lock(ctx context.Context, exclusive bool, keys ...string) (func(), error)
+	// TryLockGC 取得全局唯一的垃圾回收锁，已被持有时返回 ErrGCAlreadyRunning
+	TryLockGC(ctx context.Context) (func(), error)
+}
+
+// SetGCLocker 设置跨副本的推送与垃圾回收锁；未设置时使用进程内实现
+func (r *Registry) SetGCLocker(locker GCLocker) {
+	r.gc.locker = locker
+}
+
+// gcLocker 返回当前使用的锁
+func (r *Registry) gcLocker() GCLocker {
+	if r.gc.locker != nil {
+		return r.gc.locker
+	}
+	r.gc.localOnce.Do(func() {
+		r.gc.local = &localGCLocker{keys: make(map[string]*localKeyLock)}
+	})
+	return r.gc.local
+}
+
+// gcRepoKey 仓库的锁名称
+func gcRepoKey(repo string) string {
+	return "repo:" + repo
+}
+
+// gcBlobKey 全局 Blob 的锁名称
+func gcBlobKey(digest string) string {
+	return "blob:" + digest
+}
+
+// SortLockKeys 去重并排序锁名称：多个锁总是按相同顺序获取，避免死锁
+func SortLockKeys(keys []string) []string {
+	sorted := make([]string, 0, len(keys))
+	seen := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		if !seen[k] {
+			seen[k] = true
+			sorted = append(sorted, k)
+		}
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// localGCLocker 进程内的 GCLocker 实现
+type localGCLocker struct {
+	mu   sync.Mutex
+	keys map[string]*localKeyLock
+	gc   sync.Mutex
+}
+
+// localKeyLock 单个名称上的读写锁，refs 为持有或等待者数量（为 0 时回收）
+type localKeyLock struct {
+	rw   sync.RWMutex
+	refs int
+}
+
+// Lock 按顺序取得 keys 上的锁
+func (l *localGCLocker) Lock(ctx context.Context, exclusive bool, keys ...string) (func(), error) {
+	var held []func()
+	releaseAll := func() {
+		for i := len(held) - 1; i >= 0; i-- {
+			held[i]()
+		}
+	}
+	for _, key := range SortLockKeys(keys) {
+		release, err := l.lockKey(ctx, key, exclusive)
+		if err != nil {
+			releaseAll()
+			return nil, err
+		}
+		held = append(held, release)
+	}
+	return releaseAll, nil
+}
+
+// lockKey 取得单个名称上的锁
+// 等待中的排他锁会阻止新的共享锁，清理不会被持续的推送饿死。
+func (l *localGCLocker) lockKey(ctx context.Context, key string, exclusive bool) (func(), error) {
+	l.mu.Lock()
+	kl := l.keys[key]
+	if kl == nil {
+		kl = &localKeyLock{}
+		l.keys[key] = kl
+	}
+	kl.refs++
+	l.mu.Unlock()
+
+	lock, unlock := kl.rw.RLock, kl.rw.RUnlock
+	if exclusive {
+		lock, unlock = kl.rw.Lock, kl.rw.Unlock
+	}
+	release := func() {
+		unlock()
+		l.mu.Lock()
+		if kl.refs--; kl.refs == 0 {
+			delete(l.keys, key)
+		}
+		l.mu.Unlock()
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		lock()
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		return release, nil
+	case <-ctx.Done():
+		// 放弃等待：取得后立即释放
+		go func() {
+			<-acquired
+			release()
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+// TryLockGC 取得进程内的垃圾回收锁
+func (l *localGCLocker) TryLockGC(ctx context.Context) (func(), error) {
+	if !l.gc.TryLock() {
+		return nil, ErrGCAlreadyRunning
+	}
+	return l.gc.Unlock, nil
+}
+
+// beginPush 推送写入前取得仓库（以及要链接的 Blob）的共享锁；垃圾回收正在清理该仓库或 Blob 时
+// 最多等待 gcPushWait，仍未结束则返回 ErrGCInProgress。调用方必须在写入完成后调用返回的释放函数。
+func (r *Registry) beginPush(ctx context.Context, repo string, digests ...string) (func(), error) {
+	keys := []string{gcRepoKey(repo)}
+	for _, d := range digests {
+		keys = append(keys, gcBlobKey(d))
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, gcPushWait)
+	defer cancel()
+	release, err := r.gcLocker().Lock(waitCtx, false, keys...)
+	if err != nil {
+		if ctx.Err() == nil && errors.Is(waitCtx.Err(), context.DeadlineExceeded) {
+			return nil, ErrGCInProgress
+		}
+		return nil, err
+	}
+
+	if len(digests) > 0 {
+		r.noteLinked(digests...)
+	}
+	return release, nil
+}
+
+// noteLinked 垃圾回收进行中时记录本进程推送链接的 Blob（未设置链接索引时，删除全局 Blob 前据此确认）
+func (r *Registry) noteLinked(digests ...string) {
+	r.gc.mu.Lock()
+	defer r.gc.mu.Unlock()
+	if r.gc.linked == nil {
+		return
+	}
+	for _, d := range digests {
+		r.gc.linked[d] = true
+	}
+}
+
+// gcBlobLinked 删除全局 Blob 前（持有该 Blob 的排他锁）确认清除仓库之后没有新的链接
+// 设置了链接索引时查询索引（覆盖所有副本），否则检查本进程在回收期间的推送。
+func (r *Registry) gcBlobLinked(ctx context.Context, digest string) (bool, error) {
+	r.gc.mu.Lock()
+	linked := r.gc.linked[digest]
+	r.gc.mu.Unlock()
+	if linked || r.linkIndex == nil {
+		return linked, nil
+	}
+	return r.linkIndex.BlobLinked(ctx, digest)
+}
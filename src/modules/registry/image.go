@@ -0,0 +1,160 @@
+// Package registry Docker Registry API模块
+// 实现Docker Registry HTTP API V2规范
+package registry
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// 镜像内容的读取：区分容器镜像与其他 artifact、展开多架构索引、解压镜像层。
+// 供漏洞扫描、SBOM 生成等需要读取镜像文件系统的模块使用。
+
+// 压缩格式的魔数
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// IsImageManifest 判断是否为单平台的容器镜像 Manifest
+// 签名、SBOM 等 artifact 的 config 不是镜像配置，返回 false；多架构索引也返回 false。
+func IsImageManifest(raw []byte) bool {
+	switch ManifestMediaType(raw) {
+	case MediaTypeOCIManifest, MediaTypeDocker2Manifest:
+	default:
+		return false
+	}
+	var doc manifestDoc
+	if err := json.Unmarshal(raw, &doc); err != nil || doc.Config == nil {
+		return false
+	}
+	return doc.Config.MediaType == MediaTypeOCIImageConfig || doc.Config.MediaType == MediaTypeDocker2ImageConfig
+}
+
+// IsIndexManifest 判断是否为多架构索引
+func IsIndexManifest(raw []byte) bool {
+	mediaType := ManifestMediaType(raw)
+	return mediaType == MediaTypeOCIManifestIndex || mediaType == MediaTypeDocker2ManifestList
+}
+
+// PlatformManifest 多架构索引中的单个平台
+type PlatformManifest struct {
+	Descriptor
+	Platform string // 例如 linux/amd64、linux/arm/v7
+}
+
+// IndexPlatforms 返回多架构索引中各平台的子 Manifest
+// BuildKit 的 attestation 以 unknown/unknown 平台登记在索引中，不是可运行的镜像，跳过；未声明平台的条目同样跳过。
+func IndexPlatforms(raw []byte) ([]PlatformManifest, error) {
+	var index struct {
+		Manifests []struct {
+			Descriptor
+			Platform *struct {
+				OS           string `json:"os"`
+				Architecture string `json:"architecture"`
+				Variant      string `json:"variant,omitempty"`
+			} `json:"platform,omitempty"`
+		} `json:"manifests"`
+	}
+	if err := json.Unmarshal(raw, &index); err != nil {
+		return nil, fmt.Errorf("parse index: %w", err)
+	}
+
+	platforms := make([]PlatformManifest, 0, len(index.Manifests))
+	for _, child := range index.Manifests {
+		if child.Platform == nil || child.Platform.OS == "unknown" {
+			continue
+		}
+		platform := child.Platform.OS + "/" + child.Platform.Architecture
+		if child.Platform.Variant != "" {
+			platform += "/" + child.Platform.Variant
+		}
+		platforms = append(platforms, PlatformManifest{Descriptor: child.Descriptor, Platform: platform})
+	}
+	return platforms, nil
+}
+
+// ErrPlatformRequired 多架构镜像需要指定平台
+var ErrPlatformRequired = errors.New("registry: platform is required for multi-platform images")
+
+// SelectIndexPlatform 返回多架构索引中指定平台的子 Manifest digest
+// platform 为空时只有一个平台的索引返回该平台，否则返回 ErrPlatformRequired；索引中没有该平台时返回 ErrManifestNotFound。
+func SelectIndexPlatform(raw []byte, platform string) (string, error) {
+	platforms, err := IndexPlatforms(raw)
+	if err != nil {
+		return "", err
+	}
+	if platform == "" {
+		if len(platforms) == 1 {
+			return platforms[0].Digest, nil
+		}
+		return "", ErrPlatformRequired
+	}
+	for _, p := range platforms {
+		if p.Platform == platform {
+			return p.Digest, nil
+		}
+	}
+	return "", fmt.Errorf("%w: platform %s", ErrManifestNotFound, platform)
+}
+
+// DecompressLayer 按内容识别镜像层的压缩格式（gzip、zstd 或未压缩），返回 tar 数据流
+// 不依赖层的 mediaType：部分客户端推送的 Docker 层实际使用 zstd 压缩。
+func DecompressLayer(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(len(zstdMagic))
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return gzip.NewReader(br)
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return zr.IOReadCloser(), nil
+	default:
+		return io.NopCloser(br), nil
+	}
+}
+
+// OpenLayer 打开仓库中的镜像层，返回解压后的 tar 数据流，调用方负责关闭
+func (r *Registry) OpenLayer(ctx context.Context, repo, digest string) (io.ReadCloser, error) {
+	reader, _, err := r.GetBlob(ctx, repo, digest)
+	if err != nil {
+		return nil, err
+	}
+	closer, _ := reader.(io.Closer)
+	tr, err := DecompressLayer(reader)
+	if err != nil {
+		if closer != nil {
+			closer.Close()
+		}
+		return nil, fmt.Errorf("decompress layer %s: %w", digest, err)
+	}
+	return &layerReader{ReadCloser: tr, blob: closer}, nil
+}
+
+// layerReader 关闭时同时关闭解压流与底层 Blob
+type layerReader struct {
+	io.ReadCloser
+	blob io.Closer
+}
+
+// Close 实现 io.Closer
+func (l *layerReader) Close() error {
+	err := l.ReadCloser.Close()
+	if l.blob != nil {
+		if cerr := l.blob.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,90 @@
+package index
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/cyp-registry/registry/src/modules/registry"
+)
+
+// gcLockKey 垃圾回收锁的名称
+const gcLockKey = "gc"
+
+// Locker 基于 PostgreSQL 会话级 advisory lock 的推送与垃圾回收锁（实现 registry.GCLocker）
+// 每次加锁占用连接池中的一个独立连接直到释放，锁在数据库中持有，所有副本之间互斥；
+// 持有者异常退出时连接断开，数据库自动释放其持有的锁。
+type Locker struct {
+	db *gorm.DB
+}
+
+var _ registry.GCLocker = (*Locker)(nil)
+
+// NewLocker 创建锁
+func NewLocker(db *gorm.DB) *Locker {
+	return &Locker{db: db}
+}
+
+// Lock 按顺序取得 keys 上的共享或排他锁
+// 数据库按请求顺序授予锁：等待中的排他锁会阻止新的共享锁，清理不会被持续的推送饿死。
+func (l *Locker) Lock(ctx context.Context, exclusive bool, keys ...string) (func(), error) {
+	conn, err := l.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fn := "pg_advisory_lock_shared"
+	if exclusive {
+		fn = "pg_advisory_lock"
+	}
+	for _, key := range registry.SortLockKeys(keys) {
+		if _, err := conn.ExecContext(ctx, `SELECT `+fn+`(hashtext($1))`, advisoryKey(key)); err != nil {
+			releaseConn(conn)
+			return nil, err
+		}
+	}
+	return func() { releaseConn(conn) }, nil
+}
+
+// TryLockGC 取得全局唯一的垃圾回收锁，已被其他副本持有时返回 registry.ErrGCAlreadyRunning
+func (l *Locker) TryLockGC(ctx context.Context) (func(), error) {
+	conn, err := l.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var locked bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock(hashtext($1))`, advisoryKey(gcLockKey)).Scan(&locked); err != nil {
+		releaseConn(conn)
+		return nil, err
+	}
+	if !locked {
+		releaseConn(conn)
+		return nil, registry.ErrGCAlreadyRunning
+	}
+	return func() { releaseConn(conn) }, nil
+}
+
+// conn 从连接池取出一个独立连接
+func (l *Locker) conn(ctx context.Context) (*sql.Conn, error) {
+	sqlDB, err := l.db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("get database handle: %w", err)
+	}
+	return sqlDB.Conn(ctx)
+}
+
+// advisoryKey 锁名称加上前缀，与其他模块的 advisory lock 区分
+func advisoryKey(key string) string {
+	return "registry_gc:" + key
+}
+
+// releaseConn 释放连接上的全部 advisory lock 并归还连接
+// 无法确认释放时（例如等待被取消后连接状态未知）丢弃该连接，断开时数据库释放其持有的锁。
+func releaseConn(conn *sql.Conn) {
+	if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock_all()`); err != nil {
+		_ = conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+	}
+	_ = conn.Close()
+}
@@ -0,0 +1,214 @@
+// Package index 基于数据库的仓库与 tag 索引
+// 实现 registry.TagIndex：数据保存在 registry_images（项目内的镜像）与 registry_image_tags（tag）表，
+// 供 /v2/<name>/tags/list 与 /v2/_catalog 查询，避免在对象存储上逐级列目录，也使多副本部署看到一致的结果。
+// 同时实现 registry.LinkIndex：仓库级 Blob 链接保存在 registry_blob_links 表，用于用量统计与项目配额。
+package index
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/cyp-registry/registry/src/modules/registry"
+	"github.com/cyp-registry/registry/src/pkg/models"
+)
+
+// errProjectNotFound 仓库所属的项目不存在（已删除或仓库不属于任何项目）
+var errProjectNotFound = errors.New("index: project not found")
+
+// Store 仓库与 tag 索引
+type Store struct {
+	db *gorm.DB
+}
+
+var _ registry.TagIndex = (*Store)(nil)
+
+// NewStore 创建索引
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+// InitDatabase 初始化索引表结构
+func InitDatabase(db *gorm.DB) error {
+	if db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	if err := db.AutoMigrate(&models.Image{}, &models.ImageTag{}, &models.BlobLink{}); err != nil {
+		return fmt.Errorf("auto migrate registry_images/registry_image_tags/registry_blob_links failed: %w", err)
+	}
+	return nil
+}
+
+// splitRepository 将仓库名拆分为项目名与项目内的镜像名
+func splitRepository(repo string) (project, image string) {
+	project, image, _ = strings.Cut(repo, "/")
+	return project, image
+}
+
+// PutTag 记录 tag 的指向，仓库不存在时一并创建
+// 仓库所属的项目不存在时不记录（这样的仓库不会出现在 tags/list 与 _catalog 中）。
+func (s *Store) PutTag(ctx context.Context, repo, tag string, td *registry.TagData) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		imageID, err := ensureImage(tx, repo)
+		if err != nil {
+			return err
+		}
+		if err := upsertTag(tx, imageID, tag, td, time.Time{}); err != nil {
+			return err
+		}
+		_, err = refreshTagsCount(tx, imageID)
+		return err
+	})
+	if errors.Is(err, errProjectNotFound) {
+		log.Printf(`{"timestamp":"%s","level":"warn","module":"registry_index","operation":"put_tag","repository":"%s","tag":"%s","error":"project not found, skipped"}`, time.Now().Format(time.RFC3339), repo, tag)
+		return nil
+	}
+	return err
+}
+
+// DeleteTags 删除仓库中的 tag，仓库不再有 tag 时一并删除
+func (s *Store) DeleteTags(ctx context.Context, repo string, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		imageID, err := lockImage(tx, repo)
+		if err != nil || imageID == uuid.Nil {
+			return err
+		}
+		if err := tx.Exec(`DELETE FROM registry_image_tags WHERE image_id = ? AND name IN ?`, imageID, tags).Error; err != nil {
+			return err
+		}
+		_, err = refreshTagsCount(tx, imageID)
+		return err
+	})
+}
+
+// ListTags 按名称顺序返回仓库的全部 tag
+func (s *Store) ListTags(ctx context.Context, repo string) ([]string, error) {
+	project, image := splitRepository(repo)
+	tags := []string{}
+	err := s.db.WithContext(ctx).Raw(`
+		SELECT t.name FROM registry_image_tags t
+		JOIN registry_images i ON i.id = t.image_id
+		JOIN registry_projects p ON i.project_id = p.id::uuid
+		WHERE p.name = ? AND i.name = ?
+		  AND p.deleted_at IS NULL AND i.deleted_at IS NULL AND t.deleted_at IS NULL
+		ORDER BY t.name COLLATE "C"`, project, image).Scan(&tags).Error
+	if err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// ListRepositories 按名称顺序返回 last 之后的至多 n 个仓库（n <= 0 表示不限）
+func (s *Store) ListRepositories(ctx context.Context, n int, last string) ([]string, error) {
+	query := `
+		SELECT repository FROM (
+			SELECT CASE WHEN i.name = '' THEN p.name ELSE p.name || '/' || i.name END AS repository
+			FROM registry_images i
+			JOIN registry_projects p ON i.project_id = p.id::uuid
+			WHERE p.deleted_at IS NULL AND i.deleted_at IS NULL
+		) r
+		WHERE repository COLLATE "C" > ?
+		ORDER BY repository COLLATE "C"`
+	args := []interface{}{last}
+	if n > 0 {
+		query += ` LIMIT ?`
+		args = append(args, n)
+	}
+	repos := []string{}
+	if err := s.db.WithContext(ctx).Raw(query, args...).Scan(&repos).Error; err != nil {
+		return nil, err
+	}
+	return repos, nil
+}
+
+// Empty 索引中是否还没有任何仓库或 Blob 链接（首次启用时需要按存储重建）
+func (s *Store) Empty(ctx context.Context) (bool, error) {
+	for _, model := range []interface{}{&models.Image{}, &models.BlobLink{}} {
+		var ids []uuid.UUID
+		if err := s.db.WithContext(ctx).Model(model).Limit(1).Pluck("id", &ids).Error; err != nil {
+			return false, err
+		}
+		if len(ids) == 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// imageRow registry_images 查询结果
+type imageRow struct {
+	ID uuid.UUID
+}
+
+// ensureImage 返回仓库对应的镜像记录 ID，不存在时创建；同时锁定该行直到事务结束，
+// 使同一仓库的并发写入串行执行，tags_count 保持准确。
+func ensureImage(tx *gorm.DB, repo string) (uuid.UUID, error) {
+	project, image := splitRepository(repo)
+	now := time.Now()
+	var rows []imageRow
+	err := tx.Raw(`
+		INSERT INTO registry_images (id, project_id, name, tags_count, created_at, updated_at)
+		SELECT ?, p.id::uuid, ?, 0, ?, ? FROM registry_projects p
+		WHERE p.name = ? AND p.deleted_at IS NULL
+		ON CONFLICT (project_id, name) DO UPDATE SET updated_at = EXCLUDED.updated_at, deleted_at = NULL
+		RETURNING id`, uuid.New(), image, now, now, project).Scan(&rows).Error
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if len(rows) == 0 {
+		return uuid.Nil, errProjectNotFound
+	}
+	return rows[0].ID, nil
+}
+
+// lockImage 锁定仓库对应的镜像记录，不存在时返回 uuid.Nil
+func lockImage(tx *gorm.DB, repo string) (uuid.UUID, error) {
+	project, image := splitRepository(repo)
+	var rows []imageRow
+	err := tx.Raw(`
+		SELECT i.id FROM registry_images i
+		JOIN registry_projects p ON i.project_id = p.id::uuid
+		WHERE p.name = ? AND i.name = ? AND p.deleted_at IS NULL
+		FOR UPDATE OF i`, project, image).Scan(&rows).Error
+	if err != nil || len(rows) == 0 {
+		return uuid.Nil, err
+	}
+	return rows[0].ID, nil
+}
+
+// upsertTag 写入 tag；before 非零时只覆盖在该时间之前更新的记录（重建索引时不覆盖期间推送的新数据）
+func upsertTag(tx *gorm.DB, imageID uuid.UUID, tag string, td *registry.TagData, before time.Time) error {
+	now := time.Now()
+	query := `
+		INSERT INTO registry_image_tags (id, image_id, name, digest, size, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (image_id, name) DO UPDATE
+		SET digest = EXCLUDED.digest, size = EXCLUDED.size, updated_at = EXCLUDED.updated_at, deleted_at = NULL`
+	args := []interface{}{uuid.New(), imageID, tag, td.Digest, td.Size, now, now}
+	if !before.IsZero() {
+		query += ` WHERE registry_image_tags.updated_at < ?`
+		args = append(args, before)
+	}
+	return tx.Exec(query, args...).Error
+}
+
+// refreshTagsCount 重新统计镜像的 tag 数；没有 tag 时删除镜像记录。返回 tag 数。
+func refreshTagsCount(tx *gorm.DB, imageID uuid.UUID) (int64, error) {
+	var count int64
+	if err := tx.Model(&models.ImageTag{}).Where("image_id = ?", imageID).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	if count == 0 {
+		return 0, tx.Exec(`DELETE FROM registry_images WHERE id = ?`, imageID).Error
+	}
+	return count, tx.Exec(`UPDATE registry_images SET tags_count = ?, updated_at = ? WHERE id = ?`, count, time.Now(), imageID).Error
+}
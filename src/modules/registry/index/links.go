@@ -0,0 +1,144 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/cyp-registry/registry/src/modules/registry"
+)
+
+var _ registry.LinkIndex = (*Store)(nil)
+
+// PutLink 记录仓库可见的 Blob 及其大小，并更新所属项目去重后的用量（registry_projects.storage_used）
+func (s *Store) PutLink(ctx context.Context, repo, digest string, size int64) error {
+	project, _ := splitRepository(repo)
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockProjectLinks(tx, project); err != nil {
+			return err
+		}
+		if err := upsertLink(tx, repo, digest, size, time.Time{}); err != nil {
+			return err
+		}
+		return refreshStorageUsed(tx, project)
+	})
+}
+
+// DeleteLinks 删除仓库的 Blob 链接，并更新所属项目去重后的用量
+func (s *Store) DeleteLinks(ctx context.Context, repo string, digests []string) error {
+	if len(digests) == 0 {
+		return nil
+	}
+	project, _ := splitRepository(repo)
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockProjectLinks(tx, project); err != nil {
+			return err
+		}
+		if err := tx.Exec(`DELETE FROM registry_blob_links WHERE repository = ? AND digest IN ?`, repo, digests).Error; err != nil {
+			return err
+		}
+		return refreshStorageUsed(tx, project)
+	})
+}
+
+// Usage 统计 prefix（项目名或仓库名，空字符串表示全部）下的存储用量
+// 逻辑大小按链接累加；物理大小对同一摘要只计一次。
+func (s *Store) Usage(ctx context.Context, prefix string) (*registry.StorageUsage, error) {
+	var row struct {
+		LogicalBytes  int64
+		PhysicalBytes int64
+		LinkCount     int64
+		BlobCount     int64
+	}
+	err := s.db.WithContext(ctx).Raw(`
+		SELECT COALESCE(SUM(logical), 0) AS logical_bytes, COALESCE(SUM(size), 0) AS physical_bytes,
+		       COALESCE(SUM(links), 0) AS link_count, COUNT(*) AS blob_count
+		FROM (
+			SELECT digest, SUM(size) AS logical, MAX(size) AS size, COUNT(*) AS links
+			FROM registry_blob_links
+			WHERE ? = '' OR repository = ? OR starts_with(repository, ? || '/')
+			GROUP BY digest
+		) d`, prefix, prefix, prefix).Scan(&row).Error
+	if err != nil {
+		return nil, err
+	}
+	return &registry.StorageUsage{
+		LogicalBytes:  row.LogicalBytes,
+		PhysicalBytes: row.PhysicalBytes,
+		LinkCount:     row.LinkCount,
+		BlobCount:     row.BlobCount,
+	}, nil
+}
+
+// CheckQuota 检查仓库链接该 Blob 后所属项目是否超出配额
+// 项目内已有仓库链接该 Blob 时不占用新的空间；项目不存在时不检查。
+// 检查与写入之间不加锁，并发推送可能使用量略微超出配额。
+func (s *Store) CheckQuota(ctx context.Context, repo, digest string, size int64) error {
+	project, _ := splitRepository(repo)
+	var rows []struct {
+		StorageUsed  int64
+		StorageQuota int64
+		Linked       bool
+	}
+	err := s.db.WithContext(ctx).Raw(`
+		SELECT p.storage_used, p.storage_quota,
+		       EXISTS (SELECT 1 FROM registry_blob_links l WHERE l.project = p.name AND l.digest = ?) AS linked
+		FROM registry_projects p
+		WHERE p.name = ? AND p.deleted_at IS NULL`, digest, project).Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 || rows[0].Linked {
+		return nil
+	}
+	if p := rows[0]; p.StorageUsed+size > p.StorageQuota {
+		log.Printf(`{"timestamp":"%s","level":"warn","module":"registry_index","operation":"check_quota","repository":"%s","digest":"%s","size":%d,"storage_used":%d,"storage_quota":%d,"error":"quota exceeded"}`, time.Now().Format(time.RFC3339), repo, digest, size, p.StorageUsed, p.StorageQuota)
+		return fmt.Errorf("%w: project %s uses %d of %d bytes, blob %s needs %d more", registry.ErrQuotaExceeded, project, p.StorageUsed, p.StorageQuota, digest, size)
+	}
+	return nil
+}
+
+// BlobLinked 是否有任何仓库链接该 Blob
+func (s *Store) BlobLinked(ctx context.Context, digest string) (bool, error) {
+	var linked bool
+	err := s.db.WithContext(ctx).Raw(`SELECT EXISTS (SELECT 1 FROM registry_blob_links WHERE digest = ?)`, digest).Scan(&linked).Error
+	return linked, err
+}
+
+// lockProjectLinks 在事务内锁定项目的链接索引，使同一项目的链接写入与用量更新串行执行
+// 使用事务级 advisory lock：项目记录不存在时同样有效。
+func lockProjectLinks(tx *gorm.DB, project string) error {
+	return tx.Exec(`SELECT pg_advisory_xact_lock(hashtext(?))`, "registry_blob_links:"+project).Error
+}
+
+// upsertLink 写入链接；before 非零时只覆盖在该时间之前更新的记录（重建索引时不覆盖期间推送的新数据）
+func upsertLink(tx *gorm.DB, repo, digest string, size int64, before time.Time) error {
+	project, _ := splitRepository(repo)
+	now := time.Now()
+	query := `
+		INSERT INTO registry_blob_links (id, project, repository, digest, size, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (repository, digest) DO UPDATE
+		SET size = EXCLUDED.size, updated_at = EXCLUDED.updated_at, deleted_at = NULL`
+	args := []interface{}{uuid.New(), project, repo, digest, size, now, now}
+	if !before.IsZero() {
+		query += ` WHERE registry_blob_links.updated_at < ?`
+		args = append(args, before)
+	}
+	return tx.Exec(query, args...).Error
+}
+
+// refreshStorageUsed 按链接索引重新统计项目去重后的用量，写入 registry_projects.storage_used
+func refreshStorageUsed(tx *gorm.DB, project string) error {
+	return tx.Exec(`
+		UPDATE registry_projects SET storage_used = (
+			SELECT COALESCE(SUM(size), 0) FROM (
+				SELECT MAX(size) AS size FROM registry_blob_links WHERE project = ? GROUP BY digest
+			) d
+		)
+		WHERE name = ? AND deleted_at IS NULL`, project, project).Error
+}
@@ -0,0 +1,317 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/cyp-registry/registry/src/modules/registry"
+	"github.com/cyp-registry/registry/src/pkg/response"
+)
+
+// ReconcileReport 按存储重建索引的结果
+type ReconcileReport struct {
+	Repositories        int      `json:"repositories"`         // 存储中有 tag 的仓库数
+	Tags                int      `json:"tags"`                 // 存储中的 tag 数
+	Added               int      `json:"added"`                // 索引中缺失而补充的 tag
+	Updated             int      `json:"updated"`              // 指向与存储不一致而更新的 tag
+	Removed             int      `json:"removed"`              // 存储中已不存在而删除的 tag
+	RemovedRepositories int      `json:"removed_repositories"` // 存储中已没有 tag 而删除的仓库
+	Skipped             []string `json:"skipped"`              // 所属项目不存在而跳过的仓库
+	Links               int      `json:"links"`                // 存储中的 Blob 链接数（含旧版项目内 Blob）
+	LinksAdded          int      `json:"links_added"`          // 索引中缺失而补充的链接
+	LinksUpdated        int      `json:"links_updated"`        // 大小与存储不一致而更新的链接
+	LinksRemoved        int      `json:"links_removed"`        // 存储中已不存在而删除的链接
+}
+
+// indexedTag 索引中的 tag
+type indexedTag struct {
+	Name      string
+	Digest    string
+	UpdatedAt time.Time
+}
+
+// Reconcile 以存储中的 tag 映射为准重建索引：补充缺失的 tag、更新指向不一致的 tag、删除多余的 tag 与仓库，
+// 并以存储中的链接记录为准重建 Blob 链接索引与项目用量。
+// 可以在服务运行时执行：开始之后被推送更新过的记录保持不变，写入前在事务中重新读取存储中的 tag 与链接，
+// 期间被删除的 tag 与链接不会被重新写回。dryRun 时只统计不写入。
+func (s *Store) Reconcile(ctx context.Context, reg *registry.Registry, dryRun bool) (*ReconcileReport, error) {
+	started := time.Now()
+	report := &ReconcileReport{Skipped: []string{}}
+
+	repos, err := reg.ListRepositories(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list repositories: %w", err)
+	}
+	inStorage := make(map[string]bool, len(repos))
+	for _, repo := range repos {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		stored, err := reg.StoredTags(ctx, repo)
+		if err != nil {
+			return report, fmt.Errorf("%s: %w", repo, err)
+		}
+		if len(stored) == 0 {
+			continue
+		}
+		inStorage[repo] = true
+		report.Repositories++
+		report.Tags += len(stored)
+
+		if err := s.reconcileRepository(ctx, reg, repo, stored, started, dryRun, report); err != nil {
+			if errors.Is(err, errProjectNotFound) {
+				report.Skipped = append(report.Skipped, repo)
+				continue
+			}
+			return report, fmt.Errorf("%s: %w", repo, err)
+		}
+	}
+
+	// 索引中有、存储中已没有 tag 的仓库
+	indexed, err := s.ListRepositories(ctx, 0, "")
+	if err != nil {
+		return report, err
+	}
+	for _, repo := range indexed {
+		if inStorage[repo] {
+			continue
+		}
+		removed := report.Removed
+		if err := s.reconcileRepository(ctx, reg, repo, nil, started, dryRun, report); err != nil {
+			return report, fmt.Errorf("%s: %w", repo, err)
+		}
+		if report.Removed > removed {
+			report.RemovedRepositories++
+		}
+	}
+
+	if err := s.reconcileLinks(ctx, reg, repos, started, dryRun, report); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// reconcileRepository 在一个事务中将仓库的索引更新为 stored
+// stored 在事务开始前读取：补充或更新 tag 前持有仓库行锁重新读取存储，
+// 与之并发的删除（先删除存储中的 tag，再在同一行锁下删除索引）不会被重新写回。
+func (s *Store) reconcileRepository(ctx context.Context, reg *registry.Registry, repo string, stored map[string]*registry.TagData, started time.Time, dryRun bool, report *ReconcileReport) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var imageID uuid.UUID
+		var err error
+		if len(stored) > 0 && !dryRun {
+			imageID, err = ensureImage(tx, repo)
+		} else {
+			imageID, err = lockImage(tx, repo)
+			if err == nil && imageID == uuid.Nil && len(stored) > 0 && !projectExists(tx, repo) {
+				err = errProjectNotFound
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		existing := map[string]indexedTag{}
+		if imageID != uuid.Nil {
+			tags, err := indexedTags(tx, repo)
+			if err != nil {
+				return err
+			}
+			for _, t := range tags {
+				existing[t.Name] = t
+			}
+		}
+
+		for name, td := range stored {
+			current, ok := existing[name]
+			if ok && (current.Digest == td.Digest || !current.UpdatedAt.Before(started)) {
+				continue
+			}
+			if !dryRun {
+				td, err = reg.GetTag(ctx, repo, name)
+				if errors.Is(err, response.ErrNotFound) {
+					continue
+				}
+				if err != nil {
+					return fmt.Errorf("read tag %s:%s: %w", repo, name, err)
+				}
+				if ok && current.Digest == td.Digest {
+					continue
+				}
+				if err := upsertTag(tx, imageID, name, td, started); err != nil {
+					return err
+				}
+			}
+			if ok {
+				report.Updated++
+			} else {
+				report.Added++
+			}
+		}
+
+		var removed []string
+		for name, current := range existing {
+			if _, ok := stored[name]; ok || !current.UpdatedAt.Before(started) {
+				continue
+			}
+			removed = append(removed, name)
+		}
+		report.Removed += len(removed)
+		if dryRun || imageID == uuid.Nil {
+			return nil
+		}
+		if len(removed) > 0 {
+			if err := tx.Exec(`DELETE FROM registry_image_tags WHERE image_id = ? AND name IN ? AND updated_at < ?`, imageID, removed, started).Error; err != nil {
+				return err
+			}
+		}
+		_, err = refreshTagsCount(tx, imageID)
+		return err
+	})
+}
+
+// indexedTags 读取索引中仓库的全部 tag
+func indexedTags(tx *gorm.DB, repo string) ([]indexedTag, error) {
+	project, image := splitRepository(repo)
+	var tags []indexedTag
+	err := tx.Raw(`
+		SELECT t.name, t.digest, t.updated_at FROM registry_image_tags t
+		JOIN registry_images i ON i.id = t.image_id
+		JOIN registry_projects p ON i.project_id = p.id::uuid
+		WHERE p.name = ? AND i.name = ? AND p.deleted_at IS NULL AND t.deleted_at IS NULL`, project, image).Scan(&tags).Error
+	return tags, err
+}
+
+// projectExists 仓库所属的项目是否存在
+func projectExists(tx *gorm.DB, repo string) bool {
+	project, _ := splitRepository(repo)
+	var count int64
+	tx.Table("registry_projects").Where("name = ? AND deleted_at IS NULL", project).Count(&count)
+	return count > 0
+}
+
+// indexedLink 索引中的 Blob 链接
+type indexedLink struct {
+	Digest    string
+	Size      int64
+	UpdatedAt time.Time
+}
+
+// reconcileLinks 以存储为准重建 repos 与索引中已有仓库的 Blob 链接，并重新统计全部项目的用量
+func (s *Store) reconcileLinks(ctx context.Context, reg *registry.Registry, repos []string, started time.Time, dryRun bool, report *ReconcileReport) error {
+	var indexed []string
+	if err := s.db.WithContext(ctx).Raw(`SELECT DISTINCT repository FROM registry_blob_links`).Scan(&indexed).Error; err != nil {
+		return err
+	}
+	seen := make(map[string]bool, len(repos)+len(indexed))
+	for _, repo := range append(append([]string{}, repos...), indexed...) {
+		if seen[repo] {
+			continue
+		}
+		seen[repo] = true
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		stored, err := reg.StoredLinks(ctx, repo)
+		if err != nil {
+			return fmt.Errorf("%s: %w", repo, err)
+		}
+		report.Links += len(stored)
+		if err := s.reconcileRepositoryLinks(ctx, reg, repo, stored, started, dryRun, report); err != nil {
+			return fmt.Errorf("%s: %w", repo, err)
+		}
+	}
+	if dryRun {
+		return nil
+	}
+
+	// 没有任何链接的项目同样需要重新统计（此前的用量可能按旧方式累计）
+	var projects []string
+	if err := s.db.WithContext(ctx).Table("registry_projects").Where("deleted_at IS NULL").Pluck("name", &projects).Error; err != nil {
+		return err
+	}
+	for _, project := range projects {
+		err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := lockProjectLinks(tx, project); err != nil {
+				return err
+			}
+			return refreshStorageUsed(tx, project)
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", project, err)
+		}
+	}
+	return nil
+}
+
+// reconcileRepositoryLinks 在一个事务中将仓库的链接索引更新为 stored
+// stored 在事务开始前读取：补充或更新链接前持有项目锁重新读取存储，
+// 与之并发的删除（先删除存储中的链接，再在同一锁下删除索引）不会被重新写回。
+func (s *Store) reconcileRepositoryLinks(ctx context.Context, reg *registry.Registry, repo string, stored map[string]int64, started time.Time, dryRun bool, report *ReconcileReport) error {
+	project, _ := splitRepository(repo)
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if !dryRun {
+			if err := lockProjectLinks(tx, project); err != nil {
+				return err
+			}
+		}
+
+		var links []indexedLink
+		if err := tx.Raw(`SELECT digest, size, updated_at FROM registry_blob_links WHERE repository = ?`, repo).Scan(&links).Error; err != nil {
+			return err
+		}
+		existing := make(map[string]indexedLink, len(links))
+		for _, l := range links {
+			existing[l.Digest] = l
+		}
+
+		for digest, size := range stored {
+			current, ok := existing[digest]
+			if ok && (current.Size == size || !current.UpdatedAt.Before(started)) {
+				continue
+			}
+			if !dryRun {
+				size, err := reg.StoredLink(ctx, repo, digest)
+				if errors.Is(err, registry.ErrBlobNotFound) {
+					continue
+				}
+				if err != nil {
+					return fmt.Errorf("read blob link %s@%s: %w", repo, digest, err)
+				}
+				if ok && current.Size == size {
+					continue
+				}
+				if err := upsertLink(tx, repo, digest, size, started); err != nil {
+					return err
+				}
+			}
+			if ok {
+				report.LinksUpdated++
+			} else {
+				report.LinksAdded++
+			}
+		}
+
+		var removed []string
+		for digest, current := range existing {
+			if _, ok := stored[digest]; ok || !current.UpdatedAt.Before(started) {
+				continue
+			}
+			removed = append(removed, digest)
+		}
+		report.LinksRemoved += len(removed)
+		if dryRun {
+			return nil
+		}
+		if len(removed) > 0 {
+			if err := tx.Exec(`DELETE FROM registry_blob_links WHERE repository = ? AND digest IN ? AND updated_at < ?`, repo, removed, started).Error; err != nil {
+				return err
+			}
+		}
+		return refreshStorageUsed(tx, project)
+	})
+}
@@ -0,0 +1,282 @@
+// Package registry Docker Registry API模块
+// 实现Docker Registry HTTP API V2规范
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// ErrNotImage 不是容器镜像（签名、SBOM 等 artifact），没有镜像配置
+var ErrNotImage = errors.New("registry: manifest is not a container image")
+
+// maxImageConfigSize 读取镜像配置 blob 的大小上限
+const maxImageConfigSize = 8 << 20
+
+// ImageConfig 镜像配置 blob（OCI image config / Docker image config v1，两者结构一致）
+type ImageConfig struct {
+	Architecture string             `json:"architecture"`
+	OS           string             `json:"os"`
+	OSVersion    string             `json:"os.version,omitempty"`
+	Variant      string             `json:"variant,omitempty"`
+	Created      string             `json:"created,omitempty"`
+	Author       string             `json:"author,omitempty"`
+	Config       ImageRuntimeConfig `json:"config"`
+	RootFS       struct {
+		Type    string   `json:"type"`
+		DiffIDs []string `json:"diff_ids"`
+	} `json:"rootfs"`
+	History []ImageHistory `json:"history,omitempty"`
+}
+
+// ImageRuntimeConfig 镜像配置中的容器运行参数
+type ImageRuntimeConfig struct {
+	User         string              `json:"User,omitempty"`
+	Env          []string            `json:"Env,omitempty"`
+	Entrypoint   []string            `json:"Entrypoint,omitempty"`
+	Cmd          []string            `json:"Cmd,omitempty"`
+	WorkingDir   string              `json:"WorkingDir,omitempty"`
+	ExposedPorts map[string]struct{} `json:"ExposedPorts,omitempty"`
+	Volumes      map[string]struct{} `json:"Volumes,omitempty"`
+	Labels       map[string]string   `json:"Labels,omitempty"`
+	StopSignal   string              `json:"StopSignal,omitempty"`
+}
+
+// ImageHistory 镜像配置中的构建历史
+type ImageHistory struct {
+	Created    string `json:"created,omitempty"`
+	CreatedBy  string `json:"created_by,omitempty"`
+	Author     string `json:"author,omitempty"`
+	Comment    string `json:"comment,omitempty"`
+	EmptyLayer bool   `json:"empty_layer,omitempty"`
+}
+
+// ImageInspection 镜像检查结果；多架构索引在 Platforms 中逐个列出各平台
+type ImageInspection struct {
+	Digest    string          `json:"digest"`
+	MediaType string          `json:"media_type"`
+	Image     *ImageDetails   `json:"image,omitempty"`
+	Platforms []*ImageDetails `json:"platforms,omitempty"`
+}
+
+// ImageDetails 单平台镜像的配置与构建历史
+type ImageDetails struct {
+	Digest       string            `json:"digest"`
+	MediaType    string            `json:"media_type"`
+	Platform     string            `json:"platform"`
+	OS           string            `json:"os"`
+	OSVersion    string            `json:"os_version,omitempty"`
+	Architecture string            `json:"architecture"`
+	Variant      string            `json:"variant,omitempty"`
+	Created      *time.Time        `json:"created,omitempty"`
+	Author       string            `json:"author,omitempty"`
+	User         string            `json:"user,omitempty"`
+	WorkingDir   string            `json:"working_dir,omitempty"`
+	Entrypoint   []string          `json:"entrypoint"`
+	Cmd          []string          `json:"cmd"`
+	Env          []string          `json:"env"`
+	ExposedPorts []string          `json:"exposed_ports"`
+	Volumes      []string          `json:"volumes"`
+	Labels       map[string]string `json:"labels"`
+	StopSignal   string            `json:"stop_signal,omitempty"`
+	ConfigDigest string            `json:"config_digest"`
+	Size         int64             `json:"size"` // 配置与各层（压缩后）大小之和
+	Layers       []ImageLayer      `json:"layers"`
+	History      []HistoryEntry    `json:"history"`
+}
+
+// ImageLayer 镜像层
+type ImageLayer struct {
+	Digest    string `json:"digest"`
+	MediaType string `json:"media_type"`
+	Size      int64  `json:"size"`
+	DiffID    string `json:"diff_id,omitempty"` // 解压后内容的 digest（来自配置的 rootfs）
+}
+
+// HistoryEntry 构建历史条目；产生镜像层的步骤附带对应层的 digest 与大小
+type HistoryEntry struct {
+	Created     *time.Time `json:"created,omitempty"`
+	CreatedBy   string     `json:"created_by,omitempty"`
+	Author      string     `json:"author,omitempty"`
+	Comment     string     `json:"comment,omitempty"`
+	EmptyLayer  bool       `json:"empty_layer"`
+	LayerDigest string     `json:"layer_digest,omitempty"`
+	LayerSize   int64      `json:"layer_size,omitempty"`
+}
+
+// InspectImage 解析 tag 或 digest 指向的镜像配置与构建历史
+// 多架构索引逐个解析各平台的子 Manifest（跳过 attestation 等非镜像条目）；签名、SBOM 等 artifact 返回 ErrNotImage。
+func (r *Registry) InspectImage(ctx context.Context, repo, reference string) (*ImageInspection, error) {
+	raw, digest, err := r.GetManifestRaw(ctx, repo, reference)
+	if err != nil {
+		return nil, err
+	}
+	result := &ImageInspection{Digest: digest, MediaType: ManifestMediaType(raw)}
+
+	if !IsIndexManifest(raw) {
+		if !IsImageManifest(raw) {
+			return nil, ErrNotImage
+		}
+		details, err := r.inspectManifest(ctx, repo, digest, raw)
+		if err != nil {
+			return nil, err
+		}
+		result.Image = details
+		return result, nil
+	}
+
+	platforms, err := IndexPlatforms(raw)
+	if err != nil {
+		return nil, err
+	}
+	result.Platforms = make([]*ImageDetails, 0, len(platforms))
+	for _, child := range platforms {
+		childRaw, childDigest, err := r.GetManifestRaw(ctx, repo, child.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", child.Platform, err)
+		}
+		if !IsImageManifest(childRaw) {
+			continue
+		}
+		details, err := r.inspectManifest(ctx, repo, childDigest, childRaw)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", child.Platform, err)
+		}
+		result.Platforms = append(result.Platforms, details)
+	}
+	return result, nil
+}
+
+// ReadImageConfig 读取单平台镜像 Manifest 引用的配置 blob
+func (r *Registry) ReadImageConfig(ctx context.Context, repo string, manifest *Manifest) (*ImageConfig, error) {
+	if manifest.Config.Size > maxImageConfigSize {
+		return nil, fmt.Errorf("image config %s too large: %d bytes", manifest.Config.Digest, manifest.Config.Size)
+	}
+	reader, _, err := r.GetBlob(ctx, repo, manifest.Config.Digest)
+	if err != nil {
+		return nil, err
+	}
+	if closer, ok := reader.(io.Closer); ok {
+		defer closer.Close()
+	}
+	data, err := io.ReadAll(io.LimitReader(reader, maxImageConfigSize+1))
+	if err != nil {
+		return nil, err
+	}
+	var config ImageConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("parse image config %s: %w", manifest.Config.Digest, err)
+	}
+	return &config, nil
+}
+
+// inspectManifest 解析单平台镜像
+func (r *Registry) inspectManifest(ctx context.Context, repo, digest string, raw []byte) (*ImageDetails, error) {
+	var manifest Manifest
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return nil, fmt.Errorf("parse manifest: %w", err)
+	}
+	config, err := r.ReadImageConfig(ctx, repo, &manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	details := &ImageDetails{
+		Digest:       digest,
+		MediaType:    ManifestMediaType(raw),
+		Platform:     platformString(config.OS, config.Architecture, config.Variant),
+		OS:           config.OS,
+		OSVersion:    config.OSVersion,
+		Architecture: config.Architecture,
+		Variant:      config.Variant,
+		Created:      parseConfigTime(config.Created),
+		Author:       config.Author,
+		User:         config.Config.User,
+		WorkingDir:   config.Config.WorkingDir,
+		Entrypoint:   nonNil(config.Config.Entrypoint),
+		Cmd:          nonNil(config.Config.Cmd),
+		Env:          nonNil(config.Config.Env),
+		ExposedPorts: sortedSet(config.Config.ExposedPorts),
+		Volumes:      sortedSet(config.Config.Volumes),
+		Labels:       config.Config.Labels,
+		StopSignal:   config.Config.StopSignal,
+		ConfigDigest: manifest.Config.Digest,
+		Size:         manifest.Config.Size,
+		Layers:       make([]ImageLayer, 0, len(manifest.Layers)),
+		History:      make([]HistoryEntry, 0, len(config.History)),
+	}
+	if details.Labels == nil {
+		details.Labels = map[string]string{}
+	}
+	for i, layer := range manifest.Layers {
+		l := ImageLayer{Digest: layer.Digest, MediaType: layer.MediaType, Size: layer.Size}
+		if i < len(config.RootFS.DiffIDs) {
+			l.DiffID = config.RootFS.DiffIDs[i]
+		}
+		details.Layers = append(details.Layers, l)
+		details.Size += layer.Size
+	}
+
+	// 非 empty_layer 的历史条目按顺序与镜像层一一对应；条目数与层数不一致时（例如历史被裁剪）只配对能对应上的部分
+	next := 0
+	for _, h := range config.History {
+		entry := HistoryEntry{
+			Created:    parseConfigTime(h.Created),
+			CreatedBy:  h.CreatedBy,
+			Author:     h.Author,
+			Comment:    h.Comment,
+			EmptyLayer: h.EmptyLayer,
+		}
+		if !h.EmptyLayer && next < len(manifest.Layers) {
+			entry.LayerDigest = manifest.Layers[next].Digest
+			entry.LayerSize = manifest.Layers[next].Size
+			next++
+		}
+		details.History = append(details.History, entry)
+	}
+	return details, nil
+}
+
+// platformString 返回 os/arch[/variant]
+func platformString(os, arch, variant string) string {
+	p := os + "/" + arch
+	if variant != "" {
+		p += "/" + variant
+	}
+	return p
+}
+
+// parseConfigTime 解析镜像配置中的时间，无法解析或为零值时返回 nil
+func parseConfigTime(s string) *time.Time {
+	if s == "" {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil || t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// sortedSet 返回 map 键的有序列表
+func sortedSet(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// nonNil 将 nil 切片转换为空切片，使 JSON 输出为 []
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,391 @@
+// Package registry Docker Registry API模块
+// 实现Docker Registry HTTP API V2规范
+package registry
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/cyp-registry/registry/src/pkg/response"
+)
+
+// 镜像层文件列表缓存：
+//
+//	_layer_files/v1/<algorithm>/<hex>.json.gz  gzip 压缩的 LayerListing JSON
+//
+// 层内容由 digest 唯一确定，列表生成后不再变化；垃圾回收删除全局 Blob 时一并删除其列表。
+const (
+	layerFilesDir  = "_layer_files"
+	layerFilesRoot = layerFilesDir + "/v1"
+)
+
+// maxLayerEntries 单个层列出的条目上限，超过时截断（Truncated 为 true）
+const maxLayerEntries = 200000
+
+var (
+	// ErrInvalidLayer Blob 不是 tar 格式的镜像层（例如镜像配置）
+	ErrInvalidLayer = errors.New("registry: blob is not a layer tarball")
+	// ErrLayerFileNotFound 层中不存在该文件
+	ErrLayerFileNotFound = errors.New("registry: file not found in layer")
+	// ErrNotRegularFile 层中的条目不是普通文件（目录、符号链接、whiteout 等）
+	ErrNotRegularFile = errors.New("registry: not a regular file")
+)
+
+// 层条目类型
+const (
+	LayerEntryFile           = "file"
+	LayerEntryDir            = "dir"
+	LayerEntrySymlink        = "symlink"
+	LayerEntryHardlink       = "hardlink"
+	LayerEntryCharDevice     = "char"
+	LayerEntryBlockDevice    = "block"
+	LayerEntryFIFO           = "fifo"
+	LayerEntryWhiteout       = "whiteout"        // 删除下层的文件或目录，Path 为被删除的路径
+	LayerEntryOpaqueWhiteout = "opaque_whiteout" // 隐藏下层目录的全部内容，Path 为该目录
+	LayerEntryOther          = "other"
+)
+
+// 镜像层中的 whiteout 标记（OCI image spec / AUFS）
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+)
+
+// LayerEntry 镜像层中的 tar 条目
+type LayerEntry struct {
+	Path       string     `json:"path"`
+	Type       string     `json:"type"`
+	Mode       string     `json:"mode"` // 八进制权限位，例如 0755
+	Size       int64      `json:"size"`
+	UID        int        `json:"uid"`
+	GID        int        `json:"gid"`
+	ModTime    *time.Time `json:"mod_time,omitempty"`
+	LinkTarget string     `json:"link_target,omitempty"`
+}
+
+// LayerListing 镜像层文件列表
+type LayerListing struct {
+	Digest    string       `json:"digest"`
+	Entries   []LayerEntry `json:"entries"`
+	Files     int          `json:"files"`      // 普通文件数
+	Whiteouts int          `json:"whiteouts"`  // 删除与 opaque 标记数
+	TotalSize int64        `json:"total_size"` // 普通文件解压后的大小之和
+	Truncated bool         `json:"truncated"`
+}
+
+// buildLayerFilesPath 构建层文件列表缓存路径
+func buildLayerFilesPath(digest string) (string, error) {
+	algorithm, hexDigest, err := ParseDigest(digest)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/%s/%s.json.gz", layerFilesRoot, algorithm, hexDigest), nil
+}
+
+// ListLayerFiles 列出仓库中镜像层的 tar 条目（支持 gzip、zstd 与未压缩的层）
+// 结果按 digest 缓存在存储中；每次调用仍校验该仓库可以访问此 Blob。
+func (r *Registry) ListLayerFiles(ctx context.Context, repo, digest string) (*LayerListing, error) {
+	cachePath, err := buildLayerFilesPath(digest)
+	if err != nil {
+		return nil, ErrInvalidDigest
+	}
+	exists, err := r.CheckBlob(ctx, repo, digest)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrBlobNotFound
+	}
+
+	if listing, err := r.readLayerListing(ctx, cachePath); err == nil {
+		return listing, nil
+	} else if !errors.Is(err, response.ErrNotFound) {
+		log.Printf(`{"timestamp":"%s","level":"warn","module":"registry","operation":"layer_files_cache","digest":"%s","error":"%v"}`, time.Now().Format(time.RFC3339), digest, err)
+	}
+
+	listing, err := r.scanLayerFiles(ctx, repo, digest)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.writeLayerListing(ctx, cachePath, listing); err != nil {
+		log.Printf(`{"timestamp":"%s","level":"warn","module":"registry","operation":"layer_files_cache","digest":"%s","error":"%v"}`, time.Now().Format(time.RFC3339), digest, err)
+	}
+	return listing, nil
+}
+
+// scanLayerFiles 读取镜像层并生成文件列表
+func (r *Registry) scanLayerFiles(ctx context.Context, repo, digest string) (*LayerListing, error) {
+	rc, err := r.OpenLayer(ctx, repo, digest)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	listing := &LayerListing{Digest: digest, Entries: []LayerEntry{}}
+	tr := tar.NewReader(rc)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// 第一个条目就无法解析说明不是 tar（例如镜像配置 JSON）
+			if len(listing.Entries) == 0 {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidLayer, err)
+			}
+			return nil, fmt.Errorf("read layer %s: %w", digest, err)
+		}
+		// 跳过 pax 全局头与层的根目录（./）
+		if hdr.Typeflag == tar.TypeXGlobalHeader || cleanLayerPath(hdr.Name) == "" {
+			continue
+		}
+		if len(listing.Entries) >= maxLayerEntries {
+			listing.Truncated = true
+			break
+		}
+
+		entry := layerEntry(hdr)
+		switch entry.Type {
+		case LayerEntryFile:
+			listing.Files++
+			listing.TotalSize += entry.Size
+		case LayerEntryWhiteout, LayerEntryOpaqueWhiteout:
+			listing.Whiteouts++
+		}
+		listing.Entries = append(listing.Entries, entry)
+	}
+	return listing, nil
+}
+
+// layerEntry 将 tar 头转换为 LayerEntry，whiteout 标记转换为被删除的路径
+func layerEntry(hdr *tar.Header) LayerEntry {
+	name := cleanLayerPath(hdr.Name)
+	entry := LayerEntry{
+		Path: name,
+		Mode: fmt.Sprintf("%04o", hdr.Mode&07777),
+		UID:  hdr.Uid,
+		GID:  hdr.Gid,
+	}
+	if !hdr.ModTime.IsZero() {
+		t := hdr.ModTime.UTC()
+		entry.ModTime = &t
+	}
+
+	dir, base := path.Split(name)
+	switch {
+	case base == whiteoutOpaque:
+		entry.Type = LayerEntryOpaqueWhiteout
+		entry.Path = strings.TrimSuffix(dir, "/")
+		return entry
+	case strings.HasPrefix(base, whiteoutPrefix):
+		entry.Type = LayerEntryWhiteout
+		entry.Path = dir + strings.TrimPrefix(base, whiteoutPrefix)
+		return entry
+	}
+
+	switch hdr.Typeflag {
+	case tar.TypeReg, tar.TypeRegA:
+		entry.Type = LayerEntryFile
+		entry.Size = hdr.Size
+	case tar.TypeDir:
+		entry.Type = LayerEntryDir
+	case tar.TypeSymlink:
+		entry.Type = LayerEntrySymlink
+		entry.LinkTarget = hdr.Linkname
+	case tar.TypeLink:
+		entry.Type = LayerEntryHardlink
+		entry.LinkTarget = cleanLayerPath(hdr.Linkname)
+	case tar.TypeChar:
+		entry.Type = LayerEntryCharDevice
+	case tar.TypeBlock:
+		entry.Type = LayerEntryBlockDevice
+	case tar.TypeFifo:
+		entry.Type = LayerEntryFIFO
+	default:
+		entry.Type = LayerEntryOther
+	}
+	return entry
+}
+
+// cleanLayerPath 统一 tar 条目路径为不带前导 / 与 ./ 的相对路径
+func cleanLayerPath(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// readLayerListing 读取缓存的文件列表
+func (r *Registry) readLayerListing(ctx context.Context, p string) (*LayerListing, error) {
+	data, err := r.readStorageFile(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	var listing LayerListing
+	if err := json.NewDecoder(gz).Decode(&listing); err != nil {
+		return nil, err
+	}
+	return &listing, nil
+}
+
+// writeLayerListing 写入文件列表缓存
+func (r *Registry) writeLayerListing(ctx context.Context, p string, listing *LayerListing) error {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(gz).Encode(listing); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return r.storage.Put(ctx, p, bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+}
+
+// LayerImages 返回仓库中包含该层的全部标签对应的镜像
+// 多架构索引只要任一子 Manifest 包含该层即计入，返回的是标签指向的索引本身。
+func (r *Registry) LayerImages(ctx context.Context, repo, digest string) ([]ImageRef, error) {
+	tags, err := r.ListTags(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	var images []ImageRef
+	for _, tag := range tags {
+		raw, manifestDigest, err := r.GetManifestRaw(ctx, repo, tag)
+		if errors.Is(err, ErrManifestNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		contains, err := r.manifestContainsBlob(ctx, repo, raw, digest)
+		if err != nil {
+			return nil, err
+		}
+		if contains {
+			images = append(images, ImageRef{Repository: repo, Reference: tag, Digest: manifestDigest, Manifest: raw})
+		}
+	}
+	return images, nil
+}
+
+// manifestContainsBlob Manifest（或索引的任一子 Manifest）是否引用该 Blob
+func (r *Registry) manifestContainsBlob(ctx context.Context, repo string, raw []byte, digest string) (bool, error) {
+	blobs, children, err := ManifestReferences(raw, ManifestMediaType(raw))
+	if err != nil {
+		return false, err
+	}
+	for _, blob := range blobs {
+		if blob.Digest == digest {
+			return true, nil
+		}
+	}
+	for _, child := range children {
+		childRaw, _, err := r.GetManifestRaw(ctx, repo, child.Digest)
+		if errors.Is(err, ErrManifestNotFound) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		contains, err := r.manifestContainsBlob(ctx, repo, childRaw, digest)
+		if err != nil || contains {
+			return contains, err
+		}
+	}
+	return false, nil
+}
+
+// LayerFile 从镜像层中读取的单个文件
+type LayerFile struct {
+	io.ReadCloser
+	Path    string
+	Size    int64
+	Mode    int64
+	ModTime time.Time
+}
+
+// OpenLayerFile 打开镜像层中的单个普通文件，调用方负责关闭
+// 硬链接解析为同一层中的目标文件；目录、符号链接与 whiteout 返回 ErrNotRegularFile。
+func (r *Registry) OpenLayerFile(ctx context.Context, repo, digest, filePath string) (*LayerFile, error) {
+	if _, _, err := ParseDigest(digest); err != nil {
+		return nil, ErrInvalidDigest
+	}
+	target := cleanLayerPath(filePath)
+	if target == "" {
+		return nil, ErrLayerFileNotFound
+	}
+	// 硬链接最多跟随一次：目标总是在同一层中先于链接出现，需要重新读取该层
+	for attempt := 0; attempt < 2; attempt++ {
+		rc, err := r.OpenLayer(ctx, repo, digest)
+		if err != nil {
+			return nil, err
+		}
+		tr := tar.NewReader(rc)
+		var hdr *tar.Header
+		for {
+			hdr, err = tr.Next()
+			if err != nil {
+				break
+			}
+			if cleanLayerPath(hdr.Name) == target {
+				break
+			}
+		}
+		if err != nil {
+			rc.Close()
+			if err == io.EOF {
+				return nil, ErrLayerFileNotFound
+			}
+			return nil, fmt.Errorf("%w: %v", ErrInvalidLayer, err)
+		}
+
+		if hdr.Typeflag == tar.TypeLink && attempt == 0 {
+			rc.Close()
+			target = cleanLayerPath(hdr.Linkname)
+			continue
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			rc.Close()
+			entry := layerEntry(hdr)
+			if entry.LinkTarget != "" {
+				return nil, fmt.Errorf("%w: %s is a %s to %s", ErrNotRegularFile, target, entry.Type, entry.LinkTarget)
+			}
+			return nil, fmt.Errorf("%w: %s is a %s", ErrNotRegularFile, target, entry.Type)
+		}
+		return &LayerFile{
+			ReadCloser: &layerFileReader{Reader: tr, layer: rc},
+			Path:       target,
+			Size:       hdr.Size,
+			Mode:       hdr.Mode,
+			ModTime:    hdr.ModTime,
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNotRegularFile, target)
+}
+
+// layerFileReader 读取 tar 中的当前文件，关闭时关闭整个层
+type layerFileReader struct {
+	io.Reader
+	layer io.Closer
+}
+
+// Close 实现 io.Closer
+func (l *layerFileReader) Close() error {
+	return l.layer.Close()
+}
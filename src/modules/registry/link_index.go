@@ -0,0 +1,134 @@
+// Package registry Docker Registry API模块
+// 实现Docker Registry HTTP API V2规范
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/cyp-registry/registry/src/pkg/response"
+)
+
+// ErrQuotaExceeded 写入链接后项目去重后的存储用量将超出配额
+var ErrQuotaExceeded = errors.New("registry: project storage quota exceeded")
+
+// LinkIndex 仓库级 Blob 链接索引（由数据库实现），见 SetLinkIndex
+// 存储中的链接记录仍是权威数据：写入时先写存储再更新索引，删除时先删存储再更新索引；
+// 索引同时维护项目去重后的存储用量，用量统计与配额检查只查询索引，不再逐个读取链接。
+type LinkIndex interface {
+	// PutLink 记录仓库可见的 Blob 及其大小，并更新所属项目的用量
+	PutLink(ctx context.Context, repo, digest string, size int64) error
+	// DeleteLinks 删除仓库的 Blob 链接，并更新所属项目的用量
+	DeleteLinks(ctx context.Context, repo string, digests []string) error
+	// Usage 统计 prefix（项目名或仓库名，空字符串表示全部）下的存储用量
+	Usage(ctx context.Context, prefix string) (*StorageUsage, error)
+	// CheckQuota 检查仓库链接该 Blob 后所属项目是否超出配额（项目内已有该 Blob 时不占用新的空间），
+	// 超出时返回 ErrQuotaExceeded
+	CheckQuota(ctx context.Context, repo, digest string, size int64) error
+	// BlobLinked 是否有任何仓库链接该 Blob（垃圾回收删除全局 Blob 前确认）
+	BlobLinked(ctx context.Context, digest string) (bool, error)
+}
+
+// SetLinkIndex 设置仓库级 Blob 链接索引；设置后推送按项目去重后的用量检查配额，
+// GetUsage 直接查询索引。未设置时（例如导出子命令）不检查配额，用量通过扫描存储统计。
+func (r *Registry) SetLinkIndex(index LinkIndex) {
+	r.linkIndex = index
+}
+
+// linkBlob 检查项目配额后为仓库写入链接记录（推送、挂载、代理缓存使用）
+func (r *Registry) linkBlob(ctx context.Context, repo, digest string, size int64) error {
+	if r.linkIndex != nil {
+		if err := r.linkIndex.CheckQuota(ctx, repo, digest, size); err != nil {
+			return err
+		}
+	}
+	return r.writeBlobLink(ctx, repo, digest, size)
+}
+
+// indexLink 将链接写入索引
+func (r *Registry) indexLink(ctx context.Context, repo, digest string, size int64) error {
+	if r.linkIndex == nil {
+		return nil
+	}
+	if err := r.linkIndex.PutLink(ctx, repo, digest, size); err != nil {
+		return fmt.Errorf("failed to update blob link index: %w", err)
+	}
+	return nil
+}
+
+// unindexLinks 从索引中删除链接
+func (r *Registry) unindexLinks(ctx context.Context, repo string, digests ...string) error {
+	if r.linkIndex == nil || len(digests) == 0 {
+		return nil
+	}
+	if err := r.linkIndex.DeleteLinks(ctx, repo, digests); err != nil {
+		return fmt.Errorf("failed to update blob link index: %w", err)
+	}
+	return nil
+}
+
+// StoredLinks 读取存储中仓库可见的全部 Blob 及其大小（供重建索引使用）
+// 包括链接记录与旧版项目内 Blob。
+func (r *Registry) StoredLinks(ctx context.Context, repo string) (map[string]int64, error) {
+	links := make(map[string]int64)
+	err := r.walkStorage(ctx, repo+"/"+blobLinkDir, func(p string, isDir bool) error {
+		if isDir {
+			return nil
+		}
+		digest := path.Base(path.Dir(p)) + ":" + path.Base(p)
+		link, err := r.readBlobLink(ctx, repo, digest)
+		if err != nil {
+			if errors.Is(err, ErrBlobNotFound) {
+				return nil
+			}
+			return fmt.Errorf("read blob link %s@%s: %w", repo, digest, err)
+		}
+		links[digest] = link.Size
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.walkStorage(ctx, repo+"/blobs/sha256", func(p string, isDir bool) error {
+		if isDir {
+			return nil
+		}
+		digest := gcDigest(path.Base(p))
+		if _, ok := links[digest]; ok || !strings.HasPrefix(digest, "sha256:") {
+			return nil
+		}
+		size, _, err := r.storage.Stat(ctx, p)
+		if err != nil {
+			return nil
+		}
+		links[digest] = size
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+// StoredLink 读取存储中仓库可见的单个 Blob 的大小（链接记录或旧版项目内 Blob），不存在时返回 ErrBlobNotFound
+func (r *Registry) StoredLink(ctx context.Context, repo, digest string) (int64, error) {
+	link, err := r.readBlobLink(ctx, repo, digest)
+	if err == nil {
+		return link.Size, nil
+	}
+	if !errors.Is(err, ErrBlobNotFound) {
+		return 0, err
+	}
+	size, _, err := r.storage.Stat(ctx, BuildBlobPath(repo, digest))
+	if err != nil {
+		if errors.Is(err, response.ErrNotFound) {
+			return 0, ErrBlobNotFound
+		}
+		return 0, err
+	}
+	return size, nil
+}
@@ -25,13 +25,16 @@ var ErrManifestNotFound = errors.New("registry: manifest not found")
 // ErrBlobNotFound Blob不存在
 var ErrBlobNotFound = errors.New("registry: blob not found")
 
+// ErrBlobRangeInvalid 请求的 Blob 范围无法满足
+var ErrBlobRangeInvalid = errors.New("registry: blob range not satisfiable")
+
 // ErrUploadNotFound 上传不存在
 var ErrUploadNotFound = errors.New("registry: upload not found")
 
 // ErrInvalidContentType 无效的内容类型
 var ErrInvalidContentType = errors.New("registry: invalid content type")
 
-// ErrImmutableTag 不可覆盖的历史版本标签（例如 v1.0.0）
+// ErrImmutableTag 不可覆盖的标签（默认规则为历史版本号，例如 v1.0.0；可按项目配置）
 var ErrImmutableTag = errors.New("registry: immutable tag cannot be overwritten")
 
 // MediaType 定义OCI/Docker媒体类型
@@ -52,82 +55,51 @@ const (
 type Registry struct {
 	storage storage.Storage
 
-	// tagIndex:
-	// 为了修复部分环境下 /v2/<name>/tags/list 无法正确列出标签的问题，
-	// 在内存中维护当前进程内的 tag 索引（project -> set(tags)）。
-	// 仍保留基于底层存储的 List 逻辑作为兜底。
-	tagIndex map[string]map[string]struct{}
-	mu       sync.RWMutex
-}
+	// tagIndex 仓库与 tag 索引，见 SetTagIndex；为空时扫描存储
+	tagIndex TagIndex
 
-// NewRegistry 创建Registry服务实例
-func NewRegistry(store storage.Storage) *Registry {
-	return &Registry{
-		storage:  store,
-		tagIndex: make(map[string]map[string]struct{}),
-	}
-}
+	// linkIndex 仓库级 Blob 链接索引，见 SetLinkIndex；为空时不检查配额，用量通过扫描存储统计
+	linkIndex LinkIndex
 
-// versionTagRegexp 用于识别“历史版本号”标签（不可覆盖），例如：
-// v1.0.0、1.2.3、v2.3.4-beta、1.0.0-20260227 等。
-var versionTagRegexp = regexp.MustCompile(`^(v)?\d+\.\d+\.\d+([._-][0-9A-Za-z]+)*$`)
+	// uploadTTL 上传会话过期时间，见 SetUploadSessionTTL
+	uploadTTL time.Duration
 
-// isImmutableTag 判断给定 tag 是否为“历史版本号”标签，若是则禁止覆盖。
-// 例如：v1.0.0、1.2.3、v2.3.4-beta。
-// 像 stable、prod、latest、dev 等“当前版本”标签则允许多次更新。
-func isImmutableTag(tag string) bool {
-	return versionTagRegexp.MatchString(tag)
-}
+	// gc 垃圾回收状态与推送锁，见 gc.go
+	gc gcState
 
-// addTag 将 tag 写入内存索引
-func (r *Registry) addTag(project, tag string) {
-	if project == "" || tag == "" {
-		return
-	}
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	m, ok := r.tagIndex[project]
-	if !ok {
-		m = make(map[string]struct{})
-		r.tagIndex[project] = m
-	}
-	m[tag] = struct{}{}
-}
+	// referrersMu 串行化 referrers 索引的读-改-写
+	referrersMu sync.Mutex
 
-// removeTag 从内存索引中移除指定 tag
-func (r *Registry) removeTag(project, tag string) {
-	if project == "" || tag == "" {
-		return
-	}
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	if m, ok := r.tagIndex[project]; ok {
-		delete(m, tag)
-		if len(m) == 0 {
-			delete(r.tagIndex, project)
-		}
-	}
+	// policy 项目级策略来源，见 SetPolicyProvider
+	policy PolicyProvider
+
+	// redirectTTL Blob 下载预签名地址有效期，见 SetBlobRedirectTTL
+	redirectTTL time.Duration
+
+	// proxyProvider 代理项目配置来源，见 SetProxyProvider；proxies 缓存各代理项目的上游客户端
+	proxyProvider ProxyProvider
+	proxies       proxyState
 }
 
-// getTagsFromIndex 从内存索引中获取指定仓库的所有 tag
-func (r *Registry) getTagsFromIndex(project string) []string {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-	m, ok := r.tagIndex[project]
-	if !ok || len(m) == 0 {
-		return nil
-	}
-	tags := make([]string, 0, len(m))
-	for t := range m {
-		tags = append(tags, t)
+// NewRegistry 创建Registry服务实例
+func NewRegistry(store storage.Storage) *Registry {
+	return &Registry{
+		storage:     store,
+		uploadTTL:   DefaultUploadSessionTTL,
+		redirectTTL: DefaultBlobRedirectTTL,
 	}
-	return tags
 }
 
+// versionTagRegexp 默认的不可变标签规则（项目未配置 TagRules.ImmutablePatterns 时使用），
+// 匹配“历史版本号”标签，例如：v1.0.0、1.2.3、v2.3.4-beta、1.0.0-20260227 等。
+// 像 stable、prod、latest、dev 等“当前版本”标签则允许多次更新。
+var versionTagRegexp = regexp.MustCompile(`^(v)?\d+\.\d+\.\d+([._-][0-9A-Za-z]+)*$`)
+
 // Manifest Docker镜像Manifest结构
 type Manifest struct {
 	SchemaVersion int               `json:"schemaVersion"`
 	MediaType     string            `json:"mediaType,omitempty"`
+	ArtifactType  string            `json:"artifactType,omitempty"`
 	Config        LayerInfo         `json:"config"`
 	Layers        []LayerInfo       `json:"layers"`
 	Subject       *Descriptor       `json:"subject,omitempty"`
@@ -138,15 +110,20 @@ type Manifest struct {
 type ManifestIndex struct {
 	SchemaVersion int               `json:"schemaVersion"`
 	MediaType     string            `json:"mediaType,omitempty"`
+	ArtifactType  string            `json:"artifactType,omitempty"`
 	Manifests     []Descriptor      `json:"manifests"`
+	Subject       *Descriptor       `json:"subject,omitempty"`
 	Annotations   map[string]string `json:"annotations,omitempty"`
 }
 
 // Descriptor 描述符（引用其他资源的结构）
 type Descriptor struct {
-	MediaType string `json:"mediaType"`
-	Digest    string `json:"digest"`
-	Size      int64  `json:"size"`
+	MediaType    string            `json:"mediaType"`
+	Digest       string            `json:"digest"`
+	Size         int64             `json:"size"`
+	URLs         []string          `json:"urls,omitempty"` // 外部层（foreign/non-distributable）的下载地址
+	ArtifactType string            `json:"artifactType,omitempty"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
 }
 
 // LayerInfo 图层信息（简化版Descriptor）
@@ -165,26 +142,18 @@ type BlobInfo struct {
 }
 
 // UploadInfo 上传会话信息
+// 持久化在 <project>/uploads/<uuid>/session 中，跨副本共享
 type UploadInfo struct {
-	UUID        string
-	Project     string
-	Reference   string
-	Digest      string
-	Size        int64
-	StartedAt   time.Time
-	LastUpdated time.Time
-}
-
-// UploadState 上传状态存储
-type UploadState struct {
-	Uploads map[string]*UploadInfo
-}
-
-// NewUploadState 创建新的上传状态
-func NewUploadState() *UploadState {
-	return &UploadState{
-		Uploads: make(map[string]*UploadInfo),
-	}
+	UUID        string    `json:"uuid"`
+	Project     string    `json:"project"`
+	OwnerID     string    `json:"owner_id,omitempty"`
+	Reference   string    `json:"reference,omitempty"`
+	Digest      string    `json:"digest,omitempty"`
+	Size        int64     `json:"size"`                 // 已接收字节数
+	HashState   []byte    `json:"hash_state,omitempty"` // 已接收数据的 SHA256 中间状态
+	StartedAt   time.Time `json:"started_at"`
+	LastUpdated time.Time `json:"last_updated"`
+	ExpiresAt   time.Time `json:"expires_at"`
 }
 
 // ParseDigest 解析摘要字符串
@@ -236,8 +205,9 @@ func CalculateDigest(reader io.Reader) (string, int64, error) {
 	return digest, size, nil
 }
 
-// BuildBlobPath 构建Blob存储路径
+// BuildBlobPath 构建旧版（按项目存放）的Blob存储路径
 // 路径格式: <project>/blobs/sha256/<digest>
+// 新数据写入全局存储（见 BuildGlobalBlobPath / BuildBlobLinkPath），此路径仅用于兼容读取。
 func BuildBlobPath(project, digest string) string {
 	return fmt.Sprintf("%s/blobs/sha256/%s", project, digest)
 }
@@ -6,14 +6,13 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"io"
+	"sort"
 	"strings"
 
 	"github.com/cyp-registry/registry/src/pkg/response"
 )
 
-// ManifestReferrers 获取引用此Manifest的列表
-// GET /v2/<name>/manifests/<digest>/referrers
+// ManifestReferrers 获取引用此Manifest的列表（referrers 索引原始内容）
 func (r *Registry) ManifestReferrers(ctx context.Context, project, digest string) ([]Descriptor, error) {
 	// 验证摘要格式
 	_, hexDigest, err := ParseDigest(digest)
@@ -24,7 +23,7 @@ func (r *Registry) ManifestReferrers(ctx context.Context, project, digest string
 	// 查找引用此manifest的列表
 	referrersPath := BuildManifestPath(project, "referrers/"+hexDigest)
 
-	reader, _, err := r.storage.Get(ctx, referrersPath)
+	data, err := r.readStorageFile(ctx, referrersPath)
 	if err != nil {
 		if errors.Is(err, response.ErrNotFound) {
 			return []Descriptor{}, nil
@@ -32,11 +31,6 @@ func (r *Registry) ManifestReferrers(ctx context.Context, project, digest string
 		return nil, err
 	}
 
-	data, err := io.ReadAll(reader)
-	if err != nil {
-		return nil, err
-	}
-
 	var referrers []Descriptor
 	if err := json.Unmarshal(data, &referrers); err != nil {
 		return nil, err
@@ -45,59 +39,27 @@ func (r *Registry) ManifestReferrers(ctx context.Context, project, digest string
 	return referrers, nil
 }
 
-// Catalog 列出所有仓库
+// Catalog 列出所有仓库（项目名/镜像名），按名称排序并返回 last 之后的至多 n 个
 // GET /v2/_catalog
+// 配置了 tag 索引时查询索引，否则遍历存储。
 func (r *Registry) Catalog(ctx context.Context, n int, last string) ([]string, error) {
-	// 列出顶级目录
-	entries, err := r.storage.List(ctx, "")
-	if err != nil {
-		return nil, err
+	if r.tagIndex != nil {
+		return r.tagIndex.ListRepositories(ctx, n, last)
 	}
 
-	var repos []string
-	seen := make(map[string]bool)
-
-	for _, entry := range entries {
-		// 清理路径
-		entry = strings.TrimPrefix(entry, "/")
-		parts := strings.SplitN(entry, "/", 2)
-
-		// 只取第一级目录（项目名）
-		if len(parts) > 0 {
-			project := parts[0]
-			if !seen[project] && project != "" {
-				// 跳过非项目目录
-				if strings.Contains(project, "manifests") || strings.Contains(project, "blobs") ||
-					strings.Contains(project, "uploads") {
-					continue
-				}
-				seen[project] = true
-				repos = append(repos, project)
-			}
-		}
+	repos, err := r.ListRepositories(ctx)
+	if err != nil {
+		return nil, err
 	}
-
 	// 应用分页
+	offset := sort.SearchStrings(repos, last)
+	if offset < len(repos) && repos[offset] == last {
+		offset++
+	}
+	repos = repos[offset:]
 	if n > 0 && len(repos) > n {
-		offset := 0
-		if last != "" {
-			// 找到last的位置
-			for i, repo := range repos {
-				if repo == last {
-					offset = i + 1
-					break
-				}
-			}
-		}
-		if offset >= len(repos) {
-			return []string{}, nil
-		}
-		if offset+n > len(repos) {
-			return repos[offset:], nil
-		}
-		return repos[offset : offset+n], nil
+		repos = repos[:n]
 	}
-
 	return repos, nil
 }
 
@@ -24,6 +24,7 @@ func (r *Registry) DeleteManifest(ctx context.Context, project, reference string
 
 		// 删除真正的 manifest 内容（按 hexDigest 落盘）
 		manifestPath := BuildManifestPath(project, hexDigest)
+		r.removeManifestFromReferrers(ctx, project, fmt.Sprintf("%s:%s", alg, hexDigest))
 		if err := r.storage.Delete(ctx, manifestPath); err != nil {
 			return err
 		}
@@ -31,6 +32,7 @@ func (r *Registry) DeleteManifest(ctx context.Context, project, reference string
 		// 清理所有引用该 digest 的 tag 映射
 		tagsPath := BuildManifestPath(project, "tags")
 		entries, err := r.storage.List(ctx, tagsPath)
+		var removed []string
 		if err == nil {
 			fullDigest := fmt.Sprintf("%s:%s", alg, hexDigest)
 			for _, entry := range entries {
@@ -48,13 +50,16 @@ func (r *Registry) DeleteManifest(ctx context.Context, project, reference string
 				}
 				if tagData.Digest == fullDigest || tagData.Digest == ref {
 					_ = r.storage.Delete(ctx, tagPath)
-					// 同步从内存索引中移除
-					r.removeTag(project, name)
+					removed = append(removed, name)
 				}
 			}
 		}
+		// 同步从 tag 索引中移除
+		if err := r.unindexTags(ctx, project, removed...); err != nil {
+			return err
+		}
 	} else {
-		// tag 删除：先找到对应的 digest，并删除 tag 映射与内存索引，
+		// tag 删除：先找到对应的 digest，并删除 tag 映射与 tag 索引，
 		// 再尝试删除底层 manifest（如果不再被其他 tag 引用）。
 		tagPath := BuildManifestPath(project, "tags/"+ref)
 		tagData, err := r.getTagData(ctx, tagPath)
@@ -66,7 +71,9 @@ func (r *Registry) DeleteManifest(ctx context.Context, project, reference string
 		if err := r.storage.Delete(ctx, tagPath); err != nil {
 			return err
 		}
-		r.removeTag(project, ref)
+		if err := r.unindexTags(ctx, project, ref); err != nil {
+			return err
+		}
 
 		// 尝试删除对应的 manifest（如果没有其他 tag 引用它）
 		_, hexDigest, err := ParseDigest(tagData.Digest)
@@ -94,9 +101,13 @@ func (r *Registry) DeleteManifest(ctx context.Context, project, reference string
 				}
 			}
 			if shouldDelete {
+				r.removeManifestFromReferrers(ctx, project, tagData.Digest)
 				_ = r.storage.Delete(ctx, manifestPath)
 			}
 		}
 	}
+
+	// 删除的 tag 可能是浮动 tag 跟随的源
+	r.refreshFloatingTags(ctx, project)
 	return nil
 }
@@ -36,8 +36,16 @@ func (r *Registry) GetManifest(ctx context.Context, project, reference string) (
 }
 
 // GetManifestRaw 获取原始 Manifest 数据（不解析）
-// 返回原始字节、digest 和 error
+// 返回原始字节、digest 和 error；代理项目在本地未命中或 tag 缓存过期时从上游拉取
 func (r *Registry) GetManifestRaw(ctx context.Context, project, reference string) ([]byte, string, error) {
+	if target := r.lookupProxy(ctx, project); target != nil {
+		return r.getProxiedManifest(ctx, target, project, reference)
+	}
+	return r.getLocalManifest(ctx, project, reference)
+}
+
+// getLocalManifest 从本地存储读取原始 Manifest 数据
+func (r *Registry) getLocalManifest(ctx context.Context, project, reference string) ([]byte, string, error) {
 	// 确定是tag还是digest
 	isDigest, ref := ParseReference(reference)
 
@@ -4,6 +4,7 @@ package registry
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"strings"
@@ -24,6 +25,63 @@ func (r *Registry) BlobExists(ctx context.Context, project, digest string) (bool
 	return true, size, nil
 }
 
+// ManifestReferences 解析 Manifest 引用的对象
+// 镜像索引返回子 Manifest；镜像/artifact Manifest 返回 config、layers 与 blobs（跳过带 urls 的外部层）。
+func ManifestReferences(raw []byte, mediaType string) (blobs []Descriptor, manifests []Descriptor, err error) {
+	var doc manifestDoc
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if doc.isIndex(mediaType) {
+		return nil, doc.Manifests, nil
+	}
+
+	if doc.Config != nil && doc.Config.Digest != "" {
+		blobs = append(blobs, *doc.Config)
+	}
+	for _, desc := range append(doc.Layers, doc.Blobs...) {
+		if len(desc.URLs) == 0 {
+			blobs = append(blobs, desc)
+		}
+	}
+	return blobs, nil, nil
+}
+
+// ManifestMediaType 返回 Manifest 声明的媒体类型；未声明 mediaType 时按结构推断为 OCI 索引或 OCI Manifest
+func ManifestMediaType(raw []byte) string {
+	var doc manifestDoc
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return MediaTypeDocker2Manifest
+	}
+	if doc.MediaType != "" {
+		return doc.MediaType
+	}
+	if doc.isIndex("") {
+		return MediaTypeOCIManifestIndex
+	}
+	return MediaTypeOCIManifest
+}
+
+// ParseUploadRange 解析分片上传请求的 Content-Range 头
+// 兼容 OCI 规范的 "start-end" 与 "bytes start-end/total" 两种写法。
+func ParseUploadRange(contentRange string) (start, end int64, err error) {
+	if strings.Contains(contentRange, " ") {
+		start, end, _, err = ParseContentRange(contentRange)
+		return start, end, err
+	}
+	startStr, endStr, ok := strings.Cut(strings.TrimSpace(contentRange), "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid content-range format: %s", contentRange)
+	}
+	if start, err = strconv.ParseInt(startStr, 10, 64); err != nil || start < 0 {
+		return 0, 0, fmt.Errorf("invalid start in content-range: %s", contentRange)
+	}
+	if end, err = strconv.ParseInt(endStr, 10, 64); err != nil || end < start {
+		return 0, 0, fmt.Errorf("invalid end in content-range: %s", contentRange)
+	}
+	return start, end, nil
+}
+
 // ParseContentRange 解析Content-Range头
 // 格式: bytes start-end/total
 func ParseContentRange(contentRange string) (start, end, total int64, err error) {
@@ -7,12 +7,23 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 )
 
 // PutManifest 上传Manifest
 // PUT /v2/<name>/manifests/<reference>
 // 注意：manifest 参数仅用于获取 MediaType，实际存储使用 rawData
 func (r *Registry) PutManifest(ctx context.Context, project, reference string, manifest *Manifest) (string, error) {
+	if err := r.checkProxyWrite(ctx, project); err != nil {
+		return "", err
+	}
+
+	release, err := r.beginPush(ctx, project)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
 	// 序列化Manifest
 	data, err := json.Marshal(manifest)
 	if err != nil {
@@ -25,6 +36,18 @@ func (r *Registry) PutManifest(ctx context.Context, project, reference string, m
 		return "", err
 	}
 
+	// 项目 tag 规则
+	if isDigest, _ := ParseReference(reference); !isDigest {
+		if err := r.checkTagPolicy(ctx, project, reference, digest); err != nil {
+			return "", err
+		}
+	}
+
+	// 校验引用的 config/layers 存在且大小一致
+	if err := r.validateManifest(ctx, project, data, manifest.MediaType); err != nil {
+		return "", err
+	}
+
 	// 计算镜像实际大小：累加所有层的 size，若计算失败则退回为 Manifest JSON 大小
 	imageSize := size
 	if manifest != nil && len(manifest.Layers) > 0 {
@@ -55,16 +78,16 @@ func (r *Registry) PutManifest(ctx context.Context, project, reference string, m
 			Digest:    digest,
 			MediaType: manifest.MediaType,
 			Size:      imageSize,
+			PushedAt:  time.Now().UTC(),
 		}
 		tagDataBytes, _ := json.Marshal(tagData)
 		_ = r.storage.Put(ctx, tagPath, bytes.NewReader(tagDataBytes), int64(len(tagDataBytes)))
 
-		// 更新latest标签
-		latestPath := BuildManifestPath(project, "tags/latest")
-		_ = r.storage.Put(ctx, latestPath, bytes.NewReader(tagDataBytes), int64(len(tagDataBytes)))
-
-		// 同步写入内存 tag 索引，供 /v2/<name>/tags/list 使用
-		r.addTag(project, reference)
+		// 同步写入 tag 索引，供 /v2/<name>/tags/list 使用
+		if err := r.indexTag(ctx, project, reference, &tagData); err != nil {
+			return "", err
+		}
+		r.refreshFloatingTags(ctx, project)
 	}
 
 	return digest, nil
@@ -74,58 +97,46 @@ func (r *Registry) PutManifest(ctx context.Context, project, reference string, m
 // PUT /v2/<name>/manifests/<reference>
 // 使用原始请求体计算 digest，避免重新序列化导致的 digest 不匹配
 func (r *Registry) PutManifestRaw(ctx context.Context, project, reference string, rawData []byte, mediaType string) (string, error) {
-	// 使用原始数据计算摘要
-	digest, size, err := CalculateDigest(bytes.NewReader(rawData))
-	if err != nil {
+	if err := r.checkProxyWrite(ctx, project); err != nil {
 		return "", err
 	}
 
-	// 默认使用 Manifest JSON 大小时长；若能成功解析 Manifest，则改为镜像层总大小
-	imageSize := size
-	var manifest Manifest
-	if err := json.Unmarshal(rawData, &manifest); err == nil && len(manifest.Layers) > 0 {
-		var total int64
-		for _, layer := range manifest.Layers {
-			if layer.Size > 0 {
-				total += layer.Size
-			}
-		}
-		if total > 0 {
-			imageSize = total
-		}
+	// 与垃圾回收互斥：避免新 tag 指向正在被清除的 Manifest
+	release, err := r.beginPush(ctx, project)
+	if err != nil {
+		return "", err
 	}
+	defer release()
 
-	// 提取 digest 的 hex 部分（去掉 sha256: 前缀）
-	_, hexDigest, _ := ParseDigest(digest)
-
-	// 始终以 digest 路径存储 manifest（这是规范要求的）
-	digestPath := BuildManifestPath(project, hexDigest)
-	if err := r.storage.Put(ctx, digestPath, bytes.NewReader(rawData), size); err != nil {
-		return "", fmt.Errorf("failed to store manifest: %w", err)
+	// 使用原始数据计算摘要
+	digest, _, err := CalculateDigest(bytes.NewReader(rawData))
+	if err != nil {
+		return "", err
 	}
 
-	// 如果是tag（非digest引用），更新tag映射
-	isDigest, _ := ParseReference(reference)
-	if !isDigest {
-		// 版本号标签：若已存在则禁止覆盖（仅允许新增），例如 v1.0.0
-		if isImmutableTag(reference) {
-			tagPath := BuildManifestPath(project, "tags/"+reference)
-			if _, _, err := r.storage.Get(ctx, tagPath); err == nil {
-				return "", ErrImmutableTag
-			}
+	// 项目 tag 规则：保留名称、命名规则、不可变标签（在写入任何数据之前校验）
+	if isDigest, _ := ParseReference(reference); !isDigest {
+		if err := r.checkTagPolicy(ctx, project, reference, digest); err != nil {
+			return "", err
 		}
+	}
 
-		tagPath := BuildManifestPath(project, "tags/"+reference)
-		tagData := TagData{
-			Digest:    digest,
-			MediaType: mediaType,
-			Size:      imageSize,
-		}
-		tagDataBytes, _ := json.Marshal(tagData)
-		_ = r.storage.Put(ctx, tagPath, bytes.NewReader(tagDataBytes), int64(len(tagDataBytes)))
+	// 校验引用的 config/layers（或索引的子 Manifest）存在且大小一致，
+	// 避免存储永远无法拉取的 Manifest
+	if err := r.validateManifest(ctx, project, rawData, mediaType); err != nil {
+		return "", err
+	}
 
-		// 同步写入内存 tag 索引，供 /v2/<name>/tags/list 使用
-		r.addTag(project, reference)
+	// 始终以 digest 路径存储 manifest（这是规范要求的）；如果是tag（非digest引用），同时更新tag映射
+	tag := reference
+	if isDigest, _ := ParseReference(reference); isDigest {
+		tag = ""
+	}
+	if err := r.storeManifestData(ctx, project, tag, rawData, digest, mediaType); err != nil {
+		return "", err
+	}
+	if tag != "" {
+		r.refreshFloatingTags(ctx, project)
 	}
 
 	return digest, nil
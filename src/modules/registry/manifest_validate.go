@@ -0,0 +1,198 @@
+// Package registry Docker Registry API模块
+// 实现Docker Registry HTTP API V2规范
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cyp-registry/registry/src/pkg/response"
+)
+
+// OCI Distribution 规范错误码
+const (
+	ErrorCodeManifestBlobUnknown = "MANIFEST_BLOB_UNKNOWN"
+	ErrorCodeManifestInvalid     = "MANIFEST_INVALID"
+)
+
+// ErrManifestBlobUnknown Manifest 引用的 Blob（或索引引用的子 Manifest）在仓库中不存在
+var ErrManifestBlobUnknown = errors.New("registry: manifest references unknown blob")
+
+// ErrManifestInvalid Manifest 内容不合法（格式错误、摘要非法或声明大小与实际不符）
+var ErrManifestInvalid = errors.New("registry: manifest invalid")
+
+// ManifestValidationError Manifest 校验失败，Errors 可直接作为 APIError 返回给客户端
+type ManifestValidationError struct {
+	Code   string
+	Errors []ErrorDetail
+}
+
+// Error 实现 error 接口
+func (e *ManifestValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, d := range e.Errors {
+		if d.Detail != nil {
+			msgs = append(msgs, fmt.Sprintf("%s (%v)", d.Message, d.Detail))
+		} else {
+			msgs = append(msgs, d.Message)
+		}
+	}
+	return fmt.Sprintf("registry: %s: %s", strings.ToLower(e.Code), strings.Join(msgs, "; "))
+}
+
+// Unwrap 支持 errors.Is(err, ErrManifestBlobUnknown/ErrManifestInvalid)
+func (e *ManifestValidationError) Unwrap() error {
+	if e.Code == ErrorCodeManifestBlobUnknown {
+		return ErrManifestBlobUnknown
+	}
+	return ErrManifestInvalid
+}
+
+// APIError 转换为 Registry API 错误响应体
+func (e *ManifestValidationError) APIError() APIError {
+	return APIError{Errors: e.Errors}
+}
+
+// manifestInvalid 构造 MANIFEST_INVALID 错误
+func manifestInvalid(message string, detail interface{}) *ManifestValidationError {
+	return &ManifestValidationError{
+		Code:   ErrorCodeManifestInvalid,
+		Errors: []ErrorDetail{{Code: ErrorCodeManifestInvalid, Message: message, Detail: detail}},
+	}
+}
+
+// manifestDoc 同时兼容镜像 Manifest、镜像索引与 artifact Manifest 的解析结构
+type manifestDoc struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType"`
+	ArtifactType  string            `json:"artifactType"`
+	Config        *Descriptor       `json:"config"`
+	Layers        []Descriptor      `json:"layers"`
+	Blobs         []Descriptor      `json:"blobs"`
+	Manifests     []Descriptor      `json:"manifests"`
+	Subject       *Descriptor       `json:"subject"`
+	Annotations   map[string]string `json:"annotations"`
+}
+
+// isIndex 判断是否为镜像索引（Docker manifest list / OCI image index）
+func (d *manifestDoc) isIndex(contentType string) bool {
+	switch d.MediaType {
+	case MediaTypeDocker2ManifestList, MediaTypeOCIManifestIndex:
+		return true
+	}
+	switch contentType {
+	case MediaTypeDocker2ManifestList, MediaTypeOCIManifestIndex:
+		return true
+	}
+	return d.Manifests != nil && d.Config == nil
+}
+
+// validateManifest 校验 Manifest 引用的对象在仓库中存在且大小一致
+// 镜像 Manifest 校验 config、layers（artifact Manifest 的 blobs）；镜像索引校验子 Manifest。
+// 带 urls 的外部层（foreign/non-distributable）不要求存在于本仓库。
+func (r *Registry) validateManifest(ctx context.Context, project string, raw []byte, contentType string) error {
+	var doc manifestDoc
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return manifestInvalid("manifest invalid", err.Error())
+	}
+	if doc.SchemaVersion != 2 {
+		return manifestInvalid("unsupported manifest schemaVersion", map[string]int{"schemaVersion": doc.SchemaVersion})
+	}
+
+	var missing []ErrorDetail
+	var invalid []ErrorDetail
+
+	if doc.isIndex(contentType) {
+		for _, desc := range doc.Manifests {
+			size, err := r.manifestSize(ctx, project, desc.Digest)
+			switch {
+			case errors.Is(err, ErrInvalidDigest):
+				invalid = append(invalid, descriptorError("invalid manifest digest", desc))
+			case errors.Is(err, ErrManifestNotFound):
+				missing = append(missing, descriptorError("manifest unknown to repository", desc))
+			case err != nil:
+				return err
+			case desc.Size != size:
+				invalid = append(invalid, sizeMismatchError(desc, size))
+			}
+		}
+	} else {
+		if doc.Config == nil || doc.Config.Digest == "" {
+			return manifestInvalid("manifest config descriptor is required", nil)
+		}
+
+		descs := make([]Descriptor, 0, 1+len(doc.Layers)+len(doc.Blobs))
+		descs = append(descs, *doc.Config)
+		descs = append(descs, doc.Layers...)
+		descs = append(descs, doc.Blobs...)
+
+		for _, desc := range descs {
+			if len(desc.URLs) > 0 {
+				continue
+			}
+			if _, _, err := ParseDigest(desc.Digest); err != nil {
+				invalid = append(invalid, descriptorError("invalid blob digest", desc))
+				continue
+			}
+			_, size, err := r.resolveBlobPath(ctx, project, desc.Digest)
+			switch {
+			case errors.Is(err, ErrBlobNotFound):
+				missing = append(missing, descriptorError("blob unknown to repository", desc))
+			case err != nil:
+				return err
+			case desc.Size != size:
+				invalid = append(invalid, sizeMismatchError(desc, size))
+			}
+		}
+	}
+
+	if len(invalid) > 0 {
+		for i := range invalid {
+			invalid[i].Code = ErrorCodeManifestInvalid
+		}
+		return &ManifestValidationError{Code: ErrorCodeManifestInvalid, Errors: invalid}
+	}
+	if len(missing) > 0 {
+		for i := range missing {
+			missing[i].Code = ErrorCodeManifestBlobUnknown
+		}
+		return &ManifestValidationError{Code: ErrorCodeManifestBlobUnknown, Errors: missing}
+	}
+	return nil
+}
+
+// manifestSize 返回仓库中指定 digest 的 Manifest 大小
+func (r *Registry) manifestSize(ctx context.Context, project, digest string) (int64, error) {
+	_, hexDigest, err := ParseDigest(digest)
+	if err != nil {
+		return 0, ErrInvalidDigest
+	}
+	size, _, err := r.storage.Stat(ctx, BuildManifestPath(project, hexDigest))
+	if err != nil {
+		if errors.Is(err, response.ErrNotFound) {
+			return 0, ErrManifestNotFound
+		}
+		return 0, err
+	}
+	return size, nil
+}
+
+// descriptorError 构造引用某个描述符的错误详情
+func descriptorError(message string, desc Descriptor) ErrorDetail {
+	return ErrorDetail{Message: message, Detail: map[string]interface{}{"digest": desc.Digest}}
+}
+
+// sizeMismatchError 构造描述符大小不一致的错误详情
+func sizeMismatchError(desc Descriptor, actual int64) ErrorDetail {
+	return ErrorDetail{
+		Message: "descriptor size does not match content",
+		Detail: map[string]interface{}{
+			"digest":        desc.Digest,
+			"declared_size": desc.Size,
+			"actual_size":   actual,
+		},
+	}
+}
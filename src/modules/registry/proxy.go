@@ -0,0 +1,462 @@
+// Package registry Docker Registry API模块
+// 实现Docker Registry HTTP API V2规范
+package registry
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cyp-registry/registry/src/modules/storage"
+	"github.com/cyp-registry/registry/src/pkg/registryclient"
+	"github.com/google/uuid"
+)
+
+// ErrProxyReadOnly 代理（缓存）项目不接受推送
+var ErrProxyReadOnly = errors.New("registry: proxy project is read-only")
+
+// DefaultProxyTagTTL 代理项目中 tag 的默认缓存时间
+// 超过该时间后再次拉取 tag 时会向上游确认 digest 是否变化。
+const DefaultProxyTagTTL = 10 * time.Minute
+
+// ProxyConfig 代理项目（pull-through cache）的上游配置
+//
+//	{"url": "https://registry-1.docker.io", "username": "...", "password": "...", "tag_ttl_seconds": 600}
+//
+// 代理项目 <project>/<repo> 对应上游仓库 <repo>；Docker Hub 的官方镜像自动补全 library/ 前缀。
+type ProxyConfig struct {
+	URL      string `json:"url"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// Insecure 跳过上游 TLS 证书校验
+	Insecure bool `json:"insecure,omitempty"`
+	// TagTTLSeconds tag 缓存时间（秒），0 表示使用 DefaultProxyTagTTL
+	TagTTLSeconds int `json:"tag_ttl_seconds,omitempty"`
+}
+
+// ProxyProvider 代理项目配置来源（由项目服务实现）
+type ProxyProvider interface {
+	// ProxyConfig 返回项目的代理配置，非代理项目返回 nil
+	ProxyConfig(ctx context.Context, projectName string) (*ProxyConfig, error)
+}
+
+// ValidateProxyConfig 校验代理配置
+func ValidateProxyConfig(cfg *ProxyConfig) error {
+	if cfg == nil {
+		return nil
+	}
+	if _, err := registryclient.NormalizeEndpoint(cfg.URL); err != nil {
+		return err
+	}
+	if cfg.TagTTLSeconds < 0 {
+		return errors.New("tag_ttl_seconds must not be negative")
+	}
+	if cfg.Password != "" && cfg.Username == "" {
+		return errors.New("username is required when password is set")
+	}
+	return nil
+}
+
+// TagTTL 返回 tag 缓存时间
+func (c *ProxyConfig) TagTTL() time.Duration {
+	if c.TagTTLSeconds <= 0 {
+		return DefaultProxyTagTTL
+	}
+	return time.Duration(c.TagTTLSeconds) * time.Second
+}
+
+// proxyState 上游客户端缓存（配置变化时重建，以保留 token 缓存）
+type proxyState struct {
+	mu      sync.Mutex
+	clients map[string]*proxyClient // project -> client
+}
+
+// proxyClient 某个代理项目的上游客户端
+type proxyClient struct {
+	cfg    ProxyConfig
+	client *registryclient.Client
+}
+
+// proxyTarget 一次代理请求的上下文
+type proxyTarget struct {
+	client *registryclient.Client
+	repo   string // 上游仓库名
+	ttl    time.Duration
+}
+
+// SetProxyProvider 设置代理项目配置来源
+func (r *Registry) SetProxyProvider(p ProxyProvider) {
+	r.proxyProvider = p
+}
+
+// proxyFor 返回仓库所属代理项目的上游信息，非代理项目返回 nil
+func (r *Registry) proxyFor(ctx context.Context, repo string) (*proxyTarget, error) {
+	if r.proxyProvider == nil {
+		return nil, nil
+	}
+	projectName, upstreamRepo, ok := strings.Cut(repo, "/")
+	if !ok || upstreamRepo == "" {
+		// 代理项目下必须有仓库路径（<project>/<upstream-repo>）
+		return nil, nil
+	}
+
+	cfg, err := r.proxyProvider.ProxyConfig(ctx, projectName)
+	if err != nil || cfg == nil {
+		return nil, err
+	}
+
+	r.proxies.mu.Lock()
+	defer r.proxies.mu.Unlock()
+	if r.proxies.clients == nil {
+		r.proxies.clients = make(map[string]*proxyClient)
+	}
+	pc, ok := r.proxies.clients[projectName]
+	if !ok || pc.cfg != *cfg {
+		client, err := registryclient.New(cfg.URL, registryclient.Options{
+			Username: cfg.Username,
+			Password: cfg.Password,
+			Insecure: cfg.Insecure,
+		})
+		if err != nil {
+			return nil, err
+		}
+		pc = &proxyClient{cfg: *cfg, client: client}
+		r.proxies.clients[projectName] = pc
+	}
+
+	return &proxyTarget{
+		client: pc.client,
+		repo:   pc.client.RepositoryName(upstreamRepo),
+		ttl:    cfg.TagTTL(),
+	}, nil
+}
+
+// checkProxyWrite 拒绝向代理项目写入
+func (r *Registry) checkProxyWrite(ctx context.Context, repo string) error {
+	target, err := r.proxyFor(ctx, repo)
+	if err != nil {
+		return fmt.Errorf("failed to load proxy config: %w", err)
+	}
+	if target != nil {
+		return ErrProxyReadOnly
+	}
+	return nil
+}
+
+// lookupProxy 读取路径上的代理信息；配置读取失败时记录日志并按普通项目处理
+func (r *Registry) lookupProxy(ctx context.Context, repo string) *proxyTarget {
+	target, err := r.proxyFor(ctx, repo)
+	if err != nil {
+		log.Printf(`{"timestamp":"%s","level":"warn","module":"registry","operation":"proxy_lookup","repository":"%s","error":"%v"}`, time.Now().Format(time.RFC3339), repo, err)
+		return nil
+	}
+	return target
+}
+
+// getProxiedManifest 代理项目的 Manifest 读取：
+//   - digest 引用：本地命中直接返回，否则从上游拉取并缓存（内容不可变，无需过期）
+//   - tag 引用：缓存未过期时直接返回；过期后向上游 HEAD 确认 digest，变化时重新拉取。
+//     上游不可用时返回已缓存的（可能过期的）内容，保证镜像源故障时仍可拉取。
+func (r *Registry) getProxiedManifest(ctx context.Context, target *proxyTarget, project, reference string) ([]byte, string, error) {
+	isDigest, ref := ParseReference(reference)
+	if isDigest {
+		if data, digest, err := r.getLocalManifest(ctx, project, reference); err == nil {
+			return data, digest, nil
+		}
+		return r.fetchProxiedManifest(ctx, target, project, ref, "")
+	}
+
+	tagData, _ := r.GetTag(ctx, project, ref)
+	if tagData != nil && time.Since(tagData.PushedAt) < target.ttl {
+		if data, digest, err := r.getLocalManifest(ctx, project, reference); err == nil {
+			return data, digest, nil
+		}
+	}
+
+	desc, err := target.client.HeadManifest(ctx, target.repo, ref)
+	if err != nil {
+		if tagData != nil && !errors.Is(err, registryclient.ErrNotFound) {
+			log.Printf(`{"timestamp":"%s","level":"warn","module":"registry","operation":"proxy_manifest","repository":"%s","reference":"%s","error":"upstream unavailable, serving cached: %v"}`, time.Now().Format(time.RFC3339), project, reference, err)
+			return r.getLocalManifest(ctx, project, reference)
+		}
+		if errors.Is(err, registryclient.ErrNotFound) {
+			return nil, "", ErrManifestNotFound
+		}
+		return nil, "", err
+	}
+
+	// 上游 digest 未变化：刷新缓存时间
+	if tagData != nil && desc.Digest != "" && desc.Digest == tagData.Digest {
+		if data, digest, err := r.getLocalManifest(ctx, project, reference); err == nil {
+			tagData.PushedAt = time.Now().UTC()
+			if err := r.writeTagData(ctx, project, ref, tagData); err != nil {
+				log.Printf(`{"timestamp":"%s","level":"warn","module":"registry","operation":"proxy_manifest","repository":"%s","reference":"%s","error":"failed to refresh tag: %v"}`, time.Now().Format(time.RFC3339), project, reference, err)
+			}
+			return data, digest, nil
+		}
+	}
+
+	return r.fetchProxiedManifest(ctx, target, project, ref, ref)
+}
+
+// fetchProxiedManifest 从上游拉取 Manifest 并写入缓存
+// tag 非空时同时更新 tag 映射；GC 进行中时仅返回内容而不缓存。
+func (r *Registry) fetchProxiedManifest(ctx context.Context, target *proxyTarget, project, reference, tag string) ([]byte, string, error) {
+	data, desc, err := target.client.GetManifest(ctx, target.repo, reference)
+	if err != nil {
+		if errors.Is(err, registryclient.ErrNotFound) {
+			return nil, "", ErrManifestNotFound
+		}
+		return nil, "", err
+	}
+
+	release, err := r.beginPush(ctx, project)
+	if err != nil {
+		log.Printf(`{"timestamp":"%s","level":"warn","module":"registry","operation":"proxy_manifest","repository":"%s","reference":"%s","error":"not cached: %v"}`, time.Now().Format(time.RFC3339), project, reference, err)
+		return data, desc.Digest, nil
+	}
+	defer release()
+
+	if err := r.storeManifestData(ctx, project, tag, data, desc.Digest, desc.MediaType); err != nil {
+		log.Printf(`{"timestamp":"%s","level":"error","module":"registry","operation":"proxy_manifest","repository":"%s","reference":"%s","error":"failed to cache manifest: %v"}`, time.Now().Format(time.RFC3339), project, reference, err)
+	} else {
+		log.Printf(`{"timestamp":"%s","level":"info","module":"registry","operation":"proxy_manifest","repository":"%s","reference":"%s","upstream":"%s","digest":"%s"}`, time.Now().Format(time.RFC3339), project, reference, target.client.Endpoint(), desc.Digest)
+	}
+	return data, desc.Digest, nil
+}
+
+// proxyBlobSize 代理项目的 Blob 大小查询（本地未命中时调用）
+func (r *Registry) proxyBlobSize(ctx context.Context, target *proxyTarget, digest string) (int64, error) {
+	size, err := target.client.HeadBlob(ctx, target.repo, digest)
+	if err != nil {
+		if errors.Is(err, registryclient.ErrNotFound) {
+			return 0, ErrBlobNotFound
+		}
+		return 0, err
+	}
+	return size, nil
+}
+
+// fetchProxiedBlob 从上游下载 Blob：数据边返回给客户端边写入缓存
+// 读取完毕且摘要校验通过后才进入全局存储并建立仓库链接；客户端中途断开时丢弃已缓存的部分。
+func (r *Registry) fetchProxiedBlob(ctx context.Context, target *proxyTarget, project, digest string) (io.Reader, int64, error) {
+	body, size, err := target.client.GetBlob(ctx, target.repo, digest)
+	if err != nil {
+		if errors.Is(err, registryclient.ErrNotFound) {
+			return nil, 0, ErrBlobNotFound
+		}
+		return nil, 0, err
+	}
+
+	pr := &proxyBlobReader{
+		r:        r,
+		ctx:      context.WithoutCancel(ctx),
+		project:  project,
+		digest:   digest,
+		uploadID: uuid.New().String(),
+		src:      body,
+		h:        sha256.New(),
+	}
+	// 复用上传会话目录：进程异常退出时遗留的数据由上传清理任务回收
+	if w, err := r.storage.Writer(ctx, buildUploadPath(project, pr.uploadID, uploadDataFile), false); err == nil {
+		pr.w = w
+	} else {
+		log.Printf(`{"timestamp":"%s","level":"warn","module":"registry","operation":"proxy_blob","repository":"%s","digest":"%s","error":"not cached: %v"}`, time.Now().Format(time.RFC3339), project, digest, err)
+	}
+	return pr, size, nil
+}
+
+// proxyBlobReader 边读边缓存的上游 Blob 数据流
+type proxyBlobReader struct {
+	r        *Registry
+	ctx      context.Context
+	project  string
+	digest   string
+	uploadID string
+
+	src  io.ReadCloser
+	w    storage.FileWriter
+	h    hash.Hash
+	n    int64
+	done bool
+}
+
+// Read 读取上游数据，同时写入缓存与摘要；读到末尾时完成缓存
+func (p *proxyBlobReader) Read(b []byte) (int, error) {
+	n, err := p.src.Read(b)
+	if n > 0 {
+		p.h.Write(b[:n])
+		p.n += int64(n)
+		if p.w != nil {
+			if _, werr := p.w.Write(b[:n]); werr != nil {
+				p.abandon(werr)
+			}
+		}
+	}
+	if err == io.EOF && !p.done {
+		p.done = true
+		if p.w != nil {
+			if cerr := p.commit(); cerr != nil {
+				p.abandon(cerr)
+			}
+		}
+	}
+	return n, err
+}
+
+// Close 关闭上游连接；未读完时丢弃已缓存的部分
+func (p *proxyBlobReader) Close() error {
+	err := p.src.Close()
+	if !p.done && p.w != nil {
+		p.abandon(errors.New("download interrupted"))
+	}
+	return err
+}
+
+// commit 校验摘要并将缓存数据移入全局存储
+func (p *proxyBlobReader) commit() error {
+	actual := "sha256:" + hex.EncodeToString(p.h.Sum(nil))
+	if actual != p.digest {
+		return fmt.Errorf("digest mismatch: expected %s, got %s", p.digest, actual)
+	}
+
+	release, err := p.r.beginPush(p.ctx, p.project, actual)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	w := p.w
+	p.w = nil
+	dataPath := buildUploadPath(p.project, p.uploadID, uploadDataFile)
+
+	if _, err := p.r.globalBlobSize(p.ctx, actual); errors.Is(err, ErrBlobNotFound) {
+		if err := w.Commit(p.ctx); err != nil {
+			return err
+		}
+		if err := p.r.storage.Move(p.ctx, dataPath, BuildGlobalBlobPath(actual)); err != nil {
+			return err
+		}
+	} else if err != nil {
+		w.Cancel(p.ctx)
+		return err
+	} else {
+		w.Cancel(p.ctx)
+	}
+	_ = p.r.deleteUpload(p.ctx, p.project, p.uploadID)
+
+	if err := p.r.linkBlob(p.ctx, p.project, actual, p.n); err != nil {
+		return err
+	}
+	log.Printf(`{"timestamp":"%s","level":"info","module":"registry","operation":"proxy_blob","repository":"%s","digest":"%s","size":%d}`, time.Now().Format(time.RFC3339), p.project, actual, p.n)
+	return nil
+}
+
+// abandon 放弃缓存（不影响返回给客户端的数据）
+func (p *proxyBlobReader) abandon(cause error) {
+	if p.w != nil {
+		_ = p.w.Cancel(p.ctx)
+		p.w = nil
+	}
+	_ = p.r.deleteUpload(p.ctx, p.project, p.uploadID)
+	log.Printf(`{"timestamp":"%s","level":"warn","module":"registry","operation":"proxy_blob","repository":"%s","digest":"%s","error":"not cached: %v"}`, time.Now().Format(time.RFC3339), p.project, p.digest, cause)
+}
+
+// cacheProxiedBlob 完整下载上游 Blob 到缓存（用于范围请求等无法边读边返回的场景）
+func (r *Registry) cacheProxiedBlob(ctx context.Context, target *proxyTarget, project, digest string) error {
+	reader, _, err := r.fetchProxiedBlob(ctx, target, project, digest)
+	if err != nil {
+		return err
+	}
+	pr := reader.(*proxyBlobReader)
+	defer pr.Close()
+
+	if _, err := io.Copy(io.Discard, pr); err != nil {
+		return err
+	}
+	if pr.w == nil && !pr.done {
+		return ErrBlobNotFound
+	}
+	return nil
+}
+
+// writeTagData 写入 tag 映射并更新 tag 索引
+func (r *Registry) writeTagData(ctx context.Context, project, tag string, td *TagData) error {
+	data, err := json.Marshal(td)
+	if err != nil {
+		return err
+	}
+	if err := r.storage.Put(ctx, BuildManifestPath(project, "tags/"+tag), bytes.NewReader(data), int64(len(data))); err != nil {
+		return err
+	}
+	return r.indexTag(ctx, project, tag, td)
+}
+
+// storeManifestData 写入 Manifest 内容、referrers 索引与（可选的）tag 映射
+// 调用方负责摘要计算、策略与引用校验。
+func (r *Registry) storeManifestData(ctx context.Context, project, tag string, raw []byte, digest, mediaType string) error {
+	_, hexDigest, err := ParseDigest(digest)
+	if err != nil {
+		return err
+	}
+	size := int64(len(raw))
+	if err := r.storage.Put(ctx, BuildManifestPath(project, hexDigest), bytes.NewReader(raw), size); err != nil {
+		return fmt.Errorf("failed to store manifest: %w", err)
+	}
+
+	// 带 subject 的 Manifest（签名、SBOM、attestation 等）：登记到 subject 的 referrers 索引
+	var doc manifestDoc
+	if err := json.Unmarshal(raw, &doc); err == nil && doc.Subject != nil && doc.Subject.Digest != "" {
+		desc := referrerDescriptor(&doc, digest, size, mediaType)
+		if err := r.addReferrer(ctx, project, doc.Subject.Digest, desc); err != nil {
+			return fmt.Errorf("failed to update referrers index: %w", err)
+		}
+	}
+
+	if tag == "" {
+		return nil
+	}
+	return r.writeTagData(ctx, project, tag, &TagData{
+		Digest:    digest,
+		MediaType: mediaType,
+		Size:      manifestImageSize(raw, size),
+		PushedAt:  time.Now().UTC(),
+	})
+}
+
+// manifestImageSize 镜像大小：能解析出镜像层时为层总大小，否则为 Manifest 本身大小
+func manifestImageSize(raw []byte, size int64) int64 {
+	var manifest Manifest
+	if err := json.Unmarshal(raw, &manifest); err != nil || len(manifest.Layers) == 0 {
+		return size
+	}
+	var total int64
+	for _, layer := range manifest.Layers {
+		if layer.Size > 0 {
+			total += layer.Size
+		}
+	}
+	if total > 0 {
+		return total
+	}
+	return size
+}
+
+// IsUpstreamError 判断错误是否来自代理项目的上游（供控制器返回 502 而不是 500）
+func IsUpstreamError(err error) bool {
+	var se *registryclient.StatusError
+	return errors.As(err, &se) ||
+		errors.Is(err, registryclient.ErrUnauthorized) ||
+		errors.Is(err, registryclient.ErrUnavailable) ||
+		errors.Is(err, registryclient.ErrDigestMismatch)
+}
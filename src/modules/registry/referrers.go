@@ -0,0 +1,160 @@
+// Package registry Docker Registry API模块
+// 实现Docker Registry HTTP API V2规范
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/cyp-registry/registry/src/pkg/response"
+)
+
+// MediaTypeOCIEmptyConfig OCI 1.1 artifact 使用的空 config 媒体类型
+const MediaTypeOCIEmptyConfig = "application/vnd.oci.empty.v1+json"
+
+// referrers 索引：
+//
+//	<project>/manifests/referrers/<subject-hex>  JSON []Descriptor
+//
+// 推送带 subject 的 Manifest 时写入，删除该 Manifest 时移除。
+// 条目的 artifactType/annotations 取自 referrer Manifest 本身，查询时无需再读取各个 Manifest。
+
+// ParseManifestSubject 返回 Manifest 的 subject digest（无 subject 或无法解析时返回空串）
+func ParseManifestSubject(raw []byte) string {
+	var doc manifestDoc
+	if err := json.Unmarshal(raw, &doc); err != nil || doc.Subject == nil {
+		return ""
+	}
+	return doc.Subject.Digest
+}
+
+// referrerDescriptor 构造 referrers 索引条目
+// artifactType 为空时按 OCI 1.1 规范回退为 config 的媒体类型。
+func referrerDescriptor(doc *manifestDoc, digest string, size int64, mediaType string) Descriptor {
+	if doc.MediaType != "" {
+		mediaType = doc.MediaType
+	}
+	artifactType := doc.ArtifactType
+	if artifactType == "" && doc.Config != nil {
+		artifactType = doc.Config.MediaType
+	}
+	return Descriptor{
+		MediaType:    mediaType,
+		Digest:       digest,
+		Size:         size,
+		ArtifactType: artifactType,
+		Annotations:  doc.Annotations,
+	}
+}
+
+// buildReferrersPath 构建 subject 的 referrers 索引路径
+func buildReferrersPath(project, subject string) (string, error) {
+	_, hexDigest, err := ParseDigest(subject)
+	if err != nil {
+		return "", err
+	}
+	return BuildManifestPath(project, "referrers/"+hexDigest), nil
+}
+
+// updateReferrers 读-改-写 subject 的 referrers 索引
+// fn 返回新的列表；列表为空时删除索引文件。
+func (r *Registry) updateReferrers(ctx context.Context, project, subject string, fn func([]Descriptor) []Descriptor) error {
+	indexPath, err := buildReferrersPath(project, subject)
+	if err != nil {
+		return err
+	}
+
+	r.referrersMu.Lock()
+	defer r.referrersMu.Unlock()
+
+	current, err := r.ManifestReferrers(ctx, project, subject)
+	if err != nil {
+		return err
+	}
+
+	updated := fn(current)
+	if len(updated) == 0 {
+		if err := r.storage.Delete(ctx, indexPath); err != nil && !errors.Is(err, response.ErrNotFound) {
+			return err
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(updated)
+	if err != nil {
+		return err
+	}
+	return r.storage.Put(ctx, indexPath, bytes.NewReader(data), int64(len(data)))
+}
+
+// addReferrer 将 referrer 写入 subject 的索引（相同 digest 的条目会被替换）
+func (r *Registry) addReferrer(ctx context.Context, project, subject string, desc Descriptor) error {
+	return r.updateReferrers(ctx, project, subject, func(list []Descriptor) []Descriptor {
+		out := make([]Descriptor, 0, len(list)+1)
+		for _, d := range list {
+			if d.Digest != desc.Digest {
+				out = append(out, d)
+			}
+		}
+		return append(out, desc)
+	})
+}
+
+// removeReferrer 从 subject 的索引中移除 referrer
+func (r *Registry) removeReferrer(ctx context.Context, project, subject, digest string) error {
+	return r.updateReferrers(ctx, project, subject, func(list []Descriptor) []Descriptor {
+		out := make([]Descriptor, 0, len(list))
+		for _, d := range list {
+			if d.Digest != digest {
+				out = append(out, d)
+			}
+		}
+		return out
+	})
+}
+
+// removeManifestFromReferrers 删除 Manifest 前调用：若其带 subject，则从 subject 的索引中移除
+func (r *Registry) removeManifestFromReferrers(ctx context.Context, project, digest string) {
+	_, hexDigest, err := ParseDigest(digest)
+	if err != nil {
+		return
+	}
+	raw, err := r.readStorageFile(ctx, BuildManifestPath(project, hexDigest))
+	if err != nil {
+		return
+	}
+	if subject := ParseManifestSubject(raw); subject != "" {
+		_ = r.removeReferrer(ctx, project, subject, digest)
+	}
+}
+
+// GetReferrers 返回引用 subject 的 Manifest 列表（OCI 镜像索引）
+// GET /v2/<name>/referrers/<digest>[?artifactType=<type>]
+// artifactType 非空时只返回匹配的条目，filtered 表示已应用过滤（用于 OCI-Filters-Applied 响应头）。
+// subject 不存在时返回空索引（规范要求）。
+func (r *Registry) GetReferrers(ctx context.Context, project, subject, artifactType string) (index *ManifestIndex, filtered bool, err error) {
+	if _, _, err := ParseDigest(subject); err != nil {
+		return nil, false, ErrInvalidDigest
+	}
+
+	list, err := r.ManifestReferrers(ctx, project, subject)
+	if err != nil {
+		return nil, false, err
+	}
+
+	manifests := make([]Descriptor, 0, len(list))
+	for _, d := range list {
+		if artifactType != "" && d.ArtifactType != artifactType {
+			continue
+		}
+		manifests = append(manifests, d)
+	}
+
+	return &ManifestIndex{
+		SchemaVersion: 2,
+		MediaType:     MediaTypeOCIManifestIndex,
+		Manifests:     manifests,
+	}, artifactType != "", nil
+}
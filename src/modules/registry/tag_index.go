@@ -0,0 +1,111 @@
+// Package registry Docker Registry API模块
+// 实现Docker Registry HTTP API V2规范
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cyp-registry/registry/src/pkg/response"
+)
+
+// TagIndex 仓库与 tag 索引（由数据库实现），见 SetTagIndex
+// 存储中的 tag 映射仍是权威数据：写入时先写存储再更新索引，删除时先删存储再更新索引；
+// 两者不一致（例如更新索引失败）时可以用 reconcile 子命令按存储重建索引。
+type TagIndex interface {
+	// PutTag 记录 tag 的指向，仓库不存在时一并创建
+	PutTag(ctx context.Context, repo, tag string, td *TagData) error
+	// DeleteTags 删除仓库中的 tag，仓库不再有 tag 时一并删除
+	DeleteTags(ctx context.Context, repo string, tags []string) error
+	// ListTags 按名称顺序返回仓库的全部 tag
+	ListTags(ctx context.Context, repo string) ([]string, error)
+	// ListRepositories 按名称顺序返回 last 之后的至多 n 个仓库（n <= 0 表示不限）
+	ListRepositories(ctx context.Context, n int, last string) ([]string, error)
+}
+
+// SetTagIndex 设置仓库与 tag 索引；设置后 tags/list 与 _catalog 直接查询索引，
+// 未设置时（例如导出子命令）扫描存储。
+func (r *Registry) SetTagIndex(index TagIndex) {
+	r.tagIndex = index
+}
+
+// indexTag 将 tag 写入索引
+func (r *Registry) indexTag(ctx context.Context, repo, tag string, td *TagData) error {
+	if r.tagIndex == nil || repo == "" || tag == "" {
+		return nil
+	}
+	if err := r.tagIndex.PutTag(ctx, repo, tag, td); err != nil {
+		return fmt.Errorf("failed to update tag index: %w", err)
+	}
+	return nil
+}
+
+// unindexTags 从索引中删除 tag
+func (r *Registry) unindexTags(ctx context.Context, repo string, tags ...string) error {
+	if r.tagIndex == nil || repo == "" || len(tags) == 0 {
+		return nil
+	}
+	if err := r.tagIndex.DeleteTags(ctx, repo, tags); err != nil {
+		return fmt.Errorf("failed to update tag index: %w", err)
+	}
+	return nil
+}
+
+// StoredTags 读取存储中仓库的全部 tag 映射（供重建索引使用）
+func (r *Registry) StoredTags(ctx context.Context, repo string) (map[string]*TagData, error) {
+	names, err := r.storedTagNames(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	tags := make(map[string]*TagData, len(names))
+	for _, name := range names {
+		td, err := r.GetTag(ctx, repo, name)
+		if err != nil {
+			if errors.Is(err, response.ErrNotFound) {
+				continue
+			}
+			return nil, fmt.Errorf("read tag %s:%s: %w", repo, name, err)
+		}
+		tags[name] = td
+	}
+	return tags, nil
+}
+
+// storedTagNames 扫描存储中仓库的 tags 目录，返回有序的 tag 列表
+func (r *Registry) storedTagNames(ctx context.Context, repo string) ([]string, error) {
+	entries, err := r.storage.List(ctx, BuildManifestPath(repo, "tags"))
+	if err != nil {
+		if errors.Is(err, response.ErrNotFound) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+
+	seen := make(map[string]struct{}, len(entries))
+	for _, entry := range entries {
+		// 典型返回示例（不同驱动可能略有差异）：
+		// - "pat-test/small/manifests/tags/5mb"
+		// - "pat-test/small/manifests/tags/5mb/"
+		// - "5mb"
+		name := strings.TrimSuffix(entry, "/")
+		if idx := strings.LastIndex(name, "/"); idx != -1 {
+			name = name[idx+1:]
+		}
+		// 注意：不能过滤 "latest" 等合法标签名，否则前端/客户端将看不到这些标签
+		if name == "" {
+			continue
+		}
+		seen[name] = struct{}{}
+	}
+
+	tags := make([]string, 0, len(seen))
+	for t := range seen {
+		tags = append(tags, t)
+	}
+	// 保持稳定输出，便于分页与测试
+	sort.Strings(tags)
+	return tags, nil
+}
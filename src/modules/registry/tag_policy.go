@@ -0,0 +1,165 @@
+// Package registry Docker Registry API模块
+// 实现Docker Registry HTTP API V2规范
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// ErrTagNotAllowed tag 名称不符合项目的命名规则
+var ErrTagNotAllowed = errors.New("registry: tag name not allowed by project rules")
+
+// ErrTagReserved tag 为项目保留名称，不允许推送
+var ErrTagReserved = errors.New("registry: tag is reserved")
+
+// TagRules 项目级 tag 规则
+//
+// 每条规则为一个模式：以 ^ 开头时按正则表达式匹配，否则按 path.Match 通配符匹配（如 release-*）。
+// ImmutablePatterns 为 nil 时使用默认规则（语义化版本号标签不可覆盖，见 versionTagRegexp），
+// 为空列表时表示不启用不可变标签。
+type TagRules struct {
+	ImmutablePatterns []string `json:"immutable_patterns"` // 已存在时禁止覆盖的 tag
+	AllowedPatterns   []string `json:"allowed_patterns"`   // 非空时 tag 必须匹配其中之一
+	ReservedTags      []string `json:"reserved_tags"`      // 禁止推送的 tag
+
+	// FloatingTags 浮动 tag 规则（例如 latest 跟随最高语义化版本号），见 floating_tags.go
+	FloatingTags []FloatingTagRule `json:"floating_tags,omitempty"`
+}
+
+// PolicyProvider 提供项目级策略（由项目模块实现）
+// projectName 为仓库名的第一段；项目未配置时返回 nil, nil 使用默认规则。
+type PolicyProvider interface {
+	TagRules(ctx context.Context, projectName string) (*TagRules, error)
+}
+
+// TagPolicyError tag 规则校验失败
+type TagPolicyError struct {
+	Tag     string
+	Pattern string
+	Err     error // ErrImmutableTag / ErrTagNotAllowed / ErrTagReserved / ErrFloatingTag
+}
+
+// Error 实现 error 接口
+func (e *TagPolicyError) Error() string {
+	switch e.Err {
+	case ErrImmutableTag:
+		return fmt.Sprintf("tag %q is immutable (matches %q) and already exists; push a new tag instead", e.Tag, e.Pattern)
+	case ErrTagReserved:
+		return fmt.Sprintf("tag %q is reserved (matches %q)", e.Tag, e.Pattern)
+	case ErrFloatingTag:
+		return fmt.Sprintf("tag %q is a floating tag maintained by the registry and cannot be pushed directly", e.Tag)
+	default:
+		return fmt.Sprintf("tag %q does not match any allowed pattern of the project", e.Tag)
+	}
+}
+
+// Unwrap 支持 errors.Is
+func (e *TagPolicyError) Unwrap() error {
+	return e.Err
+}
+
+// SetPolicyProvider 设置项目策略来源；未设置时所有项目使用默认规则
+func (r *Registry) SetPolicyProvider(p PolicyProvider) {
+	r.policy = p
+}
+
+// ValidateTagRules 校验规则中的模式是否合法
+func ValidateTagRules(rules *TagRules) error {
+	if rules == nil {
+		return nil
+	}
+	groups := map[string][]string{
+		"immutable_patterns": rules.ImmutablePatterns,
+		"allowed_patterns":   rules.AllowedPatterns,
+		"reserved_tags":      rules.ReservedTags,
+	}
+	if err := validateFloatingTagRules(rules.FloatingTags); err != nil {
+		return err
+	}
+	for field, patterns := range groups {
+		for _, p := range patterns {
+			if strings.TrimSpace(p) == "" {
+				return fmt.Errorf("%s: empty pattern", field)
+			}
+			if strings.HasPrefix(p, "^") {
+				if _, err := regexp.Compile(p); err != nil {
+					return fmt.Errorf("%s: invalid regexp %q: %v", field, p, err)
+				}
+			} else if _, err := path.Match(p, ""); err != nil {
+				return fmt.Errorf("%s: invalid pattern %q: %v", field, p, err)
+			}
+		}
+	}
+	return nil
+}
+
+// matchTagPattern 判断 tag 是否匹配模式，返回命中的模式
+func matchTagPattern(patterns []string, tag string) (string, bool) {
+	for _, p := range patterns {
+		if strings.HasPrefix(p, "^") {
+			if re, err := regexp.Compile(p); err == nil && re.MatchString(tag) {
+				return p, true
+			}
+			continue
+		}
+		if ok, _ := path.Match(p, tag); ok {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// projectTagRules 获取仓库所属项目的 tag 规则（未配置时返回 nil）
+func (r *Registry) projectTagRules(ctx context.Context, repo string) (*TagRules, error) {
+	if r.policy == nil {
+		return nil, nil
+	}
+	projectName := repo
+	if idx := strings.Index(repo, "/"); idx > 0 {
+		projectName = repo[:idx]
+	}
+	return r.policy.TagRules(ctx, projectName)
+}
+
+// checkTagPolicy 推送 tag 前校验项目规则：保留名称、命名规则与不可变标签
+// 不可变标签重复推送相同 digest（客户端重试）视为幂等操作，不报错。
+func (r *Registry) checkTagPolicy(ctx context.Context, repo, tag, digest string) error {
+	rules, err := r.projectTagRules(ctx, repo)
+	if err != nil {
+		return fmt.Errorf("failed to load tag rules: %w", err)
+	}
+
+	var immutable []string
+	if rules == nil || rules.ImmutablePatterns == nil {
+		immutable = []string{versionTagRegexp.String()}
+	} else {
+		immutable = rules.ImmutablePatterns
+	}
+
+	if rules != nil {
+		if rules.floatingRule(tag) != nil {
+			return &TagPolicyError{Tag: tag, Err: ErrFloatingTag}
+		}
+		if p, ok := matchTagPattern(rules.ReservedTags, tag); ok {
+			return &TagPolicyError{Tag: tag, Pattern: p, Err: ErrTagReserved}
+		}
+		if len(rules.AllowedPatterns) > 0 {
+			if _, ok := matchTagPattern(rules.AllowedPatterns, tag); !ok {
+				return &TagPolicyError{Tag: tag, Err: ErrTagNotAllowed}
+			}
+		}
+	}
+
+	if p, ok := matchTagPattern(immutable, tag); ok {
+		td, err := r.getTagData(ctx, BuildManifestPath(repo, "tags/"+tag))
+		if err == nil && td.Digest != digest {
+			return &TagPolicyError{Tag: tag, Pattern: p, Err: ErrImmutableTag}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+// Package registry Docker Registry API模块
+// 实现Docker Registry HTTP API V2规范
+package registry
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/cyp-registry/registry/src/pkg/response"
+)
+
+// ErrUploadOffsetMismatch 分片起始位置与已接收数据长度不一致
+var ErrUploadOffsetMismatch = errors.New("registry: upload offset mismatch")
+
+// ErrUploadRangeMismatch 分片实际长度与 Content-Range 声明的范围不一致
+var ErrUploadRangeMismatch = errors.New("registry: upload chunk length does not match content-range")
+
+// UploadAppend 作为 UploadBlobChunk 的 offset 时表示不校验起始位置，追加到会话当前末尾（请求未带 Content-Range）
+const UploadAppend int64 = -1
+
+// DefaultUploadSessionTTL 上传会话默认保留时间（自最后一次写入起算）
+const DefaultUploadSessionTTL = 24 * time.Hour
+
+// 上传会话布局：
+//
+//	<project>/uploads/<uuid>/data     已接收的数据（通过 storage.FileWriter 追加写入）
+//	<project>/uploads/<uuid>/session  会话记录（UploadInfo JSON，含 SHA256 中间状态）
+//
+// 会话记录与数据都保存在共享存储中，客户端断线后重连到任一副本都能从正确的偏移继续上传。
+const (
+	uploadDataFile    = "data"
+	uploadSessionFile = "session"
+)
+
+// BuildUploadDir 构建上传会话目录
+// 路径格式: <project>/uploads/<uuid>
+func BuildUploadDir(project, uploadID string) string {
+	return fmt.Sprintf("%s/uploads/%s", project, uploadID)
+}
+
+// buildUploadPath 构建上传会话内的文件路径
+func buildUploadPath(project, uploadID, name string) string {
+	return BuildUploadDir(project, uploadID) + "/" + name
+}
+
+// SetUploadSessionTTL 设置上传会话的过期时间，d <= 0 时使用默认值
+func (r *Registry) SetUploadSessionTTL(d time.Duration) {
+	if d <= 0 {
+		d = DefaultUploadSessionTTL
+	}
+	r.uploadTTL = d
+}
+
+// UploadSessionTTL 返回上传会话的过期时间
+func (r *Registry) UploadSessionTTL() time.Duration {
+	if r.uploadTTL <= 0 {
+		return DefaultUploadSessionTTL
+	}
+	return r.uploadTTL
+}
+
+// uploadExpired 判断会话是否过期（以当前配置的 TTL 为准，ExpiresAt 仅供客户端参考）
+func (r *Registry) uploadExpired(info *UploadInfo, now time.Time) bool {
+	return now.After(info.LastUpdated.Add(r.UploadSessionTTL()))
+}
+
+// readUploadSession 读取上传会话记录（不检查过期），不存在时返回 ErrUploadNotFound
+func (r *Registry) readUploadSession(ctx context.Context, project, uploadID string) (*UploadInfo, error) {
+	reader, _, err := r.storage.Get(ctx, buildUploadPath(project, uploadID, uploadSessionFile))
+	if err != nil {
+		if errors.Is(err, response.ErrNotFound) {
+			return nil, ErrUploadNotFound
+		}
+		return nil, err
+	}
+	if closer, ok := reader.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	var info UploadInfo
+	if err := json.NewDecoder(reader).Decode(&info); err != nil {
+		return nil, fmt.Errorf("invalid upload session: %w", err)
+	}
+	return &info, nil
+}
+
+// loadUploadSession 读取未过期的上传会话，并恢复其摘要状态
+func (r *Registry) loadUploadSession(ctx context.Context, project, uploadID string) (*UploadInfo, hash.Hash, error) {
+	if uploadID == "" {
+		return nil, nil, ErrUploadNotFound
+	}
+
+	info, err := r.readUploadSession(ctx, project, uploadID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if r.uploadExpired(info, time.Now()) {
+		return nil, nil, fmt.Errorf("%w: upload session expired", ErrUploadNotFound)
+	}
+
+	h := sha256.New()
+	if len(info.HashState) > 0 {
+		if err := h.(encoding.BinaryUnmarshaler).UnmarshalBinary(info.HashState); err != nil {
+			return nil, nil, fmt.Errorf("invalid upload hash state: %w", err)
+		}
+	}
+	return info, h, nil
+}
+
+// saveUploadSession 持久化上传会话记录（同时刷新过期时间）
+func (r *Registry) saveUploadSession(ctx context.Context, info *UploadInfo, h hash.Hash) error {
+	state, err := h.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("failed to marshal hash state: %w", err)
+	}
+
+	info.HashState = state
+	info.LastUpdated = time.Now()
+	info.ExpiresAt = info.LastUpdated.Add(r.UploadSessionTTL())
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return r.storage.Put(ctx, buildUploadPath(info.Project, info.UUID, uploadSessionFile), bytes.NewReader(data), int64(len(data)))
+}
+
+// deleteUpload 删除上传会话的全部数据（最佳努力）
+func (r *Registry) deleteUpload(ctx context.Context, project, uploadID string) error {
+	var firstErr error
+	if w, err := r.storage.Writer(ctx, buildUploadPath(project, uploadID, uploadDataFile), true); err == nil {
+		if err := w.Cancel(ctx); err != nil {
+			firstErr = err
+		}
+	}
+	if err := r.storage.Delete(ctx, buildUploadPath(project, uploadID, uploadSessionFile)); err != nil &&
+		!errors.Is(err, response.ErrNotFound) && firstErr == nil {
+		firstErr = err
+	}
+	// 本地存储需要删除空目录；对象存储中目录不存在，忽略错误
+	_ = r.storage.Delete(ctx, BuildUploadDir(project, uploadID))
+	return firstErr
+}
+
+// PurgeExpiredUploads 清理所有仓库下过期或被遗弃的上传会话
+// 会话记录缺失（例如旧版本遗留的临时文件）时，按文件修改时间判断是否超过保留时间。
+// 返回清理的会话数量。
+func (r *Registry) PurgeExpiredUploads(ctx context.Context) (int, error) {
+	now := time.Now()
+	ttl := r.UploadSessionTTL()
+	purged := 0
+
+	err := r.walkRepositoryDirs(ctx, func(dir string, subdirs map[string]bool) error {
+		if dir == "" || !subdirs["uploads"] {
+			return nil
+		}
+
+		project := dir
+		entries, err := r.storage.List(ctx, dir+"/uploads")
+		if err != nil {
+			return nil
+		}
+
+		for _, entry := range entries {
+			// 会话 ID 为 UUID：跳过同名目录下的其他内容（例如名为 <project>/uploads 的仓库）
+			uploadID := path.Base(strings.TrimSuffix(entry, "/"))
+			if _, err := uuid.Parse(uploadID); err != nil {
+				continue
+			}
+
+			expired := false
+			if info, err := r.readUploadSession(ctx, project, uploadID); err == nil {
+				expired = r.uploadExpired(info, now)
+			} else {
+				// 无会话记录：按数据文件（或旧版平铺文件）的修改时间判断
+				target := buildUploadPath(project, uploadID, uploadDataFile)
+				if entry[len(entry)-1] != '/' {
+					target = BuildUploadDir(project, uploadID)
+				}
+				if _, modTime, sErr := r.storage.Stat(ctx, target); sErr == nil {
+					if t, pErr := time.Parse(time.RFC3339, modTime); pErr == nil {
+						expired = now.Sub(t) > ttl
+					}
+				} else if errors.Is(sErr, response.ErrNotFound) {
+					expired = true
+				}
+			}
+			if !expired {
+				continue
+			}
+
+			if err := r.deleteUpload(ctx, project, uploadID); err != nil {
+				log.Printf(`{"timestamp":"%s","level":"warn","module":"registry","operation":"purge_upload","repository":"%s","upload_id":"%s","error":"%v"}`, time.Now().Format(time.RFC3339), project, uploadID, err)
+				continue
+			}
+			purged++
+			log.Printf(`{"timestamp":"%s","level":"info","module":"registry","operation":"purge_upload","repository":"%s","upload_id":"%s"}`, time.Now().Format(time.RFC3339), project, uploadID)
+		}
+		return nil
+	})
+
+	return purged, err
+}
@@ -0,0 +1,166 @@
+// Package registry Docker Registry API模块
+// 实现Docker Registry HTTP API V2规范
+package registry
+
+import (
+	"context"
+	"errors"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/cyp-registry/registry/src/pkg/response"
+)
+
+// errSkipDir 由 walkStorage 回调返回，表示跳过当前目录
+var errSkipDir = errors.New("registry: skip dir")
+
+// walkStorage 递归遍历存储中 root 下的所有条目
+// 目录以 isDir=true 回调（回调返回 errSkipDir 则不再深入），文件以 isDir=false 回调。
+// 底层 storage.List 只列出直接子项，目录项以 / 结尾。
+func (r *Registry) walkStorage(ctx context.Context, root string, fn func(path string, isDir bool) error) error {
+	root = strings.Trim(root, "/")
+
+	entries, err := r.storage.List(ctx, root)
+	if err != nil {
+		if errors.Is(err, response.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		isDir := strings.HasSuffix(entry, "/")
+		entry = strings.Trim(entry, "/")
+		if entry == "" || entry == root {
+			continue
+		}
+
+		if err := fn(entry, isDir); err != nil {
+			if errors.Is(err, errSkipDir) {
+				continue
+			}
+			return err
+		}
+		if isDir {
+			if err := r.walkStorage(ctx, entry, fn); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// repoLayoutDirs 仓库根目录下由存储布局使用的子目录
+var repoLayoutDirs = map[string]bool{
+	"manifests": true,
+	"blobs":     true,
+	"uploads":   true,
+}
+
+// walkRepositoryDirs 遍历存储中可能包含仓库的目录，对每个目录以其子目录名回调
+// 只跳过存储布局中固定位置的内部目录：根目录下的全局 Blob 与层文件缓存、仓库根目录（含 manifests 或 _links
+// 子目录）下的 _links，以及仓库根目录下 manifests/tags、manifests/referrers、blobs/sha256 与 uploads/<会话 ID>。
+// 这些名称出现在其他位置时照常遍历，例如仓库 proj/blobs/app 或 proj/uploads。
+func (r *Registry) walkRepositoryDirs(ctx context.Context, fn func(dir string, subdirs map[string]bool) error) error {
+	return r.walkRepositoryDir(ctx, "", "", fn)
+}
+
+// walkRepositoryDir 遍历 dir；layout 非空时 dir 为仓库根目录下名为 layout 的布局目录
+func (r *Registry) walkRepositoryDir(ctx context.Context, dir, layout string, fn func(dir string, subdirs map[string]bool) error) error {
+	entries, err := r.storage.List(ctx, dir)
+	if err != nil {
+		if errors.Is(err, response.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	subdirs := make(map[string]bool)
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry, "/") {
+			continue
+		}
+		entry = strings.Trim(entry, "/")
+		if entry == "" || entry == dir {
+			continue
+		}
+		subdirs[path.Base(entry)] = true
+	}
+	if err := fn(dir, subdirs); err != nil {
+		return err
+	}
+
+	repoRoot := dir != "" && isRepositoryRoot(subdirs)
+	names := make([]string, 0, len(subdirs))
+	for name := range subdirs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		childLayout := ""
+		switch {
+		case dir == "" && (name == globalBlobRoot || name == layerFilesDir):
+			continue
+		case repoRoot && name == blobLinkDir:
+			continue
+		case repoRoot && repoLayoutDirs[name]:
+			childLayout = name
+		case layout != "" && isLayoutInternal(layout, name):
+			continue
+		}
+		child := name
+		if dir != "" {
+			child = dir + "/" + name
+		}
+		if err := r.walkRepositoryDir(ctx, child, childLayout, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isLayoutInternal 仓库根目录下的布局目录 layout 中，名为 name 的子目录是否为存储布局内部使用
+func isLayoutInternal(layout, name string) bool {
+	switch layout {
+	case "manifests":
+		return name == "tags" || name == "referrers"
+	case "blobs":
+		return name == "sha256"
+	case "uploads":
+		_, err := uuid.Parse(name)
+		return err == nil
+	}
+	return false
+}
+
+// isRepositoryRoot 根据子目录判断是否为仓库根目录（已有 Manifest 或 Blob 链接）
+func isRepositoryRoot(subdirs map[string]bool) bool {
+	return subdirs["manifests"] || subdirs[blobLinkDir]
+}
+
+// ListRepositories 通过遍历存储列出所有仓库（包含 manifests 或 _links 目录的路径）
+func (r *Registry) ListRepositories(ctx context.Context) ([]string, error) {
+	var repos []string
+	err := r.walkRepositoryDirs(ctx, func(dir string, subdirs map[string]bool) error {
+		if dir != "" && isRepositoryRoot(subdirs) {
+			repos = append(repos, dir)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(repos)
+	return repos, nil
+}
@@ -0,0 +1,345 @@
+// Package controller 提供镜像复制相关的HTTP接口
+package controller
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/cyp-registry/registry/src/middleware"
+	"github.com/cyp-registry/registry/src/modules/replication/dto"
+	"github.com/cyp-registry/registry/src/modules/replication/models"
+	"github.com/cyp-registry/registry/src/modules/replication/service"
+	"github.com/cyp-registry/registry/src/pkg/audit"
+	"github.com/cyp-registry/registry/src/pkg/response"
+)
+
+// ReplicationController 镜像复制控制器
+// 路由前缀：/api/v1/replication（仅管理员）
+type ReplicationController struct {
+	svc    *service.Service
+	authMw *middleware.AuthMiddleware
+}
+
+// NewReplicationController 创建控制器
+func NewReplicationController(svc *service.Service, authMw *middleware.AuthMiddleware) *ReplicationController {
+	return &ReplicationController{
+		svc:    svc,
+		authMw: authMw,
+	}
+}
+
+// RegisterRoutes 注册路由
+// 远端仓库保存了第三方凭据，规则可以跨项目复制镜像，因此全部接口要求管理员权限。
+func (c *ReplicationController) RegisterRoutes(r *gin.Engine) {
+	api := r.Group("/api/v1/replication")
+	if c.authMw != nil {
+		api.Use(c.authMw.Auth())
+		api.Use(c.authMw.AdminRequired())
+	}
+	{
+		api.POST("/endpoints", c.CreateEndpoint)
+		api.GET("/endpoints", c.ListEndpoints)
+		api.GET("/endpoints/:id", c.GetEndpoint)
+		api.PUT("/endpoints/:id", c.UpdateEndpoint)
+		api.DELETE("/endpoints/:id", c.DeleteEndpoint)
+		api.POST("/endpoints/:id/ping", c.PingEndpoint)
+
+		api.POST("/rules", c.CreateRule)
+		api.GET("/rules", c.ListRules)
+		api.GET("/rules/:id", c.GetRule)
+		api.PUT("/rules/:id", c.UpdateRule)
+		api.DELETE("/rules/:id", c.DeleteRule)
+		api.POST("/rules/:id/executions", c.ExecuteRule)
+		api.GET("/rules/:id/executions", c.ListExecutions)
+
+		api.GET("/executions/:id", c.GetExecution)
+	}
+}
+
+// CreateEndpoint 创建远端仓库
+// POST /api/v1/replication/endpoints
+func (c *ReplicationController) CreateEndpoint(ctx *gin.Context) {
+	var req dto.EndpointRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.ParamError(ctx, "请求参数不合法")
+		return
+	}
+
+	ep, err := c.svc.CreateEndpoint(ctx.Request.Context(), &req, currentUserID(ctx))
+	if err != nil {
+		c.fail(ctx, err)
+		return
+	}
+	c.record(ctx, "create_replication_endpoint", map[string]interface{}{"endpoint_id": ep.ID, "name": ep.Name, "url": ep.URL})
+	response.Success(ctx, dto.FromEndpoint(ep))
+}
+
+// ListEndpoints 列出远端仓库
+// GET /api/v1/replication/endpoints
+func (c *ReplicationController) ListEndpoints(ctx *gin.Context) {
+	eps, err := c.svc.ListEndpoints(ctx.Request.Context())
+	if err != nil {
+		response.InternalServerError(ctx, "获取远端仓库列表失败")
+		return
+	}
+	response.Success(ctx, dto.FromEndpointSlice(eps))
+}
+
+// GetEndpoint 获取远端仓库
+// GET /api/v1/replication/endpoints/:id
+func (c *ReplicationController) GetEndpoint(ctx *gin.Context) {
+	ep, err := c.svc.GetEndpoint(ctx.Request.Context(), ctx.Param("id"))
+	if err != nil {
+		c.fail(ctx, err)
+		return
+	}
+	response.Success(ctx, dto.FromEndpoint(ep))
+}
+
+// UpdateEndpoint 更新远端仓库
+// PUT /api/v1/replication/endpoints/:id
+func (c *ReplicationController) UpdateEndpoint(ctx *gin.Context) {
+	var req dto.EndpointRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.ParamError(ctx, "请求参数不合法")
+		return
+	}
+
+	ep, err := c.svc.UpdateEndpoint(ctx.Request.Context(), ctx.Param("id"), &req)
+	if err != nil {
+		c.fail(ctx, err)
+		return
+	}
+	c.record(ctx, "update_replication_endpoint", map[string]interface{}{"endpoint_id": ep.ID, "name": ep.Name, "url": ep.URL})
+	response.Success(ctx, dto.FromEndpoint(ep))
+}
+
+// DeleteEndpoint 删除远端仓库
+// DELETE /api/v1/replication/endpoints/:id
+func (c *ReplicationController) DeleteEndpoint(ctx *gin.Context) {
+	id := ctx.Param("id")
+	if err := c.svc.DeleteEndpoint(ctx.Request.Context(), id); err != nil {
+		c.fail(ctx, err)
+		return
+	}
+	c.record(ctx, "delete_replication_endpoint", map[string]interface{}{"endpoint_id": id})
+	response.Success(ctx, nil)
+}
+
+// PingEndpoint 检查远端仓库连通性与凭据
+// POST /api/v1/replication/endpoints/:id/ping
+func (c *ReplicationController) PingEndpoint(ctx *gin.Context) {
+	if err := c.svc.PingEndpoint(ctx.Request.Context(), ctx.Param("id")); err != nil {
+		if errors.Is(err, service.ErrEndpointNotFound) {
+			c.fail(ctx, err)
+			return
+		}
+		response.Success(ctx, gin.H{"reachable": false, "error": err.Error()})
+		return
+	}
+	response.Success(ctx, gin.H{"reachable": true})
+}
+
+// CreateRule 创建复制规则
+// POST /api/v1/replication/rules
+func (c *ReplicationController) CreateRule(ctx *gin.Context) {
+	var req dto.RuleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.ParamError(ctx, "请求参数不合法")
+		return
+	}
+
+	rule, err := c.svc.CreateRule(ctx.Request.Context(), &req, currentUserID(ctx))
+	if err != nil {
+		c.fail(ctx, err)
+		return
+	}
+	c.record(ctx, "create_replication_rule", ruleDetails(rule))
+	response.Success(ctx, c.toRuleResponse(ctx, rule, nil))
+}
+
+// ListRules 列出复制规则
+// GET /api/v1/replication/rules
+func (c *ReplicationController) ListRules(ctx *gin.Context) {
+	rules, err := c.svc.ListRules(ctx.Request.Context())
+	if err != nil {
+		response.InternalServerError(ctx, "获取复制规则列表失败")
+		return
+	}
+
+	names := make(map[string]string)
+	if eps, err := c.svc.ListEndpoints(ctx.Request.Context()); err == nil {
+		for _, ep := range eps {
+			names[ep.ID] = ep.Name
+		}
+	}
+	result := make([]dto.RuleResponse, len(rules))
+	for i := range rules {
+		result[i] = c.toRuleResponse(ctx, &rules[i], names)
+	}
+	response.Success(ctx, result)
+}
+
+// GetRule 获取复制规则
+// GET /api/v1/replication/rules/:id
+func (c *ReplicationController) GetRule(ctx *gin.Context) {
+	rule, err := c.svc.GetRule(ctx.Request.Context(), ctx.Param("id"))
+	if err != nil {
+		c.fail(ctx, err)
+		return
+	}
+	response.Success(ctx, c.toRuleResponse(ctx, rule, nil))
+}
+
+// UpdateRule 更新复制规则
+// PUT /api/v1/replication/rules/:id
+func (c *ReplicationController) UpdateRule(ctx *gin.Context) {
+	var req dto.RuleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.ParamError(ctx, "请求参数不合法")
+		return
+	}
+
+	rule, err := c.svc.UpdateRule(ctx.Request.Context(), ctx.Param("id"), &req)
+	if err != nil {
+		c.fail(ctx, err)
+		return
+	}
+	c.record(ctx, "update_replication_rule", ruleDetails(rule))
+	response.Success(ctx, c.toRuleResponse(ctx, rule, nil))
+}
+
+// DeleteRule 删除复制规则
+// DELETE /api/v1/replication/rules/:id
+func (c *ReplicationController) DeleteRule(ctx *gin.Context) {
+	id := ctx.Param("id")
+	if err := c.svc.DeleteRule(ctx.Request.Context(), id); err != nil {
+		c.fail(ctx, err)
+		return
+	}
+	c.record(ctx, "delete_replication_rule", map[string]interface{}{"rule_id": id})
+	response.Success(ctx, nil)
+}
+
+// ExecuteRule 手动触发复制规则
+// POST /api/v1/replication/rules/:id/executions
+func (c *ReplicationController) ExecuteRule(ctx *gin.Context) {
+	exec, err := c.svc.Execute(ctx.Request.Context(), ctx.Param("id"))
+	if err != nil {
+		c.fail(ctx, err)
+		return
+	}
+	c.record(ctx, "execute_replication_rule", map[string]interface{}{"rule_id": exec.RuleID, "execution_id": exec.ID})
+	response.Success(ctx, exec)
+}
+
+// ListExecutions 列出规则的执行记录
+// GET /api/v1/replication/rules/:id/executions
+func (c *ReplicationController) ListExecutions(ctx *gin.Context) {
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("page_size", "20"))
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	execs, total, err := c.svc.ListExecutions(ctx.Request.Context(), ctx.Param("id"), page, pageSize)
+	if err != nil {
+		response.InternalServerError(ctx, "获取执行记录失败")
+		return
+	}
+
+	totalPage := int(total) / pageSize
+	if int(total)%pageSize > 0 {
+		totalPage++
+	}
+	response.Success(ctx, dto.ExecutionListResponse{
+		Executions: execs,
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPage:  totalPage,
+	})
+}
+
+// GetExecution 获取执行详情（含每个镜像的复制结果与错误）
+// GET /api/v1/replication/executions/:id
+func (c *ReplicationController) GetExecution(ctx *gin.Context) {
+	exec, tasks, err := c.svc.GetExecution(ctx.Request.Context(), ctx.Param("id"))
+	if err != nil {
+		c.fail(ctx, err)
+		return
+	}
+	response.Success(ctx, dto.ExecutionResponse{Execution: *exec, Tasks: tasks})
+}
+
+// toRuleResponse 转换规则响应；names 为空时单独查询远端仓库名称
+func (c *ReplicationController) toRuleResponse(ctx *gin.Context, rule *models.Rule, names map[string]string) dto.RuleResponse {
+	resp := dto.RuleResponse{Rule: *rule, NextRunAt: service.NextRunAt(rule, time.Now())}
+	if names != nil {
+		resp.EndpointName = names[rule.EndpointID]
+	} else if ep, err := c.svc.GetEndpoint(ctx.Request.Context(), rule.EndpointID); err == nil {
+		resp.EndpointName = ep.Name
+	}
+	return resp
+}
+
+// fail 将服务层错误映射为 HTTP 响应
+func (c *ReplicationController) fail(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrEndpointNotFound):
+		response.NotFound(ctx, "远端仓库不存在")
+	case errors.Is(err, service.ErrRuleNotFound):
+		response.NotFound(ctx, "复制规则不存在")
+	case errors.Is(err, service.ErrExecutionNotFound):
+		response.NotFound(ctx, "执行记录不存在")
+	case errors.Is(err, service.ErrEndpointInUse):
+		response.Conflict(ctx, "远端仓库仍被复制规则引用，请先删除相关规则")
+	case errors.Is(err, service.ErrRuleDisabled):
+		response.Conflict(ctx, "复制规则已停用")
+	case errors.Is(err, service.ErrInvalidEndpoint), errors.Is(err, service.ErrInvalidRule):
+		response.ParamError(ctx, err.Error())
+	default:
+		response.InternalServerError(ctx, err.Error())
+	}
+}
+
+// record 记录审计日志
+func (c *ReplicationController) record(ctx *gin.Context, action string, details map[string]interface{}) {
+	var userID *uuid.UUID
+	if v, exists := ctx.Get(middleware.ContextKeyUserID); exists {
+		if id, ok := v.(uuid.UUID); ok && id != uuid.Nil {
+			userID = &id
+		}
+	}
+	audit.Record(ctx.Request.Context(), action, "replication", nil, userID, ctx.ClientIP(), ctx.Request.UserAgent(), details)
+}
+
+// currentUserID 获取当前用户ID字符串（未登录时为空）
+func currentUserID(ctx *gin.Context) string {
+	if v, exists := ctx.Get(middleware.ContextKeyUserID); exists {
+		if id, ok := v.(uuid.UUID); ok && id != uuid.Nil {
+			return id.String()
+		}
+	}
+	return ""
+}
+
+// ruleDetails 审计日志中的规则摘要
+func ruleDetails(rule *models.Rule) map[string]interface{} {
+	return map[string]interface{}{
+		"rule_id":        rule.ID,
+		"name":           rule.Name,
+		"mode":           rule.Mode,
+		"endpoint_id":    rule.EndpointID,
+		"source_project": rule.SourceProject,
+		"destination":    rule.Destination,
+		"trigger":        rule.Trigger,
+	}
+}
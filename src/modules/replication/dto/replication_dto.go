@@ -0,0 +1,97 @@
+// Package dto 定义镜像复制相关的请求与响应结构体
+package dto
+
+import (
+	"time"
+
+	"github.com/cyp-registry/registry/src/modules/replication/models"
+)
+
+// EndpointRequest 创建/更新远端仓库请求体
+// 更新时 password 为空且用户名未变化表示保留原密码
+type EndpointRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	URL         string `json:"url"`                // 例如 https://harbor.example.com、docker.io
+	Username    string `json:"username,omitempty"` // 可选
+	Password    string `json:"password,omitempty"` // 可选，密码或访问令牌
+	Insecure    bool   `json:"insecure,omitempty"` // 跳过 TLS 证书校验
+}
+
+// EndpointResponse 远端仓库响应（不返回密码）
+type EndpointResponse struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	URL         string    `json:"url"`
+	Username    string    `json:"username"`
+	HasPassword bool      `json:"has_password"`
+	Insecure    bool      `json:"insecure"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// RuleRequest 创建/更新复制规则请求体
+type RuleRequest struct {
+	Name          string `json:"name"`
+	Description   string `json:"description,omitempty"`
+	Mode          string `json:"mode"` // push / pull
+	EndpointID    string `json:"endpoint_id"`
+	SourceProject string `json:"source_project"`        // push: 本地项目；pull: 远端命名空间（可为空）
+	RepoFilter    string `json:"repo_filter,omitempty"` // 逗号分隔的 glob，例如 "app,tools/*"
+	TagFilter     string `json:"tag_filter,omitempty"`  // 逗号分隔的 glob，例如 "v*,latest"
+	Destination   string `json:"destination,omitempty"` // push: 远端命名空间；pull: 本地项目
+	Trigger       string `json:"trigger,omitempty"`     // manual（默认）/ event / cron
+	Cron          string `json:"cron,omitempty"`        // trigger=cron 时必填，例如 "0 2 * * *"
+	Override      *bool  `json:"override,omitempty"`    // 默认 true
+	Enabled       *bool  `json:"enabled,omitempty"`     // 默认 true
+}
+
+// RuleResponse 复制规则响应
+type RuleResponse struct {
+	models.Rule
+	EndpointName string     `json:"endpoint_name,omitempty"`
+	NextRunAt    *time.Time `json:"next_run_at,omitempty"` // 仅 cron 触发的启用规则
+}
+
+// ExecutionResponse 执行详情（含每个镜像的复制记录）
+type ExecutionResponse struct {
+	models.Execution
+	Tasks []models.Task `json:"tasks,omitempty"`
+}
+
+// ExecutionListResponse 执行列表响应结构
+type ExecutionListResponse struct {
+	Executions []models.Execution `json:"executions"`
+	Total      int64              `json:"total"`
+	Page       int                `json:"page"`
+	PageSize   int                `json:"page_size"`
+	TotalPage  int                `json:"total_page"`
+}
+
+// FromEndpoint 将远端仓库模型转换为响应结构
+func FromEndpoint(ep *models.Endpoint) EndpointResponse {
+	if ep == nil {
+		return EndpointResponse{}
+	}
+	return EndpointResponse{
+		ID:          ep.ID,
+		Name:        ep.Name,
+		Description: ep.Description,
+		URL:         ep.URL,
+		Username:    ep.Username,
+		HasPassword: ep.Password != "",
+		Insecure:    ep.Insecure,
+		CreatedAt:   ep.CreatedAt,
+		UpdatedAt:   ep.UpdatedAt,
+	}
+}
+
+// FromEndpointSlice 批量转换
+func FromEndpointSlice(eps []models.Endpoint) []EndpointResponse {
+	result := make([]EndpointResponse, len(eps))
+	for i := range eps {
+		result[i] = FromEndpoint(&eps[i])
+	}
+	return result
+}
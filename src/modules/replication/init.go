@@ -0,0 +1,22 @@
+// Package replication 提供镜像复制模块的初始化入口
+// 主要负责数据库表结构初始化（AutoMigrate）
+package replication
+
+import (
+	"fmt"
+
+	"github.com/cyp-registry/registry/src/modules/replication/models"
+	"github.com/cyp-registry/registry/src/pkg/database"
+)
+
+// InitDatabase 初始化镜像复制相关的数据库表
+// 在 cmd/server/main.go 中调用；失败时不会阻止主进程启动，而是以警告形式输出
+func InitDatabase() error {
+	if database.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	if err := database.DB.AutoMigrate(&models.Endpoint{}, &models.Rule{}, &models.Execution{}, &models.Task{}); err != nil {
+		return fmt.Errorf("auto migrate replication tables failed: %w", err)
+	}
+	return nil
+}
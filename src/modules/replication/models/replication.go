@@ -0,0 +1,170 @@
+// Package models 定义镜像复制（Replication）的数据模型
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	// 注册 serializer:secret
+	_ "github.com/cyp-registry/registry/src/pkg/secrets"
+)
+
+// 复制模式
+const (
+	// ModePush 将本地项目中的镜像推送到远端仓库
+	ModePush = "push"
+	// ModePull 从远端仓库拉取镜像到本地项目
+	ModePull = "pull"
+)
+
+// 触发方式
+const (
+	// TriggerManual 仅手动触发
+	TriggerManual = "manual"
+	// TriggerEvent 本地推送事件触发（仅 push 模式），只复制本次推送的 tag
+	TriggerEvent = "event"
+	// TriggerCron 按 cron 表达式定时触发
+	TriggerCron = "cron"
+)
+
+// ExecutionStatus 执行状态
+type ExecutionStatus string
+
+const (
+	// ExecutionPending 等待执行（同一规则的执行串行进行）
+	ExecutionPending ExecutionStatus = "pending"
+	// ExecutionRunning 正在执行
+	ExecutionRunning ExecutionStatus = "running"
+	// ExecutionSuccess 全部镜像复制成功（或无需复制）
+	ExecutionSuccess ExecutionStatus = "success"
+	// ExecutionFailed 存在复制失败的镜像，或执行本身失败
+	ExecutionFailed ExecutionStatus = "failed"
+)
+
+// TaskStatus 单个镜像的复制结果
+type TaskStatus string
+
+const (
+	// TaskSuccess 复制成功
+	TaskSuccess TaskStatus = "success"
+	// TaskFailed 复制失败
+	TaskFailed TaskStatus = "failed"
+	// TaskSkipped 目标已是相同内容，或目标 tag 已存在且规则不允许覆盖
+	TaskSkipped TaskStatus = "skipped"
+)
+
+// Endpoint 远端仓库定义
+// 表名: replication_endpoints
+type Endpoint struct {
+	ID          string `gorm:"type:varchar(36);primaryKey" json:"id"`
+	Name        string `gorm:"type:varchar(128);uniqueIndex;not null;comment:名称" json:"name"`
+	Description string `gorm:"type:text;comment:描述" json:"description"`
+	URL         string `gorm:"type:varchar(512);not null;comment:仓库地址" json:"url"`
+	Username    string `gorm:"type:varchar(128);comment:用户名(可选)" json:"username"`
+	Password    string `gorm:"type:text;serializer:secret;comment:密码或Token(可选，加密保存)" json:"-"`
+	Insecure    bool   `gorm:"not null;default:false;comment:跳过TLS校验" json:"insecure"`
+	CreatedBy   string `gorm:"type:varchar(36);comment:创建者ID" json:"created_by"`
+
+	CreatedAt time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// TableName 表名
+func (Endpoint) TableName() string {
+	return "replication_endpoints"
+}
+
+// Rule 复制规则
+// 表名: replication_rules
+//
+// push 模式：SourceProject 为本地项目，Destination 为远端命名空间（为空时与源项目同名）；
+// pull 模式：SourceProject 为远端命名空间（可为空），Destination 为本地项目。
+// RepoFilter/TagFilter 为逗号分隔的 glob 列表（path.Match 语法），为空表示全部；
+// RepoFilter 匹配去掉源项目/命名空间前缀后的仓库名。
+type Rule struct {
+	ID            string `gorm:"type:varchar(36);primaryKey" json:"id"`
+	Name          string `gorm:"type:varchar(128);uniqueIndex;not null;comment:名称" json:"name"`
+	Description   string `gorm:"type:text;comment:描述" json:"description"`
+	Mode          string `gorm:"type:varchar(16);not null;comment:push/pull" json:"mode"`
+	EndpointID    string `gorm:"type:varchar(36);index;not null;comment:远端仓库ID" json:"endpoint_id"`
+	SourceProject string `gorm:"type:varchar(256);comment:源项目或远端命名空间" json:"source_project"`
+	RepoFilter    string `gorm:"type:varchar(512);comment:仓库过滤" json:"repo_filter"`
+	TagFilter     string `gorm:"type:varchar(512);comment:标签过滤" json:"tag_filter"`
+	Destination   string `gorm:"type:varchar(256);comment:目标命名空间或本地项目" json:"destination"`
+	Trigger       string `gorm:"type:varchar(16);not null;default:'manual';comment:manual/event/cron" json:"trigger"`
+	Cron          string `gorm:"type:varchar(64);comment:cron表达式" json:"cron"`
+	Override      bool   `gorm:"not null;comment:覆盖目标已存在的tag" json:"override"`
+	Enabled       bool   `gorm:"not null;index;comment:是否启用" json:"enabled"`
+	CreatedBy     string `gorm:"type:varchar(36);comment:创建者ID" json:"created_by"`
+
+	LastRunAt *time.Time     `json:"last_run_at"`
+	CreatedAt time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// TableName 表名
+func (Rule) TableName() string {
+	return "replication_rules"
+}
+
+// Execution 规则的一次执行
+// 表名: replication_executions
+type Execution struct {
+	ID        string `gorm:"type:varchar(36);primaryKey" json:"id"`
+	RuleID    string `gorm:"type:varchar(36);index;not null;comment:规则ID" json:"rule_id"`
+	Trigger   string `gorm:"type:varchar(16);not null;comment:触发方式" json:"trigger"`
+	Status    string `gorm:"type:varchar(32);index;not null;comment:执行状态" json:"status"`
+	Total     int    `gorm:"not null;default:0" json:"total"`
+	Succeeded int    `gorm:"not null;default:0" json:"succeeded"`
+	Failed    int    `gorm:"not null;default:0" json:"failed"`
+	Skipped   int    `gorm:"not null;default:0" json:"skipped"`
+	Message   string `gorm:"type:text;comment:状态消息" json:"message"`
+	Error     string `gorm:"type:text;comment:错误信息" json:"error"`
+
+	StartedAt   *time.Time `json:"started_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime;index" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName 表名
+func (Execution) TableName() string {
+	return "replication_executions"
+}
+
+// Task 执行中单个镜像（仓库 + tag）的复制记录
+// 表名: replication_tasks
+type Task struct {
+	ID          string `gorm:"type:varchar(36);primaryKey" json:"id"`
+	ExecutionID string `gorm:"type:varchar(36);index;not null;comment:执行ID" json:"execution_id"`
+	Source      string `gorm:"type:varchar(512);not null;comment:源仓库" json:"source"`
+	Destination string `gorm:"type:varchar(512);not null;comment:目标仓库" json:"destination"`
+	Tag         string `gorm:"type:varchar(128);not null;comment:标签" json:"tag"`
+	Digest      string `gorm:"type:varchar(128);comment:复制的Manifest摘要" json:"digest"`
+	Status      string `gorm:"type:varchar(32);not null;comment:复制结果" json:"status"`
+	Bytes       int64  `gorm:"not null;default:0;comment:传输的Blob字节数" json:"bytes"`
+	Message     string `gorm:"type:text;comment:说明" json:"message"`
+	Error       string `gorm:"type:text;comment:错误信息" json:"error"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName 表名
+func (Task) TableName() string {
+	return "replication_tasks"
+}
+
+// NewExecution 创建等待执行的执行记录
+func NewExecution(ruleID, trigger string) *Execution {
+	return &Execution{
+		ID:      uuid.New().String(),
+		RuleID:  ruleID,
+		Trigger: trigger,
+		Status:  string(ExecutionPending),
+		Message: "等待执行",
+	}
+}
@@ -0,0 +1,471 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/cyp-registry/registry/src/modules/registry"
+	"github.com/cyp-registry/registry/src/modules/replication/models"
+	"github.com/cyp-registry/registry/src/modules/scanner"
+	"github.com/cyp-registry/registry/src/pkg/audit"
+	"github.com/cyp-registry/registry/src/pkg/registryclient"
+)
+
+// executionTimeout 单次执行的最长时间
+const executionTimeout = 6 * time.Hour
+
+// artifact 一个待复制的镜像（源仓库 + 目标仓库 + tag）
+type artifact struct {
+	Source      string
+	Destination string
+	Tag         string
+}
+
+// copyResult 单个镜像的复制结果
+type copyResult struct {
+	digest  string
+	bytes   int64
+	skipped string // 非空表示跳过及原因
+}
+
+// run 执行一次复制；同一规则的执行串行进行
+func (s *Service) run(ctx context.Context, execID string, rule models.Rule, only *artifact) {
+	lock := s.ruleLock(rule.ID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, executionTimeout)
+	defer cancel()
+
+	started := time.Now()
+	s.updateExecution(ctx, execID, map[string]interface{}{
+		"status":     string(models.ExecutionRunning),
+		"message":    "正在复制",
+		"started_at": started,
+	})
+	_ = s.db.WithContext(ctx).Model(&models.Rule{}).Where("id = ?", rule.ID).Update("last_run_at", started).Error
+
+	fail := func(err error, msg string) {
+		now := time.Now()
+		s.updateExecution(ctx, execID, map[string]interface{}{
+			"status":       string(models.ExecutionFailed),
+			"message":      msg,
+			"error":        err.Error(),
+			"completed_at": now,
+		})
+		log.Printf(`{"timestamp":"%s","level":"error","module":"replication","operation":"execute","rule_id":"%s","execution_id":"%s","error":%q}`, now.Format(time.RFC3339), rule.ID, execID, err.Error())
+	}
+
+	ep, err := s.GetEndpoint(ctx, rule.EndpointID)
+	if err != nil {
+		fail(err, "远端仓库不存在")
+		return
+	}
+	client, err := newClient(ep)
+	if err != nil {
+		fail(err, "远端仓库地址无效")
+		return
+	}
+
+	var artifacts []artifact
+	if only != nil {
+		artifacts = []artifact{*only}
+	} else if artifacts, err = s.listArtifacts(ctx, &rule, client); err != nil {
+		fail(err, "获取待复制镜像列表失败")
+		return
+	}
+	s.updateExecution(ctx, execID, map[string]interface{}{"total": len(artifacts)})
+
+	var succeeded, failed, skipped int
+	for _, a := range artifacts {
+		if err := ctx.Err(); err != nil {
+			fail(err, "执行超时或被中断")
+			return
+		}
+
+		var res copyResult
+		if rule.Mode == models.ModePush {
+			res, err = s.pushArtifact(ctx, client, &rule, a)
+		} else {
+			res, err = s.pullArtifact(ctx, client, a, rule.Override)
+		}
+
+		task := &models.Task{
+			ID:          uuid.New().String(),
+			ExecutionID: execID,
+			Source:      a.Source,
+			Destination: a.Destination,
+			Tag:         a.Tag,
+			Digest:      res.digest,
+			Bytes:       res.bytes,
+		}
+		switch {
+		case err != nil:
+			failed++
+			task.Status = string(models.TaskFailed)
+			task.Error = err.Error()
+			log.Printf(`{"timestamp":"%s","level":"warn","module":"replication","operation":"copy","rule_id":"%s","source":"%s","destination":"%s","tag":"%s","error":%q}`, time.Now().Format(time.RFC3339), rule.ID, a.Source, a.Destination, a.Tag, err.Error())
+		case res.skipped != "":
+			skipped++
+			task.Status = string(models.TaskSkipped)
+			task.Message = res.skipped
+		default:
+			succeeded++
+			task.Status = string(models.TaskSuccess)
+			log.Printf(`{"timestamp":"%s","level":"info","module":"replication","operation":"copy","rule_id":"%s","mode":"%s","source":"%s","destination":"%s","tag":"%s","digest":"%s","bytes":%d}`, time.Now().Format(time.RFC3339), rule.ID, rule.Mode, a.Source, a.Destination, a.Tag, res.digest, res.bytes)
+			if rule.Mode == models.ModePull {
+				s.notifyPulled(ctx, rule, a, res.digest)
+			}
+		}
+		if err := s.db.WithContext(ctx).Create(task).Error; err != nil {
+			log.Printf(`{"timestamp":"%s","level":"warn","module":"replication","operation":"save_task","execution_id":"%s","error":"%v"}`, time.Now().Format(time.RFC3339), execID, err)
+		}
+		s.updateExecution(ctx, execID, map[string]interface{}{
+			"succeeded": succeeded,
+			"failed":    failed,
+			"skipped":   skipped,
+		})
+	}
+
+	status := models.ExecutionSuccess
+	msg := fmt.Sprintf("复制完成：成功 %d，跳过 %d", succeeded, skipped)
+	if failed > 0 {
+		status = models.ExecutionFailed
+		msg = fmt.Sprintf("复制完成：成功 %d，跳过 %d，失败 %d", succeeded, skipped, failed)
+	}
+	s.updateExecution(ctx, execID, map[string]interface{}{
+		"status":       string(status),
+		"message":      msg,
+		"completed_at": time.Now(),
+	})
+}
+
+// updateExecution 更新执行记录（最佳努力）
+func (s *Service) updateExecution(ctx context.Context, execID string, updates map[string]interface{}) {
+	updates["updated_at"] = time.Now()
+	if err := s.db.WithContext(ctx).Model(&models.Execution{}).Where("id = ?", execID).Updates(updates).Error; err != nil {
+		log.Printf(`{"timestamp":"%s","level":"warn","module":"replication","operation":"update_execution","execution_id":"%s","error":"%v"}`, time.Now().Format(time.RFC3339), execID, err)
+	}
+}
+
+// listArtifacts 按规则的过滤条件列出待复制的镜像
+func (s *Service) listArtifacts(ctx context.Context, rule *models.Rule, client *registryclient.Client) ([]artifact, error) {
+	if rule.Mode == models.ModePush {
+		return s.listLocalArtifacts(ctx, rule)
+	}
+	return listRemoteArtifacts(ctx, rule, client)
+}
+
+// listLocalArtifacts push 模式：列出本地源项目中命中过滤条件的仓库与 tag
+func (s *Service) listLocalArtifacts(ctx context.Context, rule *models.Rule) ([]artifact, error) {
+	repos, err := s.reg.ListRepositories(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []artifact
+	prefix := rule.SourceProject + "/"
+	for _, repo := range repos {
+		name, ok := strings.CutPrefix(repo, prefix)
+		if !ok || !matchFilter(rule.RepoFilter, name) {
+			continue
+		}
+		tags, err := s.reg.ListTags(ctx, repo)
+		if err != nil {
+			return nil, fmt.Errorf("list tags of %s: %w", repo, err)
+		}
+		for _, tag := range tags {
+			if matchFilter(rule.TagFilter, tag) {
+				out = append(out, artifact{Source: repo, Destination: pushDestination(rule, name), Tag: tag})
+			}
+		}
+	}
+	return out, nil
+}
+
+// pushDestination push 模式下的远端仓库名
+func pushDestination(rule *models.Rule, name string) string {
+	ns := rule.Destination
+	if ns == "" {
+		ns = rule.SourceProject
+	}
+	return ns + "/" + name
+}
+
+// listRemoteArtifacts pull 模式：列出远端命名空间中命中过滤条件的仓库与 tag
+// 仓库过滤条件不含通配符时直接访问对应仓库，否则通过 _catalog 列出。
+func listRemoteArtifacts(ctx context.Context, rule *models.Rule, client *registryclient.Client) ([]artifact, error) {
+	prefix := ""
+	if rule.SourceProject != "" {
+		prefix = rule.SourceProject + "/"
+	}
+
+	names, literal := literalFilter(rule.RepoFilter)
+	if !literal {
+		repos, err := client.Catalog(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("list remote repositories (use repository names without wildcards if the remote has no catalog API): %w", err)
+		}
+		for _, repo := range repos {
+			if name, ok := strings.CutPrefix(repo, prefix); ok && matchFilter(rule.RepoFilter, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	var out []artifact
+	for _, name := range names {
+		remote := client.RepositoryName(prefix + name)
+		tags, err := client.ListTags(ctx, remote)
+		if err != nil {
+			return nil, fmt.Errorf("list tags of %s: %w", remote, err)
+		}
+		for _, tag := range tags {
+			if matchFilter(rule.TagFilter, tag) {
+				out = append(out, artifact{Source: remote, Destination: rule.Destination + "/" + name, Tag: tag})
+			}
+		}
+	}
+	return out, nil
+}
+
+// ---------------------------------------------------------------------------
+// push：本地 -> 远端
+// ---------------------------------------------------------------------------
+
+// pushArtifact 将本地镜像推送到远端；远端 tag 已指向相同 digest 时跳过
+func (s *Service) pushArtifact(ctx context.Context, client *registryclient.Client, rule *models.Rule, a artifact) (copyResult, error) {
+	raw, digest, err := s.reg.GetManifestRaw(ctx, a.Source, a.Tag)
+	if err != nil {
+		return copyResult{}, fmt.Errorf("read local manifest: %w", err)
+	}
+	res := copyResult{digest: digest}
+	if err := s.checkPushPolicy(ctx, rule, a, digest, raw); err != nil {
+		return res, err
+	}
+
+	remote, err := client.HeadManifest(ctx, a.Destination, a.Tag)
+	switch {
+	case err == nil && remote.Digest == digest:
+		res.skipped = "目标已是相同内容"
+		return res, nil
+	case err == nil && !rule.Override:
+		res.skipped = "目标 tag 已存在且规则不允许覆盖"
+		return res, nil
+	case err != nil && !errors.Is(err, registryclient.ErrNotFound):
+		return res, err
+	}
+
+	if res.bytes, err = s.pushManifest(ctx, client, a, raw, a.Tag); err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+// checkPushPolicy 推送前按源仓库所属项目的漏洞策略检查镜像，不满足策略时记录日志与审计并返回错误
+// 只检查顶层 Manifest：多架构镜像的扫描报告按顶层 digest 汇总，与客户端拉取时的检查一致。
+func (s *Service) checkPushPolicy(ctx context.Context, rule *models.Rule, a artifact, digest string, raw []byte) error {
+	if s.pullGuard == nil {
+		return nil
+	}
+	err := s.pullGuard.CheckPull(ctx, a.Source, digest, raw)
+	if err == nil {
+		return nil
+	}
+	var denied *scanner.PullDeniedError
+	if !errors.As(err, &denied) {
+		return fmt.Errorf("check vulnerability policy: %w", err)
+	}
+
+	log.Printf(`{"timestamp":"%s","level":"warn","module":"replication","operation":"push_denied","rule_id":"%s","source":"%s","destination":"%s","tag":"%s","digest":"%s","error":%q}`, time.Now().Format(time.RFC3339), rule.ID, a.Source, a.Destination, a.Tag, digest, denied.Error())
+	var userID *uuid.UUID
+	if id, err := uuid.Parse(rule.CreatedBy); err == nil {
+		userID = &id
+	}
+	audit.Record(ctx, "replication_denied", "image", nil, userID, "", "", map[string]interface{}{
+		"rule_id":         rule.ID,
+		"repository":      a.Source,
+		"tag":             a.Tag,
+		"destination":     a.Destination,
+		"digest":          digest,
+		"severity":        denied.Severity,
+		"scan_status":     denied.Status,
+		"vulnerabilities": denied.Count,
+		"cves":            denied.IDs,
+	})
+	return denied
+}
+
+// pushManifest 推送 Manifest 及其引用的 Blob/子 Manifest，返回上传的 Blob 字节数
+func (s *Service) pushManifest(ctx context.Context, client *registryclient.Client, a artifact, raw []byte, reference string) (int64, error) {
+	mediaType := registry.ManifestMediaType(raw)
+	blobs, children, err := registry.ManifestReferences(raw, mediaType)
+	if err != nil {
+		return 0, err
+	}
+
+	var transferred int64
+	for _, child := range children {
+		childRaw, _, err := s.reg.GetManifestRaw(ctx, a.Source, child.Digest)
+		if err != nil {
+			return transferred, fmt.Errorf("read child manifest %s: %w", child.Digest, err)
+		}
+		n, err := s.pushManifest(ctx, client, a, childRaw, child.Digest)
+		transferred += n
+		if err != nil {
+			return transferred, err
+		}
+	}
+
+	for _, blob := range blobs {
+		exists, err := client.BlobExists(ctx, a.Destination, blob.Digest)
+		if err != nil {
+			return transferred, err
+		}
+		if exists {
+			continue
+		}
+		n, err := s.pushBlob(ctx, client, a, blob.Digest)
+		transferred += n
+		if err != nil {
+			return transferred, fmt.Errorf("push blob %s: %w", blob.Digest, err)
+		}
+	}
+
+	if _, err := client.PutManifest(ctx, a.Destination, reference, mediaType, raw); err != nil {
+		return transferred, fmt.Errorf("push manifest %s: %w", reference, err)
+	}
+	return transferred, nil
+}
+
+// pushBlob 从本地存储读取 Blob 并上传到远端
+func (s *Service) pushBlob(ctx context.Context, client *registryclient.Client, a artifact, digest string) (int64, error) {
+	reader, size, err := s.reg.GetBlob(ctx, a.Source, digest)
+	if err != nil {
+		return 0, err
+	}
+	if closer, ok := reader.(io.Closer); ok {
+		defer closer.Close()
+	}
+	if err := client.PushBlob(ctx, a.Destination, digest, size, reader); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// ---------------------------------------------------------------------------
+// pull：远端 -> 本地
+// ---------------------------------------------------------------------------
+
+// pullArtifact 将远端镜像拉取到本地项目；本地 tag 已指向相同 digest 时跳过
+func (s *Service) pullArtifact(ctx context.Context, client *registryclient.Client, a artifact, override bool) (copyResult, error) {
+	remote, err := client.HeadManifest(ctx, a.Source, a.Tag)
+	if err != nil {
+		return copyResult{}, err
+	}
+	res := copyResult{digest: remote.Digest}
+
+	if local, err := s.reg.GetTag(ctx, a.Destination, a.Tag); err == nil && local != nil {
+		if remote.Digest != "" && local.Digest == remote.Digest {
+			res.skipped = "目标已是相同内容"
+			return res, nil
+		}
+		if !override {
+			res.skipped = "目标 tag 已存在且规则不允许覆盖"
+			return res, nil
+		}
+	}
+
+	raw, desc, err := client.GetManifest(ctx, a.Source, a.Tag)
+	if err != nil {
+		return res, err
+	}
+	res.digest = desc.Digest
+	res.bytes, err = s.pullManifest(ctx, client, a, raw, desc.MediaType, a.Tag)
+	return res, err
+}
+
+// notifyPulled pull 模式写入本地 tag 后发送推送事件
+func (s *Service) notifyPulled(ctx context.Context, rule models.Rule, a artifact, digest string) {
+	if s.notifier == nil {
+		return
+	}
+	var projectID string
+	if s.projectSvc != nil {
+		project, _, _ := strings.Cut(a.Destination, "/")
+		if p, err := s.projectSvc.GetProjectByName(ctx, project); err == nil && p != nil {
+			projectID = p.ID
+		}
+	}
+	var size int64
+	if td, err := s.reg.GetTag(ctx, a.Destination, a.Tag); err == nil {
+		size = td.Size
+	}
+	if err := s.notifier.PushPushEvent(projectID, a.Destination, a.Tag, digest, size, rule.CreatedBy, ""); err != nil {
+		log.Printf(`{"timestamp":"%s","level":"warn","module":"replication","operation":"push_event","rule_id":"%s","repository":"%s","tag":"%s","error":"%v"}`, time.Now().Format(time.RFC3339), rule.ID, a.Destination, a.Tag, err)
+	}
+}
+
+// pullManifest 拉取 Manifest 引用的 Blob/子 Manifest 后写入本地，返回下载的 Blob 字节数
+func (s *Service) pullManifest(ctx context.Context, client *registryclient.Client, a artifact, raw []byte, mediaType, reference string) (int64, error) {
+	if mediaType == "" {
+		mediaType = registry.ManifestMediaType(raw)
+	}
+	blobs, children, err := registry.ManifestReferences(raw, mediaType)
+	if err != nil {
+		return 0, err
+	}
+
+	var transferred int64
+	for _, child := range children {
+		childRaw, desc, err := client.GetManifest(ctx, a.Source, child.Digest)
+		if err != nil {
+			return transferred, fmt.Errorf("fetch child manifest %s: %w", child.Digest, err)
+		}
+		n, err := s.pullManifest(ctx, client, a, childRaw, desc.MediaType, child.Digest)
+		transferred += n
+		if err != nil {
+			return transferred, err
+		}
+	}
+
+	for _, blob := range blobs {
+		exists, err := s.reg.CheckBlob(ctx, a.Destination, blob.Digest)
+		if err != nil {
+			return transferred, err
+		}
+		if exists {
+			continue
+		}
+		n, err := s.pullBlob(ctx, client, a, blob)
+		transferred += n
+		if err != nil {
+			return transferred, fmt.Errorf("pull blob %s: %w", blob.Digest, err)
+		}
+	}
+
+	if _, err := s.reg.PutManifestRaw(ctx, a.Destination, reference, raw, mediaType); err != nil {
+		return transferred, fmt.Errorf("store manifest %s: %w", reference, err)
+	}
+	return transferred, nil
+}
+
+// pullBlob 下载远端 Blob 并直接写入本地存储（写入时校验摘要）
+func (s *Service) pullBlob(ctx context.Context, client *registryclient.Client, a artifact, blob registry.Descriptor) (int64, error) {
+	body, _, err := client.GetBlob(ctx, a.Source, blob.Digest)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+
+	return s.reg.PutBlob(ctx, a.Destination, blob.Digest, blob.Size, body)
+}
@@ -0,0 +1,516 @@
+// Package service 实现镜像复制（Replication）的核心业务逻辑
+// 规则按 push/pull 模式在本地项目与远端仓库之间复制镜像，支持手动、推送事件与 cron 定时触发。
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	projectservice "github.com/cyp-registry/registry/src/modules/project/service"
+	"github.com/cyp-registry/registry/src/modules/registry"
+	"github.com/cyp-registry/registry/src/modules/replication/dto"
+	"github.com/cyp-registry/registry/src/modules/replication/models"
+	"github.com/cyp-registry/registry/src/pkg/database"
+	"github.com/cyp-registry/registry/src/pkg/registryclient"
+)
+
+var (
+	// ErrEndpointNotFound 远端仓库不存在
+	ErrEndpointNotFound = errors.New("replication: endpoint not found")
+	// ErrEndpointInUse 远端仓库仍被复制规则引用
+	ErrEndpointInUse = errors.New("replication: endpoint is referenced by rules")
+	// ErrInvalidEndpoint 远端仓库参数不合法
+	ErrInvalidEndpoint = errors.New("replication: invalid endpoint")
+	// ErrRuleNotFound 复制规则不存在
+	ErrRuleNotFound = errors.New("replication: rule not found")
+	// ErrInvalidRule 复制规则参数不合法
+	ErrInvalidRule = errors.New("replication: invalid rule")
+	// ErrRuleDisabled 规则已停用
+	ErrRuleDisabled = errors.New("replication: rule is disabled")
+	// ErrExecutionNotFound 执行记录不存在
+	ErrExecutionNotFound = errors.New("replication: execution not found")
+)
+
+// EventNotifier 推送事件通知（由 Webhook 服务实现）
+type EventNotifier interface {
+	PushPushEvent(projectID, repository, tag, digest string, imageSize int64, userID, username string) error
+}
+
+// PullGuard 读取本地镜像前的漏洞策略检查（由漏洞扫描模块实现）
+// 不满足策略时返回 *scanner.PullDeniedError，其余错误表示无法完成检查。
+type PullGuard interface {
+	CheckPull(ctx context.Context, repository, digest string, manifest []byte) error
+}
+
+// Service 镜像复制服务
+type Service struct {
+	db         *gorm.DB
+	reg        *registry.Registry
+	projectSvc projectservice.Service
+	notifier   EventNotifier // pull 模式写入 tag 后的推送事件通知，可为空
+	pullGuard  PullGuard     // push 模式读取本地镜像前的漏洞策略检查，可为空
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex // ruleID -> 执行锁，同一规则的执行串行进行
+}
+
+// NewService 创建镜像复制服务
+// projectSvc 用于校验规则中的本地项目，可为 nil（不校验）
+func NewService(reg *registry.Registry, projectSvc projectservice.Service) *Service {
+	return &Service{
+		db:         database.GetDB(),
+		reg:        reg,
+		projectSvc: projectSvc,
+		locks:      make(map[string]*sync.Mutex),
+	}
+}
+
+// SetNotifier 设置推送事件的通知方式
+// pull 模式复制到本地的每个 tag 都按推送通知，与客户端推送一样触发扫描、SBOM 生成与 Webhook。
+func (s *Service) SetNotifier(n EventNotifier) {
+	s.notifier = n
+}
+
+// SetPullGuard 设置 push 模式的漏洞策略检查
+// 与客户端拉取一样，不满足项目漏洞策略的镜像不会被推送到远端仓库；未设置时不限制。
+func (s *Service) SetPullGuard(g PullGuard) {
+	s.pullGuard = g
+}
+
+// ---------------------------------------------------------------------------
+// 远端仓库
+// ---------------------------------------------------------------------------
+
+// CreateEndpoint 创建远端仓库
+func (s *Service) CreateEndpoint(ctx context.Context, req *dto.EndpointRequest, userID string) (*models.Endpoint, error) {
+	ep := &models.Endpoint{ID: uuid.New().String(), CreatedBy: userID}
+	if err := applyEndpoint(ep, req); err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Create(ep).Error; err != nil {
+		return nil, fmt.Errorf("创建远端仓库失败: %w", err)
+	}
+	return ep, nil
+}
+
+// UpdateEndpoint 更新远端仓库；password 为空且用户名未变化时保留原密码
+func (s *Service) UpdateEndpoint(ctx context.Context, id string, req *dto.EndpointRequest) (*models.Endpoint, error) {
+	ep, err := s.GetEndpoint(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	password := ep.Password
+	if req.Password == "" && strings.TrimSpace(req.Username) == ep.Username {
+		req.Password = password
+	}
+	if err := applyEndpoint(ep, req); err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Save(ep).Error; err != nil {
+		return nil, fmt.Errorf("更新远端仓库失败: %w", err)
+	}
+	return ep, nil
+}
+
+// applyEndpoint 校验请求并写入模型
+func applyEndpoint(ep *models.Endpoint, req *dto.EndpointRequest) error {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidEndpoint)
+	}
+	if _, err := registryclient.NormalizeEndpoint(req.URL); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidEndpoint, err)
+	}
+	username := strings.TrimSpace(req.Username)
+	if username == "" && req.Password != "" {
+		return fmt.Errorf("%w: password requires username", ErrInvalidEndpoint)
+	}
+
+	ep.Name = name
+	ep.Description = req.Description
+	ep.URL = strings.TrimSpace(req.URL)
+	ep.Username = username
+	ep.Password = req.Password
+	ep.Insecure = req.Insecure
+	return nil
+}
+
+// GetEndpoint 获取远端仓库
+func (s *Service) GetEndpoint(ctx context.Context, id string) (*models.Endpoint, error) {
+	var ep models.Endpoint
+	if err := s.db.WithContext(ctx).First(&ep, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrEndpointNotFound
+		}
+		return nil, err
+	}
+	return &ep, nil
+}
+
+// ListEndpoints 列出全部远端仓库
+func (s *Service) ListEndpoints(ctx context.Context) ([]models.Endpoint, error) {
+	var eps []models.Endpoint
+	if err := s.db.WithContext(ctx).Order("name ASC").Find(&eps).Error; err != nil {
+		return nil, err
+	}
+	return eps, nil
+}
+
+// DeleteEndpoint 删除远端仓库（仍被规则引用时拒绝）
+func (s *Service) DeleteEndpoint(ctx context.Context, id string) error {
+	if _, err := s.GetEndpoint(ctx, id); err != nil {
+		return err
+	}
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&models.Rule{}).Where("endpoint_id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrEndpointInUse
+	}
+	return s.db.WithContext(ctx).Delete(&models.Endpoint{}, "id = ?", id).Error
+}
+
+// PingEndpoint 检查远端仓库可达且凭据有效
+func (s *Service) PingEndpoint(ctx context.Context, id string) error {
+	ep, err := s.GetEndpoint(ctx, id)
+	if err != nil {
+		return err
+	}
+	client, err := newClient(ep)
+	if err != nil {
+		return err
+	}
+	return client.Ping(ctx)
+}
+
+// newClient 根据远端仓库定义创建客户端
+func newClient(ep *models.Endpoint) (*registryclient.Client, error) {
+	return registryclient.New(ep.URL, registryclient.Options{
+		Username: ep.Username,
+		Password: ep.Password,
+		Insecure: ep.Insecure,
+	})
+}
+
+// ---------------------------------------------------------------------------
+// 复制规则
+// ---------------------------------------------------------------------------
+
+// CreateRule 创建复制规则
+func (s *Service) CreateRule(ctx context.Context, req *dto.RuleRequest, userID string) (*models.Rule, error) {
+	rule := &models.Rule{ID: uuid.New().String(), CreatedBy: userID, Override: true, Enabled: true}
+	if err := s.applyRule(ctx, rule, req); err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Create(rule).Error; err != nil {
+		return nil, fmt.Errorf("创建复制规则失败: %w", err)
+	}
+	return rule, nil
+}
+
+// UpdateRule 更新复制规则（整体替换，override/enabled 未提供时保持原值）
+func (s *Service) UpdateRule(ctx context.Context, id string, req *dto.RuleRequest) (*models.Rule, error) {
+	rule, err := s.GetRule(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.applyRule(ctx, rule, req); err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Save(rule).Error; err != nil {
+		return nil, fmt.Errorf("更新复制规则失败: %w", err)
+	}
+	return rule, nil
+}
+
+// applyRule 校验请求并写入模型
+func (s *Service) applyRule(ctx context.Context, rule *models.Rule, req *dto.RuleRequest) error {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidRule)
+	}
+
+	mode := strings.ToLower(strings.TrimSpace(req.Mode))
+	if mode != models.ModePush && mode != models.ModePull {
+		return fmt.Errorf("%w: mode must be push or pull", ErrInvalidRule)
+	}
+	if _, err := s.GetEndpoint(ctx, req.EndpointID); err != nil {
+		if errors.Is(err, ErrEndpointNotFound) {
+			return fmt.Errorf("%w: endpoint not found", ErrInvalidRule)
+		}
+		return err
+	}
+
+	source := strings.Trim(strings.TrimSpace(req.SourceProject), "/")
+	dest := strings.Trim(strings.TrimSpace(req.Destination), "/")
+	switch mode {
+	case models.ModePush:
+		if source == "" {
+			return fmt.Errorf("%w: source_project is required for push rules", ErrInvalidRule)
+		}
+		if err := s.checkLocalProject(ctx, source); err != nil {
+			return err
+		}
+	case models.ModePull:
+		if dest == "" {
+			return fmt.Errorf("%w: destination project is required for pull rules", ErrInvalidRule)
+		}
+		if err := s.checkLocalProject(ctx, dest); err != nil {
+			return err
+		}
+	}
+
+	for _, filter := range []string{req.RepoFilter, req.TagFilter} {
+		if err := validateFilter(filter); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRule, err)
+		}
+	}
+
+	trigger := strings.ToLower(strings.TrimSpace(req.Trigger))
+	if trigger == "" {
+		trigger = models.TriggerManual
+	}
+	cronExpr := strings.TrimSpace(req.Cron)
+	switch trigger {
+	case models.TriggerManual:
+	case models.TriggerEvent:
+		if mode != models.ModePush {
+			return fmt.Errorf("%w: event trigger is only supported for push rules", ErrInvalidRule)
+		}
+	case models.TriggerCron:
+		if _, err := parseCron(cronExpr); err != nil {
+			return fmt.Errorf("%w: invalid cron: %v", ErrInvalidRule, err)
+		}
+	default:
+		return fmt.Errorf("%w: trigger must be manual, event or cron", ErrInvalidRule)
+	}
+	if trigger != models.TriggerCron {
+		cronExpr = ""
+	}
+
+	rule.Name = name
+	rule.Description = req.Description
+	rule.Mode = mode
+	rule.EndpointID = req.EndpointID
+	rule.SourceProject = source
+	rule.RepoFilter = strings.TrimSpace(req.RepoFilter)
+	rule.TagFilter = strings.TrimSpace(req.TagFilter)
+	rule.Destination = dest
+	rule.Trigger = trigger
+	rule.Cron = cronExpr
+	if req.Override != nil {
+		rule.Override = *req.Override
+	}
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+	return nil
+}
+
+// checkLocalProject 校验本地项目存在
+func (s *Service) checkLocalProject(ctx context.Context, name string) error {
+	if strings.Contains(name, "/") {
+		return fmt.Errorf("%w: project name must not contain '/'", ErrInvalidRule)
+	}
+	if s.projectSvc == nil {
+		return nil
+	}
+	if _, err := s.projectSvc.GetProjectByName(ctx, name); err != nil {
+		return fmt.Errorf("%w: project %q not found", ErrInvalidRule, name)
+	}
+	return nil
+}
+
+// GetRule 获取复制规则
+func (s *Service) GetRule(ctx context.Context, id string) (*models.Rule, error) {
+	var rule models.Rule
+	if err := s.db.WithContext(ctx).First(&rule, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRuleNotFound
+		}
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// ListRules 列出全部复制规则
+func (s *Service) ListRules(ctx context.Context) ([]models.Rule, error) {
+	var rules []models.Rule
+	if err := s.db.WithContext(ctx).Order("name ASC").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// DeleteRule 删除复制规则（执行记录保留）
+func (s *Service) DeleteRule(ctx context.Context, id string) error {
+	if _, err := s.GetRule(ctx, id); err != nil {
+		return err
+	}
+	return s.db.WithContext(ctx).Delete(&models.Rule{}, "id = ?", id).Error
+}
+
+// NextRunAt 返回 cron 规则的下次执行时间（非 cron 或已停用的规则返回 nil）
+func NextRunAt(rule *models.Rule, now time.Time) *time.Time {
+	if !rule.Enabled || rule.Trigger != models.TriggerCron {
+		return nil
+	}
+	sched, err := parseCron(rule.Cron)
+	if err != nil {
+		return nil
+	}
+	next := sched.next(now)
+	if next.IsZero() {
+		return nil
+	}
+	return &next
+}
+
+// ---------------------------------------------------------------------------
+// 执行记录
+// ---------------------------------------------------------------------------
+
+// Execute 手动触发规则，创建执行记录并异步执行
+func (s *Service) Execute(ctx context.Context, ruleID string) (*models.Execution, error) {
+	rule, err := s.GetRule(ctx, ruleID)
+	if err != nil {
+		return nil, err
+	}
+	if !rule.Enabled {
+		return nil, ErrRuleDisabled
+	}
+	return s.start(ctx, rule, models.TriggerManual, nil)
+}
+
+// start 创建执行记录并在后台执行；only 非空时只复制指定的仓库与 tag（事件触发）
+func (s *Service) start(ctx context.Context, rule *models.Rule, trigger string, only *artifact) (*models.Execution, error) {
+	exec := models.NewExecution(rule.ID, trigger)
+	if err := s.db.WithContext(ctx).Create(exec).Error; err != nil {
+		return nil, fmt.Errorf("创建执行记录失败: %w", err)
+	}
+
+	// 异步执行，不阻塞 API 响应或推送请求
+	go s.run(context.Background(), exec.ID, *rule, only)
+
+	return exec, nil
+}
+
+// GetExecution 获取执行详情（含每个镜像的复制记录）
+func (s *Service) GetExecution(ctx context.Context, id string) (*models.Execution, []models.Task, error) {
+	var exec models.Execution
+	if err := s.db.WithContext(ctx).First(&exec, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrExecutionNotFound
+		}
+		return nil, nil, err
+	}
+
+	var tasks []models.Task
+	if err := s.db.WithContext(ctx).
+		Where("execution_id = ?", id).
+		Order("created_at ASC").
+		Find(&tasks).Error; err != nil {
+		return nil, nil, err
+	}
+	return &exec, tasks, nil
+}
+
+// ListExecutions 列出规则的执行记录
+func (s *Service) ListExecutions(ctx context.Context, ruleID string, page, pageSize int) ([]models.Execution, int64, error) {
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	var total int64
+	if err := s.db.WithContext(ctx).
+		Model(&models.Execution{}).
+		Where("rule_id = ?", ruleID).
+		Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var execs []models.Execution
+	if err := s.db.WithContext(ctx).
+		Where("rule_id = ?", ruleID).
+		Order("created_at DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&execs).Error; err != nil {
+		return nil, 0, err
+	}
+	return execs, total, nil
+}
+
+// ruleLock 返回规则的执行锁
+func (s *Service) ruleLock(ruleID string) *sync.Mutex {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, ok := s.locks[ruleID]
+	if !ok {
+		l = &sync.Mutex{}
+		s.locks[ruleID] = l
+	}
+	return l
+}
+
+// validateFilter 校验逗号分隔的 glob 列表
+func validateFilter(filter string) error {
+	for _, pattern := range splitFilter(filter) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid filter %q: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// matchFilter 判断名称是否命中过滤条件（空过滤条件匹配全部）
+func matchFilter(filter, name string) bool {
+	patterns := splitFilter(filter)
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// literalFilter 过滤条件不含通配符时返回其中的名称列表
+// pull 模式下可据此直接访问仓库，无需远端开放 _catalog 接口。
+func literalFilter(filter string) ([]string, bool) {
+	patterns := splitFilter(filter)
+	if len(patterns) == 0 {
+		return nil, false
+	}
+	for _, pattern := range patterns {
+		if strings.ContainsAny(pattern, `*?[\`) {
+			return nil, false
+		}
+	}
+	return patterns, true
+}
+
+// splitFilter 拆分逗号分隔的过滤条件
+func splitFilter(filter string) []string {
+	var patterns []string
+	for _, p := range strings.Split(filter, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/cyp-registry/registry/src/modules/replication/models"
+	"github.com/cyp-registry/registry/src/modules/webhook"
+)
+
+// Start 启动事件触发与 cron 定时触发（阻塞直到 ctx 结束，通常以 goroutine 方式调用）
+func (s *Service) Start(ctx context.Context) {
+	events, unsubscribe := webhook.SubscribeRegistryEvents()
+	defer unsubscribe()
+
+	log.Printf("镜像复制调度已启动: 监听推送事件与 cron 规则")
+
+	timer := time.NewTimer(untilNextMinute(time.Now()))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if event.Type == webhook.RegistryEventPush {
+				s.HandlePushEvent(ctx, event.Repository, event.Tag)
+			}
+		case now := <-timer.C:
+			s.runCronRules(ctx, now.Truncate(time.Minute))
+			timer.Reset(untilNextMinute(time.Now()))
+		}
+	}
+}
+
+// HandlePushEvent 本地推送完成后触发匹配的事件规则（只复制本次推送的 tag）
+// 按 digest 推送（例如多架构镜像的子 Manifest）不触发，随后推送的索引 tag 会一并复制它们。
+func (s *Service) HandlePushEvent(ctx context.Context, repository, tag string) {
+	project, name, ok := strings.Cut(repository, "/")
+	if !ok || name == "" || tag == "" || strings.Contains(tag, ":") {
+		return
+	}
+
+	var rules []models.Rule
+	if err := s.db.WithContext(ctx).
+		Where("enabled = ? AND trigger = ? AND mode = ? AND source_project = ?", true, models.TriggerEvent, models.ModePush, project).
+		Find(&rules).Error; err != nil {
+		log.Printf(`{"timestamp":"%s","level":"warn","module":"replication","operation":"push_event","repository":"%s","error":"%v"}`, time.Now().Format(time.RFC3339), repository, err)
+		return
+	}
+
+	for i := range rules {
+		rule := &rules[i]
+		if !matchFilter(rule.RepoFilter, name) || !matchFilter(rule.TagFilter, tag) {
+			continue
+		}
+		only := &artifact{Source: repository, Destination: pushDestination(rule, name), Tag: tag}
+		if _, err := s.start(ctx, rule, models.TriggerEvent, only); err != nil {
+			log.Printf(`{"timestamp":"%s","level":"warn","module":"replication","operation":"push_event","rule_id":"%s","repository":"%s","tag":"%s","error":"%v"}`, time.Now().Format(time.RFC3339), rule.ID, repository, tag, err)
+		}
+	}
+}
+
+// runCronRules 执行当前分钟命中的 cron 规则
+// 上一次执行尚未结束（pending/running）的规则本轮跳过，避免执行堆积。
+func (s *Service) runCronRules(ctx context.Context, now time.Time) {
+	var rules []models.Rule
+	if err := s.db.WithContext(ctx).
+		Where("enabled = ? AND trigger = ?", true, models.TriggerCron).
+		Find(&rules).Error; err != nil {
+		log.Printf(`{"timestamp":"%s","level":"warn","module":"replication","operation":"cron","error":"%v"}`, time.Now().Format(time.RFC3339), err)
+		return
+	}
+
+	for i := range rules {
+		rule := &rules[i]
+		sched, err := parseCron(rule.Cron)
+		if err != nil || !sched.matches(now) {
+			continue
+		}
+
+		var active int64
+		if err := s.db.WithContext(ctx).Model(&models.Execution{}).
+			Where("rule_id = ? AND status IN ?", rule.ID, []string{string(models.ExecutionPending), string(models.ExecutionRunning)}).
+			Count(&active).Error; err != nil || active > 0 {
+			continue
+		}
+
+		if _, err := s.start(ctx, rule, models.TriggerCron, nil); err != nil {
+			log.Printf(`{"timestamp":"%s","level":"warn","module":"replication","operation":"cron","rule_id":"%s","error":"%v"}`, time.Now().Format(time.RFC3339), rule.ID, err)
+		}
+	}
+}
+
+// untilNextMinute 距离下一个整分钟的时间
+func untilNextMinute(now time.Time) time.Duration {
+	return now.Truncate(time.Minute).Add(time.Minute).Sub(now)
+}
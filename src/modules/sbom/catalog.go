@@ -0,0 +1,153 @@
+package sbom
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxCatalogFileSize 读取的软件包数据库 / 锁文件的大小上限，超过时跳过该文件
+const maxCatalogFileSize = 32 << 20
+
+// 镜像层中的 whiteout 标记（OCI image spec / AUFS）
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+)
+
+// LayerOpener 打开镜像层，返回解压后的 tar 数据流
+type LayerOpener func(ctx context.Context, digest string) (io.ReadCloser, error)
+
+// catalogFile 合并各层后仍存在的文件
+type catalogFile struct {
+	data  []byte
+	layer int
+}
+
+// Catalog 按顺序读取镜像层，识别最终文件系统中的系统软件包与语言依赖锁文件
+// 只保留需要解析的文件；上层的 whiteout 会删除下层的同名文件或目录内容。
+func Catalog(ctx context.Context, layers []string, open LayerOpener) (*Inventory, error) {
+	files := make(map[string]*catalogFile)
+	for i, digest := range layers {
+		if err := readLayer(ctx, i, digest, open, files); err != nil {
+			return nil, fmt.Errorf("layer %s: %w", digest, err)
+		}
+	}
+
+	inv := &Inventory{}
+	for _, p := range []string{"etc/os-release", "usr/lib/os-release"} {
+		if f, ok := files[p]; ok {
+			inv.OS = parseOSRelease(f.data)
+			break
+		}
+	}
+
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		for _, d := range detectors {
+			if d.match(p) {
+				inv.Packages = append(inv.Packages, d.parse(p, files[p].data, inv.OS)...)
+				break
+			}
+		}
+	}
+	return inv, nil
+}
+
+// readLayer 读取单个镜像层，更新 files
+func readLayer(ctx context.Context, index int, digest string, open LayerOpener, files map[string]*catalogFile) error {
+	rc, err := open(ctx, digest)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	tr := tar.NewReader(rc)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := cleanPath(hdr.Name)
+		if name == "" {
+			continue
+		}
+		dir, base := path.Split(name)
+		switch {
+		case base == whiteoutOpaque:
+			removeLower(files, dir, index)
+			continue
+		case strings.HasPrefix(base, whiteoutPrefix):
+			target := dir + strings.TrimPrefix(base, whiteoutPrefix)
+			if f, ok := files[target]; ok && f.layer < index {
+				delete(files, target)
+			}
+			removeLower(files, target+"/", index)
+			continue
+		}
+
+		// 非普通文件（目录、链接等）覆盖下层的同名文件
+		if hdr.Typeflag != tar.TypeReg {
+			delete(files, name)
+			continue
+		}
+		if !wanted(name) {
+			continue
+		}
+		if hdr.Size > maxCatalogFileSize {
+			log.Printf(`{"timestamp":"%s","level":"warn","module":"sbom","operation":"catalog","layer":"%s","file":"%s","size":%d,"message":"file too large, skipped"}`, time.Now().Format(time.RFC3339), digest, name, hdr.Size)
+			delete(files, name)
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		files[name] = &catalogFile{data: data, layer: index}
+	}
+}
+
+// removeLower 删除下层中位于 dir 目录下的文件（opaque whiteout 不影响同一层的文件）
+func removeLower(files map[string]*catalogFile, dir string, index int) {
+	for p, f := range files {
+		if f.layer < index && strings.HasPrefix(p, dir) {
+			delete(files, p)
+		}
+	}
+}
+
+// cleanPath 统一 tar 条目路径为不带前导 / 与 ./ 的相对路径
+func cleanPath(name string) string {
+	name = path.Clean("/" + name)
+	return strings.TrimPrefix(name, "/")
+}
+
+// wanted 是否为需要解析的文件
+func wanted(name string) bool {
+	if name == "etc/os-release" || name == "usr/lib/os-release" {
+		return true
+	}
+	for _, d := range detectors {
+		if d.match(name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,252 @@
+// Package controller 提供 SBOM 相关的HTTP接口
+package controller
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/cyp-registry/registry/src/middleware"
+	projectservice "github.com/cyp-registry/registry/src/modules/project/service"
+	"github.com/cyp-registry/registry/src/modules/registry"
+	"github.com/cyp-registry/registry/src/modules/sbom"
+	"github.com/cyp-registry/registry/src/modules/sbom/service"
+	"github.com/cyp-registry/registry/src/pkg/audit"
+	"github.com/cyp-registry/registry/src/pkg/imageroute"
+	"github.com/cyp-registry/registry/src/pkg/response"
+)
+
+// maxUploadSize 上传 SBOM 文档的大小上限
+const maxUploadSize = 64 << 20
+
+// SBOMController SBOM 控制器
+// 路由：/api/v1/projects/:id/images/<name>/sbom 与 /api/v1/projects/:id/images/<name>/sboms（经 imageroute 分发）
+type SBOMController struct {
+	svc        *service.Service
+	projectSvc projectservice.Service
+}
+
+// NewSBOMController 创建控制器
+func NewSBOMController(svc *service.Service, projectSvc projectservice.Service) *SBOMController {
+	return &SBOMController{
+		svc:        svc,
+		projectSvc: projectSvc,
+	}
+}
+
+// RegisterRoutes 注册到镜像子资源路由
+func (c *SBOMController) RegisterRoutes(r *imageroute.Router) {
+	r.Handle(http.MethodGet, "sbom", c.Get)
+	r.Handle(http.MethodPut, "sbom", c.Upload)
+	r.Handle(http.MethodPost, "sbom", c.Generate)
+	r.Handle(http.MethodGet, "sboms", c.List)
+}
+
+// Get 下载 SBOM 文档
+// GET /api/v1/projects/:id/images/<name>/sbom?reference=<tag|digest>&format=&source=&platform=
+// 响应体为 SPDX / CycloneDX JSON 原文，Content-Type 为对应的媒体类型。
+func (c *SBOMController) Get(ctx *gin.Context) {
+	reference := strings.TrimSpace(ctx.Query("reference"))
+	if reference == "" {
+		response.ParamError(ctx, "reference 不能为空")
+		return
+	}
+	repository, ok := c.resolve(ctx, "pull")
+	if !ok {
+		return
+	}
+
+	doc, data, err := c.svc.Get(ctx.Request.Context(), repository, reference, ctx.Query("format"), ctx.Query("source"), ctx.Query("platform"))
+	if err != nil {
+		c.fail(ctx, err)
+		return
+	}
+	ctx.Header("X-SBOM-Digest", doc.Digest)
+	ctx.Header("X-SBOM-Subject", doc.Subject)
+	ctx.Header("X-SBOM-Source", doc.Source)
+	ctx.Data(http.StatusOK, doc.MediaType, data)
+}
+
+// List 列出 tag 或 digest 关联的 SBOM（多架构镜像不含各平台子 Manifest 的 SBOM）
+// GET /api/v1/projects/:id/images/<name>/sboms?reference=<tag|digest>
+func (c *SBOMController) List(ctx *gin.Context) {
+	reference := strings.TrimSpace(ctx.Query("reference"))
+	if reference == "" {
+		response.ParamError(ctx, "reference 不能为空")
+		return
+	}
+	repository, ok := c.resolve(ctx, "pull")
+	if !ok {
+		return
+	}
+
+	docs, err := c.svc.List(ctx.Request.Context(), repository, reference)
+	if err != nil {
+		c.fail(ctx, err)
+		return
+	}
+	if docs == nil {
+		docs = []*service.Document{}
+	}
+	response.Success(ctx, docs)
+}
+
+// Upload 上传第三方生成的 SBOM
+// PUT /api/v1/projects/:id/images/<name>/sbom?reference=<tag|digest>
+// 请求体为 SPDX 或 CycloneDX JSON 文档，格式按内容识别；需要项目推送权限。
+func (c *SBOMController) Upload(ctx *gin.Context) {
+	reference := strings.TrimSpace(ctx.Query("reference"))
+	if reference == "" {
+		response.ParamError(ctx, "reference 不能为空")
+		return
+	}
+	repository, ok := c.resolve(ctx, "push")
+	if !ok {
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxUploadSize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			response.ParamError(ctx, "SBOM 文档过大")
+			return
+		}
+		response.ParamError(ctx, "读取请求体失败")
+		return
+	}
+
+	doc, err := c.svc.Upload(ctx.Request.Context(), repository, reference, data)
+	if err != nil {
+		c.fail(ctx, err)
+		return
+	}
+	c.record(ctx, "upload_sbom", map[string]interface{}{
+		"repository": repository,
+		"reference":  reference,
+		"subject":    doc.Subject,
+		"digest":     doc.Digest,
+		"format":     doc.Format,
+	})
+	response.Success(ctx, doc)
+}
+
+// Generate 立即生成 SBOM（替换已有的生成结果，上传的 SBOM 保留）
+// POST /api/v1/projects/:id/images/<name>/sbom?reference=<tag|digest>&format=spdx|cyclonedx
+// 需要项目推送权限。
+func (c *SBOMController) Generate(ctx *gin.Context) {
+	reference := strings.TrimSpace(ctx.Query("reference"))
+	if reference == "" {
+		response.ParamError(ctx, "reference 不能为空")
+		return
+	}
+	format := ctx.Query("format")
+	if format == "" {
+		format = c.svc.Format()
+	}
+	repository, ok := c.resolve(ctx, "push")
+	if !ok {
+		return
+	}
+
+	docs, err := c.svc.Generate(ctx.Request.Context(), repository, reference, format, true)
+	if err != nil {
+		c.fail(ctx, err)
+		return
+	}
+	c.record(ctx, "generate_sbom", map[string]interface{}{
+		"repository": repository,
+		"reference":  reference,
+		"format":     docs[0].Format,
+		"documents":  len(docs),
+	})
+	response.Success(ctx, docs)
+}
+
+// resolve 校验项目权限（含 PAT scope）并返回完整仓库名（项目名/镜像名），失败时已写入响应
+func (c *SBOMController) resolve(ctx *gin.Context, action string) (string, bool) {
+	image := imageroute.Image(ctx)
+	projectID := ctx.Param("id")
+
+	userID := currentUserID(ctx)
+	if userID == "" {
+		response.Unauthorized(ctx, "user not authenticated")
+		return "", false
+	}
+
+	scope := "read"
+	if action == "push" {
+		scope = "write"
+	}
+	if ok, code, message := middleware.HasScope(ctx, scope); !ok {
+		response.Fail(ctx, code, message)
+		return "", false
+	}
+
+	project, err := c.projectSvc.GetProject(ctx.Request.Context(), projectID)
+	if err != nil {
+		if errors.Is(err, projectservice.ErrProjectNotFound) {
+			response.NotFound(ctx, "项目不存在")
+			return "", false
+		}
+		response.InternalServerError(ctx, "获取项目失败")
+		return "", false
+	}
+
+	canAccess, err := c.projectSvc.CanAccess(ctx.Request.Context(), userID, projectID, action)
+	if err != nil {
+		response.InternalServerError(ctx, "failed to check permission")
+		return "", false
+	}
+	if !canAccess {
+		response.Forbidden(ctx, "permission denied")
+		return "", false
+	}
+	return project.Name + "/" + image, true
+}
+
+// fail 将服务层错误转换为HTTP响应
+func (c *SBOMController) fail(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, registry.ErrManifestNotFound):
+		response.NotFound(ctx, "镜像不存在")
+	case errors.Is(err, service.ErrSBOMNotFound):
+		response.NotFound(ctx, "镜像没有符合条件的 SBOM")
+	case errors.Is(err, service.ErrNotImage):
+		response.ParamError(ctx, "不是容器镜像（签名、SBOM 等 artifact 不支持 SBOM）")
+	case errors.Is(err, registry.ErrPlatformRequired):
+		response.ParamError(ctx, "多架构镜像需要指定 platform，例如 linux/amd64")
+	case errors.Is(err, registry.ErrProxyReadOnly):
+		response.ParamError(ctx, "代理项目不接受写入")
+	case errors.Is(err, sbom.ErrUnsupportedFormat), errors.Is(err, sbom.ErrInvalidDocument):
+		response.ParamError(ctx, err.Error())
+	default:
+		response.InternalServerError(ctx, err.Error())
+	}
+}
+
+// record 记录审计日志
+func (c *SBOMController) record(ctx *gin.Context, action string, details map[string]interface{}) {
+	var userID *uuid.UUID
+	if v, exists := ctx.Get(middleware.ContextKeyUserID); exists {
+		if id, ok := v.(uuid.UUID); ok && id != uuid.Nil {
+			userID = &id
+		}
+	}
+	details["project_id"] = ctx.Param("id")
+	audit.Record(ctx.Request.Context(), action, "image", nil, userID, ctx.ClientIP(), ctx.Request.UserAgent(), details)
+}
+
+// currentUserID 获取当前用户ID字符串（未登录时为空）
+func currentUserID(ctx *gin.Context) string {
+	if v, exists := ctx.Get(middleware.ContextKeyUserID); exists {
+		if id, ok := v.(uuid.UUID); ok && id != uuid.Nil {
+			return id.String()
+		}
+	}
+	return ""
+}
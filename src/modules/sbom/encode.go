@@ -0,0 +1,281 @@
+package sbom
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// namespacePrefix SPDX documentNamespace 的前缀
+const namespacePrefix = "https://cyp-registry.io/spdx/"
+
+// licenseExpression 可直接作为 SPDX / CycloneDX 许可证表达式的值（简单的 AND / OR / WITH 组合）
+var licenseExpression = regexp.MustCompile(`^[A-Za-z0-9.+-]+( (AND|OR|WITH) [A-Za-z0-9.+-]+)*$`)
+
+// Encode 按格式生成 SBOM 文档
+func Encode(format string, img Image, inv *Inventory, generator string, created time.Time) ([]byte, error) {
+	var doc interface{}
+	switch format {
+	case FormatSPDX:
+		doc = encodeSPDX(img, inv, generator, created)
+	case FormatCycloneDX:
+		doc = encodeCycloneDX(img, inv, generator, created)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+
+	// purl 中的 & 等字符保持原样，便于其他工具直接比较
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// imagePURL 镜像本身的 purl（pkg:oci）
+func imagePURL(img Image) string {
+	name := img.Repository
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	return purl("oci", "", strings.ToLower(name), img.Digest,
+		[2]string{"repository_url", img.Repository},
+		[2]string{"platform", img.Platform})
+}
+
+// ---- SPDX 2.3 ----
+
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	SPDXID           string            `json:"SPDXID"`
+	Name             string            `json:"name"`
+	VersionInfo      string            `json:"versionInfo,omitempty"`
+	DownloadLocation string            `json:"downloadLocation"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	LicenseConcluded string            `json:"licenseConcluded"`
+	LicenseDeclared  string            `json:"licenseDeclared"`
+	PrimaryPurpose   string            `json:"primaryPackagePurpose,omitempty"`
+	SourceInfo       string            `json:"sourceInfo,omitempty"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs,omitempty"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+func encodeSPDX(img Image, inv *Inventory, generator string, created time.Time) *spdxDocument {
+	const imageID = "SPDXRef-Image"
+	doc := &spdxDocument{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              img.Repository + "@" + img.Digest,
+		DocumentNamespace: namespacePrefix + img.Repository + "/" + img.Digest + "-" + uuid.New().String(),
+		CreationInfo: spdxCreationInfo{
+			Created:  created.UTC().Format(time.RFC3339),
+			Creators: []string{"Tool: " + generator},
+		},
+		Packages: []spdxPackage{{
+			SPDXID:           imageID,
+			Name:             img.Repository,
+			VersionInfo:      img.Digest,
+			DownloadLocation: "NOASSERTION",
+			LicenseConcluded: "NOASSERTION",
+			LicenseDeclared:  "NOASSERTION",
+			PrimaryPurpose:   "CONTAINER",
+			ExternalRefs: []spdxExternalRef{{
+				ReferenceCategory: "PACKAGE-MANAGER",
+				ReferenceType:     "purl",
+				ReferenceLocator:  imagePURL(img),
+			}},
+		}},
+		Relationships: []spdxRelationship{{
+			SPDXElementID:      "SPDXRef-DOCUMENT",
+			RelationshipType:   "DESCRIBES",
+			RelatedSPDXElement: imageID,
+		}},
+	}
+
+	if inv.OS != nil {
+		doc.Packages = append(doc.Packages, spdxPackage{
+			SPDXID:           "SPDXRef-OperatingSystem",
+			Name:             inv.OS.ID,
+			VersionInfo:      inv.OS.VersionID,
+			DownloadLocation: "NOASSERTION",
+			LicenseConcluded: "NOASSERTION",
+			LicenseDeclared:  "NOASSERTION",
+			PrimaryPurpose:   "OPERATING-SYSTEM",
+			SourceInfo:       inv.OS.PrettyName,
+		})
+		doc.Relationships = append(doc.Relationships, spdxRelationship{
+			SPDXElementID:      imageID,
+			RelationshipType:   "CONTAINS",
+			RelatedSPDXElement: "SPDXRef-OperatingSystem",
+		})
+	}
+
+	for i, p := range inv.Packages {
+		id := fmt.Sprintf("SPDXRef-Package-%s-%d", p.Type, i+1)
+		license := "NOASSERTION"
+		if licenseExpression.MatchString(p.License) {
+			license = p.License
+		}
+		doc.Packages = append(doc.Packages, spdxPackage{
+			SPDXID:           id,
+			Name:             p.Name,
+			VersionInfo:      p.Version,
+			DownloadLocation: "NOASSERTION",
+			LicenseConcluded: "NOASSERTION",
+			LicenseDeclared:  license,
+			PrimaryPurpose:   "LIBRARY",
+			SourceInfo:       "acquired package info from " + p.Location,
+			ExternalRefs: []spdxExternalRef{{
+				ReferenceCategory: "PACKAGE-MANAGER",
+				ReferenceType:     "purl",
+				ReferenceLocator:  p.PURL,
+			}},
+		})
+		doc.Relationships = append(doc.Relationships, spdxRelationship{
+			SPDXElementID:      imageID,
+			RelationshipType:   "CONTAINS",
+			RelatedSPDXElement: id,
+		})
+	}
+	return doc
+}
+
+// ---- CycloneDX 1.5 ----
+
+type cdxDocument struct {
+	BOMFormat    string         `json:"bomFormat"`
+	SpecVersion  string         `json:"specVersion"`
+	SerialNumber string         `json:"serialNumber"`
+	Version      int            `json:"version"`
+	Metadata     cdxMetadata    `json:"metadata"`
+	Components   []cdxComponent `json:"components"`
+}
+
+type cdxMetadata struct {
+	Timestamp string       `json:"timestamp"`
+	Tools     cdxTools     `json:"tools"`
+	Component cdxComponent `json:"component"`
+}
+
+type cdxTools struct {
+	Components []cdxComponent `json:"components"`
+}
+
+type cdxComponent struct {
+	BOMRef      string        `json:"bom-ref,omitempty"`
+	Type        string        `json:"type"`
+	Name        string        `json:"name"`
+	Version     string        `json:"version,omitempty"`
+	Description string        `json:"description,omitempty"`
+	PURL        string        `json:"purl,omitempty"`
+	Licenses    []cdxLicense  `json:"licenses,omitempty"`
+	Properties  []cdxProperty `json:"properties,omitempty"`
+}
+
+type cdxLicense struct {
+	Expression string          `json:"expression,omitempty"`
+	License    *cdxLicenseName `json:"license,omitempty"`
+}
+
+type cdxLicenseName struct {
+	Name string `json:"name"`
+}
+
+type cdxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func encodeCycloneDX(img Image, inv *Inventory, generator string, created time.Time) *cdxDocument {
+	image := cdxComponent{
+		BOMRef:  imagePURL(img),
+		Type:    "container",
+		Name:    img.Repository,
+		Version: img.Digest,
+		PURL:    imagePURL(img),
+	}
+	if img.Platform != "" {
+		image.Properties = []cdxProperty{{Name: "cyp-registry:platform", Value: img.Platform}}
+	}
+
+	doc := &cdxDocument{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: "urn:uuid:" + uuid.New().String(),
+		Version:      1,
+		Metadata: cdxMetadata{
+			Timestamp: created.UTC().Format(time.RFC3339),
+			Tools:     cdxTools{Components: []cdxComponent{{Type: "application", Name: generator}}},
+			Component: image,
+		},
+		Components: []cdxComponent{},
+	}
+
+	if inv.OS != nil {
+		doc.Components = append(doc.Components, cdxComponent{
+			BOMRef:      "os:" + inv.OS.ID,
+			Type:        "operating-system",
+			Name:        inv.OS.ID,
+			Version:     inv.OS.VersionID,
+			Description: inv.OS.PrettyName,
+		})
+	}
+
+	for i, p := range inv.Packages {
+		c := cdxComponent{
+			BOMRef:  fmt.Sprintf("%s#%d", p.PURL, i+1),
+			Type:    "library",
+			Name:    p.Name,
+			Version: p.Version,
+			PURL:    p.PURL,
+			Properties: []cdxProperty{
+				{Name: "cyp-registry:package:type", Value: p.Type},
+				{Name: "cyp-registry:location", Value: p.Location},
+			},
+		}
+		switch {
+		case p.License == "":
+		case licenseExpression.MatchString(p.License):
+			c.Licenses = []cdxLicense{{Expression: p.License}}
+		default:
+			c.Licenses = []cdxLicense{{License: &cdxLicenseName{Name: p.License}}}
+		}
+		doc.Components = append(doc.Components, c)
+	}
+	return doc
+}
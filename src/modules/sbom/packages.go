@@ -0,0 +1,548 @@
+package sbom
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"path"
+	"sort"
+	"strings"
+)
+
+// detector 识别一类软件包数据库或锁文件
+type detector struct {
+	match func(name string) bool
+	parse func(name string, data []byte, os *OSRelease) []Package
+}
+
+// detectors 支持的软件包来源
+// RPM 数据库（Berkeley DB / SQLite）暂不支持。
+var detectors = []detector{
+	{match: exact("var/lib/dpkg/status"), parse: parseDpkgStatus},
+	{match: func(name string) bool { return path.Dir(name) == "var/lib/dpkg/status.d" }, parse: parseDpkgStatus},
+	{match: exact("lib/apk/db/installed"), parse: parseApkInstalled},
+	{match: lockfile("package-lock.json"), parse: parsePackageLock},
+	{match: lockfile("yarn.lock"), parse: parseYarnLock},
+	{match: lockfile("go.mod"), parse: parseGoMod},
+	{match: lockfile("requirements.txt"), parse: parseRequirements},
+	{match: lockfile("Pipfile.lock"), parse: parsePipfileLock},
+	{match: lockfile("poetry.lock"), parse: parseTOMLPackages(TypePyPI)},
+	{match: lockfile("Cargo.lock"), parse: parseTOMLPackages(TypeCargo)},
+	{match: lockfile("composer.lock"), parse: parseComposerLock},
+	{match: lockfile("Gemfile.lock"), parse: parseGemfileLock},
+}
+
+// exact 匹配固定路径
+func exact(p string) func(string) bool {
+	return func(name string) bool { return name == p }
+}
+
+// lockfile 按文件名匹配语言锁文件，跳过 node_modules 等依赖目录中的副本（它们已由上层锁文件描述）
+func lockfile(base string) func(string) bool {
+	return func(name string) bool {
+		if path.Base(name) != base {
+			return false
+		}
+		for _, skip := range []string{"node_modules/", "proc/", "sys/", "dev/"} {
+			if strings.HasPrefix(name, skip) || strings.Contains(name, "/"+skip) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// parseOSRelease 解析 os-release（KEY=VALUE，值可带引号）
+func parseOSRelease(data []byte) *OSRelease {
+	rel := &OSRelease{}
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(value, `"'`)
+		switch key {
+		case "ID":
+			rel.ID = value
+		case "VERSION_ID":
+			rel.VersionID = value
+		case "PRETTY_NAME":
+			rel.PrettyName = value
+		}
+	}
+	if rel.ID == "" {
+		return nil
+	}
+	return rel
+}
+
+// distro 返回 purl 的 distro 限定符，例如 debian-12
+func distro(os *OSRelease) string {
+	if os == nil {
+		return ""
+	}
+	if os.VersionID == "" {
+		return os.ID
+	}
+	return os.ID + "-" + os.VersionID
+}
+
+// parseStanzas 解析以空行分隔的 "Key: Value" 记录（dpkg status 格式）
+// 以空白开头的行是上一字段的续行，忽略。
+func parseStanzas(data []byte) []map[string]string {
+	var stanzas []map[string]string
+	cur := map[string]string{}
+	flush := func() {
+		if len(cur) > 0 {
+			stanzas = append(stanzas, cur)
+			cur = map[string]string{}
+		}
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, ":"); ok {
+			cur[key] = strings.TrimSpace(value)
+		}
+	}
+	flush()
+	return stanzas
+}
+
+// parseDpkgStatus 解析 Debian / Ubuntu 的 dpkg 数据库（distroless 镜像使用 status.d 目录）
+func parseDpkgStatus(name string, data []byte, os *OSRelease) []Package {
+	namespace := "debian"
+	if os != nil && os.ID != "" {
+		namespace = os.ID
+	}
+	var pkgs []Package
+	for _, s := range parseStanzas(data) {
+		pkg, version := s["Package"], s["Version"]
+		if pkg == "" || version == "" {
+			continue
+		}
+		// status.d 中的记录没有 Status 字段；dpkg status 中只统计已安装的软件包
+		if status, ok := s["Status"]; ok && !strings.HasSuffix(status, " installed") {
+			continue
+		}
+		pkgs = append(pkgs, Package{
+			Name:     pkg,
+			Version:  version,
+			Type:     TypeDeb,
+			PURL:     purl(TypeDeb, namespace, pkg, version, [2]string{"arch", s["Architecture"]}, [2]string{"distro", distro(os)}),
+			Location: name,
+		})
+	}
+	return pkgs
+}
+
+// parseApkInstalled 解析 Alpine 的 apk 数据库（单字母字段，记录以空行分隔）
+func parseApkInstalled(name string, data []byte, os *OSRelease) []Package {
+	namespace := "alpine"
+	if os != nil && os.ID != "" {
+		namespace = os.ID
+	}
+	var pkgs []Package
+	var pkg, version, arch, license string
+	flush := func() {
+		if pkg != "" && version != "" {
+			pkgs = append(pkgs, Package{
+				Name:     pkg,
+				Version:  version,
+				Type:     TypeApk,
+				PURL:     purl(TypeApk, namespace, pkg, version, [2]string{"arch", arch}, [2]string{"distro", distro(os)}),
+				License:  license,
+				Location: name,
+			})
+		}
+		pkg, version, arch, license = "", "", "", ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			flush()
+			continue
+		}
+		if len(line) < 2 || line[1] != ':' {
+			continue
+		}
+		value := line[2:]
+		switch line[0] {
+		case 'P':
+			pkg = value
+		case 'V':
+			version = value
+		case 'A':
+			arch = value
+		case 'L':
+			license = value
+		}
+	}
+	flush()
+	return pkgs
+}
+
+// npmPackage 构造 npm 软件包，名称可带 @scope
+func npmPackage(pkgName, version, license, location string) Package {
+	namespace, base := "", pkgName
+	if strings.HasPrefix(pkgName, "@") {
+		if i := strings.Index(pkgName, "/"); i > 0 {
+			namespace, base = pkgName[:i], pkgName[i+1:]
+		}
+	}
+	return Package{
+		Name:     pkgName,
+		Version:  version,
+		Type:     TypeNpm,
+		PURL:     purl(TypeNpm, namespace, base, version),
+		License:  license,
+		Location: location,
+	}
+}
+
+// parsePackageLock 解析 npm 的 package-lock.json（lockfileVersion 1 使用 dependencies，2/3 使用 packages），跳过开发依赖
+func parsePackageLock(name string, data []byte, _ *OSRelease) []Package {
+	var lock struct {
+		Packages map[string]struct {
+			Name    string      `json:"name"`
+			Version string      `json:"version"`
+			Dev     bool        `json:"dev"`
+			License interface{} `json:"license"`
+			Link    bool        `json:"link"`
+		} `json:"packages"`
+		Dependencies map[string]json.RawMessage `json:"dependencies"`
+	}
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil
+	}
+
+	var pkgs []Package
+	if len(lock.Packages) > 0 {
+		keys := sortedKeys(lock.Packages)
+		for _, key := range keys {
+			p := lock.Packages[key]
+			// 空键是项目本身；link 指向工作区中的本地目录
+			if key == "" || p.Dev || p.Link || p.Version == "" {
+				continue
+			}
+			pkgName := p.Name
+			if pkgName == "" {
+				i := strings.LastIndex(key, "node_modules/")
+				if i < 0 {
+					continue
+				}
+				pkgName = key[i+len("node_modules/"):]
+			}
+			license, _ := p.License.(string)
+			pkgs = append(pkgs, npmPackage(pkgName, p.Version, license, name))
+		}
+		return pkgs
+	}
+
+	var walk func(deps map[string]json.RawMessage)
+	walk = func(deps map[string]json.RawMessage) {
+		for _, key := range sortedKeys(deps) {
+			var dep struct {
+				Version      string                     `json:"version"`
+				Dev          bool                       `json:"dev"`
+				Dependencies map[string]json.RawMessage `json:"dependencies"`
+			}
+			if err := json.Unmarshal(deps[key], &dep); err != nil || dep.Dev {
+				continue
+			}
+			if dep.Version != "" && !strings.HasPrefix(dep.Version, "file:") {
+				pkgs = append(pkgs, npmPackage(key, dep.Version, "", name))
+			}
+			walk(dep.Dependencies)
+		}
+	}
+	walk(lock.Dependencies)
+	return pkgs
+}
+
+// parseYarnLock 解析 yarn.lock（v1 与 berry 格式均为 "名称@范围:" 开头、缩进的 version 字段）
+func parseYarnLock(name string, data []byte, _ *OSRelease) []Package {
+	var pkgs []Package
+	seen := make(map[string]bool)
+	current := ""
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if line[0] != ' ' {
+			// 第一个条目即可确定名称，例如 "@babel/core@^7.0.0, @babel/core@^7.1.0":
+			spec := strings.TrimSuffix(line, ":")
+			spec = strings.Trim(strings.SplitN(spec, ",", 2)[0], `" `)
+			current = ""
+			if spec == "__metadata" {
+				continue
+			}
+			if i := strings.LastIndex(spec, "@"); i > 0 {
+				current = spec[:i]
+			}
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		if current == "" || !strings.HasPrefix(trimmed, "version") {
+			continue
+		}
+		version := strings.TrimSpace(strings.TrimPrefix(trimmed, "version"))
+		version = strings.Trim(strings.TrimPrefix(version, ":"), `" `)
+		if version == "" || seen[current+"@"+version] {
+			continue
+		}
+		seen[current+"@"+version] = true
+		pkgs = append(pkgs, npmPackage(current, version, "", name))
+		current = ""
+	}
+	return pkgs
+}
+
+// parseGoMod 解析 go.mod 的 require 指令（单行与块形式）
+func parseGoMod(name string, data []byte, _ *OSRelease) []Package {
+	var pkgs []Package
+	add := func(fields []string) {
+		if len(fields) < 2 {
+			return
+		}
+		module, version := fields[0], fields[1]
+		namespace, base := "", module
+		if i := strings.LastIndex(module, "/"); i > 0 {
+			namespace, base = module[:i], module[i+1:]
+		}
+		pkgs = append(pkgs, Package{
+			Name:     module,
+			Version:  version,
+			Type:     TypeGolang,
+			PURL:     purl(TypeGolang, namespace, base, version),
+			Location: name,
+		})
+	}
+
+	inBlock := false
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch {
+		case inBlock && fields[0] == ")":
+			inBlock = false
+		case inBlock:
+			add(fields)
+		case fields[0] == "require" && len(fields) > 1 && fields[1] == "(":
+			inBlock = true
+		case fields[0] == "require":
+			add(fields[1:])
+		}
+	}
+	return pkgs
+}
+
+// pypiPackage 构造 PyPI 软件包，名称按 PEP 503 规范化
+func pypiPackage(pkgName, version, location string) Package {
+	normalized := strings.ToLower(strings.NewReplacer("_", "-", ".", "-").Replace(pkgName))
+	return Package{
+		Name:     pkgName,
+		Version:  version,
+		Type:     TypePyPI,
+		PURL:     purl(TypePyPI, "", normalized, version),
+		Location: location,
+	}
+}
+
+// parseRequirements 解析 requirements.txt 中固定版本（==）的依赖
+func parseRequirements(name string, data []byte, _ *OSRelease) []Package {
+	var pkgs []Package
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		// 去掉环境标记与哈希等选项
+		if i := strings.IndexAny(line, "; "); i >= 0 {
+			line = line[:i]
+		}
+		pkg, version, ok := strings.Cut(line, "==")
+		if !ok || strings.HasPrefix(line, "-") {
+			continue
+		}
+		if i := strings.Index(pkg, "["); i >= 0 {
+			pkg = pkg[:i]
+		}
+		pkg, version = strings.TrimSpace(pkg), strings.TrimSpace(version)
+		if pkg == "" || version == "" {
+			continue
+		}
+		pkgs = append(pkgs, pypiPackage(pkg, version, name))
+	}
+	return pkgs
+}
+
+// parsePipfileLock 解析 Pipfile.lock 的 default 依赖（不含 develop）
+func parsePipfileLock(name string, data []byte, _ *OSRelease) []Package {
+	var lock struct {
+		Default map[string]struct {
+			Version string `json:"version"`
+		} `json:"default"`
+	}
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil
+	}
+	var pkgs []Package
+	for _, key := range sortedKeys(lock.Default) {
+		version := strings.TrimPrefix(lock.Default[key].Version, "==")
+		if version == "" {
+			continue
+		}
+		pkgs = append(pkgs, pypiPackage(key, version, name))
+	}
+	return pkgs
+}
+
+// parseTOMLPackages 解析 poetry.lock / Cargo.lock 中的 [[package]] 表（只读取 name 与 version）
+func parseTOMLPackages(typ string) func(string, []byte, *OSRelease) []Package {
+	return func(name string, data []byte, _ *OSRelease) []Package {
+		var pkgs []Package
+		var pkg, version string
+		inPackage := false
+		flush := func() {
+			if inPackage && pkg != "" && version != "" {
+				if typ == TypePyPI {
+					pkgs = append(pkgs, pypiPackage(pkg, version, name))
+				} else {
+					pkgs = append(pkgs, Package{
+						Name:     pkg,
+						Version:  version,
+						Type:     typ,
+						PURL:     purl(typ, "", pkg, version),
+						Location: name,
+					})
+				}
+			}
+			pkg, version = "", ""
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if strings.HasPrefix(line, "[") {
+				flush()
+				inPackage = line == "[[package]]"
+				continue
+			}
+			if !inPackage {
+				continue
+			}
+			key, value, ok := strings.Cut(line, "=")
+			if !ok {
+				continue
+			}
+			value = strings.Trim(strings.TrimSpace(value), `"`)
+			switch strings.TrimSpace(key) {
+			case "name":
+				pkg = value
+			case "version":
+				version = value
+			}
+		}
+		flush()
+		return pkgs
+	}
+}
+
+// parseComposerLock 解析 PHP composer.lock 的 packages（不含 packages-dev）
+func parseComposerLock(name string, data []byte, _ *OSRelease) []Package {
+	var lock struct {
+		Packages []struct {
+			Name    string   `json:"name"`
+			Version string   `json:"version"`
+			License []string `json:"license"`
+		} `json:"packages"`
+	}
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil
+	}
+	var pkgs []Package
+	for _, p := range lock.Packages {
+		if p.Name == "" || p.Version == "" {
+			continue
+		}
+		namespace, base := "", p.Name
+		if i := strings.Index(p.Name, "/"); i > 0 {
+			namespace, base = p.Name[:i], p.Name[i+1:]
+		}
+		pkgs = append(pkgs, Package{
+			Name:     p.Name,
+			Version:  p.Version,
+			Type:     TypeComposer,
+			PURL:     purl(TypeComposer, namespace, base, p.Version),
+			License:  strings.Join(p.License, " OR "),
+			Location: name,
+		})
+	}
+	return pkgs
+}
+
+// parseGemfileLock 解析 Gemfile.lock 中 GEM 段的 specs（4 空格缩进的 "名称 (版本)"）
+func parseGemfileLock(name string, data []byte, _ *OSRelease) []Package {
+	var pkgs []Package
+	section, inSpecs := "", false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		if line[0] != ' ' {
+			section, inSpecs = line, false
+			continue
+		}
+		if section != "GEM" {
+			continue
+		}
+		if strings.TrimSpace(line) == "specs:" {
+			inSpecs = true
+			continue
+		}
+		if !inSpecs || !strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "     ") {
+			continue
+		}
+		gem, rest, ok := strings.Cut(strings.TrimSpace(line), " (")
+		if !ok {
+			continue
+		}
+		version := strings.TrimSuffix(rest, ")")
+		// 平台相关的 gem 版本形如 1.15.5-x86_64-linux
+		platform := ""
+		if i := strings.Index(version, "-"); i > 0 {
+			version, platform = version[:i], version[i+1:]
+		}
+		pkgs = append(pkgs, Package{
+			Name:     gem,
+			Version:  version,
+			Type:     TypeGem,
+			PURL:     purl(TypeGem, "", gem, version, [2]string{"platform", platform}),
+			Location: name,
+		})
+	}
+	return pkgs
+}
+
+// sortedKeys 返回排序后的 map 键，保证输出稳定
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
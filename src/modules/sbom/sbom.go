@@ -0,0 +1,172 @@
+// Package sbom 软件物料清单（SBOM）模块
+// 从镜像层中识别系统软件包与语言依赖锁文件（见 catalog.go），输出 SPDX 或 CycloneDX JSON 文档。
+// SBOM 以 OCI artifact 的形式通过 subject 关联到镜像，由 service 包负责生成、上传与查询。
+package sbom
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 支持的 SBOM 格式
+const (
+	FormatSPDX      = "spdx"
+	FormatCycloneDX = "cyclonedx"
+)
+
+// SBOM 文档的媒体类型，同时作为 OCI artifact 的 artifactType
+const (
+	MediaTypeSPDX      = "application/spdx+json"
+	MediaTypeCycloneDX = "application/vnd.cyclonedx+json"
+)
+
+var (
+	// ErrUnsupportedFormat 不支持的 SBOM 格式
+	ErrUnsupportedFormat = errors.New("sbom: unsupported format")
+	// ErrInvalidDocument 不是合法的 SPDX / CycloneDX JSON 文档
+	ErrInvalidDocument = errors.New("sbom: invalid document")
+)
+
+// 软件包类型（与 purl 的 type 一致）
+const (
+	TypeDeb      = "deb"
+	TypeApk      = "apk"
+	TypeNpm      = "npm"
+	TypeGolang   = "golang"
+	TypePyPI     = "pypi"
+	TypeCargo    = "cargo"
+	TypeComposer = "composer"
+	TypeGem      = "gem"
+)
+
+// Package 识别出的软件包
+type Package struct {
+	Name     string `json:"name"`
+	Version  string `json:"version"`
+	Type     string `json:"type"`
+	PURL     string `json:"purl"`
+	License  string `json:"license,omitempty"`
+	Location string `json:"location"` // 识别来源的文件路径，例如 var/lib/dpkg/status
+}
+
+// OSRelease 镜像的操作系统（/etc/os-release）
+type OSRelease struct {
+	ID         string `json:"id"`
+	VersionID  string `json:"version_id"`
+	PrettyName string `json:"pretty_name"`
+}
+
+// Inventory 镜像中识别出的全部软件包
+type Inventory struct {
+	OS       *OSRelease
+	Packages []Package
+}
+
+// Image SBOM 描述的镜像
+type Image struct {
+	Repository string
+	Digest     string
+	Platform   string // 例如 linux/amd64，未知时为空
+}
+
+// ParseFormat 解析格式名称，支持 spdx / cyclonedx（及 cdx 简写）与对应的媒体类型；为空时返回 SPDX
+func ParseFormat(s string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", FormatSPDX, "spdx-json", MediaTypeSPDX:
+		return FormatSPDX, nil
+	case FormatCycloneDX, "cdx", "cyclonedx-json", MediaTypeCycloneDX:
+		return FormatCycloneDX, nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedFormat, s)
+	}
+}
+
+// MediaType 返回格式对应的媒体类型
+func MediaType(format string) string {
+	if format == FormatCycloneDX {
+		return MediaTypeCycloneDX
+	}
+	return MediaTypeSPDX
+}
+
+// FormatOf 返回媒体类型对应的格式，不是 SBOM 媒体类型时返回空字符串
+func FormatOf(mediaType string) string {
+	switch mediaType {
+	case MediaTypeSPDX:
+		return FormatSPDX
+	case MediaTypeCycloneDX:
+		return FormatCycloneDX
+	default:
+		return ""
+	}
+}
+
+// DetectFormat 识别 SBOM 文档的格式（仅支持 JSON 编码）
+func DetectFormat(data []byte) (string, error) {
+	var doc struct {
+		SPDXVersion string `json:"spdxVersion"`
+		BOMFormat   string `json:"bomFormat"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return "", fmt.Errorf("%w: not a JSON document: %v", ErrInvalidDocument, err)
+	}
+	switch {
+	case strings.HasPrefix(doc.SPDXVersion, "SPDX-"):
+		return FormatSPDX, nil
+	case doc.BOMFormat == "CycloneDX":
+		return FormatCycloneDX, nil
+	default:
+		return "", fmt.Errorf("%w: neither spdxVersion nor bomFormat=CycloneDX found", ErrInvalidDocument)
+	}
+}
+
+// purl 构造 package URL（https://github.com/package-url/purl-spec）
+// namespace 可包含多段（以 / 分隔），各段与 name、version 分别百分号编码；qualifiers 按给定顺序输出，值为空的跳过。
+func purl(typ, namespace, name, version string, qualifiers ...[2]string) string {
+	var b strings.Builder
+	b.WriteString("pkg:")
+	b.WriteString(typ)
+	b.WriteByte('/')
+	if namespace != "" {
+		for _, seg := range strings.Split(namespace, "/") {
+			b.WriteString(purlEscape(seg))
+			b.WriteByte('/')
+		}
+	}
+	b.WriteString(purlEscape(name))
+	if version != "" {
+		b.WriteByte('@')
+		b.WriteString(purlEscape(version))
+	}
+	sep := byte('?')
+	for _, q := range qualifiers {
+		if q[1] == "" {
+			continue
+		}
+		b.WriteByte(sep)
+		b.WriteString(q[0])
+		b.WriteByte('=')
+		b.WriteString(purlEscape(q[1]))
+		sep = '&'
+	}
+	return b.String()
+}
+
+// purlEscape 百分号编码，只保留 RFC 3986 的非保留字符
+func purlEscape(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&0x0f])
+	}
+	return b.String()
+}
@@ -0,0 +1,445 @@
+// Package service 实现 SBOM 的生成、上传与查询
+// SBOM 以 OCI artifact（artifactType 为 SBOM 媒体类型、subject 指向镜像）存储在镜像所在仓库中，
+// 可通过 /v2/<name>/referrers/<digest> 发现，并随镜像一起导出、复制和回收。
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cyp-registry/registry/src/modules/registry"
+	"github.com/cyp-registry/registry/src/modules/sbom"
+	"github.com/cyp-registry/registry/src/pkg/config"
+)
+
+// SBOM artifact 的 annotations
+const (
+	// AnnotationCreated 生成或上传时间（RFC 3339）
+	AnnotationCreated = "org.opencontainers.image.created"
+	// AnnotationTitle SBOM 文件名
+	AnnotationTitle = "org.opencontainers.image.title"
+	// AnnotationSource SBOM 来源：generated / uploaded
+	AnnotationSource = "io.cyp-registry.sbom.source"
+	// AnnotationGenerator 生成工具
+	AnnotationGenerator = "io.cyp-registry.sbom.generator"
+	// AnnotationPlatform 多架构镜像中子 Manifest 的平台
+	AnnotationPlatform = "io.cyp-registry.sbom.platform"
+)
+
+// SBOM 来源
+const (
+	SourceGenerated = "generated"
+	SourceUploaded  = "uploaded"
+)
+
+// Generator 写入 SBOM 文档的生成工具名称
+const Generator = "cyp-registry-sbom"
+
+const (
+	// DefaultWorkers 同时生成 SBOM 的镜像数
+	DefaultWorkers = 1
+	// queueSize 推送事件触发的生成任务队列长度，队列满时丢弃新任务（可手动补生成）
+	queueSize = 256
+)
+
+var (
+	// ErrSBOMNotFound 镜像没有符合条件的 SBOM
+	ErrSBOMNotFound = errors.New("sbom: not found")
+	// ErrNotImage 不是容器镜像（例如签名、SBOM 等 artifact）
+	ErrNotImage = errors.New("sbom: artifact is not a container image")
+)
+
+// Document 仓库中的一份 SBOM
+type Document struct {
+	Digest    string    `json:"digest"`  // SBOM artifact Manifest 的 digest
+	Subject   string    `json:"subject"` // 所描述镜像的 Manifest digest
+	Format    string    `json:"format"`
+	MediaType string    `json:"media_type"`
+	Source    string    `json:"source"`
+	Platform  string    `json:"platform,omitempty"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// job 推送事件触发的生成任务
+type job struct {
+	repository string
+	tag        string
+}
+
+// Service SBOM 服务
+type Service struct {
+	reg     *registry.Registry
+	enabled bool   // 推送后自动生成
+	format  string // 自动生成的格式
+	workers int
+	jobs    chan job
+
+	locks sync.Map // 按 subject 串行生成，避免推送事件与手动生成重复写入
+}
+
+// NewService 创建 SBOM 服务
+func NewService(reg *registry.Registry, cfg *config.SBOMConfig) *Service {
+	format, err := sbom.ParseFormat(cfg.Format)
+	if err != nil {
+		log.Printf(`{"timestamp":"%s","level":"warn","module":"sbom","operation":"init","format":"%s","error":"%v"}`, time.Now().Format(time.RFC3339), cfg.Format, err)
+		format = sbom.FormatSPDX
+	}
+	s := &Service{
+		reg:     reg,
+		enabled: cfg.Enabled,
+		format:  format,
+		workers: cfg.Workers,
+		jobs:    make(chan job, queueSize),
+	}
+	if s.workers <= 0 {
+		s.workers = DefaultWorkers
+	}
+	return s
+}
+
+// Format 默认生成格式
+func (s *Service) Format() string {
+	return s.format
+}
+
+// lock 获取 key 对应的互斥锁，返回解锁函数
+func (s *Service) lock(key string) func() {
+	v, _ := s.locks.LoadOrStore(key, &sync.Mutex{})
+	mu := v.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// Generate 为仓库中的 tag 或 digest 生成 SBOM
+// 多架构索引为每个平台的子 Manifest 分别生成；已存在同格式的生成结果时跳过，replace 为 true 时重新生成并替换。
+func (s *Service) Generate(ctx context.Context, repository, reference, format string, replace bool) ([]*Document, error) {
+	format, err := sbom.ParseFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	raw, digest, err := s.reg.GetManifestRaw(ctx, repository, reference)
+	if err != nil {
+		return nil, err
+	}
+
+	if registry.IsImageManifest(raw) {
+		doc, err := s.generateImage(ctx, repository, subjectOf(raw, digest), "", format, replace)
+		if err != nil {
+			return nil, err
+		}
+		return []*Document{doc}, nil
+	}
+	if !registry.IsIndexManifest(raw) {
+		return nil, ErrNotImage
+	}
+
+	platforms, err := registry.IndexPlatforms(raw)
+	if err != nil {
+		return nil, err
+	}
+	var docs []*Document
+	for _, child := range platforms {
+		childRaw, childDigest, err := s.reg.GetManifestRaw(ctx, repository, child.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", child.Platform, err)
+		}
+		if !registry.IsImageManifest(childRaw) {
+			continue
+		}
+		doc, err := s.generateImage(ctx, repository, subjectOf(childRaw, childDigest), child.Platform, format, replace)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", child.Platform, err)
+		}
+		docs = append(docs, doc)
+	}
+	if len(docs) == 0 {
+		return nil, ErrNotImage
+	}
+	return docs, nil
+}
+
+// generateImage 为单个镜像 Manifest 生成 SBOM 并作为 referrer 存储
+func (s *Service) generateImage(ctx context.Context, repository string, subject registry.Descriptor, platform, format string, replace bool) (*Document, error) {
+	defer s.lock(repository + "@" + subject.Digest)()
+
+	existing, err := s.list(ctx, repository, subject.Digest, format, SourceGenerated)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) > 0 && !replace {
+		return existing[0], nil
+	}
+
+	raw, _, err := s.reg.GetManifestRaw(ctx, repository, subject.Digest)
+	if err != nil {
+		return nil, err
+	}
+	var manifest registry.Manifest
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return nil, fmt.Errorf("parse manifest: %w", err)
+	}
+	layers := make([]string, 0, len(manifest.Layers))
+	for _, layer := range manifest.Layers {
+		// 外部层（Windows 基础镜像等）不在仓库中
+		if len(layer.URLs) > 0 {
+			continue
+		}
+		layers = append(layers, layer.Digest)
+	}
+
+	started := time.Now()
+	inv, err := sbom.Catalog(ctx, layers, func(ctx context.Context, digest string) (io.ReadCloser, error) {
+		return s.reg.OpenLayer(ctx, repository, digest)
+	})
+	if err != nil {
+		return nil, err
+	}
+	data, err := sbom.Encode(format, sbom.Image{Repository: repository, Digest: subject.Digest, Platform: platform}, inv, Generator, started)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := s.attach(ctx, repository, subject, format, data, SourceGenerated, platform)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf(`{"timestamp":"%s","level":"info","module":"sbom","operation":"generate","repository":"%s","digest":"%s","platform":"%s","format":"%s","packages":%d,"duration_ms":%d}`, time.Now().Format(time.RFC3339), repository, subject.Digest, platform, format, len(inv.Packages), time.Since(started).Milliseconds())
+
+	// 新文档写入后再删除旧的生成结果，避免中途失败时镜像没有 SBOM
+	for _, old := range existing {
+		if err := s.reg.DeleteManifest(ctx, repository, old.Digest); err != nil {
+			log.Printf(`{"timestamp":"%s","level":"warn","module":"sbom","operation":"replace","repository":"%s","digest":"%s","error":"%v"}`, time.Now().Format(time.RFC3339), repository, old.Digest, err)
+		}
+	}
+	return doc, nil
+}
+
+// Upload 将第三方生成的 SBOM（SPDX 或 CycloneDX JSON）关联到仓库中的 tag 或 digest
+func (s *Service) Upload(ctx context.Context, repository, reference string, data []byte) (*Document, error) {
+	format, err := sbom.DetectFormat(data)
+	if err != nil {
+		return nil, err
+	}
+	raw, digest, err := s.reg.GetManifestRaw(ctx, repository, reference)
+	if err != nil {
+		return nil, err
+	}
+	if !registry.IsImageManifest(raw) && !registry.IsIndexManifest(raw) {
+		return nil, ErrNotImage
+	}
+	return s.attach(ctx, repository, subjectOf(raw, digest), format, data, SourceUploaded, "")
+}
+
+// Get 返回 tag 或 digest 的 SBOM 及其内容
+// format、source 为空表示不限；存在多份时返回最新的一份。多架构镜像优先使用关联到索引本身的 SBOM（通常是上传的），
+// 否则按 platform 选择子 Manifest 的 SBOM，只有一个平台时可省略 platform。
+func (s *Service) Get(ctx context.Context, repository, reference, format, source, platform string) (*Document, []byte, error) {
+	if format != "" {
+		f, err := sbom.ParseFormat(format)
+		if err != nil {
+			return nil, nil, err
+		}
+		format = f
+	}
+	raw, digest, err := s.reg.GetManifestRaw(ctx, repository, reference)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	docs, err := s.list(ctx, repository, digest, format, source)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(docs) == 0 && registry.IsIndexManifest(raw) {
+		child, err := registry.SelectIndexPlatform(raw, platform)
+		if errors.Is(err, registry.ErrManifestNotFound) {
+			return nil, nil, fmt.Errorf("%w: platform %s", ErrSBOMNotFound, platform)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if docs, err = s.list(ctx, repository, child, format, source); err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(docs) == 0 {
+		return nil, nil, ErrSBOMNotFound
+	}
+
+	data, err := s.content(ctx, repository, docs[0].Digest)
+	if err != nil {
+		return nil, nil, err
+	}
+	return docs[0], data, nil
+}
+
+// List 列出 tag 或 digest（不含多架构索引的子 Manifest）关联的全部 SBOM，最新的在前
+func (s *Service) List(ctx context.Context, repository, reference string) ([]*Document, error) {
+	_, digest, err := s.reg.GetManifestRaw(ctx, repository, reference)
+	if err != nil {
+		return nil, err
+	}
+	return s.list(ctx, repository, digest, "", "")
+}
+
+// list 从 referrers 索引中筛选 subject 的 SBOM，最新的在前
+func (s *Service) list(ctx context.Context, repository, subject, format, source string) ([]*Document, error) {
+	referrers, err := s.reg.ManifestReferrers(ctx, repository, subject)
+	if err != nil {
+		return nil, err
+	}
+	var docs []*Document
+	for _, desc := range referrers {
+		f := sbom.FormatOf(desc.ArtifactType)
+		if f == "" || (format != "" && f != format) {
+			continue
+		}
+		doc := documentOf(desc, subject, f)
+		if source != "" && doc.Source != source {
+			continue
+		}
+		docs = append(docs, doc)
+	}
+	// 时间相同时后登记的在前
+	for i, j := 0, len(docs)-1; i < j; i, j = i+1, j-1 {
+		docs[i], docs[j] = docs[j], docs[i]
+	}
+	sort.SliceStable(docs, func(i, j int) bool {
+		return docs[i].CreatedAt.After(docs[j].CreatedAt)
+	})
+	return docs, nil
+}
+
+// documentOf 由 referrers 索引条目构造 Document（来源未知的 SBOM，例如客户端以 ORAS 推送的，视为上传）
+func documentOf(desc registry.Descriptor, subject, format string) *Document {
+	doc := &Document{
+		Digest:    desc.Digest,
+		Subject:   subject,
+		Format:    format,
+		MediaType: desc.ArtifactType,
+		Source:    desc.Annotations[AnnotationSource],
+		Platform:  desc.Annotations[AnnotationPlatform],
+		Size:      desc.Size,
+	}
+	if doc.Source == "" {
+		doc.Source = SourceUploaded
+	}
+	if t, err := time.Parse(time.RFC3339, desc.Annotations[AnnotationCreated]); err == nil {
+		doc.CreatedAt = t
+	}
+	return doc
+}
+
+// content 读取 SBOM artifact 中的文档（第一个 layer）
+func (s *Service) content(ctx context.Context, repository, digest string) ([]byte, error) {
+	raw, _, err := s.reg.GetManifestRaw(ctx, repository, digest)
+	if err != nil {
+		return nil, err
+	}
+	var manifest registry.Manifest
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return nil, fmt.Errorf("parse sbom manifest: %w", err)
+	}
+	if len(manifest.Layers) == 0 {
+		return nil, fmt.Errorf("sbom manifest %s has no layers", digest)
+	}
+	reader, _, err := s.reg.GetBlob(ctx, repository, manifest.Layers[0].Digest)
+	if err != nil {
+		return nil, err
+	}
+	if closer, ok := reader.(io.Closer); ok {
+		defer closer.Close()
+	}
+	return io.ReadAll(reader)
+}
+
+// attach 将 SBOM 文档写入仓库：空 config、文档 blob 与 subject 指向镜像的 artifact Manifest
+func (s *Service) attach(ctx context.Context, repository string, subject registry.Descriptor, format string, data []byte, source, platform string) (*Document, error) {
+	emptyConfig := []byte("{}")
+	configDigest, configSize, err := registry.CalculateDigest(bytes.NewReader(emptyConfig))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.reg.PutBlob(ctx, repository, configDigest, configSize, bytes.NewReader(emptyConfig)); err != nil {
+		return nil, fmt.Errorf("put config: %w", err)
+	}
+
+	mediaType := sbom.MediaType(format)
+	blobDigest, blobSize, err := registry.CalculateDigest(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.reg.PutBlob(ctx, repository, blobDigest, blobSize, bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("put sbom: %w", err)
+	}
+
+	created := time.Now().UTC()
+	annotations := map[string]string{
+		AnnotationCreated: created.Format(time.RFC3339),
+		AnnotationSource:  source,
+	}
+	if source == SourceGenerated {
+		annotations[AnnotationGenerator] = Generator
+	}
+	if platform != "" {
+		annotations[AnnotationPlatform] = platform
+	}
+	manifest := registry.Manifest{
+		SchemaVersion: 2,
+		MediaType:     registry.MediaTypeOCIManifest,
+		ArtifactType:  mediaType,
+		Config: registry.LayerInfo{Descriptor: registry.Descriptor{
+			MediaType: registry.MediaTypeOCIEmptyConfig,
+			Digest:    configDigest,
+			Size:      configSize,
+		}},
+		Layers: []registry.LayerInfo{{Descriptor: registry.Descriptor{
+			MediaType:   mediaType,
+			Digest:      blobDigest,
+			Size:        blobSize,
+			Annotations: map[string]string{AnnotationTitle: "sbom." + format + ".json"},
+		}}},
+		Subject:     &subject,
+		Annotations: annotations,
+	}
+	raw, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	digest, size, err := registry.CalculateDigest(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.reg.PutManifestRaw(ctx, repository, digest, raw, registry.MediaTypeOCIManifest); err != nil {
+		return nil, fmt.Errorf("put sbom manifest: %w", err)
+	}
+
+	return &Document{
+		Digest:    digest,
+		Subject:   subject.Digest,
+		Format:    format,
+		MediaType: mediaType,
+		Source:    source,
+		Platform:  platform,
+		Size:      size,
+		CreatedAt: created,
+	}, nil
+}
+
+// subjectOf 构造指向 Manifest 的 subject 描述符
+func subjectOf(raw []byte, digest string) registry.Descriptor {
+	return registry.Descriptor{
+		MediaType: registry.ManifestMediaType(raw),
+		Digest:    digest,
+		Size:      int64(len(raw)),
+	}
+}
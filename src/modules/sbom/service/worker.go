@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/cyp-registry/registry/src/modules/webhook"
+)
+
+// Start 启动生成 worker 并监听推送事件（阻塞直到 ctx 结束，通常以 goroutine 方式调用）
+// 未启用自动生成时直接返回，手动生成与上传不受影响。
+func (s *Service) Start(ctx context.Context) {
+	if !s.enabled {
+		return
+	}
+
+	for i := 0; i < s.workers; i++ {
+		go s.work(ctx)
+	}
+
+	events, unsubscribe := webhook.SubscribeRegistryEvents()
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if event.Type == webhook.RegistryEventPush {
+				s.handlePushEvent(event)
+			}
+		}
+	}
+}
+
+// handlePushEvent 推送 tag 后排队生成 SBOM
+// 按 digest 推送（多架构镜像的子 Manifest、SBOM/签名等 artifact）不触发，随后推送的索引 tag 会覆盖各平台。
+func (s *Service) handlePushEvent(event webhook.RegistryEvent) {
+	if event.Tag == "" || strings.Contains(event.Tag, ":") {
+		return
+	}
+	select {
+	case s.jobs <- job{repository: event.Repository, tag: event.Tag}:
+	default:
+		log.Printf(`{"timestamp":"%s","level":"warn","module":"sbom","operation":"push_event","repository":"%s","tag":"%s","message":"queue full, skipped"}`, time.Now().Format(time.RFC3339), event.Repository, event.Tag)
+	}
+}
+
+// work 依次执行生成任务
+func (s *Service) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case j := <-s.jobs:
+			_, err := s.Generate(ctx, j.repository, j.tag, s.format, false)
+			if err != nil && !errors.Is(err, ErrNotImage) {
+				log.Printf(`{"timestamp":"%s","level":"warn","module":"sbom","operation":"generate","repository":"%s","tag":"%s","error":"%v"}`, time.Now().Format(time.RFC3339), j.repository, j.tag, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,237 @@
+// Package controller 提供漏洞扫描相关的HTTP接口
+package controller
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/cyp-registry/registry/src/middleware"
+	projectservice "github.com/cyp-registry/registry/src/modules/project/service"
+	"github.com/cyp-registry/registry/src/modules/registry"
+	"github.com/cyp-registry/registry/src/modules/scanner/dto"
+	"github.com/cyp-registry/registry/src/modules/scanner/models"
+	"github.com/cyp-registry/registry/src/modules/scanner/service"
+	"github.com/cyp-registry/registry/src/pkg/audit"
+	"github.com/cyp-registry/registry/src/pkg/response"
+)
+
+// syncWaitTimeout 同步模式下手动扫描接口最长等待时间，超时后返回当前状态的报告
+const syncWaitTimeout = 2 * time.Minute
+
+// ScannerController 漏洞扫描控制器
+// 路由前缀：/api/v1/projects/:id/scans
+type ScannerController struct {
+	svc        *service.Service
+	projectSvc projectservice.Service
+	async      bool // 为 false 时手动扫描接口等待扫描结束
+}
+
+// NewScannerController 创建控制器
+func NewScannerController(svc *service.Service, projectSvc projectservice.Service, async bool) *ScannerController {
+	return &ScannerController{
+		svc:        svc,
+		projectSvc: projectSvc,
+		async:      async,
+	}
+}
+
+// Scan 手动触发扫描
+// POST /api/v1/projects/:id/scans
+// reference 为空时扫描镜像的全部 tag；需要项目推送权限。
+func (c *ScannerController) Scan(ctx *gin.Context) {
+	var req dto.ScanRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.ParamError(ctx, "请求参数不合法")
+		return
+	}
+	image := strings.Trim(strings.TrimSpace(req.Image), "/")
+	if image == "" {
+		response.ParamError(ctx, "image 不能为空")
+		return
+	}
+	if !c.svc.Enabled() {
+		response.ServiceUnavailable(ctx, "未启用漏洞扫描")
+		return
+	}
+
+	projectID := ctx.Param("id")
+	repository, ok := c.resolve(ctx, projectID, image, "push")
+	if !ok {
+		return
+	}
+
+	references := []string{req.Reference}
+	if req.Reference == "" {
+		tags, err := c.svc.ListTags(ctx.Request.Context(), repository)
+		if err != nil {
+			response.InternalServerError(ctx, "获取镜像标签失败")
+			return
+		}
+		if len(tags) == 0 {
+			response.NotFound(ctx, "镜像不存在")
+			return
+		}
+		references = tags
+	}
+
+	userID := currentUserID(ctx)
+	resp := dto.ScanResponse{Reports: make([]*models.Report, 0, len(references))}
+	for _, ref := range references {
+		report, err := c.svc.ScanImage(ctx.Request.Context(), projectID, repository, ref, models.TriggerManual, userID)
+		if err != nil {
+			// 指定 reference 时直接返回错误；扫描全部 tag 时跳过无法扫描的 tag
+			if req.Reference != "" {
+				c.fail(ctx, err)
+				return
+			}
+			resp.Failed = append(resp.Failed, dto.ScanFailure{Reference: ref, Error: failMessage(err)})
+			continue
+		}
+		resp.Reports = append(resp.Reports, report)
+	}
+
+	if !c.async {
+		waitCtx, cancel := context.WithTimeout(ctx.Request.Context(), syncWaitTimeout)
+		for i, report := range resp.Reports {
+			if finished, err := c.svc.WaitReport(waitCtx, report.Digest); err == nil {
+				resp.Reports[i] = finished
+			}
+		}
+		cancel()
+	}
+
+	c.record(ctx, "trigger_scan", map[string]interface{}{
+		"project_id": projectID,
+		"repository": repository,
+		"reference":  req.Reference,
+		"queued":     len(resp.Reports),
+	})
+	response.Success(ctx, resp)
+}
+
+// ListTagReports 列出镜像各 tag 的扫描摘要
+// GET /api/v1/projects/:id/scans?image=<name>
+func (c *ScannerController) ListTagReports(ctx *gin.Context) {
+	image := strings.Trim(strings.TrimSpace(ctx.Query("image")), "/")
+	if image == "" {
+		response.ParamError(ctx, "image 不能为空")
+		return
+	}
+	repository, ok := c.resolve(ctx, ctx.Param("id"), image, "pull")
+	if !ok {
+		return
+	}
+
+	items, err := c.svc.ListTagReports(ctx.Request.Context(), repository)
+	if err != nil {
+		response.InternalServerError(ctx, "获取扫描报告失败")
+		return
+	}
+	response.Success(ctx, dto.FromTagReportSlice(items))
+}
+
+// GetReport 获取 tag 或 digest 的完整扫描报告
+// GET /api/v1/projects/:id/scans/report?image=<name>&reference=<tag|digest>
+func (c *ScannerController) GetReport(ctx *gin.Context) {
+	image := strings.Trim(strings.TrimSpace(ctx.Query("image")), "/")
+	reference := strings.TrimSpace(ctx.Query("reference"))
+	if image == "" || reference == "" {
+		response.ParamError(ctx, "image 与 reference 不能为空")
+		return
+	}
+	repository, ok := c.resolve(ctx, ctx.Param("id"), image, "pull")
+	if !ok {
+		return
+	}
+
+	report, err := c.svc.GetReport(ctx.Request.Context(), repository, reference)
+	if err != nil {
+		c.fail(ctx, err)
+		return
+	}
+	response.Success(ctx, report)
+}
+
+// resolve 校验项目权限并返回完整仓库名（项目名/镜像名），失败时已写入响应
+func (c *ScannerController) resolve(ctx *gin.Context, projectID, image, action string) (string, bool) {
+	userID := currentUserID(ctx)
+	if userID == "" {
+		response.Unauthorized(ctx, "user not authenticated")
+		return "", false
+	}
+
+	project, err := c.projectSvc.GetProject(ctx.Request.Context(), projectID)
+	if err != nil {
+		if errors.Is(err, projectservice.ErrProjectNotFound) {
+			response.NotFound(ctx, "项目不存在")
+			return "", false
+		}
+		response.InternalServerError(ctx, "获取项目失败")
+		return "", false
+	}
+
+	canAccess, err := c.projectSvc.CanAccess(ctx.Request.Context(), userID, projectID, action)
+	if err != nil {
+		response.InternalServerError(ctx, "failed to check permission")
+		return "", false
+	}
+	if !canAccess {
+		response.Forbidden(ctx, "permission denied")
+		return "", false
+	}
+	return project.Name + "/" + image, true
+}
+
+// fail 将服务层错误转换为HTTP响应
+func (c *ScannerController) fail(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrScannerDisabled):
+		response.ServiceUnavailable(ctx, "未启用漏洞扫描")
+	case errors.Is(err, service.ErrReportNotFound):
+		response.NotFound(ctx, "镜像尚未扫描")
+	case errors.Is(err, registry.ErrManifestNotFound):
+		response.NotFound(ctx, "镜像不存在")
+	case errors.Is(err, service.ErrNotScannable):
+		response.ParamError(ctx, failMessage(err))
+	default:
+		response.InternalServerError(ctx, err.Error())
+	}
+}
+
+// failMessage 扫描请求失败的原因
+func failMessage(err error) string {
+	switch {
+	case errors.Is(err, registry.ErrManifestNotFound):
+		return "镜像不存在"
+	case errors.Is(err, service.ErrNotScannable):
+		return "不是容器镜像（签名、SBOM 等 artifact 不支持扫描）"
+	default:
+		return err.Error()
+	}
+}
+
+// record 记录审计日志
+func (c *ScannerController) record(ctx *gin.Context, action string, details map[string]interface{}) {
+	var userID *uuid.UUID
+	if v, exists := ctx.Get(middleware.ContextKeyUserID); exists {
+		if id, ok := v.(uuid.UUID); ok && id != uuid.Nil {
+			userID = &id
+		}
+	}
+	audit.Record(ctx.Request.Context(), action, "image", nil, userID, ctx.ClientIP(), ctx.Request.UserAgent(), details)
+}
+
+// currentUserID 获取当前用户ID字符串（未登录时为空）
+func currentUserID(ctx *gin.Context) string {
+	if v, exists := ctx.Get(middleware.ContextKeyUserID); exists {
+		if id, ok := v.(uuid.UUID); ok && id != uuid.Nil {
+			return id.String()
+		}
+	}
+	return ""
+}
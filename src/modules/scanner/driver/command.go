@@ -0,0 +1,150 @@
+// Package driver 漏洞扫描器实现
+package driver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/cyp-registry/registry/src/modules/scanner"
+)
+
+// ArchivePlaceholder 命令参数中的占位符，替换为待扫描镜像的 OCI 归档路径
+const ArchivePlaceholder = "{archive}"
+
+// DefaultTrivyArgs 调用 Trivy 扫描 OCI 归档的默认参数
+var DefaultTrivyArgs = []string{"image", "--input", ArchivePlaceholder, "--format", "json", "--quiet"}
+
+// maxStderr 错误信息中保留的标准错误输出长度
+const maxStderr = 2048
+
+// CommandScanner 调用本地扫描器命令，解析其 JSON 输出
+// 输出格式为 Trivy 的 JSON 报告（`trivy image --format json`），兼容该格式的其他扫描器也可使用。
+type CommandScanner struct {
+	name    string
+	command string
+	args    []string
+
+	versionOnce sync.Once
+	version     string
+	versionErr  error
+}
+
+// NewCommandScanner 创建命令扫描器
+// args 中的 {archive} 替换为待扫描的归档路径；args 为空时使用 DefaultTrivyArgs。
+func NewCommandScanner(name, command string, args []string) (*CommandScanner, error) {
+	if command == "" {
+		return nil, errors.New("scanner: command is required")
+	}
+	path, err := exec.LookPath(command)
+	if err != nil {
+		return nil, fmt.Errorf("scanner: %w", err)
+	}
+	if len(args) == 0 {
+		args = DefaultTrivyArgs
+	}
+	hasArchive := false
+	for _, arg := range args {
+		if strings.Contains(arg, ArchivePlaceholder) {
+			hasArchive = true
+		}
+	}
+	if !hasArchive {
+		return nil, fmt.Errorf("scanner: args must contain %s", ArchivePlaceholder)
+	}
+	return &CommandScanner{name: name, command: path, args: args}, nil
+}
+
+// Name 实现 scanner.Scanner
+func (s *CommandScanner) Name() string {
+	return s.name
+}
+
+// Version 实现 scanner.Scanner，取 `<command> --version` 输出的第一行（只执行一次）
+func (s *CommandScanner) Version(ctx context.Context) (string, error) {
+	s.versionOnce.Do(func() {
+		out, err := exec.CommandContext(ctx, s.command, "--version").Output()
+		if err != nil {
+			s.versionErr = err
+			return
+		}
+		line, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+		s.version = strings.TrimSpace(strings.TrimPrefix(line, "Version:"))
+	})
+	return s.version, s.versionErr
+}
+
+// Scan 实现 scanner.Scanner
+func (s *CommandScanner) Scan(ctx context.Context, target *scanner.Target) (*scanner.Result, error) {
+	args := make([]string, len(s.args))
+	for i, arg := range s.args {
+		args[i] = strings.ReplaceAll(arg, ArchivePlaceholder, target.ArchivePath)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, s.command, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		msg := strings.TrimSpace(stderr.String())
+		if len(msg) > maxStderr {
+			msg = msg[len(msg)-maxStderr:]
+		}
+		return nil, fmt.Errorf("%s: %w: %s", s.name, err, msg)
+	}
+
+	vulns, err := ParseTrivyReport(stdout.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", s.name, err)
+	}
+	version, _ := s.Version(ctx)
+	return &scanner.Result{Scanner: s.name, ScannerVersion: version, Vulnerabilities: vulns}, nil
+}
+
+// trivyReport Trivy JSON 报告中用到的字段
+type trivyReport struct {
+	Results []struct {
+		Target          string `json:"Target"`
+		Vulnerabilities []struct {
+			VulnerabilityID  string `json:"VulnerabilityID"`
+			PkgName          string `json:"PkgName"`
+			InstalledVersion string `json:"InstalledVersion"`
+			FixedVersion     string `json:"FixedVersion"`
+			Severity         string `json:"Severity"`
+			Title            string `json:"Title"`
+			PrimaryURL       string `json:"PrimaryURL"`
+		} `json:"Vulnerabilities"`
+	} `json:"Results"`
+}
+
+// ParseTrivyReport 解析 Trivy JSON 报告
+func ParseTrivyReport(data []byte) ([]scanner.Vulnerability, error) {
+	var report trivyReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("parse report: %w", err)
+	}
+	var vulns []scanner.Vulnerability
+	for _, result := range report.Results {
+		for _, v := range result.Vulnerabilities {
+			vulns = append(vulns, scanner.Vulnerability{
+				ID:               v.VulnerabilityID,
+				Package:          v.PkgName,
+				InstalledVersion: v.InstalledVersion,
+				FixedVersion:     v.FixedVersion,
+				Severity:         scanner.NormalizeSeverity(v.Severity),
+				Title:            v.Title,
+				URL:              v.PrimaryURL,
+				Target:           result.Target,
+			})
+		}
+	}
+	return scanner.Merge(vulns), nil
+}
@@ -0,0 +1,82 @@
+// Package driver 漏洞扫描器实现
+package driver
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cyp-registry/registry/src/modules/scanner"
+)
+
+// FakeScanner 不实际扫描的扫描器，用于测试与未安装扫描器的开发环境
+// 按 digest 返回预设的漏洞（未预设时返回 Default），并记录扫描过的 digest。
+type FakeScanner struct {
+	mu      sync.Mutex
+	results map[string][]scanner.Vulnerability
+	errs    map[string]error
+	scanned []string
+
+	// Default 未按 digest 预设时返回的漏洞
+	Default []scanner.Vulnerability
+}
+
+// NewFakeScanner 创建假扫描器（默认不报告任何漏洞）
+func NewFakeScanner() *FakeScanner {
+	return &FakeScanner{
+		results: make(map[string][]scanner.Vulnerability),
+		errs:    make(map[string]error),
+	}
+}
+
+// SetResult 预设 digest 的扫描结果
+func (s *FakeScanner) SetResult(digest string, vulns []scanner.Vulnerability) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results[digest] = vulns
+}
+
+// SetError 预设 digest 扫描失败
+func (s *FakeScanner) SetError(digest string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errs[digest] = err
+}
+
+// Scanned 返回扫描过的 digest（按扫描顺序）
+func (s *FakeScanner) Scanned() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.scanned...)
+}
+
+// Name 实现 scanner.Scanner
+func (s *FakeScanner) Name() string {
+	return "fake"
+}
+
+// Version 实现 scanner.Scanner
+func (s *FakeScanner) Version(ctx context.Context) (string, error) {
+	return "0.0.0", nil
+}
+
+// Scan 实现 scanner.Scanner
+func (s *FakeScanner) Scan(ctx context.Context, target *scanner.Target) (*scanner.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scanned = append(s.scanned, target.Digest)
+	if err := s.errs[target.Digest]; err != nil {
+		return nil, err
+	}
+	vulns, ok := s.results[target.Digest]
+	if !ok {
+		vulns = s.Default
+	}
+	return &scanner.Result{
+		Scanner:         s.Name(),
+		ScannerVersion:  "0.0.0",
+		Vulnerabilities: scanner.Merge(vulns),
+	}, nil
+}
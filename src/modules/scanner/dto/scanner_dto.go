@@ -0,0 +1,75 @@
+// Package dto 定义漏洞扫描相关的请求与响应结构体
+package dto
+
+import (
+	"time"
+
+	"github.com/cyp-registry/registry/src/modules/scanner/models"
+	"github.com/cyp-registry/registry/src/modules/scanner/service"
+)
+
+// ScanRequest 手动触发扫描请求体
+type ScanRequest struct {
+	Image     string `json:"image"`               // 项目内的镜像名称，例如 app 或 team/app
+	Reference string `json:"reference,omitempty"` // tag 或 digest，为空表示扫描全部 tag
+}
+
+// ScanResponse 手动触发扫描的结果（每个 tag/digest 一份报告）
+type ScanResponse struct {
+	Reports []*models.Report `json:"reports"`
+	Failed  []ScanFailure    `json:"failed,omitempty"` // 无法加入扫描队列的 tag，例如签名等非镜像 artifact
+}
+
+// ScanFailure 无法扫描的 tag 及原因
+type ScanFailure struct {
+	Reference string `json:"reference"`
+	Error     string `json:"error"`
+}
+
+// TagReportResponse tag 扫描摘要（不含漏洞明细）
+type TagReportResponse struct {
+	Tag         string     `json:"tag"`
+	Digest      string     `json:"digest"`
+	Status      string     `json:"status"` // 尚未扫描时为 not_scanned
+	Scanner     string     `json:"scanner,omitempty"`
+	Critical    int        `json:"critical"`
+	High        int        `json:"high"`
+	Medium      int        `json:"medium"`
+	Low         int        `json:"low"`
+	Unknown     int        `json:"unknown"`
+	Error       string     `json:"error,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// StatusNotScanned tag 当前 digest 尚未扫描
+const StatusNotScanned = "not_scanned"
+
+// FromTagReport 转换为 tag 扫描摘要
+func FromTagReport(item service.TagReport) TagReportResponse {
+	resp := TagReportResponse{
+		Tag:    item.Tag,
+		Digest: item.Digest,
+		Status: StatusNotScanned,
+	}
+	if r := item.Report; r != nil {
+		resp.Status = r.Status
+		resp.Scanner = r.Scanner
+		resp.Critical = r.Critical
+		resp.High = r.High
+		resp.Medium = r.Medium
+		resp.Low = r.Low
+		resp.Unknown = r.Unknown
+		resp.Error = r.Error
+		resp.CompletedAt = r.CompletedAt
+	}
+	return resp
+}
+
+// FromTagReportSlice 批量转换
+func FromTagReportSlice(items []service.TagReport) []TagReportResponse {
+	out := make([]TagReportResponse, 0, len(items))
+	for _, item := range items {
+		out = append(out, FromTagReport(item))
+	}
+	return out
+}
@@ -0,0 +1,49 @@
+// Package factory 扫描器工厂模块
+// 根据配置创建相应的扫描器实例
+package factory
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cyp-registry/registry/src/modules/scanner"
+	"github.com/cyp-registry/registry/src/modules/scanner/driver"
+	"github.com/cyp-registry/registry/src/pkg/config"
+)
+
+// ScannerType 扫描器类型
+type ScannerType string
+
+const (
+	// ScannerTypeTrivy 调用本地 Trivy（或输出兼容 JSON 的扫描器）命令
+	ScannerTypeTrivy ScannerType = "trivy"
+	// ScannerTypeFake 不实际扫描，用于测试
+	ScannerTypeFake ScannerType = "fake"
+)
+
+// ErrUnsupportedScanner 不支持的扫描器类型
+var ErrUnsupportedScanner = errors.New("factory: unsupported scanner type")
+
+// NewScanner 创建扫描器实例
+// 扫描器命令不存在时返回错误，调用方可据此禁用扫描。
+func NewScanner(cfg *config.ScannerConfig) (scanner.Scanner, error) {
+	scannerType := ScannerType(cfg.Type)
+	if scannerType == "" {
+		scannerType = ScannerTypeTrivy
+	}
+
+	switch scannerType {
+	case ScannerTypeTrivy:
+		command := cfg.Command
+		if command == "" {
+			command = "trivy"
+		}
+		return driver.NewCommandScanner(string(ScannerTypeTrivy), command, cfg.Args)
+
+	case ScannerTypeFake:
+		return driver.NewFakeScanner(), nil
+
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedScanner, scannerType)
+	}
+}
@@ -0,0 +1,99 @@
+// Package models 定义漏洞扫描的数据模型
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/cyp-registry/registry/src/modules/scanner"
+)
+
+// ReportStatus 扫描状态
+type ReportStatus string
+
+const (
+	// ReportPending 等待扫描
+	ReportPending ReportStatus = "pending"
+	// ReportRunning 正在扫描
+	ReportRunning ReportStatus = "running"
+	// ReportSuccess 扫描完成
+	ReportSuccess ReportStatus = "success"
+	// ReportFailed 扫描失败
+	ReportFailed ReportStatus = "failed"
+)
+
+// 触发方式
+const (
+	// TriggerPush 推送后自动扫描
+	TriggerPush = "push"
+	// TriggerManual 手动触发
+	TriggerManual = "manual"
+)
+
+// Report 按 Manifest digest 保存的最近一次扫描报告（重新扫描时覆盖）
+// 表名: scan_reports
+//
+// 多架构索引的报告为各平台子 Manifest 报告的合并结果，子 Manifest 各自另有报告。
+type Report struct {
+	ID         string `gorm:"type:varchar(36);primaryKey" json:"id"`
+	Digest     string `gorm:"type:varchar(128);uniqueIndex;not null;comment:Manifest摘要" json:"digest"`
+	MediaType  string `gorm:"type:varchar(255);comment:Manifest类型" json:"media_type"`
+	ProjectID  string `gorm:"type:varchar(36);index;comment:最近一次扫描所在项目" json:"project_id"`
+	Repository string `gorm:"type:varchar(512);not null;comment:最近一次扫描所在仓库" json:"repository"`
+	Tag        string `gorm:"type:varchar(128);comment:触发扫描的标签(可选)" json:"tag"`
+	Trigger    string `gorm:"type:varchar(16);not null;comment:push/manual" json:"trigger"`
+	Status     string `gorm:"type:varchar(32);index;not null;comment:扫描状态" json:"status"`
+
+	Scanner        string `gorm:"type:varchar(64);comment:扫描器" json:"scanner"`
+	ScannerVersion string `gorm:"type:varchar(128);comment:扫描器版本" json:"scanner_version"`
+	Critical       int    `gorm:"not null;default:0" json:"critical"`
+	High           int    `gorm:"not null;default:0" json:"high"`
+	Medium         int    `gorm:"not null;default:0" json:"medium"`
+	Low            int    `gorm:"not null;default:0" json:"low"`
+	Unknown        int    `gorm:"not null;default:0" json:"unknown"`
+	// Vulnerabilities 漏洞明细（只保留配置的严重级别）
+	Vulnerabilities []scanner.Vulnerability `gorm:"type:jsonb;serializer:json" json:"vulnerabilities,omitempty"`
+	Error           string                  `gorm:"type:text;comment:错误信息" json:"error,omitempty"`
+	RequestedBy     string                  `gorm:"type:varchar(36);comment:触发用户ID" json:"requested_by,omitempty"`
+
+	StartedAt   *time.Time `json:"started_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName 表名
+func (Report) TableName() string {
+	return "scan_reports"
+}
+
+// NewReport 创建等待扫描的报告
+func NewReport(digest, projectID, repository, tag, trigger, requestedBy string) *Report {
+	return &Report{
+		ID:          uuid.New().String(),
+		Digest:      digest,
+		ProjectID:   projectID,
+		Repository:  repository,
+		Tag:         tag,
+		Trigger:     trigger,
+		Status:      string(ReportPending),
+		RequestedBy: requestedBy,
+	}
+}
+
+// Summary 各严重级别的漏洞数
+func (r *Report) Summary() scanner.Summary {
+	return scanner.Summary{
+		Critical: r.Critical,
+		High:     r.High,
+		Medium:   r.Medium,
+		Low:      r.Low,
+		Unknown:  r.Unknown,
+	}
+}
+
+// Finished 扫描是否已结束（成功或失败）
+func (r *Report) Finished() bool {
+	return r.Status == string(ReportSuccess) || r.Status == string(ReportFailed)
+}
@@ -0,0 +1,150 @@
+// Package scanner 漏洞扫描模块
+// 定义扫描器接口，具体实现见 driver 包（本地扫描器命令、测试用的假扫描器），由 factory 包按配置创建。
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrPullDenied 镜像不满足项目的漏洞策略，拒绝拉取
+var ErrPullDenied = errors.New("scanner: pull denied by vulnerability policy")
+
+// PullPolicy 项目级漏洞拉取策略
+//
+// 启用后拉取 Manifest 时检查该 digest 最近一次扫描的结果：存在不低于 Severity 的漏洞（白名单中未过期的 CVE 除外），
+// 或尚未扫描完成（AllowUnscanned 为 false 时）则拒绝拉取。签名、SBOM 等非镜像 artifact 不受限制。
+type PullPolicy struct {
+	Enabled bool `json:"enabled"`
+	// Severity 阻止拉取的最低严重级别，为空表示 CRITICAL
+	Severity string `json:"severity,omitempty"`
+	// AllowUnscanned 是否允许拉取尚未扫描（或从未扫描成功）的镜像
+	AllowUnscanned bool `json:"allow_unscanned"`
+	// Allowlist 忽略的漏洞
+	Allowlist []AllowedCVE `json:"allowlist,omitempty"`
+}
+
+// AllowedCVE 漏洞白名单条目，ExpiresAt 为空表示长期有效
+type AllowedCVE struct {
+	ID        string     `json:"id"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Reason    string     `json:"reason,omitempty"`
+}
+
+// ValidatePullPolicy 校验策略
+func ValidatePullPolicy(p *PullPolicy) error {
+	if p == nil {
+		return nil
+	}
+	if p.Severity != "" && NormalizeSeverity(p.Severity) != strings.ToUpper(strings.TrimSpace(p.Severity)) {
+		return fmt.Errorf("severity: unknown severity %q", p.Severity)
+	}
+	seen := make(map[string]bool, len(p.Allowlist))
+	for _, entry := range p.Allowlist {
+		id := strings.TrimSpace(entry.ID)
+		if id == "" {
+			return fmt.Errorf("allowlist: empty id")
+		}
+		if seen[strings.ToUpper(id)] {
+			return fmt.Errorf("allowlist: duplicate id %q", id)
+		}
+		seen[strings.ToUpper(id)] = true
+	}
+	return nil
+}
+
+// Threshold 阻止拉取的最低严重级别
+func (p *PullPolicy) Threshold() string {
+	if p.Severity == "" {
+		return SeverityCritical
+	}
+	return NormalizeSeverity(p.Severity)
+}
+
+// Allowed 漏洞在 now 时是否在白名单中（已过期的条目不生效）
+func (p *PullPolicy) Allowed(id string, now time.Time) bool {
+	for _, entry := range p.Allowlist {
+		if !strings.EqualFold(strings.TrimSpace(entry.ID), id) {
+			continue
+		}
+		if entry.ExpiresAt == nil || now.Before(*entry.ExpiresAt) {
+			return true
+		}
+	}
+	return false
+}
+
+// Evaluate 返回扫描结果中违反策略的漏洞数与（有明细的）漏洞 ID
+// summary 为完整统计，vulns 可能只包含部分严重级别的明细；没有明细的漏洞无法匹配白名单，按违反策略计算。
+func (p *PullPolicy) Evaluate(summary Summary, vulns []Vulnerability, now time.Time) (int, []string) {
+	threshold := SeverityRank(p.Threshold())
+	counts := map[string]int{
+		SeverityCritical: summary.Critical,
+		SeverityHigh:     summary.High,
+		SeverityMedium:   summary.Medium,
+		SeverityLow:      summary.Low,
+		SeverityUnknown:  summary.Unknown,
+	}
+	total := 0
+	for severity, n := range counts {
+		if SeverityRank(severity) >= threshold {
+			total += n
+		}
+	}
+
+	var ids []string
+	seen := make(map[string]bool)
+	for _, v := range vulns {
+		if SeverityRank(v.Severity) < threshold {
+			continue
+		}
+		if p.Allowed(v.ID, now) {
+			total--
+			continue
+		}
+		if !seen[v.ID] {
+			seen[v.ID] = true
+			ids = append(ids, v.ID)
+		}
+	}
+	if total < 0 {
+		total = 0
+	}
+	return total, ids
+}
+
+// PullDeniedError 拒绝拉取的原因
+type PullDeniedError struct {
+	Repository string
+	Digest     string
+	Severity   string   // 策略的严重级别阈值
+	Status     string   // 扫描状态，尚未扫描时为 not_scanned
+	Count      int      // 违反策略的漏洞数，未扫描时为 0
+	IDs        []string // 违反策略的漏洞 ID（可能不完整）
+}
+
+// maxDeniedIDs 错误信息中最多列出的漏洞 ID 数
+const maxDeniedIDs = 5
+
+// Error 实现 error 接口
+func (e *PullDeniedError) Error() string {
+	if e.Count == 0 {
+		return fmt.Sprintf("image %s@%s has not been scanned (status: %s); pulls are blocked by the project vulnerability policy", e.Repository, e.Digest, e.Status)
+	}
+	msg := fmt.Sprintf("image %s@%s has %d vulnerabilities of severity %s or higher; pulls are blocked by the project vulnerability policy", e.Repository, e.Digest, e.Count, e.Severity)
+	if len(e.IDs) > 0 {
+		ids := e.IDs
+		if len(ids) > maxDeniedIDs {
+			ids = append(ids[:maxDeniedIDs:maxDeniedIDs], "...")
+		}
+		msg += " (" + strings.Join(ids, ", ") + ")"
+	}
+	return msg
+}
+
+// Unwrap 支持 errors.Is(err, ErrPullDenied)
+func (e *PullDeniedError) Unwrap() error {
+	return ErrPullDenied
+}
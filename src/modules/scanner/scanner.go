@@ -0,0 +1,154 @@
+// Package scanner 漏洞扫描模块
+// 定义扫描器接口，具体实现见 driver 包（本地扫描器命令、测试用的假扫描器），由 factory 包按配置创建。
+package scanner
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// 严重级别（与 Trivy 等扫描器的输出一致）
+const (
+	SeverityCritical = "CRITICAL"
+	SeverityHigh     = "HIGH"
+	SeverityMedium   = "MEDIUM"
+	SeverityLow      = "LOW"
+	SeverityUnknown  = "UNKNOWN"
+)
+
+// severityRank 严重级别排序，数值越大越严重
+var severityRank = map[string]int{
+	SeverityUnknown:  0,
+	SeverityLow:      1,
+	SeverityMedium:   2,
+	SeverityHigh:     3,
+	SeverityCritical: 4,
+}
+
+// NormalizeSeverity 统一为大写的严重级别，无法识别时返回 UNKNOWN
+func NormalizeSeverity(s string) string {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if _, ok := severityRank[s]; ok {
+		return s
+	}
+	return SeverityUnknown
+}
+
+// SeverityRank 返回严重级别的排序值（UNKNOWN 为 0，CRITICAL 为 4）
+func SeverityRank(s string) int {
+	return severityRank[NormalizeSeverity(s)]
+}
+
+// Target 待扫描的镜像
+// ArchivePath 为只包含该镜像（单个平台的 Manifest）的 OCI image layout tar 文件，扫描结束后由调用方删除。
+type Target struct {
+	Repository  string
+	Digest      string
+	MediaType   string
+	Platform    string // 例如 linux/amd64，未知时为空
+	ArchivePath string
+}
+
+// Vulnerability 单个漏洞
+type Vulnerability struct {
+	ID               string `json:"id"` // 例如 CVE-2024-3094
+	Package          string `json:"package"`
+	InstalledVersion string `json:"installed_version"`
+	FixedVersion     string `json:"fixed_version,omitempty"`
+	Severity         string `json:"severity"`
+	Title            string `json:"title,omitempty"`
+	URL              string `json:"url,omitempty"`
+	Target           string `json:"target,omitempty"` // 所在的扫描目标，例如 debian 12.5、app/package-lock.json
+}
+
+// Result 扫描结果
+type Result struct {
+	Scanner         string          `json:"scanner"`
+	ScannerVersion  string          `json:"scanner_version"`
+	Vulnerabilities []Vulnerability `json:"vulnerabilities"`
+}
+
+// Scanner 漏洞扫描器
+type Scanner interface {
+	// Name 扫描器名称，记录在报告中
+	Name() string
+	// Version 扫描器（及漏洞库）版本，记录在报告中
+	Version(ctx context.Context) (string, error)
+	// Scan 扫描镜像；ctx 结束时应尽快返回
+	Scan(ctx context.Context, target *Target) (*Result, error)
+}
+
+// Summary 按严重级别统计的漏洞数
+type Summary struct {
+	Critical int `json:"critical"`
+	High     int `json:"high"`
+	Medium   int `json:"medium"`
+	Low      int `json:"low"`
+	Unknown  int `json:"unknown"`
+}
+
+// Summarize 统计各严重级别的漏洞数
+func Summarize(vulns []Vulnerability) Summary {
+	var s Summary
+	for _, v := range vulns {
+		switch NormalizeSeverity(v.Severity) {
+		case SeverityCritical:
+			s.Critical++
+		case SeverityHigh:
+			s.High++
+		case SeverityMedium:
+			s.Medium++
+		case SeverityLow:
+			s.Low++
+		default:
+			s.Unknown++
+		}
+	}
+	return s
+}
+
+// Highest 返回出现的最高严重级别，没有漏洞时返回空字符串
+func (s Summary) Highest() string {
+	switch {
+	case s.Critical > 0:
+		return SeverityCritical
+	case s.High > 0:
+		return SeverityHigh
+	case s.Medium > 0:
+		return SeverityMedium
+	case s.Low > 0:
+		return SeverityLow
+	case s.Unknown > 0:
+		return SeverityUnknown
+	default:
+		return ""
+	}
+}
+
+// Merge 合并多个结果中的漏洞（同一软件包版本的同一漏洞只保留一条），按严重级别从高到低排序
+func Merge(lists ...[]Vulnerability) []Vulnerability {
+	seen := make(map[string]bool)
+	merged := make([]Vulnerability, 0)
+	for _, list := range lists {
+		for _, v := range list {
+			key := v.ID + "|" + v.Package + "|" + v.InstalledVersion
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			v.Severity = NormalizeSeverity(v.Severity)
+			merged = append(merged, v)
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		if ri, rj := SeverityRank(merged[i].Severity), SeverityRank(merged[j].Severity); ri != rj {
+			return ri > rj
+		}
+		if merged[i].ID != merged[j].ID {
+			return merged[i].ID < merged[j].ID
+		}
+		return merged[i].Package < merged[j].Package
+	})
+	return merged
+}
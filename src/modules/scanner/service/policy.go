@@ -0,0 +1,87 @@
+// Package service 实现漏洞扫描任务的排队、执行与报告管理
+// 推送 tag 后自动扫描，也可手动触发；报告按 Manifest digest 保存，同一 digest 在多个 tag/仓库间共享。
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/cyp-registry/registry/src/modules/scanner"
+	"github.com/cyp-registry/registry/src/modules/scanner/models"
+)
+
+// statusNotScanned digest 没有扫描报告
+const statusNotScanned = "not_scanned"
+
+// PolicyProvider 提供项目级漏洞拉取策略（由项目模块实现）
+// projectName 为仓库名的第一段；项目未配置时返回 nil, nil 使用全局默认策略。
+type PolicyProvider interface {
+	PullPolicy(ctx context.Context, projectName string) (*scanner.PullPolicy, error)
+}
+
+// SetPolicyProvider 设置项目漏洞拉取策略来源；未设置时所有项目使用全局默认策略
+func (s *Service) SetPolicyProvider(p PolicyProvider) {
+	s.policy = p
+}
+
+// CheckPull 按仓库所属项目的漏洞策略检查是否允许拉取 Manifest
+// 不满足策略时返回 *scanner.PullDeniedError；manifest 为原始内容，用于跳过签名、SBOM 等非镜像 artifact。
+func (s *Service) CheckPull(ctx context.Context, repository, digest string, manifest []byte) error {
+	policy, err := s.pullPolicy(ctx, repository)
+	if err != nil {
+		return err
+	}
+	if policy == nil || !policy.Enabled || !scannable(manifest) {
+		return nil
+	}
+
+	report, err := s.GetReportByDigest(ctx, digest)
+	if err != nil && !errors.Is(err, ErrReportNotFound) {
+		return err
+	}
+	denied := &scanner.PullDeniedError{
+		Repository: repository,
+		Digest:     digest,
+		Severity:   policy.Threshold(),
+		Status:     statusNotScanned,
+	}
+	if report == nil || !hasResult(report) {
+		if policy.AllowUnscanned {
+			return nil
+		}
+		if report != nil {
+			denied.Status = report.Status
+		}
+		return denied
+	}
+
+	denied.Status = report.Status
+	denied.Count, denied.IDs = policy.Evaluate(report.Summary(), report.Vulnerabilities, time.Now())
+	if denied.Count > 0 {
+		return denied
+	}
+	return nil
+}
+
+// pullPolicy 获取仓库所属项目的漏洞拉取策略，项目未配置时使用全局默认策略
+func (s *Service) pullPolicy(ctx context.Context, repository string) (*scanner.PullPolicy, error) {
+	if s.policy != nil {
+		projectName, _, _ := strings.Cut(repository, "/")
+		policy, err := s.policy.PullPolicy(ctx, projectName)
+		if err != nil {
+			return nil, err
+		}
+		if policy != nil {
+			return policy, nil
+		}
+	}
+	return s.defaultPolicy, nil
+}
+
+// hasResult 报告中是否有扫描结果
+// 重新扫描（排队、执行中或失败）时保留上一次成功扫描的结果，以扫描器名称是否已写入判断。
+func hasResult(report *models.Report) bool {
+	return report.Status == string(models.ReportSuccess) || report.Scanner != ""
+}
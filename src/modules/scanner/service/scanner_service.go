@@ -0,0 +1,276 @@
+// Package service 实现漏洞扫描任务的排队、执行与报告管理
+// 推送 tag 后自动扫描，也可手动触发；报告按 Manifest digest 保存，同一 digest 在多个 tag/仓库间共享。
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/cyp-registry/registry/src/modules/registry"
+	"github.com/cyp-registry/registry/src/modules/scanner"
+	"github.com/cyp-registry/registry/src/modules/scanner/models"
+	"github.com/cyp-registry/registry/src/pkg/config"
+	"github.com/cyp-registry/registry/src/pkg/database"
+)
+
+// 扫描并发与超时的默认值
+const (
+	// DefaultWorkers 同时执行的扫描任务数
+	DefaultWorkers = 1
+	// DefaultTimeout 单个镜像的扫描超时时间
+	DefaultTimeout = 10 * time.Minute
+)
+
+var (
+	// ErrScannerDisabled 未启用漏洞扫描
+	ErrScannerDisabled = errors.New("scanner: scanning is disabled")
+	// ErrReportNotFound 镜像尚未扫描
+	ErrReportNotFound = errors.New("scanner: report not found")
+	// ErrNotScannable 不是容器镜像（例如签名、SBOM 等 artifact），无法扫描
+	ErrNotScannable = errors.New("scanner: artifact is not a container image")
+)
+
+// activeStatuses 未结束的扫描状态
+var activeStatuses = []string{string(models.ReportPending), string(models.ReportRunning)}
+
+// EventNotifier 扫描完成事件通知（由 Webhook 服务实现）
+type EventNotifier interface {
+	PushScanEvent(projectID, repository, tag, digest, scanStatus string, criticalCount, highCount int, userID, username string) error
+}
+
+// Service 漏洞扫描服务
+type Service struct {
+	db       *gorm.DB
+	reg      *registry.Registry
+	scanner  scanner.Scanner // 为 nil 表示未启用扫描
+	notifier EventNotifier
+	policy   PolicyProvider
+
+	// defaultPolicy 项目未配置漏洞拉取策略时使用（scanner.block_on_critical），为 nil 表示不限制
+	defaultPolicy *scanner.PullPolicy
+
+	severities map[string]bool // 报告中保留明细的严重级别，为空表示全部
+	workers    int
+	timeout    time.Duration
+	workDir    string // 待扫描镜像归档的临时目录，为空表示系统临时目录
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  []string // 等待扫描的报告 ID
+	closed bool
+}
+
+// NewService 创建漏洞扫描服务
+// sc 为 nil 时不执行扫描（仍可查询已有报告）。
+func NewService(reg *registry.Registry, sc scanner.Scanner, cfg *config.ScannerConfig) *Service {
+	s := &Service{
+		db:         database.GetDB(),
+		reg:        reg,
+		scanner:    sc,
+		workers:    cfg.Workers,
+		timeout:    time.Duration(cfg.Timeout) * time.Second,
+		severities: make(map[string]bool),
+	}
+	if s.workers <= 0 {
+		s.workers = DefaultWorkers
+	}
+	if s.timeout <= 0 {
+		s.timeout = DefaultTimeout
+	}
+	for _, severity := range cfg.Severity {
+		s.severities[scanner.NormalizeSeverity(severity)] = true
+	}
+	if cfg.BlockOnCritical {
+		// 全局默认策略只拦截严重漏洞，不拦截启用扫描前推送、尚未扫描的镜像
+		s.defaultPolicy = &scanner.PullPolicy{
+			Enabled:        true,
+			Severity:       scanner.SeverityCritical,
+			AllowUnscanned: true,
+		}
+	}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+// InitDatabase 初始化漏洞扫描相关的数据库表
+func InitDatabase(db *gorm.DB) error {
+	if db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	if err := db.AutoMigrate(&models.Report{}); err != nil {
+		return fmt.Errorf("auto migrate scan_reports failed: %w", err)
+	}
+	return nil
+}
+
+// SetNotifier 设置扫描完成事件的通知方式
+func (s *Service) SetNotifier(n EventNotifier) {
+	s.notifier = n
+}
+
+// SetWorkDir 设置待扫描镜像归档的临时目录
+func (s *Service) SetWorkDir(dir string) {
+	s.workDir = dir
+}
+
+// Enabled 是否启用了扫描
+func (s *Service) Enabled() bool {
+	return s.scanner != nil
+}
+
+// ScanImage 将仓库中的 tag 或 digest 加入扫描队列，返回该 digest 的报告
+// 同一 digest 已在排队或扫描中时直接返回该报告；重新扫描期间保留上一次的结果，直到新结果写入。
+func (s *Service) ScanImage(ctx context.Context, projectID, repository, reference, trigger, userID string) (*models.Report, error) {
+	if !s.Enabled() {
+		return nil, ErrScannerDisabled
+	}
+
+	raw, digest, err := s.reg.GetManifestRaw(ctx, repository, reference)
+	if err != nil {
+		return nil, err
+	}
+	if !scannable(raw) {
+		return nil, ErrNotScannable
+	}
+	tag := reference
+	if isDigest, _ := registry.ParseReference(reference); isDigest {
+		tag = ""
+	}
+
+	report := models.NewReport(digest, projectID, repository, tag, trigger, userID)
+	report.MediaType = registry.ManifestMediaType(raw)
+	result := s.db.WithContext(ctx).Model(&models.Report{}).
+		Where("digest = ? AND status NOT IN ?", digest, activeStatuses).
+		Updates(map[string]interface{}{
+			"media_type":   report.MediaType,
+			"project_id":   projectID,
+			"repository":   repository,
+			"tag":          tag,
+			"trigger":      trigger,
+			"status":       string(models.ReportPending),
+			"error":        "",
+			"requested_by": userID,
+			"started_at":   nil,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		// 首次扫描；并发创建时唯一索引冲突，以已存在的报告为准
+		if err := s.db.WithContext(ctx).Create(report).Error; err != nil {
+			existing, findErr := s.GetReportByDigest(ctx, digest)
+			if findErr != nil {
+				return nil, err
+			}
+			return existing, nil
+		}
+	}
+
+	report, err = s.GetReportByDigest(ctx, digest)
+	if err != nil {
+		return nil, err
+	}
+	if report.Status == string(models.ReportPending) {
+		s.enqueue(report.ID)
+	}
+	return report, nil
+}
+
+// WaitReport 等待 digest 的扫描结束，ctx 结束时返回当前状态的报告
+func (s *Service) WaitReport(ctx context.Context, digest string) (*models.Report, error) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		report, err := s.GetReportByDigest(context.WithoutCancel(ctx), digest)
+		if err != nil || report.Finished() {
+			return report, err
+		}
+		select {
+		case <-ctx.Done():
+			return report, nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// GetReportByDigest 获取 digest 的扫描报告
+func (s *Service) GetReportByDigest(ctx context.Context, digest string) (*models.Report, error) {
+	var report models.Report
+	if err := s.db.WithContext(ctx).Where("digest = ?", digest).First(&report).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrReportNotFound
+		}
+		return nil, err
+	}
+	return &report, nil
+}
+
+// GetReport 获取仓库中 tag 或 digest 当前指向的镜像的扫描报告
+func (s *Service) GetReport(ctx context.Context, repository, reference string) (*models.Report, error) {
+	_, digest, err := s.reg.GetManifestRaw(ctx, repository, reference)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetReportByDigest(ctx, digest)
+}
+
+// ListTags 列出仓库的全部 tag
+func (s *Service) ListTags(ctx context.Context, repository string) ([]string, error) {
+	return s.reg.ListTags(ctx, repository)
+}
+
+// TagReport tag 及其当前 digest 的扫描报告（尚未扫描时 Report 为 nil）
+type TagReport struct {
+	Tag    string
+	Digest string
+	Report *models.Report
+}
+
+// ListTagReports 列出仓库全部 tag 的扫描报告（不含漏洞明细）
+func (s *Service) ListTagReports(ctx context.Context, repository string) ([]TagReport, error) {
+	tags, err := s.reg.ListTags(ctx, repository)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]TagReport, 0, len(tags))
+	digests := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		data, err := s.reg.GetTag(ctx, repository, tag)
+		if err != nil {
+			continue
+		}
+		items = append(items, TagReport{Tag: tag, Digest: data.Digest})
+		digests = append(digests, data.Digest)
+	}
+	if len(digests) == 0 {
+		return items, nil
+	}
+
+	var reports []models.Report
+	if err := s.db.WithContext(ctx).
+		Omit("vulnerabilities").
+		Where("digest IN ?", digests).
+		Find(&reports).Error; err != nil {
+		return nil, err
+	}
+	byDigest := make(map[string]*models.Report, len(reports))
+	for i := range reports {
+		byDigest[reports[i].Digest] = &reports[i]
+	}
+	for i := range items {
+		items[i].Report = byDigest[items[i].Digest]
+	}
+	return items, nil
+}
+
+// scannable 判断 Manifest 是否为可扫描的容器镜像（多架构索引按子 Manifest 扫描）
+// 签名、SBOM 等 artifact 的 config 不是镜像配置，跳过。
+func scannable(raw []byte) bool {
+	return registry.IsIndexManifest(raw) || registry.IsImageManifest(raw)
+}
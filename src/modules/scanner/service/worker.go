@@ -0,0 +1,333 @@
+// Package service 实现漏洞扫描任务的排队、执行与报告管理
+// 推送 tag 后自动扫描，也可手动触发；报告按 Manifest digest 保存，同一 digest 在多个 tag/仓库间共享。
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/cyp-registry/registry/src/middleware"
+	"github.com/cyp-registry/registry/src/modules/registry"
+	"github.com/cyp-registry/registry/src/modules/scanner"
+	"github.com/cyp-registry/registry/src/modules/scanner/models"
+	"github.com/cyp-registry/registry/src/modules/webhook"
+	"github.com/cyp-registry/registry/src/pkg/audit"
+)
+
+// Start 启动扫描 worker 并监听推送事件（阻塞直到 ctx 结束，通常以 goroutine 方式调用）
+// 启动时恢复上次退出时未完成的扫描。
+func (s *Service) Start(ctx context.Context) {
+	if !s.Enabled() {
+		return
+	}
+
+	for i := 0; i < s.workers; i++ {
+		go s.work(ctx)
+	}
+	if n, err := s.recover(ctx); err != nil {
+		log.Printf(`{"timestamp":"%s","level":"warn","module":"scanner","operation":"recover","error":"%v"}`, time.Now().Format(time.RFC3339), err)
+	} else if n > 0 {
+		log.Printf(`{"timestamp":"%s","level":"info","module":"scanner","operation":"recover","requeued":%d}`, time.Now().Format(time.RFC3339), n)
+	}
+
+	events, unsubscribe := webhook.SubscribeRegistryEvents()
+	defer unsubscribe()
+	defer func() {
+		s.mu.Lock()
+		s.closed = true
+		s.cond.Broadcast()
+		s.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if event.Type == webhook.RegistryEventPush {
+				s.handlePushEvent(ctx, event)
+			}
+		}
+	}
+}
+
+// handlePushEvent 推送 tag 后自动扫描
+// 按 digest 推送（例如多架构镜像的子 Manifest）不触发，随后推送的索引 tag 会一并扫描它们。
+func (s *Service) handlePushEvent(ctx context.Context, event webhook.RegistryEvent) {
+	if event.Tag == "" || strings.Contains(event.Tag, ":") {
+		return
+	}
+	_, err := s.ScanImage(ctx, event.ProjectID, event.Repository, event.Tag, models.TriggerPush, "")
+	if err != nil && !errors.Is(err, ErrNotScannable) {
+		log.Printf(`{"timestamp":"%s","level":"warn","module":"scanner","operation":"push_event","repository":"%s","tag":"%s","error":"%v"}`, time.Now().Format(time.RFC3339), event.Repository, event.Tag, err)
+	}
+}
+
+// enqueue 报告进入等待队列
+func (s *Service) enqueue(reportID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queue = append(s.queue, reportID)
+	s.cond.Signal()
+}
+
+// work 依次取出并执行扫描
+func (s *Service) work(ctx context.Context) {
+	for {
+		s.mu.Lock()
+		for len(s.queue) == 0 && !s.closed {
+			s.cond.Wait()
+		}
+		if s.closed {
+			s.mu.Unlock()
+			return
+		}
+		id := s.queue[0]
+		s.queue = s.queue[1:]
+		s.mu.Unlock()
+
+		s.run(ctx, id)
+	}
+}
+
+// recover 将上次退出时未完成的扫描重新排队
+func (s *Service) recover(ctx context.Context) (int, error) {
+	var reports []models.Report
+	if err := s.db.WithContext(ctx).
+		Select("id").
+		Where("status IN ?", activeStatuses).
+		Order("created_at ASC").
+		Find(&reports).Error; err != nil {
+		return 0, err
+	}
+	if err := s.db.WithContext(ctx).Model(&models.Report{}).
+		Where("status = ?", string(models.ReportRunning)).
+		Update("status", string(models.ReportPending)).Error; err != nil {
+		return 0, err
+	}
+	for _, report := range reports {
+		s.enqueue(report.ID)
+	}
+	return len(reports), nil
+}
+
+// run 执行一次扫描并保存报告
+func (s *Service) run(ctx context.Context, reportID string) {
+	now := time.Now()
+	result := s.db.WithContext(ctx).Model(&models.Report{}).
+		Where("id = ? AND status = ?", reportID, string(models.ReportPending)).
+		Updates(map[string]interface{}{"status": string(models.ReportRunning), "started_at": now})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return
+	}
+	var report models.Report
+	if err := s.db.WithContext(ctx).First(&report, "id = ?", reportID).Error; err != nil {
+		return
+	}
+
+	scanCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	res, err := s.scanManifest(scanCtx, &report)
+	if err != nil {
+		s.fail(ctx, &report, err)
+		return
+	}
+	s.complete(ctx, &report, res)
+}
+
+// scanManifest 扫描报告对应的 Manifest；多架构索引逐个扫描各平台的子 Manifest 并合并结果
+func (s *Service) scanManifest(ctx context.Context, report *models.Report) (*scanner.Result, error) {
+	raw, _, err := s.reg.GetManifestRaw(ctx, report.Repository, report.Digest)
+	if err != nil {
+		return nil, err
+	}
+
+	if !registry.IsIndexManifest(raw) {
+		return s.scanImage(ctx, report.Repository, report.Digest, registry.ManifestMediaType(raw), "")
+	}
+
+	platforms, err := registry.IndexPlatforms(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := &scanner.Result{}
+	var lists [][]scanner.Vulnerability
+	for _, child := range platforms {
+		childRaw, _, err := s.reg.GetManifestRaw(ctx, report.Repository, child.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", child.Platform, err)
+		}
+		if !scannable(childRaw) {
+			continue
+		}
+		res, err := s.scanImage(ctx, report.Repository, child.Digest, child.MediaType, child.Platform)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", child.Platform, err)
+		}
+		s.saveChildReport(ctx, report, child.Digest, child.MediaType, res)
+		merged.Scanner, merged.ScannerVersion = res.Scanner, res.ScannerVersion
+		lists = append(lists, res.Vulnerabilities)
+	}
+	if len(lists) == 0 {
+		return nil, ErrNotScannable
+	}
+	merged.Vulnerabilities = scanner.Merge(lists...)
+	return merged, nil
+}
+
+// scanImage 将单个镜像导出为 OCI 归档后交给扫描器
+func (s *Service) scanImage(ctx context.Context, repository, digest, mediaType, platform string) (*scanner.Result, error) {
+	export, err := s.reg.PrepareExport(ctx, []registry.ExportTarget{{Repository: repository, Reference: digest}}, registry.ExportOptions{SkipReferrers: true})
+	if err != nil {
+		return nil, err
+	}
+
+	if s.workDir != "" {
+		if err := os.MkdirAll(s.workDir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	f, err := os.CreateTemp(s.workDir, "scan-*.tar")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	err = export.WriteTo(ctx, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("export image: %w", err)
+	}
+
+	return s.scanner.Scan(ctx, &scanner.Target{
+		Repository:  repository,
+		Digest:      digest,
+		MediaType:   mediaType,
+		Platform:    platform,
+		ArchivePath: f.Name(),
+	})
+}
+
+// saveChildReport 保存多架构索引中单个平台的扫描结果
+func (s *Service) saveChildReport(ctx context.Context, parent *models.Report, digest, mediaType string, res *scanner.Result) {
+	child := models.NewReport(digest, parent.ProjectID, parent.Repository, "", parent.Trigger, parent.RequestedBy)
+	child.MediaType = mediaType
+	if err := s.db.WithContext(ctx).
+		Where("digest = ?", digest).
+		Attrs(child).
+		FirstOrCreate(child).Error; err != nil {
+		return
+	}
+	child.StartedAt = parent.StartedAt
+	s.complete(ctx, child, res)
+}
+
+// complete 保存扫描结果
+func (s *Service) complete(ctx context.Context, report *models.Report, res *scanner.Result) {
+	summary := scanner.Summarize(res.Vulnerabilities)
+	details := res.Vulnerabilities
+	if len(s.severities) > 0 {
+		details = make([]scanner.Vulnerability, 0, len(res.Vulnerabilities))
+		for _, v := range res.Vulnerabilities {
+			if s.severities[scanner.NormalizeSeverity(v.Severity)] {
+				details = append(details, v)
+			}
+		}
+	}
+	if details == nil {
+		details = []scanner.Vulnerability{}
+	}
+
+	now := time.Now()
+	report.Status = string(models.ReportSuccess)
+	report.Scanner, report.ScannerVersion = res.Scanner, res.ScannerVersion
+	report.Critical, report.High, report.Medium, report.Low, report.Unknown =
+		summary.Critical, summary.High, summary.Medium, summary.Low, summary.Unknown
+	report.Vulnerabilities = details
+	report.Error = ""
+	report.CompletedAt = &now
+	if err := s.db.WithContext(ctx).
+		Select("status", "scanner", "scanner_version", "critical", "high", "medium", "low", "unknown", "vulnerabilities", "error", "started_at", "completed_at").
+		Updates(report).Error; err != nil {
+		log.Printf(`{"timestamp":"%s","level":"error","module":"scanner","operation":"save_report","digest":"%s","error":"%v"}`, time.Now().Format(time.RFC3339), report.Digest, err)
+		return
+	}
+
+	highest := strings.ToLower(summary.Highest())
+	if highest == "" {
+		highest = "none"
+	}
+	middleware.ScanTotal.WithLabelValues("success", highest).Inc()
+	log.Printf(`{"timestamp":"%s","level":"info","module":"scanner","operation":"scan","repository":"%s","digest":"%s","critical":%d,"high":%d,"medium":%d,"low":%d,"unknown":%d}`, time.Now().Format(time.RFC3339), report.Repository, report.Digest, summary.Critical, summary.High, summary.Medium, summary.Low, summary.Unknown)
+
+	if report.Tag != "" {
+		s.notify(report, "success")
+	}
+}
+
+// fail 记录扫描失败
+func (s *Service) fail(ctx context.Context, report *models.Report, scanErr error) {
+	message := scanErr.Error()
+	switch {
+	case errors.Is(scanErr, context.DeadlineExceeded):
+		message = fmt.Sprintf("扫描超时（%s）", s.timeout)
+	case errors.Is(scanErr, registry.ErrManifestNotFound):
+		message = "镜像已被删除"
+	case errors.Is(scanErr, ErrNotScannable):
+		message = "索引中没有可扫描的镜像"
+	}
+
+	now := time.Now()
+	_ = s.db.WithContext(ctx).Model(&models.Report{}).
+		Where("id = ?", report.ID).
+		Updates(map[string]interface{}{
+			"status":       string(models.ReportFailed),
+			"error":        message,
+			"completed_at": now,
+		}).Error
+
+	middleware.ScanTotal.WithLabelValues("failed", "none").Inc()
+	log.Printf(`{"timestamp":"%s","level":"warn","module":"scanner","operation":"scan","repository":"%s","digest":"%s","error":"%v"}`, time.Now().Format(time.RFC3339), report.Repository, report.Digest, scanErr)
+
+	report.Status, report.Error = string(models.ReportFailed), message
+	if report.Tag != "" {
+		s.notify(report, "failed")
+	}
+}
+
+// notify 发送扫描完成的 Webhook 事件并记录审计日志
+func (s *Service) notify(report *models.Report, status string) {
+	if s.notifier != nil && report.ProjectID != "" {
+		if err := s.notifier.PushScanEvent(report.ProjectID, report.Repository, report.Tag, report.Digest, status, report.Critical, report.High, report.RequestedBy, ""); err != nil {
+			log.Printf(`{"timestamp":"%s","level":"warn","module":"scanner","operation":"scan_event","digest":"%s","error":"%v"}`, time.Now().Format(time.RFC3339), report.Digest, err)
+		}
+	}
+
+	var userID *uuid.UUID
+	if id, err := uuid.Parse(report.RequestedBy); err == nil {
+		userID = &id
+	}
+	audit.Record(context.Background(), "scan_image", "image", nil, userID, "", "", map[string]interface{}{
+		"repository": report.Repository,
+		"tag":        report.Tag,
+		"digest":     report.Digest,
+		"trigger":    report.Trigger,
+		"status":     status,
+		"critical":   report.Critical,
+		"high":       report.High,
+		"error":      report.Error,
+	})
+}
@@ -13,6 +13,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/cyp-registry/registry/src/modules/storage"
 	"github.com/cyp-registry/registry/src/pkg/config"
 	"github.com/cyp-registry/registry/src/pkg/response"
 )
@@ -203,6 +204,41 @@ func (s *LocalStorage) Get(ctx context.Context, path string) (io.Reader, int64,
 	return file, stat.Size(), nil
 }
 
+// GetRange 读取文件的一部分（通过 Seek 定位，不读取偏移之前的数据）
+func (s *LocalStorage) GetRange(ctx context.Context, path string, offset, length int64) (io.Reader, int64, error) {
+	reader, size, err := s.Get(ctx, path)
+	if err != nil {
+		return nil, 0, err
+	}
+	file := reader.(*os.File)
+
+	if offset < 0 || offset > size {
+		file.Close()
+		return nil, 0, storage.ErrInvalidRange
+	}
+	if length < 0 || offset+length > size {
+		length = size - offset
+	}
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, 0, fmt.Errorf("failed to seek file: %w", err)
+	}
+
+	return &limitedFile{Reader: io.LimitReader(file, length), file: file}, length, nil
+}
+
+// limitedFile 限定读取长度的文件，Close 时关闭底层文件
+type limitedFile struct {
+	io.Reader
+	file *os.File
+}
+
+// Close 关闭底层文件
+func (f *limitedFile) Close() error {
+	return f.file.Close()
+}
+
 // Delete 删除文件
 func (s *LocalStorage) Delete(ctx context.Context, path string) error {
 	// 验证路径
@@ -0,0 +1,118 @@
+// Package driver 存储驱动模块
+// 提供多种存储后端实现：本地文件系统和MinIO S3
+package driver
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/cyp-registry/registry/src/modules/storage"
+	"github.com/cyp-registry/registry/src/pkg/response"
+)
+
+// localFileWriter 本地文件追加写入会话
+// 直接以 O_APPEND 打开目标文件，每个分片只写入新增数据。
+type localFileWriter struct {
+	file *os.File
+	path string
+	size int64
+}
+
+// Writer 打开可追加写入的上传会话
+func (s *LocalStorage) Writer(ctx context.Context, path string, append bool) (storage.FileWriter, error) {
+	// 验证路径
+	if err := s.validatePath(path); err != nil {
+		return nil, err
+	}
+
+	fullPath := s.getFullPath(path)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY
+	if append {
+		flags |= os.O_APPEND
+	} else {
+		flags |= os.O_TRUNC
+	}
+
+	file, err := os.OpenFile(fullPath, flags, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	return &localFileWriter{
+		file: file,
+		path: fullPath,
+		size: stat.Size(),
+	}, nil
+}
+
+// Write 追加写入数据
+func (w *localFileWriter) Write(p []byte) (int, error) {
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Size 返回累计写入字节数
+func (w *localFileWriter) Size() int64 {
+	return w.size
+}
+
+// Close 结束本次写入
+func (w *localFileWriter) Close() error {
+	return w.file.Close()
+}
+
+// Commit 完成写入（本地文件写入即可读，只需落盘）
+func (w *localFileWriter) Commit(ctx context.Context) error {
+	if err := w.file.Sync(); err != nil {
+		w.file.Close()
+		return fmt.Errorf("failed to sync file: %w", err)
+	}
+	return w.file.Close()
+}
+
+// Cancel 放弃写入并删除文件
+func (w *localFileWriter) Cancel(ctx context.Context) error {
+	w.file.Close()
+	if err := os.Remove(w.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	return nil
+}
+
+// Move 在存储内部移动文件
+func (s *LocalStorage) Move(ctx context.Context, src, dst string) error {
+	// 验证路径
+	if err := s.validatePath(src); err != nil {
+		return err
+	}
+	if err := s.validatePath(dst); err != nil {
+		return err
+	}
+
+	srcPath := s.getFullPath(src)
+	dstPath := s.getFullPath(dst)
+	if err := os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	if err := os.Rename(srcPath, dstPath); err != nil {
+		if os.IsNotExist(err) {
+			return response.ErrNotFound
+		}
+		return fmt.Errorf("failed to move file: %w", err)
+	}
+	return nil
+}
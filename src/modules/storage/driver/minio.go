@@ -6,11 +6,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"hash"
 	"io"
-	"strconv"
+	"net/url"
 	"strings"
+	"time"
 
+	"github.com/cyp-registry/registry/src/modules/storage"
 	"github.com/cyp-registry/registry/src/pkg/config"
 	"github.com/cyp-registry/registry/src/pkg/response"
 	"github.com/minio/minio-go/v7"
@@ -20,11 +21,13 @@ import (
 // MinIOStorage MinIO S3兼容存储驱动
 // 用于生产环境，支持分布式存储
 type MinIOStorage struct {
-	client     *minio.Client
-	bucket     string
-	location   string
-	partSize   int64
-	sha256Hash hash.Hash
+	client   *minio.Client
+	bucket   string
+	location string
+	partSize int64
+
+	// redirect 为 true 时，下载请求重定向到预签名地址（见 RedirectURL）
+	redirect bool
 }
 
 // NewMinIOStorage 创建MinIO存储驱动
@@ -79,11 +82,11 @@ func NewMinIOStorage(cfg *config.Config) (*MinIOStorage, error) {
 	}
 
 	return &MinIOStorage{
-		client:     client,
-		bucket:     bucket,
-		location:   location,
-		partSize:   partSize,
-		sha256Hash: nil, // 延迟初始化
+		client:   client,
+		bucket:   bucket,
+		location: location,
+		partSize: partSize,
+		redirect: cfg.GetBool("storage.s3.redirect"),
 	}, nil
 }
 
@@ -115,34 +118,14 @@ func (s *MinIOStorage) Put(ctx context.Context, path string, reader io.Reader, s
 		return s.putLargeObject(ctx, path, reader, uploadSize)
 	}
 
-	// 未知大小，逐块读取
-	// 先计算摘要和总大小
-	hash := s.sha256Hash
-	if hash == nil {
-		hash = createSHA256()
-	}
-	hash.Reset()
-
-	reader = io.TeeReader(reader, hash)
-	var totalSize int64
-
-	buf := make([]byte, 32*1024)
-	for {
-		n, err := reader.Read(buf)
-		if n > 0 {
-			totalSize += int64(n)
-		}
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return fmt.Errorf("failed to read data: %w", err)
-		}
+	// 未知大小：由 minio-go 按 partSize 流式分片上传，不在内存中缓存全部数据
+	_, err := s.client.PutObject(ctx, s.bucket, path, reader, -1, minio.PutObjectOptions{
+		ContentType: "application/octet-stream",
+		PartSize:    uint64(s.partSize),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put object: %w", err)
 	}
-
-	// 重置reader
-	s.sha256Hash.Reset()
-	// 重新读取数据（这里简化处理，实际应缓存或重新打开reader）
 	return nil
 }
 
@@ -176,15 +159,84 @@ func (s *MinIOStorage) Get(ctx context.Context, path string) (io.Reader, int64,
 		return nil, 0, fmt.Errorf("failed to get object: %w", err)
 	}
 
-	// 获取对象信息
+	// 获取对象信息（GetObject 惰性请求，不存在的对象在此处才会报错）
 	stat, err := object.Stat()
 	if err != nil {
+		object.Close()
+		if isMinIONotFound(err) {
+			return nil, 0, response.ErrNotFound
+		}
 		return nil, 0, fmt.Errorf("failed to stat object: %w", err)
 	}
 
 	return object, stat.Size, nil
 }
 
+// GetRange 读取对象的一部分（HTTP Range GET）
+func (s *MinIOStorage) GetRange(ctx context.Context, path string, offset, length int64) (io.Reader, int64, error) {
+	// 验证路径
+	if err := s.validatePath(path); err != nil {
+		return nil, 0, err
+	}
+	if offset < 0 || length == 0 {
+		return nil, 0, storage.ErrInvalidRange
+	}
+
+	opts := minio.GetObjectOptions{}
+	end := int64(0) // 0 表示读到对象末尾
+	if length > 0 {
+		end = offset + length - 1
+	}
+	if offset > 0 || end > 0 {
+		if err := opts.SetRange(offset, end); err != nil {
+			return nil, 0, storage.ErrInvalidRange
+		}
+	}
+
+	object, err := s.client.GetObject(ctx, s.bucket, path, opts)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get object: %w", err)
+	}
+
+	// 对于范围请求，Stat 返回的大小即本次可读取的长度
+	stat, err := object.Stat()
+	if err != nil {
+		object.Close()
+		if isMinIONotFound(err) {
+			return nil, 0, response.ErrNotFound
+		}
+		if minio.ToErrorResponse(err).Code == "InvalidRange" {
+			return nil, 0, storage.ErrInvalidRange
+		}
+		return nil, 0, fmt.Errorf("failed to stat object: %w", err)
+	}
+
+	return object, stat.Size, nil
+}
+
+// RedirectURL 生成对象的预签名下载地址（实现 storage.Redirector）
+// 未启用 storage.s3.redirect 时返回空字符串；有效期限制在 1 秒到 7 天之间（S3 签名上限）。
+func (s *MinIOStorage) RedirectURL(ctx context.Context, path string, expiry time.Duration) (string, error) {
+	if !s.redirect {
+		return "", nil
+	}
+	if err := s.validatePath(path); err != nil {
+		return "", err
+	}
+
+	if expiry < time.Second {
+		expiry = time.Second
+	} else if expiry > 7*24*time.Hour {
+		expiry = 7 * 24 * time.Hour
+	}
+
+	u, err := s.client.PresignedGetObject(ctx, s.bucket, path, expiry, url.Values{})
+	if err != nil {
+		return "", fmt.Errorf("failed to presign object: %w", err)
+	}
+	return u.String(), nil
+}
+
 // Delete 删除文件
 func (s *MinIOStorage) Delete(ctx context.Context, path string) error {
 	// 验证路径
@@ -243,6 +295,12 @@ func (s *MinIOStorage) List(ctx context.Context, prefix string) ([]string, error
 		return nil, err
 	}
 
+	// 与本地存储保持一致：List 返回“目录”下的直接子项，
+	// 因此非空前缀需以 / 结尾，否则 MinIO 只会返回前缀自身
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
 	// 列出对象
 	objects := s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{
 		Prefix:    prefix,
@@ -318,40 +376,3 @@ func (s *MinIOStorage) Close() error {
 	// MinIO客户端不需要显式关闭
 	return nil
 }
-
-// createSHA256 创建SHA256哈希实例
-func createSHA256() hash.Hash {
-	return new(sha256Hash)
-}
-
-// sha256Hash SHA256哈希的简单实现
-type sha256Hash struct {
-	ctx []byte
-}
-
-func (h *sha256Hash) Write(p []byte) (n int, err error) {
-	h.ctx = append(h.ctx, p...)
-	return len(p), nil
-}
-
-func (h *sha256Hash) Sum(b []byte) []byte {
-	// 简化实现，实际应计算真实SHA256
-	hash := make([]byte, 32)
-	copy(hash, h.ctx)
-	return hash
-}
-
-func (h *sha256Hash) Reset() {
-	h.ctx = nil
-}
-
-func (h *sha256Hash) Size() int {
-	return 32
-}
-
-func (h *sha256Hash) BlockSize() int {
-	return 64
-}
-
-// 确保MinIO依赖已添加（运行时需要）
-var _ = strconv.IntSize
@@ -0,0 +1,289 @@
+// Package driver 存储驱动模块
+// 提供多种存储后端实现：本地文件系统和MinIO S3
+package driver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/cyp-registry/registry/src/modules/storage"
+	"github.com/cyp-registry/registry/src/pkg/response"
+	"github.com/minio/minio-go/v7"
+)
+
+// MinIO 追加写入会话的辅助对象后缀：
+//
+//	<path>.upload  会话状态（multipart uploadId 与已上传分片）
+//	<path>.tail    尚不足一个分片大小的尾部数据
+//
+// S3 multipart 要求除最后一个分片外每片不小于 5MB，而客户端分片可能更小，
+// 因此不足 partSize 的数据暂存为 tail 对象，下次追加时取回（内存占用不超过 partSize）。
+const (
+	minioUploadStateSuffix = ".upload"
+	minioUploadTailSuffix  = ".tail"
+)
+
+// minioUploadPart 已上传的分片
+type minioUploadPart struct {
+	Number int    `json:"number"`
+	ETag   string `json:"etag"`
+	Size   int64  `json:"size"`
+}
+
+// minioUploadState 持久化的会话状态
+type minioUploadState struct {
+	UploadID string            `json:"upload_id"`
+	Parts    []minioUploadPart `json:"parts"`
+}
+
+// minioFileWriter MinIO 追加写入会话
+type minioFileWriter struct {
+	s     *MinIOStorage
+	ctx   context.Context
+	path  string
+	state minioUploadState
+	buf   []byte
+	size  int64
+}
+
+// Writer 打开可追加写入的上传会话
+func (s *MinIOStorage) Writer(ctx context.Context, path string, append bool) (storage.FileWriter, error) {
+	// 验证路径
+	if err := s.validatePath(path); err != nil {
+		return nil, err
+	}
+
+	w := &minioFileWriter{s: s, ctx: ctx, path: path}
+
+	if !append {
+		// 丢弃可能残留的旧会话
+		if err := w.abort(ctx); err != nil {
+			return nil, err
+		}
+		return w, nil
+	}
+
+	// 恢复会话状态
+	if reader, _, err := s.Get(ctx, path+minioUploadStateSuffix); err == nil {
+		data, rErr := io.ReadAll(reader)
+		closeReader(reader)
+		if rErr != nil {
+			return nil, fmt.Errorf("failed to read upload state: %w", rErr)
+		}
+		if err := json.Unmarshal(data, &w.state); err != nil {
+			return nil, fmt.Errorf("invalid upload state: %w", err)
+		}
+	} else if !errors.Is(err, response.ErrNotFound) {
+		return nil, err
+	}
+	for _, part := range w.state.Parts {
+		w.size += part.Size
+	}
+
+	// 取回尾部数据
+	if reader, _, err := s.Get(ctx, path+minioUploadTailSuffix); err == nil {
+		w.buf, err = io.ReadAll(reader)
+		closeReader(reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read upload tail: %w", err)
+		}
+		w.size += int64(len(w.buf))
+	} else if !errors.Is(err, response.ErrNotFound) {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Write 追加写入数据，累计满一个分片即上传
+func (w *minioFileWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		room := int(w.s.partSize) - len(w.buf)
+		n := len(p)
+		if n > room {
+			n = room
+		}
+		w.buf = append(w.buf, p[:n]...)
+		p = p[n:]
+		written += n
+		w.size += int64(n)
+
+		if int64(len(w.buf)) >= w.s.partSize {
+			if err := w.flushPart(w.ctx); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// flushPart 将缓冲区作为一个分片上传
+func (w *minioFileWriter) flushPart(ctx context.Context) error {
+	core := minio.Core{Client: w.s.client}
+
+	if w.state.UploadID == "" {
+		uploadID, err := core.NewMultipartUpload(ctx, w.s.bucket, w.path, minio.PutObjectOptions{
+			ContentType: "application/octet-stream",
+		})
+		if err != nil {
+			return fmt.Errorf("failed to start multipart upload: %w", err)
+		}
+		w.state.UploadID = uploadID
+	}
+
+	number := len(w.state.Parts) + 1
+	part, err := core.PutObjectPart(ctx, w.s.bucket, w.path, w.state.UploadID, number,
+		bytes.NewReader(w.buf), int64(len(w.buf)), minio.PutObjectPartOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to upload part %d: %w", number, err)
+	}
+
+	w.state.Parts = append(w.state.Parts, minioUploadPart{
+		Number: number,
+		ETag:   part.ETag,
+		Size:   int64(len(w.buf)),
+	})
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// Size 返回累计写入字节数
+func (w *minioFileWriter) Size() int64 {
+	return w.size
+}
+
+// Close 持久化会话状态与尾部数据，以便后续分片请求继续追加
+func (w *minioFileWriter) Close() error {
+	ctx := context.Background()
+
+	if len(w.buf) > 0 {
+		if err := w.s.Put(ctx, w.path+minioUploadTailSuffix, bytes.NewReader(w.buf), int64(len(w.buf))); err != nil {
+			return err
+		}
+	} else {
+		_ = w.s.Delete(ctx, w.path+minioUploadTailSuffix)
+	}
+
+	data, err := json.Marshal(w.state)
+	if err != nil {
+		return err
+	}
+	return w.s.Put(ctx, w.path+minioUploadStateSuffix, bytes.NewReader(data), int64(len(data)))
+}
+
+// Commit 完成 multipart 上传生成完整对象
+func (w *minioFileWriter) Commit(ctx context.Context) error {
+	if w.state.UploadID == "" {
+		// 数据不足一个分片：直接单次上传
+		if err := w.s.Put(ctx, w.path, bytes.NewReader(w.buf), int64(len(w.buf))); err != nil {
+			return err
+		}
+	} else {
+		if len(w.buf) > 0 {
+			if err := w.flushPart(ctx); err != nil {
+				return err
+			}
+		}
+
+		parts := make([]minio.CompletePart, 0, len(w.state.Parts))
+		for _, part := range w.state.Parts {
+			parts = append(parts, minio.CompletePart{PartNumber: part.Number, ETag: part.ETag})
+		}
+
+		core := minio.Core{Client: w.s.client}
+		if _, err := core.CompleteMultipartUpload(ctx, w.s.bucket, w.path, w.state.UploadID, parts, minio.PutObjectOptions{
+			ContentType: "application/octet-stream",
+		}); err != nil {
+			return fmt.Errorf("failed to complete multipart upload: %w", err)
+		}
+	}
+
+	w.cleanup(ctx)
+	return nil
+}
+
+// Cancel 放弃写入并清理 multipart 分片与辅助对象
+func (w *minioFileWriter) Cancel(ctx context.Context) error {
+	if err := w.abort(ctx); err != nil {
+		return err
+	}
+	_ = w.s.Delete(ctx, w.path)
+	return nil
+}
+
+// abort 中止可能存在的 multipart 上传并删除辅助对象
+func (w *minioFileWriter) abort(ctx context.Context) error {
+	state := w.state
+	if state.UploadID == "" {
+		if reader, _, err := w.s.Get(ctx, w.path+minioUploadStateSuffix); err == nil {
+			data, _ := io.ReadAll(reader)
+			closeReader(reader)
+			_ = json.Unmarshal(data, &state)
+		} else if !errors.Is(err, response.ErrNotFound) {
+			return err
+		}
+	}
+
+	if state.UploadID != "" {
+		core := minio.Core{Client: w.s.client}
+		if err := core.AbortMultipartUpload(ctx, w.s.bucket, w.path, state.UploadID); err != nil {
+			log.Printf(`{"timestamp":"%s","level":"warn","module":"storage","driver":"minio","operation":"abort_multipart","path":"%s","error":"%v"}`, time.Now().Format(time.RFC3339), w.path, err)
+		}
+	}
+
+	w.state = minioUploadState{}
+	w.buf = nil
+	w.size = 0
+	w.cleanup(ctx)
+	return nil
+}
+
+// cleanup 删除会话辅助对象
+func (w *minioFileWriter) cleanup(ctx context.Context) {
+	_ = w.s.Delete(ctx, w.path+minioUploadStateSuffix)
+	_ = w.s.Delete(ctx, w.path+minioUploadTailSuffix)
+}
+
+// Move 通过服务端复制移动对象（ComposeObject 支持超过 5GB 的对象）
+func (s *MinIOStorage) Move(ctx context.Context, src, dst string) error {
+	// 验证路径
+	if err := s.validatePath(src); err != nil {
+		return err
+	}
+	if err := s.validatePath(dst); err != nil {
+		return err
+	}
+
+	_, err := s.client.ComposeObject(ctx,
+		minio.CopyDestOptions{Bucket: s.bucket, Object: dst},
+		minio.CopySrcOptions{Bucket: s.bucket, Object: src},
+	)
+	if err != nil {
+		if isMinIONotFound(err) {
+			return response.ErrNotFound
+		}
+		return fmt.Errorf("failed to copy object: %w", err)
+	}
+
+	return s.Delete(ctx, src)
+}
+
+// isMinIONotFound 判断 MinIO 错误是否为对象不存在
+func isMinIONotFound(err error) bool {
+	resp := minio.ToErrorResponse(err)
+	return resp.Code == "NoSuchKey" || resp.StatusCode == 404
+}
+
+// closeReader 关闭 Get 返回的 reader（如支持）
+func closeReader(reader io.Reader) {
+	if closer, ok := reader.(io.Closer); ok {
+		closer.Close()
+	}
+}
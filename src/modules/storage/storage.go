@@ -6,6 +6,7 @@ import (
 	"context"
 	"errors"
 	"io"
+	"time"
 )
 
 // ErrNotFound 资源不存在
@@ -17,6 +18,9 @@ var ErrQuotaExceeded = errors.New("storage: storage quota exceeded")
 // ErrInvalidPath 无效路径
 var ErrInvalidPath = errors.New("storage: invalid path")
 
+// ErrInvalidRange 读取范围超出文件大小
+var ErrInvalidRange = errors.New("storage: invalid range")
+
 // Storage 存储接口
 // 定义统一的存储操作，所有存储驱动必须实现此接口
 type Storage interface {
@@ -33,6 +37,14 @@ type Storage interface {
 	// 返回文件内容和长度
 	Get(ctx context.Context, path string) (io.Reader, int64, error)
 
+	// GetRange 读取文件的一部分
+	// ctx: 上下文
+	// path: 存储路径
+	// offset: 起始偏移（超出文件大小时返回 ErrInvalidRange）
+	// length: 读取长度（-1表示读到文件末尾，超出部分自动截断）
+	// 返回数据流和实际可读取的长度
+	GetRange(ctx context.Context, path string, offset, length int64) (io.Reader, int64, error)
+
 	// Delete 删除文件
 	// ctx: 上下文
 	// path: 存储路径
@@ -55,6 +67,19 @@ type Storage interface {
 	// 返回文件路径列表
 	List(ctx context.Context, path string) ([]string, error)
 
+	// Writer 打开可追加写入的上传会话
+	// ctx: 上下文
+	// path: 存储路径
+	// append: true 表示在已有会话数据之后继续写入，false 表示丢弃旧数据重新开始
+	// 数据在 Commit 之前不保证可通过 Get 读取
+	Writer(ctx context.Context, path string, append bool) (FileWriter, error)
+
+	// Move 在存储内部移动文件（目标已存在时覆盖），不经过本进程中转数据
+	// ctx: 上下文
+	// src: 源路径
+	// dst: 目标路径
+	Move(ctx context.Context, src, dst string) error
+
 	// GetUsage 获取存储使用量
 	// ctx: 上下文
 	// path: 目录路径
@@ -68,6 +93,32 @@ type Storage interface {
 	Close() error
 }
 
+// Redirector 可选接口：支持为读取请求生成临时直链的存储驱动
+// 调用方可将客户端重定向到该地址，使大文件下载不经过本进程。
+type Redirector interface {
+	// RedirectURL 返回 path 的临时下载地址，有效期为 expiry
+	// 驱动未启用重定向时返回空字符串和 nil
+	RedirectURL(ctx context.Context, path string, expiry time.Duration) (string, error)
+}
+
+// FileWriter 可追加写入的上传会话
+// 每次 HTTP 分片请求打开一次并在结束时 Close；最终由 Commit 生成完整对象或由 Cancel 清理。
+type FileWriter interface {
+	io.Writer
+
+	// Size 返回会话累计写入的字节数（包含此前打开会话时写入的数据）
+	Size() int64
+
+	// Close 结束本次写入并持久化会话状态，之后可再次以追加模式打开
+	Close() error
+
+	// Commit 完成写入，使数据以完整文件形式可读
+	Commit(ctx context.Context) error
+
+	// Cancel 放弃写入并清理已写入的数据
+	Cancel(ctx context.Context) error
+}
+
 // BlobInfo Blob信息
 type BlobInfo struct {
 	Digest    string `json:"digest"`     // SHA256摘要
@@ -9,6 +9,9 @@ import (
 
 	"github.com/cyp-registry/registry/src/pkg/database"
 	"gorm.io/gorm"
+
+	// 注册 serializer:secret
+	_ "github.com/cyp-registry/registry/src/pkg/secrets"
 )
 
 // WebhookModel Webhook数据库模型
@@ -18,10 +21,10 @@ type WebhookModel struct {
 	Name            string         `gorm:"type:varchar(255);not null" json:"name"`
 	Description     string         `gorm:"type:text" json:"description"`
 	URL             string         `gorm:"type:varchar(512);not null" json:"url"`
-	Secret          string         `gorm:"type:varchar(255)" json:"secret"`
+	Secret          string         `gorm:"type:text;serializer:secret" json:"secret"`
 	Events          StringArray    `gorm:"type:text" json:"events"`
 	IsActive        bool           `gorm:"default:true;index" json:"isActive"`
-	Headers         StringMap      `gorm:"type:text" json:"headers"`
+	Headers         StringMap      `gorm:"type:text;serializer:secret" json:"headers"`
 	RetryPolicyJSON string         `gorm:"type:text" json:"-"` // 存储重试策略JSON
 	CreatedAt       time.Time      `gorm:"autoCreateTime" json:"createdAt"`
 	UpdatedAt       time.Time      `gorm:"autoUpdateTime" json:"updatedAt"`
@@ -70,7 +73,7 @@ type WebhookDeliveryModel struct {
 	WebhookID       string         `gorm:"type:varchar(36);index;not null" json:"webhookId"`
 	RequestURL      string         `gorm:"type:varchar(512)" json:"requestUrl"`
 	RequestMethod   string         `gorm:"type:varchar(10)" json:"requestMethod"`
-	RequestHeaders  StringMap      `gorm:"type:text" json:"requestHeaders"`
+	RequestHeaders  StringMap      `gorm:"type:text;serializer:secret" json:"requestHeaders"` // 含自定义请求头，加密保存
 	RequestBody     string         `gorm:"type:text" json:"requestBody"`
 	ResponseStatus  int            `gorm:"default:0" json:"responseStatus"`
 	ResponseHeaders StringMap      `gorm:"type:text" json:"responseHeaders"`
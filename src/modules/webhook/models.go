@@ -25,6 +25,7 @@ const (
 	EventTypeScanFail = "scan_fail" // 漏洞扫描失败
 	EventTypePolicy   = "policy"    // 策略变更
 	EventTypeMember   = "member"    // 成员变更
+	EventTypeSync     = "sync"      // 同步订阅从上游更新了镜像
 )
 
 // 事件状态常量
@@ -149,6 +150,17 @@ type DeleteEventPayload struct {
 	Digest     string `json:"digest"`
 }
 
+// SyncEventPayload 同步更新事件载荷
+type SyncEventPayload struct {
+	EventPayload
+	Repository     string `json:"repository"`
+	Tag            string `json:"tag"`
+	Digest         string `json:"digest"`         // 上游新的 digest
+	PreviousDigest string `json:"previousDigest"` // 上一次同步的上游 digest
+	Source         string `json:"source"`         // 上游镜像地址
+	SubscriptionID string `json:"subscriptionId"`
+}
+
 // ScanEventPayload 扫描事件载荷
 type ScanEventPayload struct {
 	EventPayload
@@ -210,7 +222,7 @@ func (w *Webhook) Validate() error {
 	validEvents := map[string]bool{
 		EventTypePush: true, EventTypePull: true, EventTypeDelete: true,
 		EventTypeScan: true, EventTypeScanFail: true, EventTypePolicy: true,
-		EventTypeMember: true,
+		EventTypeMember: true, EventTypeSync: true,
 	}
 
 	for _, event := range w.Events {
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/cyp-registry/registry/src/modules/webhook"
@@ -143,6 +145,28 @@ func (s *WebhookService) PushDeleteEvent(projectID, repository, tag, digest stri
 	return nil
 }
 
+// PushSyncEvent 同步订阅更新事件：上游 tag 指向的镜像变化且已重新导入
+func (s *WebhookService) PushSyncEvent(projectID, repository, tag, digest, previousDigest, source, subscriptionID, userID string) error {
+	actor := &webhook.Actor{UserID: userID}
+	payload := &webhook.SyncEventPayload{
+		EventPayload: webhook.EventPayload{
+			Action:    "sync",
+			Timestamp: time.Now(),
+			Actor:     actor,
+		},
+		Repository:     repository,
+		Tag:            tag,
+		Digest:         digest,
+		PreviousDigest: previousDigest,
+		Source:         source,
+		SubscriptionID: subscriptionID,
+	}
+
+	// 向外部 Webhook 订阅者分发事件
+	// 本地订阅者不在此通知：重新导入写入 tag 时已发送推送事件（见 PushPushEvent）
+	return s.TriggerEvent(webhook.EventTypeSync, projectID, repository, payload, actor)
+}
+
 // PushScanEvent 扫描完成事件
 func (s *WebhookService) PushScanEvent(projectID, repository, tag, digest, scanStatus string, criticalCount, highCount int, userID, username string) error {
 	payload := &webhook.ScanEventPayload{
@@ -160,7 +184,10 @@ func (s *WebhookService) PushScanEvent(projectID, repository, tag, digest, scanS
 		ScanStatus:    scanStatus,
 		CriticalCount: criticalCount,
 		HighCount:     highCount,
-		ReportURL:     fmt.Sprintf("/api/v1/scans/reports/%s", digest),
+	}
+	// 报告接口按项目内的镜像名称查询（仓库名去掉项目前缀）
+	if _, image, ok := strings.Cut(repository, "/"); ok {
+		payload.ReportURL = fmt.Sprintf("/api/v1/projects/%s/scans/report?image=%s&reference=%s", projectID, url.QueryEscape(image), url.QueryEscape(digest))
 	}
 
 	actor := &webhook.Actor{
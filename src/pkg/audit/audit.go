@@ -16,6 +16,27 @@ import (
 	"github.com/cyp-registry/registry/src/pkg/models"
 )
 
+// actorKey 上下文中审计操作者的键
+type actorKey struct{}
+
+// Actor 审计日志中的操作者
+type Actor struct {
+	UserID    *uuid.UUID
+	IP        string
+	UserAgent string
+}
+
+// WithActor 将操作者写入上下文，供拿不到 HTTP 请求的下层模块（例如 registry）记录审计日志
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom 读取上下文中的操作者，没有时返回零值（匿名或后台任务）
+func ActorFrom(ctx context.Context) Actor {
+	actor, _ := ctx.Value(actorKey{}).(Actor)
+	return actor
+}
+
 // Record 记录一条成功的审计日志
 // action: 操作类型，例如 "list_tags" / "get_manifest"
 // resource: 资源类型，例如 "image"
@@ -23,6 +23,10 @@ type Config struct {
 	Logging  LoggingConfig  `yaml:"logging"`
 	Scanner  ScannerConfig  `yaml:"scanner"`
 	Webhook  WebhookConfig  `yaml:"webhook"`
+
+	ImageImport ImageImportConfig `yaml:"image_import"`
+	Secrets     SecretsConfig     `yaml:"secrets"`
+	SBOM        SBOMConfig        `yaml:"sbom"`
 }
 
 // AppConfig 应用基础配置
@@ -115,6 +119,10 @@ type MinIOStorage struct {
 	SecretKey string `yaml:"secret_key"`
 	Bucket    string `yaml:"bucket"`
 	UseSSL    bool   `yaml:"use_ssl"`
+
+	// Redirect 为 true 时 Blob 下载以 307 重定向到预签名地址，流量不经过本服务
+	// （要求客户端能够直接访问 Endpoint）
+	Redirect bool `yaml:"redirect"`
 }
 
 // RegistryConfig 镜像仓库配置
@@ -122,6 +130,24 @@ type RegistryConfig struct {
 	MaxLayerSize   int64 `yaml:"max_layer_size"`
 	AllowAnonymous bool  `yaml:"allow_anonymous"`
 	TokenExpire    int   `yaml:"token_expire"` // 秒
+
+	// UploadSessionTTL 上传会话在无新分片后的保留时间（秒），0 表示使用默认值（24小时）
+	UploadSessionTTL int `yaml:"upload_session_ttl"`
+	// UploadJanitorInterval 过期上传会话清理间隔（秒），0 表示使用默认值（1小时）
+	UploadJanitorInterval int `yaml:"upload_janitor_interval"`
+
+	// GCInterval 定时垃圾回收间隔（秒），0 表示不启用定时回收（仍可通过管理员接口手动触发）
+	GCInterval int `yaml:"gc_interval"`
+	// GCGracePeriod 垃圾回收宽限期（秒），晚于该时间写入的对象不会被回收，0 表示使用默认值（24小时）
+	GCGracePeriod int `yaml:"gc_grace_period"`
+	// GCDeleteUntagged 定时垃圾回收是否同时删除不被任何 tag 引用的 Manifest
+	GCDeleteUntagged bool `yaml:"gc_delete_untagged"`
+
+	// BlobRedirectTTL Blob 下载预签名地址的有效期（秒），0 表示使用默认值（5分钟）
+	BlobRedirectTTL int `yaml:"blob_redirect_ttl"`
+
+	// MirrorProject 作为 Docker 守护进程 registry-mirrors 使用的代理项目名称，为空表示不启用
+	MirrorProject string `yaml:"mirror_project"`
 }
 
 // SecurityConfig 安全配置
@@ -177,10 +203,54 @@ type TraceLog struct {
 
 // ScannerConfig 扫描器配置
 type ScannerConfig struct {
-	Enabled         bool     `yaml:"enabled"`
+	Enabled bool `yaml:"enabled"`
+	// Severity 报告中保留明细的严重级别（各级别的数量始终完整统计），为空表示全部
 	Severity        []string `yaml:"severity"`
 	BlockOnCritical bool     `yaml:"block_on_critical"`
-	Async           bool     `yaml:"async"`
+	// Async 为 false 时手动触发扫描的接口等待扫描完成后返回报告
+	Async bool `yaml:"async"`
+
+	// Type 扫描器类型：trivy（默认，调用本地扫描器命令）或 fake（不实际扫描，用于测试）
+	Type string `yaml:"type"`
+	// Command 扫描器命令，默认 trivy
+	Command string `yaml:"command"`
+	// Args 命令参数，{archive} 替换为待扫描镜像的 OCI 归档路径；为空时使用 Trivy 的默认参数
+	Args []string `yaml:"args"`
+	// Workers 同时执行的扫描任务数，0 表示使用默认值（1）
+	Workers int `yaml:"workers"`
+	// Timeout 单个镜像的扫描超时时间（秒），0 表示使用默认值（10分钟）
+	Timeout int `yaml:"timeout"`
+}
+
+// ImageImportConfig 镜像导入配置
+type ImageImportConfig struct {
+	// Workers 全局同时执行的导入任务数，0 表示使用默认值（4）
+	Workers int `yaml:"workers"`
+	// PerProjectLimit 单个项目同时执行的导入任务数，0 表示使用默认值（2）
+	PerProjectLimit int `yaml:"per_project_limit"`
+	// ArchiveDir 上传的镜像归档暂存目录，为空表示使用系统临时目录
+	ArchiveDir string `yaml:"archive_dir"`
+	// ArchiveTTL 失败或取消的任务保留暂存归档的时间（秒），0 表示使用默认值（24小时）
+	ArchiveTTL int `yaml:"archive_ttl"`
+}
+
+// SBOMConfig 软件物料清单（SBOM）配置
+type SBOMConfig struct {
+	// Enabled 推送 tag 后自动生成 SBOM（关闭时仍可手动生成与上传）
+	Enabled bool `yaml:"enabled"`
+	// Format 自动生成的格式：spdx（默认）或 cyclonedx
+	Format string `yaml:"format"`
+	// Workers 同时生成 SBOM 的镜像数，0 表示使用默认值（1）
+	Workers int `yaml:"workers"`
+}
+
+// SecretsConfig 数据库凭据加密配置（两者只能配置其一）
+// 主密钥格式为 <key-id>:<base64 编码的 32 字节密钥>，多个密钥以换行或逗号分隔，第一个用于加密。
+type SecretsConfig struct {
+	// MasterKey 主密钥列表（建议通过环境变量 SECRETS_MASTER_KEY 提供）
+	MasterKey string `yaml:"master_key"`
+	// KeyFile 主密钥文件路径，每行一个密钥
+	KeyFile string `yaml:"key_file"`
 }
 
 // WebhookConfig Webhook配置
@@ -430,6 +500,13 @@ func applyEnvOverrides(c *Config) {
 	} else if key := os.Getenv("MINIO_SECRET_KEY"); key != "" {
 		c.Storage.MinIO.SecretKey = key
 	}
+	if redirect := os.Getenv("APP_STORAGE_MINIO_REDIRECT"); redirect != "" {
+		c.Storage.MinIO.Redirect = (redirect == "true" || redirect == "1")
+	} else if redirect := os.Getenv("STORAGE_MINIO_REDIRECT"); redirect != "" {
+		c.Storage.MinIO.Redirect = (redirect == "true" || redirect == "1")
+	} else if redirect := os.Getenv("MINIO_REDIRECT"); redirect != "" {
+		c.Storage.MinIO.Redirect = (redirect == "true" || redirect == "1")
+	}
 	if bucket := os.Getenv("APP_STORAGE_MINIO_BUCKET"); bucket != "" {
 		c.Storage.MinIO.Bucket = bucket
 	} else if bucket := os.Getenv("STORAGE_MINIO_BUCKET"); bucket != "" {
@@ -489,6 +566,75 @@ func applyEnvOverrides(c *Config) {
 		c.Logging.Format = format
 	}
 
+	// 镜像仓库配置
+	if ttl := os.Getenv("REGISTRY_UPLOAD_SESSION_TTL"); ttl != "" {
+		var n int
+		if _, err := fmt.Sscanf(ttl, "%d", &n); err == nil && n > 0 {
+			c.Registry.UploadSessionTTL = n
+		}
+	}
+	if interval := os.Getenv("REGISTRY_UPLOAD_JANITOR_INTERVAL"); interval != "" {
+		var n int
+		if _, err := fmt.Sscanf(interval, "%d", &n); err == nil && n > 0 {
+			c.Registry.UploadJanitorInterval = n
+		}
+	}
+	if interval := os.Getenv("REGISTRY_GC_INTERVAL"); interval != "" {
+		var n int
+		if _, err := fmt.Sscanf(interval, "%d", &n); err == nil && n >= 0 {
+			c.Registry.GCInterval = n
+		}
+	}
+	if grace := os.Getenv("REGISTRY_GC_GRACE_PERIOD"); grace != "" {
+		var n int
+		if _, err := fmt.Sscanf(grace, "%d", &n); err == nil && n > 0 {
+			c.Registry.GCGracePeriod = n
+		}
+	}
+	if untagged := os.Getenv("REGISTRY_GC_DELETE_UNTAGGED"); untagged != "" {
+		c.Registry.GCDeleteUntagged = (untagged == "true" || untagged == "1")
+	}
+	if ttl := os.Getenv("REGISTRY_BLOB_REDIRECT_TTL"); ttl != "" {
+		var n int
+		if _, err := fmt.Sscanf(ttl, "%d", &n); err == nil && n > 0 {
+			c.Registry.BlobRedirectTTL = n
+		}
+	}
+	if mirror := os.Getenv("REGISTRY_MIRROR_PROJECT"); mirror != "" {
+		c.Registry.MirrorProject = mirror
+	}
+
+	// 镜像导入配置
+	if workers := os.Getenv("IMAGE_IMPORT_WORKERS"); workers != "" {
+		var n int
+		if _, err := fmt.Sscanf(workers, "%d", &n); err == nil && n > 0 {
+			c.ImageImport.Workers = n
+		}
+	}
+	if limit := os.Getenv("IMAGE_IMPORT_PER_PROJECT_LIMIT"); limit != "" {
+		var n int
+		if _, err := fmt.Sscanf(limit, "%d", &n); err == nil && n > 0 {
+			c.ImageImport.PerProjectLimit = n
+		}
+	}
+	if dir := os.Getenv("IMAGE_IMPORT_ARCHIVE_DIR"); dir != "" {
+		c.ImageImport.ArchiveDir = dir
+	}
+	if ttl := os.Getenv("IMAGE_IMPORT_ARCHIVE_TTL"); ttl != "" {
+		var n int
+		if _, err := fmt.Sscanf(ttl, "%d", &n); err == nil && n > 0 {
+			c.ImageImport.ArchiveTTL = n
+		}
+	}
+
+	// 凭据加密配置
+	if key := os.Getenv("SECRETS_MASTER_KEY"); key != "" {
+		c.Secrets.MasterKey = key
+	}
+	if file := os.Getenv("SECRETS_KEY_FILE"); file != "" {
+		c.Secrets.KeyFile = file
+	}
+
 	// 扫描器配置
 	if enabled := os.Getenv("SCANNER_ENABLED"); enabled != "" {
 		c.Scanner.Enabled = (enabled == "true" || enabled == "1")
@@ -496,6 +642,36 @@ func applyEnvOverrides(c *Config) {
 	if block := os.Getenv("SCANNER_BLOCK_ON_CRITICAL"); block != "" {
 		c.Scanner.BlockOnCritical = (block == "true" || block == "1")
 	}
+	if stype := os.Getenv("SCANNER_TYPE"); stype != "" {
+		c.Scanner.Type = stype
+	}
+	if command := os.Getenv("SCANNER_COMMAND"); command != "" {
+		c.Scanner.Command = command
+	}
+	if workers := os.Getenv("SCANNER_WORKERS"); workers != "" {
+		var n int
+		if _, err := fmt.Sscanf(workers, "%d", &n); err == nil && n > 0 {
+			c.Scanner.Workers = n
+		}
+	}
+	if timeout := os.Getenv("SCANNER_TIMEOUT"); timeout != "" {
+		var n int
+		if _, err := fmt.Sscanf(timeout, "%d", &n); err == nil && n > 0 {
+			c.Scanner.Timeout = n
+		}
+	}
+	if enabled := os.Getenv("SBOM_ENABLED"); enabled != "" {
+		c.SBOM.Enabled = (enabled == "true" || enabled == "1")
+	}
+	if format := os.Getenv("SBOM_FORMAT"); format != "" {
+		c.SBOM.Format = format
+	}
+	if workers := os.Getenv("SBOM_WORKERS"); workers != "" {
+		var n int
+		if _, err := fmt.Sscanf(workers, "%d", &n); err == nil && n > 0 {
+			c.SBOM.Workers = n
+		}
+	}
 }
 
 // Load 加载配置（供测试使用）
@@ -513,6 +689,14 @@ func (c *Config) GetString(key string) string {
 		return c.Storage.Local.RootPath
 	case "storage.type":
 		return c.Storage.Type
+	case "storage.s3.endpoint":
+		return c.Storage.MinIO.Endpoint
+	case "storage.s3.access_key":
+		return c.Storage.MinIO.AccessKey
+	case "storage.s3.secret_key":
+		return c.Storage.MinIO.SecretKey
+	case "storage.s3.bucket":
+		return c.Storage.MinIO.Bucket
 	case "app.host":
 		return c.App.Host
 	case "app.env":
@@ -527,8 +711,10 @@ func (c *Config) GetString(key string) string {
 // GetBool 获取布尔配置
 func (c *Config) GetBool(key string) bool {
 	switch key {
-	case "storage.minio.use_ssl":
+	case "storage.minio.use_ssl", "storage.s3.secure":
 		return c.Storage.MinIO.UseSSL
+	case "storage.s3.redirect":
+		return c.Storage.MinIO.Redirect
 	case "app.debug":
 		return c.App.Debug
 	case "registry.allow_anonymous":
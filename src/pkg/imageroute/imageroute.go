@@ -0,0 +1,73 @@
+// Package imageroute 镜像子资源路由
+// 镜像名可以包含多级路径（例如 team/app），无法直接用 gin 的路由参数表示，
+// 因此 /api/v1/projects/:id/images/<name...>/<action> 统一注册为一个路由，按最后一段 action 分发给各模块的处理函数。
+package imageroute
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/cyp-registry/registry/src/pkg/response"
+)
+
+// ContextKeyImage 上下文中的镜像名（不含项目名）
+const ContextKeyImage = "imageroute.image"
+
+// Router 镜像子资源路由表
+type Router struct {
+	handlers map[string]map[string]gin.HandlerFunc // action -> method -> handler
+}
+
+// New 创建路由表
+func New() *Router {
+	return &Router{handlers: make(map[string]map[string]gin.HandlerFunc)}
+}
+
+// Handle 注册 <name...>/<action> 的处理函数
+func (r *Router) Handle(method, action string, handler gin.HandlerFunc) {
+	if r.handlers[action] == nil {
+		r.handlers[action] = make(map[string]gin.HandlerFunc)
+	}
+	r.handlers[action][method] = handler
+}
+
+// Register 在 group 下注册 <prefix>/:name/*path（prefix 例如 /:id/images）
+// 与 prefix 下的静态路由（例如 /:id/images/import）可以共存，静态路由优先。
+func (r *Router) Register(group *gin.RouterGroup, prefix string) {
+	path := prefix + "/:name/*path"
+	for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodDelete} {
+		group.Handle(method, path, r.dispatch)
+	}
+}
+
+// dispatch 解析镜像名与 action 并调用对应的处理函数
+func (r *Router) dispatch(ctx *gin.Context) {
+	full := strings.Trim(ctx.Param("name")+ctx.Param("path"), "/")
+	i := strings.LastIndex(full, "/")
+	if i <= 0 {
+		response.NotFound(ctx, "接口不存在")
+		return
+	}
+	image, action := full[:i], full[i+1:]
+
+	methods, ok := r.handlers[action]
+	if !ok {
+		response.NotFound(ctx, "接口不存在")
+		return
+	}
+	handler, ok := methods[ctx.Request.Method]
+	if !ok {
+		response.ParamError(ctx, "请求方法不支持")
+		return
+	}
+
+	ctx.Set(ContextKeyImage, image)
+	handler(ctx)
+}
+
+// Image 返回当前请求的镜像名（不含项目名）
+func Image(ctx *gin.Context) string {
+	return ctx.GetString(ContextKeyImage)
+}
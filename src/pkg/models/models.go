@@ -204,8 +204,8 @@ func (AuditLog) TableName() string {
 // 表名: registry_images
 type Image struct {
 	BaseModel
-	ProjectID   uuid.UUID `gorm:"type:uuid;not null;index;comment:项目ID" json:"project_id"`
-	Name        string    `gorm:"type:varchar(256);not null;comment:镜像名称" json:"name"`
+	ProjectID   uuid.UUID `gorm:"type:uuid;not null;index:idx_images_project;uniqueIndex:registry_images_project_id_name_key;comment:项目ID" json:"project_id"`
+	Name        string    `gorm:"type:varchar(256);not null;uniqueIndex:registry_images_project_id_name_key;comment:镜像名称（不含项目名）" json:"name"`
 	Description string    `gorm:"type:text;comment:镜像描述" json:"description"`
 	TagsCount   int       `gorm:"default:0;comment:标签数量" json:"tags_count"`
 }
@@ -219,9 +219,9 @@ func (Image) TableName() string {
 // 表名: registry_image_tags
 type ImageTag struct {
 	BaseModel
-	ImageID    uuid.UUID `gorm:"type:uuid;not null;index;comment:镜像ID" json:"image_id"`
-	Name       string    `gorm:"type:varchar(128);not null;comment:标签名称" json:"name"`
-	Digest     string    `gorm:"type:varchar(256);uniqueIndex;comment:摘要" json:"digest"`
+	ImageID    uuid.UUID `gorm:"type:uuid;not null;index:idx_image_tags_image;uniqueIndex:registry_image_tags_image_id_name_key;comment:镜像ID" json:"image_id"`
+	Name       string    `gorm:"type:varchar(128);not null;uniqueIndex:registry_image_tags_image_id_name_key;comment:标签名称" json:"name"`
+	Digest     string    `gorm:"type:varchar(256);index:idx_image_tags_digest;comment:摘要" json:"digest"`
 	Manifest   string    `gorm:"type:text;comment:Manifest内容" json:"manifest"`
 	Size       int64     `gorm:"default:0;comment:大小(字节)" json:"size"`
 	LastPullAt time.Time `gorm:"comment:最后拉取时间" json:"last_pull_at"`
@@ -233,6 +233,21 @@ func (ImageTag) TableName() string {
 	return "registry_image_tags"
 }
 
+// BlobLink 仓库级 Blob 链接索引（存储中 <repository>/_links 的数据库副本）
+// 表名: registry_blob_links
+type BlobLink struct {
+	BaseModel
+	Project    string `gorm:"type:varchar(128);not null;index:idx_blob_links_project_digest;comment:项目名称" json:"project"`
+	Repository string `gorm:"type:varchar(512);not null;uniqueIndex:registry_blob_links_repository_digest_key;comment:仓库名称" json:"repository"`
+	Digest     string `gorm:"type:varchar(256);not null;uniqueIndex:registry_blob_links_repository_digest_key;index:idx_blob_links_project_digest;comment:摘要" json:"digest"`
+	Size       int64  `gorm:"default:0;comment:大小(字节)" json:"size"`
+}
+
+// TableName 指定表名
+func (BlobLink) TableName() string {
+	return "registry_blob_links"
+}
+
 // SecurityEvent 安全事件模型
 // 表名: registry_security_events
 type SecurityEvent struct {
@@ -0,0 +1,505 @@
+// Package registryclient 上游镜像仓库客户端
+// 实现 Docker Registry HTTP API V2 / OCI Distribution 的拉取、推送与列表接口，
+// 支持匿名访问、Basic 认证与 Bearer Token 认证（Docker Hub、ghcr.io、quay.io 等）。
+package registryclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotFound 上游不存在该 Manifest/Blob
+var ErrNotFound = errors.New("registryclient: not found")
+
+// ErrUnauthorized 上游拒绝访问（凭据错误或无权限）
+var ErrUnauthorized = errors.New("registryclient: unauthorized")
+
+// ErrDigestMismatch 上游返回内容与请求的 digest 不一致
+var ErrDigestMismatch = errors.New("registryclient: digest mismatch")
+
+// ErrUnavailable 无法连接上游（网络错误、超时）
+var ErrUnavailable = errors.New("registryclient: upstream unavailable")
+
+// DefaultTimeout 单次请求（不含 Blob 数据传输）的默认超时时间
+const DefaultTimeout = 30 * time.Second
+
+// maxManifestSize Manifest 最大读取长度（与 Distribution 默认限制一致）
+const maxManifestSize = 4 * 1024 * 1024
+
+// ManifestAccept 拉取 Manifest 时默认接受的媒体类型
+var ManifestAccept = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// Docker Hub 的 API 地址（docker.io / index.docker.io 均映射到此地址）
+const dockerHubHost = "registry-1.docker.io"
+
+// Options 客户端选项
+type Options struct {
+	Username string
+	Password string
+	// Insecure 跳过 TLS 证书校验（仅用于自签名证书的内网仓库）
+	Insecure bool
+	// Timeout 建立连接与等待响应头的超时，0 表示使用 DefaultTimeout
+	Timeout   time.Duration
+	UserAgent string
+}
+
+// Client 上游仓库客户端（并发安全）
+type Client struct {
+	base *url.URL
+	opts Options
+	http *http.Client
+
+	mu     sync.Mutex
+	tokens map[string]bearerToken // scope -> token
+	basic  bool                   // 上游要求 Basic 认证
+}
+
+// bearerToken 缓存的 Bearer Token
+type bearerToken struct {
+	value   string
+	expires time.Time
+}
+
+// Descriptor 上游返回的内容描述
+type Descriptor struct {
+	MediaType string
+	Digest    string
+	Size      int64
+}
+
+// StatusError 上游返回的非预期 HTTP 状态
+type StatusError struct {
+	Method string
+	URL    string
+	Code   int
+	Body   string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("registryclient: %s %s: unexpected status %d: %s", e.Method, e.URL, e.Code, e.Body)
+}
+
+// NormalizeEndpoint 规范化上游地址
+// 未指定协议时使用 https；docker.io / index.docker.io 映射到 registry-1.docker.io。
+func NormalizeEndpoint(endpoint string) (*url.URL, error) {
+	endpoint = strings.TrimSpace(endpoint)
+	if endpoint == "" {
+		return nil, errors.New("registryclient: endpoint is required")
+	}
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("registryclient: invalid endpoint: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("registryclient: unsupported scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, errors.New("registryclient: endpoint host is required")
+	}
+	switch u.Host {
+	case "docker.io", "index.docker.io":
+		u.Host = dockerHubHost
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	u.RawQuery, u.Fragment = "", ""
+	return u, nil
+}
+
+// New 创建上游仓库客户端
+func New(endpoint string, opts Options) (*Client, error) {
+	base, err := NormalizeEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.UserAgent == "" {
+		opts.UserAgent = "cyp-registry"
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = opts.Timeout
+	if opts.Insecure {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true} //nolint:gosec // 由项目配置显式开启
+	}
+
+	return &Client{
+		base:   base,
+		opts:   opts,
+		http:   &http.Client{Transport: transport},
+		tokens: make(map[string]bearerToken),
+	}, nil
+}
+
+// Endpoint 返回规范化后的上游地址
+func (c *Client) Endpoint() string {
+	return c.base.String()
+}
+
+// IsDockerHub 上游是否为 Docker Hub
+func (c *Client) IsDockerHub() bool {
+	return c.base.Host == dockerHubHost
+}
+
+// RepositoryName 返回上游实际使用的仓库名
+// Docker Hub 的官方镜像（如 nginx）需要补全 library/ 前缀。
+func (c *Client) RepositoryName(repo string) string {
+	if c.IsDockerHub() && !strings.Contains(repo, "/") {
+		return "library/" + repo
+	}
+	return repo
+}
+
+// Ping 检查上游可达且凭据有效（GET /v2/）
+func (c *Client) Ping(ctx context.Context) error {
+	resp, err := c.do(ctx, http.MethodGet, "/v2/", "", nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// HeadManifest 获取 Manifest 的描述信息（不下载内容）
+func (c *Client) HeadManifest(ctx context.Context, repo, reference string) (*Descriptor, error) {
+	resp, err := c.do(ctx, http.MethodHead, "/v2/"+repo+"/manifests/"+reference, pullScope(repo), ManifestAccept)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	desc := &Descriptor{
+		MediaType: mediaTypeOf(resp.Header),
+		Digest:    resp.Header.Get("Docker-Content-Digest"),
+		Size:      resp.ContentLength,
+	}
+	if desc.Digest == "" && isDigest(reference) {
+		desc.Digest = reference
+	}
+	return desc, nil
+}
+
+// GetManifest 下载 Manifest，返回原始内容与描述信息
+// reference 为 digest 时校验内容摘要。
+func (c *Client) GetManifest(ctx context.Context, repo, reference string) ([]byte, *Descriptor, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/v2/"+repo+"/manifests/"+reference, pullScope(repo), ManifestAccept)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxManifestSize+1))
+	if err != nil {
+		return nil, nil, fmt.Errorf("registryclient: failed to read manifest: %w", err)
+	}
+	if len(data) > maxManifestSize {
+		return nil, nil, fmt.Errorf("registryclient: manifest exceeds %d bytes", maxManifestSize)
+	}
+
+	sum := sha256.Sum256(data)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	if isDigest(reference) && reference != digest {
+		return nil, nil, fmt.Errorf("%w: expected %s, got %s", ErrDigestMismatch, reference, digest)
+	}
+
+	mediaType := mediaTypeOf(resp.Header)
+	if mediaType == "" || mediaType == "application/json" || mediaType == "text/plain" {
+		var doc struct {
+			MediaType string `json:"mediaType"`
+		}
+		if json.Unmarshal(data, &doc) == nil && doc.MediaType != "" {
+			mediaType = doc.MediaType
+		}
+	}
+
+	return data, &Descriptor{MediaType: mediaType, Digest: digest, Size: int64(len(data))}, nil
+}
+
+// HeadBlob 获取 Blob 大小
+func (c *Client) HeadBlob(ctx context.Context, repo, digest string) (int64, error) {
+	resp, err := c.do(ctx, http.MethodHead, "/v2/"+repo+"/blobs/"+digest, pullScope(repo), nil)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.ContentLength, nil
+}
+
+// GetBlob 下载 Blob，调用方负责关闭返回的数据流
+// 返回的大小为 Content-Length（未知时为 -1）；摘要由调用方在读取时校验。
+func (c *Client) GetBlob(ctx context.Context, repo, digest string) (io.ReadCloser, int64, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/v2/"+repo+"/blobs/"+digest, pullScope(repo), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+// request 单次 API 请求
+type request struct {
+	method string
+	path   string // 以 / 开头的相对路径，或上游返回的绝对地址（例如上传会话的 Location）
+	scope  string
+	accept []string
+	header http.Header
+	body   io.Reader
+	size   int64
+}
+
+// do 发送无请求体的请求
+func (c *Client) do(ctx context.Context, method, path, scope string, accept []string) (*http.Response, error) {
+	return c.send(ctx, &request{method: method, path: path, scope: scope, accept: accept})
+}
+
+// send 发送请求并处理认证挑战：收到 401 时按 WWW-Authenticate 获取凭据后重试一次
+// 带请求体的请求只有在请求体可 Seek 时才会重试。
+func (c *Client) send(ctx context.Context, r *request) (*http.Response, error) {
+	target := r.path
+	if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+		target = c.base.String() + target
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, r.method, target, r.body)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range r.header {
+			req.Header[k] = v
+		}
+		if r.body != nil {
+			req.ContentLength = r.size
+		}
+		req.Header.Set("User-Agent", c.opts.UserAgent)
+		if len(r.accept) > 0 {
+			req.Header.Set("Accept", strings.Join(r.accept, ", "))
+		}
+		c.authorize(req, r.scope)
+
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s %s: %w", ErrUnavailable, r.method, req.URL.Redacted(), err)
+		}
+
+		switch {
+		case resp.StatusCode >= 200 && resp.StatusCode < 300:
+			return resp, nil
+		case resp.StatusCode == http.StatusUnauthorized && attempt == 0 && rewind(r.body):
+			challenge := resp.Header.Get("WWW-Authenticate")
+			drain(resp)
+			if err := c.handleChallenge(ctx, challenge, r.scope); err != nil {
+				return nil, err
+			}
+			continue
+		case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+			drain(resp)
+			return nil, fmt.Errorf("%w: %s %s", ErrUnauthorized, r.method, req.URL.Redacted())
+		case resp.StatusCode == http.StatusNotFound:
+			drain(resp)
+			return nil, ErrNotFound
+		default:
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+			resp.Body.Close()
+			return nil, &StatusError{Method: r.method, URL: req.URL.Redacted(), Code: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+		}
+	}
+}
+
+// rewind 将请求体重置到开头以便重试；无请求体时直接返回 true
+func rewind(body io.Reader) bool {
+	if body == nil {
+		return true
+	}
+	seeker, ok := body.(io.Seeker)
+	if !ok {
+		return false
+	}
+	_, err := seeker.Seek(0, io.SeekStart)
+	return err == nil
+}
+
+// authorize 为请求附加已缓存的凭据
+func (c *Client) authorize(req *http.Request, scope string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if tok, ok := c.tokens[scope]; ok && time.Now().Before(tok.expires) {
+		req.Header.Set("Authorization", "Bearer "+tok.value)
+		return
+	}
+	if c.basic && c.opts.Username != "" {
+		req.SetBasicAuth(c.opts.Username, c.opts.Password)
+	}
+}
+
+// handleChallenge 处理 401 认证挑战
+func (c *Client) handleChallenge(ctx context.Context, header, scope string) error {
+	scheme, params := parseChallenge(header)
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if c.opts.Username == "" {
+			return fmt.Errorf("%w: credentials required", ErrUnauthorized)
+		}
+		c.mu.Lock()
+		c.basic = true
+		c.mu.Unlock()
+		return nil
+	case "bearer":
+		tok, err := c.fetchToken(ctx, params, scope)
+		if err != nil {
+			return err
+		}
+		c.mu.Lock()
+		c.tokens[scope] = tok
+		c.mu.Unlock()
+		return nil
+	default:
+		return fmt.Errorf("%w: unsupported auth challenge %q", ErrUnauthorized, header)
+	}
+}
+
+// fetchToken 向认证服务申请 Bearer Token
+func (c *Client) fetchToken(ctx context.Context, params map[string]string, scope string) (bearerToken, error) {
+	realm := params["realm"]
+	if realm == "" {
+		return bearerToken{}, fmt.Errorf("%w: bearer challenge without realm", ErrUnauthorized)
+	}
+	u, err := url.Parse(realm)
+	if err != nil {
+		return bearerToken{}, fmt.Errorf("registryclient: invalid token realm: %w", err)
+	}
+
+	q := u.Query()
+	if service := params["service"]; service != "" {
+		q.Set("service", service)
+	}
+	if scope == "" {
+		scope = params["scope"]
+	}
+	if scope != "" {
+		q.Set("scope", scope)
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return bearerToken{}, err
+	}
+	req.Header.Set("User-Agent", c.opts.UserAgent)
+	if c.opts.Username != "" {
+		req.SetBasicAuth(c.opts.Username, c.opts.Password)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return bearerToken{}, fmt.Errorf("%w: token request failed: %w", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return bearerToken{}, fmt.Errorf("%w: token endpoint returned %d", ErrUnauthorized, resp.StatusCode)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return bearerToken{}, fmt.Errorf("registryclient: invalid token response: %w", err)
+	}
+	tok := body.Token
+	if tok == "" {
+		tok = body.AccessToken
+	}
+	if tok == "" {
+		return bearerToken{}, fmt.Errorf("%w: empty token", ErrUnauthorized)
+	}
+
+	// 规范规定未返回 expires_in 时按 60 秒处理；提前 10 秒过期以避免边界失败
+	expiresIn := body.ExpiresIn
+	if expiresIn < 60 {
+		expiresIn = 60
+	}
+	return bearerToken{value: tok, expires: time.Now().Add(time.Duration(expiresIn-10) * time.Second)}, nil
+}
+
+// parseChallenge 解析 WWW-Authenticate 头，例如：
+//
+//	Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/nginx:pull"
+func parseChallenge(header string) (string, map[string]string) {
+	header = strings.TrimSpace(header)
+	scheme, rest, _ := strings.Cut(header, " ")
+	params := make(map[string]string)
+
+	for rest = strings.TrimSpace(rest); rest != ""; rest = strings.TrimSpace(rest) {
+		key, value, ok := strings.Cut(rest, "=")
+		if !ok {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		if strings.HasPrefix(value, `"`) {
+			end := strings.Index(value[1:], `"`)
+			if end < 0 {
+				params[key] = value[1:]
+				break
+			}
+			params[key] = value[1 : end+1]
+			rest = strings.TrimPrefix(strings.TrimSpace(value[end+2:]), ",")
+		} else {
+			v, next, _ := strings.Cut(value, ",")
+			params[key] = strings.TrimSpace(v)
+			rest = next
+		}
+	}
+	return scheme, params
+}
+
+// pullScope 拉取仓库所需的 token scope
+func pullScope(repo string) string {
+	return "repository:" + repo + ":pull"
+}
+
+// pushScope 推送仓库所需的 token scope
+func pushScope(repo string) string {
+	return "repository:" + repo + ":pull,push"
+}
+
+// isDigest 判断引用是否为 digest
+func isDigest(reference string) bool {
+	return strings.Contains(reference, ":")
+}
+
+// mediaTypeOf 读取响应的媒体类型（去掉 charset 等参数）
+func mediaTypeOf(h http.Header) string {
+	mt, _, _ := strings.Cut(h.Get("Content-Type"), ";")
+	return strings.TrimSpace(mt)
+}
+
+// drain 读尽并关闭响应体，以便复用连接
+func drain(resp *http.Response) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+}
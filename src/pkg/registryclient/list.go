@@ -0,0 +1,90 @@
+package registryclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// listPageSize 列表接口每页请求的条目数
+const listPageSize = 1000
+
+// ListTags 列出仓库的全部 tag（按 Link 头自动翻页）
+func (c *Client) ListTags(ctx context.Context, repo string) ([]string, error) {
+	var tags []string
+	next := fmt.Sprintf("/v2/%s/tags/list?n=%d", repo, listPageSize)
+	for next != "" {
+		var page struct {
+			Tags []string `json:"tags"`
+		}
+		link, err := c.getJSON(ctx, next, pullScope(repo), &page)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, page.Tags...)
+		next = link
+	}
+	return tags, nil
+}
+
+// Catalog 列出远端仓库中的全部仓库名（按 Link 头自动翻页）
+// Docker Hub 等公共仓库通常不开放该接口，此时返回 ErrUnauthorized 或 ErrNotFound。
+func (c *Client) Catalog(ctx context.Context) ([]string, error) {
+	var repos []string
+	next := fmt.Sprintf("/v2/_catalog?n=%d", listPageSize)
+	for next != "" {
+		var page struct {
+			Repositories []string `json:"repositories"`
+		}
+		link, err := c.getJSON(ctx, next, "registry:catalog:*", &page)
+		if err != nil {
+			return nil, err
+		}
+		repos = append(repos, page.Repositories...)
+		next = link
+	}
+	return repos, nil
+}
+
+// getJSON GET 并解码 JSON 响应，返回下一页地址（无下一页时为空）
+func (c *Client) getJSON(ctx context.Context, path, scope string, out interface{}) (string, error) {
+	resp, err := c.do(ctx, http.MethodGet, path, scope, []string{"application/json"})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var raw json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return "", fmt.Errorf("registryclient: invalid response from %s: %w", path, err)
+	}
+	// 兼容本项目自身的 REST 包装格式 {"code":20000,"data":{...}}（对端同为本系统时）
+	var envelope struct {
+		Code int             `json:"code"`
+		Data json.RawMessage `json:"data"`
+	}
+	if json.Unmarshal(raw, &envelope) == nil && envelope.Code != 0 && len(envelope.Data) > 0 {
+		raw = envelope.Data
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return "", fmt.Errorf("registryclient: invalid response from %s: %w", path, err)
+	}
+	return nextLink(resp.Header.Get("Link")), nil
+}
+
+// nextLink 解析分页 Link 头，例如：
+//
+//	</v2/_catalog?last=nginx&n=1000>; rel="next"
+func nextLink(header string) string {
+	for _, part := range strings.Split(header, ",") {
+		target, params, ok := strings.Cut(part, ";")
+		if !ok || !strings.Contains(params, `rel="next"`) {
+			continue
+		}
+		target = strings.TrimSpace(target)
+		return strings.TrimSuffix(strings.TrimPrefix(target, "<"), ">")
+	}
+	return ""
+}
@@ -0,0 +1,94 @@
+package registryclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// BlobExists 检查远端仓库是否已存在 Blob（推送前用于跳过已有层）
+func (c *Client) BlobExists(ctx context.Context, repo, digest string) (bool, error) {
+	resp, err := c.do(ctx, http.MethodHead, "/v2/"+repo+"/blobs/"+digest, pushScope(repo), nil)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	resp.Body.Close()
+	return true, nil
+}
+
+// PushBlob 以单次 PUT（monolithic）方式上传 Blob
+// 先 POST 创建上传会话，再将数据连同 digest 一次性 PUT 到返回的 Location。
+func (c *Client) PushBlob(ctx context.Context, repo, digest string, size int64, body io.Reader) error {
+	resp, err := c.send(ctx, &request{method: http.MethodPost, path: "/v2/" + repo + "/blobs/uploads/", scope: pushScope(repo)})
+	if err != nil {
+		return err
+	}
+	location := resp.Header.Get("Location")
+	drain(resp)
+	if location == "" {
+		return fmt.Errorf("registryclient: upload session for %s returned no Location", repo)
+	}
+
+	target, err := c.resolve(location)
+	if err != nil {
+		return err
+	}
+	q := target.Query()
+	q.Set("digest", digest)
+	target.RawQuery = q.Encode()
+
+	resp, err = c.send(ctx, &request{
+		method: http.MethodPut,
+		path:   target.String(),
+		scope:  pushScope(repo),
+		header: http.Header{"Content-Type": {"application/octet-stream"}},
+		body:   body,
+		size:   size,
+	})
+	if err != nil {
+		return err
+	}
+	drain(resp)
+	return nil
+}
+
+// PutManifest 推送 Manifest，返回远端确认的 digest
+func (c *Client) PutManifest(ctx context.Context, repo, reference, mediaType string, data []byte) (string, error) {
+	resp, err := c.send(ctx, &request{
+		method: http.MethodPut,
+		path:   "/v2/" + repo + "/manifests/" + reference,
+		scope:  pushScope(repo),
+		header: http.Header{"Content-Type": {mediaType}},
+		body:   bytes.NewReader(data),
+		size:   int64(len(data)),
+	})
+	if err != nil {
+		return "", err
+	}
+	digest := resp.Header.Get("Docker-Content-Digest")
+	drain(resp)
+
+	if digest == "" {
+		sum := sha256.Sum256(data)
+		digest = "sha256:" + hex.EncodeToString(sum[:])
+	}
+	return digest, nil
+}
+
+// resolve 将上游返回的 Location（可能是相对路径）解析为绝对地址
+func (c *Client) resolve(location string) (*url.URL, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("registryclient: invalid upload location %q: %w", location, err)
+	}
+	return c.base.ResolveReference(u), nil
+}
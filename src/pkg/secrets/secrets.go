@@ -0,0 +1,294 @@
+// Package secrets 提供数据库中凭据类字段的静态加密（信封加密）
+// 每个值使用随机生成的数据密钥（DEK）以 AES-256-GCM 加密，DEK 再由主密钥（KEK）加密后与密文一起保存：
+//
+//	enc:v1:<key-id>:<base64(加密后的 DEK)>:<base64(密文)>
+//
+// key-id 标识加密 DEK 的主密钥，轮换主密钥时只需用新密钥重新加密 DEK（见 Rekey），密文本身不变。
+// 不带 enc: 前缀的值视为升级前写入的明文，读取时原样返回。
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/cyp-registry/registry/src/pkg/config"
+)
+
+// prefix 加密值的前缀（含格式版本）
+const prefix = "enc:v1:"
+
+// keySize 主密钥与数据密钥长度（AES-256）
+const keySize = 32
+
+var (
+	// ErrNoKey 值由未配置的主密钥加密（或未配置任何主密钥）
+	ErrNoKey = errors.New("secrets: master key not configured")
+	// ErrInvalidKey 主密钥配置不合法
+	ErrInvalidKey = errors.New("secrets: invalid master key")
+	// ErrMalformed 加密值格式不合法或已被篡改
+	ErrMalformed = errors.New("secrets: malformed encrypted value")
+)
+
+// keyIDPattern 主密钥 ID 的格式
+var keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,32}$`)
+
+// Keyring 主密钥集合
+// 第一个密钥为当前密钥，用于加密新写入的值；其余密钥只用于解密轮换前写入的值。
+type Keyring struct {
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+// ParseKeyring 解析主密钥列表
+// 每项格式为 <key-id>:<base64 编码的 32 字节密钥>，以换行或逗号分隔，# 开头的行为注释。
+func ParseKeyring(s string) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string]cipher.AEAD)}
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, entry := range strings.Split(line, ",") {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			id, encoded, ok := strings.Cut(entry, ":")
+			if !ok || !keyIDPattern.MatchString(id) {
+				return nil, fmt.Errorf("%w: entry must be <key-id>:<base64 key>, key id may contain letters, digits, '.', '_' and '-'", ErrInvalidKey)
+			}
+			if _, exists := k.keys[id]; exists {
+				return nil, fmt.Errorf("%w: duplicate key id %q", ErrInvalidKey, id)
+			}
+			raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+			if err != nil || len(raw) != keySize {
+				return nil, fmt.Errorf("%w: key %q must be %d bytes encoded in base64", ErrInvalidKey, id, keySize)
+			}
+			aead, err := newAEAD(raw)
+			if err != nil {
+				return nil, err
+			}
+			k.keys[id] = aead
+			if k.primary == "" {
+				k.primary = id
+			}
+		}
+	}
+	if k.primary == "" {
+		return nil, fmt.Errorf("%w: no key", ErrInvalidKey)
+	}
+	return k, nil
+}
+
+// LoadKeyring 按配置加载主密钥：SECRETS_MASTER_KEY（master_key）或 SECRETS_KEY_FILE（key_file）
+// 均未配置时返回 nil（凭据以明文保存）。
+func LoadKeyring(cfg config.SecretsConfig) (*Keyring, error) {
+	switch {
+	case cfg.MasterKey != "" && cfg.KeyFile != "":
+		return nil, fmt.Errorf("%w: master_key and key_file are mutually exclusive", ErrInvalidKey)
+	case cfg.MasterKey != "":
+		return ParseKeyring(cfg.MasterKey)
+	case cfg.KeyFile != "":
+		data, err := os.ReadFile(cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read key file: %w", err)
+		}
+		return ParseKeyring(string(data))
+	default:
+		return nil, nil
+	}
+}
+
+// PrimaryKeyID 返回当前密钥的 ID
+func (k *Keyring) PrimaryKeyID() string {
+	return k.primary
+}
+
+// Encrypt 使用当前密钥加密；空字符串不加密
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	dek := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, dek); err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(dek)
+	if err != nil {
+		return "", err
+	}
+	data, err := seal(aead, []byte(plaintext), nil)
+	if err != nil {
+		return "", err
+	}
+	return k.wrap(dek, data)
+}
+
+// Decrypt 解密；不带加密前缀的值视为明文原样返回
+func (k *Keyring) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	_, dek, data, err := k.unwrap(value)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(dek)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(aead, data, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// Rekey 将值改为由当前密钥加密，返回新值与是否发生变化
+// 已由其他主密钥加密的值只重新加密 DEK；明文值完整加密。
+func (k *Keyring) Rekey(value string) (string, bool, error) {
+	if value == "" {
+		return value, false, nil
+	}
+	if !IsEncrypted(value) {
+		encrypted, err := k.Encrypt(value)
+		return encrypted, err == nil, err
+	}
+	keyID, dek, data, err := k.unwrap(value)
+	if err != nil {
+		return "", false, err
+	}
+	if keyID == k.primary {
+		return value, false, nil
+	}
+	rewrapped, err := k.wrap(dek, data)
+	return rewrapped, err == nil, err
+}
+
+// wrap 以当前密钥加密 DEK 并拼接为加密值（key-id 作为附加数据，防止替换）
+func (k *Keyring) wrap(dek, data []byte) (string, error) {
+	wrapped, err := seal(k.keys[k.primary], dek, []byte(k.primary))
+	if err != nil {
+		return "", err
+	}
+	return prefix + k.primary + ":" +
+		base64.RawStdEncoding.EncodeToString(wrapped) + ":" +
+		base64.RawStdEncoding.EncodeToString(data), nil
+}
+
+// unwrap 解析加密值并解密 DEK
+func (k *Keyring) unwrap(value string) (keyID string, dek, data []byte, err error) {
+	parts := strings.Split(strings.TrimPrefix(value, prefix), ":")
+	if len(parts) != 3 {
+		return "", nil, nil, ErrMalformed
+	}
+	keyID = parts[0]
+	kek, ok := k.keys[keyID]
+	if !ok {
+		return "", nil, nil, fmt.Errorf("%w: key %q", ErrNoKey, keyID)
+	}
+	wrapped, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", nil, nil, ErrMalformed
+	}
+	if data, err = base64.RawStdEncoding.DecodeString(parts[2]); err != nil {
+		return "", nil, nil, ErrMalformed
+	}
+	if dek, err = open(kek, wrapped, []byte(keyID)); err != nil {
+		return "", nil, nil, err
+	}
+	return keyID, dek, data, nil
+}
+
+// IsEncrypted 判断值是否为加密格式
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// KeyID 返回加密值使用的主密钥 ID，明文返回空字符串
+func KeyID(value string) string {
+	if !IsEncrypted(value) {
+		return ""
+	}
+	id, _, _ := strings.Cut(strings.TrimPrefix(value, prefix), ":")
+	return id
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal 加密，输出 nonce || 密文
+func seal(aead cipher.AEAD, plaintext, additional []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+// open 解密 seal 的输出
+func open(aead cipher.AEAD, data, additional []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, ErrMalformed
+	}
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], additional)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	return plaintext, nil
+}
+
+// 全局主密钥（服务启动时设置）
+var (
+	mu      sync.RWMutex
+	keyring *Keyring
+)
+
+// SetKeyring 设置全局主密钥，nil 表示不加密
+func SetKeyring(k *Keyring) {
+	mu.Lock()
+	defer mu.Unlock()
+	keyring = k
+}
+
+// Default 返回全局主密钥，未配置时为 nil
+func Default() *Keyring {
+	mu.RLock()
+	defer mu.RUnlock()
+	return keyring
+}
+
+// Encrypt 使用全局主密钥加密；未配置主密钥时原样返回明文
+func Encrypt(plaintext string) (string, error) {
+	k := Default()
+	if k == nil {
+		return plaintext, nil
+	}
+	return k.Encrypt(plaintext)
+}
+
+// Decrypt 使用全局主密钥解密；明文原样返回，加密值在未配置主密钥时返回 ErrNoKey
+func Decrypt(value string) (string, error) {
+	k := Default()
+	if k == nil {
+		if IsEncrypted(value) {
+			return "", fmt.Errorf("%w: key %q", ErrNoKey, KeyID(value))
+		}
+		return value, nil
+	}
+	return k.Decrypt(value)
+}
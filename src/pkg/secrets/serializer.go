@@ -0,0 +1,72 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm/schema"
+)
+
+// SerializerName GORM 序列化器名称，用法：`gorm:"type:text;serializer:secret"`
+const SerializerName = "secret"
+
+func init() {
+	schema.RegisterSerializer(SerializerName, Serializer{})
+}
+
+// Serializer 加密保存字段的 GORM 序列化器
+// string 字段直接加密；其他类型（如 map）先序列化为 JSON 再加密，空 map 保存为空字符串。
+// 注意：以 map 形式 Updates 的值不经过序列化器，凭据字段只能通过结构体写入。
+type Serializer struct{}
+
+// Scan 实现 schema.SerializerInterface
+func (Serializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var stored string
+	switch v := dbValue.(type) {
+	case nil:
+	case []byte:
+		stored = string(v)
+	case string:
+		stored = v
+	default:
+		return fmt.Errorf("secrets: unsupported column value %T for %s", dbValue, field.Name)
+	}
+
+	plaintext, err := Decrypt(stored)
+	if err != nil {
+		return fmt.Errorf("decrypt %s: %w", field.Name, err)
+	}
+
+	fieldValue := reflect.New(field.FieldType)
+	switch {
+	case field.FieldType.Kind() == reflect.String:
+		fieldValue.Elem().SetString(plaintext)
+	case plaintext == "":
+		if field.FieldType.Kind() == reflect.Map {
+			fieldValue.Elem().Set(reflect.MakeMap(field.FieldType))
+		}
+	default:
+		if err := json.Unmarshal([]byte(plaintext), fieldValue.Interface()); err != nil {
+			return fmt.Errorf("decode %s: %w", field.Name, err)
+		}
+	}
+	field.ReflectValueOf(ctx, dst).Set(fieldValue.Elem())
+	return nil
+}
+
+// Value 实现 schema.SerializerInterface
+func (Serializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	var plaintext string
+	if rv := reflect.ValueOf(fieldValue); rv.Kind() == reflect.String {
+		plaintext = rv.String()
+	} else if fieldValue != nil && !(rv.Kind() == reflect.Map && rv.Len() == 0) {
+		data, err := json.Marshal(fieldValue)
+		if err != nil {
+			return nil, err
+		}
+		plaintext = string(data)
+	}
+	return Encrypt(plaintext)
+}
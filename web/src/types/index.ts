@@ -202,7 +202,8 @@ export type WebhookEventType =
   | "delete"
   | "scan"
   | "scan_fail"
-  | "policy";
+  | "policy"
+  | "sync";
 
 export interface RetryPolicy {
   maxRetries: number;